@@ -0,0 +1,137 @@
+package gocb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+)
+
+// RequestInfo describes a request which is currently in-flight.
+type RequestInfo struct {
+	// Operation is the type of the request, e.g. cbas or mgmt.
+	Operation string
+	// Target is what the request is being performed against, e.g. the statement or the HTTP path.
+	Target      string
+	OperationID string
+	Elapsed     time.Duration
+
+	cancel context.CancelFunc
+}
+
+// Cancel cancels the request, if it is still in-flight then it will fail with a context cancellation error.
+func (ri RequestInfo) Cancel() {
+	if ri.cancel != nil {
+		ri.cancel()
+	}
+}
+
+type activeRequest struct {
+	operation   string
+	target      string
+	operationID string
+	startTime   time.Time
+	cancel      context.CancelFunc
+}
+
+type activeRequestRegistry struct {
+	nextID   uint64
+	lock     sync.Mutex
+	requests map[uint64]*activeRequest
+}
+
+func newActiveRequestRegistry() *activeRequestRegistry {
+	return &activeRequestRegistry{
+		requests: make(map[uint64]*activeRequest),
+	}
+}
+
+// register adds a request to the registry, the returned function must be called to remove it
+// once the request has completed. It is safe to call register on a nil registry.
+func (r *activeRequestRegistry) register(operation, target, operationID string, startTime time.Time,
+	cancel context.CancelFunc) func() {
+	if r == nil {
+		return func() {}
+	}
+
+	id := atomic.AddUint64(&r.nextID, 1)
+	req := &activeRequest{
+		operation:   operation,
+		target:      target,
+		operationID: operationID,
+		startTime:   startTime,
+		cancel:      cancel,
+	}
+
+	r.lock.Lock()
+	r.requests[id] = req
+	r.lock.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.lock.Lock()
+			delete(r.requests, id)
+			r.lock.Unlock()
+		})
+	}
+}
+
+func (r *activeRequestRegistry) snapshot() []RequestInfo {
+	if r == nil {
+		return nil
+	}
+
+	now := time.Now()
+	r.lock.Lock()
+	infos := make([]RequestInfo, 0, len(r.requests))
+	for _, req := range r.requests {
+		infos = append(infos, RequestInfo{
+			Operation:   req.operation,
+			Target:      req.target,
+			OperationID: req.operationID,
+			Elapsed:     now.Sub(req.startTime),
+			cancel:      req.cancel,
+		})
+	}
+	r.lock.Unlock()
+
+	return infos
+}
+
+// wrapHTTPProvider returns a provider which registers each request for the duration of its dispatch.
+func (r *activeRequestRegistry) wrapHTTPProvider(provider httpProvider, operation string) httpProvider {
+	if r == nil {
+		return provider
+	}
+
+	return &activeRequestHTTPProvider{
+		httpProvider: provider,
+		registry:     r,
+		operation:    operation,
+	}
+}
+
+type activeRequestHTTPProvider struct {
+	httpProvider
+	registry  *activeRequestRegistry
+	operation string
+}
+
+func (p *activeRequestHTTPProvider) DoHttpRequest(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+	parent := req.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	// The context isn't cancelled once we return as the caller may still be reading the response body, it will be
+	// released along with the parent context.
+	ctx, cancel := context.WithCancel(parent)
+	req.Context = ctx
+
+	deregister := p.registry.register(p.operation, req.Path, req.UniqueId, time.Now(), cancel)
+	defer deregister()
+
+	return p.httpProvider.DoHttpRequest(req)
+}
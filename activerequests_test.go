@@ -0,0 +1,152 @@
+package gocb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+)
+
+func TestActiveRequestsAnalytics(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("beer_sample_analytics_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	statement := "select `beer-sample`.* from `beer-sample` WHERE `type` = ? ORDER BY brewery_id, name"
+
+	var cluster *Cluster
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		active := cluster.ActiveRequests()
+		if len(active) != 1 {
+			t.Fatalf("Expected 1 active request during dispatch but was %d", len(active))
+		}
+
+		if active[0].Operation != "cbas" {
+			t.Fatalf("Expected Operation to be cbas but was %s", active[0].Operation)
+		}
+
+		if active[0].Target != statement {
+			t.Fatalf("Expected Target to be %s but was %s", statement, active[0].Target)
+		}
+
+		if active[0].OperationID != "testclientcontext" {
+			t.Fatalf("Expected OperationID to be testclientcontext but was %s", active[0].OperationID)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster = testGetClusterForHTTP(provider, 0, 60*time.Second, 0)
+
+	res, err := cluster.AnalyticsQuery(statement, &AnalyticsOptions{
+		ClientContextID:      "testclientcontext",
+		PositionalParameters: []interface{}{"brewery"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cluster.ActiveRequests()) != 1 {
+		t.Fatalf("Expected request to remain active until the result is closed")
+	}
+
+	err = res.Close()
+	if err != nil {
+		t.Fatalf("Expected Close to succeed but was %v", err)
+	}
+
+	if len(cluster.ActiveRequests()) != 0 {
+		t.Fatalf("Expected no active requests after close but was %v", cluster.ActiveRequests())
+	}
+}
+
+func TestActiveRequestsMgmt(t *testing.T) {
+	var cluster *Cluster
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		active := cluster.ActiveRequests()
+		if len(active) != 1 {
+			t.Fatalf("Expected 1 active request during dispatch but was %d", len(active))
+		}
+
+		if active[0].Operation != "mgmt" {
+			t.Fatalf("Expected Operation to be mgmt but was %s", active[0].Operation)
+		}
+
+		if active[0].Target != "/pools/default/buckets/test" {
+			t.Fatalf("Expected Target to be /pools/default/buckets/test but was %s", active[0].Target)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(""), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster = testGetClusterForHTTP(provider, 0, 0, 0)
+	cluster.sb.ManagementTimeout = 10 * time.Second
+
+	mgr, err := cluster.Buckets()
+	if err != nil {
+		t.Fatalf("Failed to get bucket manager: %v", err)
+	}
+
+	err = mgr.DropBucket("test", nil)
+	if err != nil {
+		t.Fatalf("Expected DropBucket to succeed but was %v", err)
+	}
+
+	if len(cluster.ActiveRequests()) != 0 {
+		t.Fatalf("Expected no active requests after completion but was %v", cluster.ActiveRequests())
+	}
+}
+
+func TestActiveRequestsCancel(t *testing.T) {
+	var cluster *Cluster
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		active := cluster.ActiveRequests()
+		if len(active) != 1 {
+			t.Fatalf("Expected 1 active request during dispatch but was %d", len(active))
+		}
+
+		active[0].Cancel()
+
+		<-req.Context.Done()
+		return nil, req.Context.Err()
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster = testGetClusterForHTTP(provider, 0, 0, 0)
+	cluster.sb.ManagementTimeout = 10 * time.Second
+
+	mgr, err := cluster.Buckets()
+	if err != nil {
+		t.Fatalf("Failed to get bucket manager: %v", err)
+	}
+
+	err = mgr.DropBucket("test", nil)
+	if !IsCancelledError(err) {
+		t.Fatalf("Expected error to be cancelled but was %v", err)
+	}
+
+	if len(cluster.ActiveRequests()) != 0 {
+		t.Fatalf("Expected no active requests after cancellation but was %v", cluster.ActiveRequests())
+	}
+}
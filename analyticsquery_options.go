@@ -31,11 +31,28 @@ type AnalyticsOptions struct {
 	NamedParameters      map[string]interface{}
 	ReadOnly             bool
 	ScanConsistency      AnalyticsScanConsistency
+	// ScanWait bounds how long the query will wait for the dataset to catch up when using
+	// AnalyticsScanConsistencyRequestPlus, it cannot be used with any other consistency.
+	ScanWait time.Duration
+
+	// QueryContext is the dataverse that unqualified dataset names in the statement are resolved against,
+	// e.g. default:`travel-sample`.`inventory` to run against a scope.
+	QueryContext string
 
 	// JSONSerializer is used to deserialize each row in the result. This should be a JSON deserializer as results are JSON.
 	// NOTE: if not set then query will always default to DefaultJSONSerializer.
-	Serializer    JSONSerializer
+	Serializer JSONSerializer
+	// RetryStrategy is consulted each time the analytics service responds with a temporary failure, the duration of the
+	// returned RetryAction is waited before the request is retried. If not set then the cluster level strategy is used.
 	RetryStrategy RetryStrategy
+	// MaxRetries caps the number of times the query is retried after a temporary failure, regardless of how much of
+	// the timeout remains. Once reached a RetriesExhaustedError holding the last failure is returned. Zero means that
+	// retries are only bounded by the timeout.
+	MaxRetries int
+
+	// Prepared, if set, prepares the statement the first time that it is run and caches the handle returned by the
+	// server, later runs of the same statement then execute the prepared statement by its handle.
+	Prepared bool
 }
 
 func (opts *AnalyticsOptions) toMap(statement string) (map[string]interface{}, error) {
@@ -62,6 +79,17 @@ func (opts *AnalyticsOptions) toMap(statement string) (map[string]interface{}, e
 		}
 	}
 
+	if opts.ScanWait > 0 {
+		if opts.ScanConsistency != AnalyticsScanConsistencyRequestPlus {
+			return nil, invalidArgumentsError{message: "scan wait can only be used with request plus consistency"}
+		}
+		execOpts["scan_wait"] = opts.ScanWait.String()
+	}
+
+	if opts.QueryContext != "" {
+		execOpts["query_context"] = opts.QueryContext
+	}
+
 	if opts.Priority {
 		execOpts["priority"] = -1
 	}
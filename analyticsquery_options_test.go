@@ -63,6 +63,22 @@ func TestAnalyticsQueryOptionsClientContextID(t *testing.T) {
 	testAssertOption(t, id, "client_context_id", optMap)
 }
 
+func TestAnalyticsQueryOptionsQueryContext(t *testing.T) {
+	queryContext := "default:`travel-sample`.`inventory`"
+	opts := &AnalyticsOptions{
+		QueryContext: queryContext,
+	}
+
+	statement := "select * from airline"
+	optMap, err := opts.toMap(statement)
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	testAssertOption(t, statement, "statement", optMap)
+	testAssertOption(t, queryContext, "query_context", optMap)
+}
+
 func TestAnalyticsQueryOptionsPriority(t *testing.T) {
 	opts := &AnalyticsOptions{
 		Priority: true,
@@ -78,6 +94,50 @@ func TestAnalyticsQueryOptionsPriority(t *testing.T) {
 	testAssertOption(t, -1, "priority", optMap)
 }
 
+func TestAnalyticsQueryOptionsScanWait(t *testing.T) {
+	opts := &AnalyticsOptions{
+		ScanConsistency: AnalyticsScanConsistencyRequestPlus,
+		ScanWait:        1500 * time.Millisecond,
+	}
+
+	statement := "select * from default"
+	optMap, err := opts.toMap(statement)
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	testAssertOption(t, statement, "statement", optMap)
+	testAssertOption(t, "request_plus", "scan_consistency", optMap)
+	testAssertOption(t, "1.5s", "scan_wait", optMap)
+
+	opts = &AnalyticsOptions{
+		ScanConsistency: AnalyticsScanConsistencyRequestPlus,
+	}
+
+	optMap, err = opts.toMap(statement)
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	if _, ok := optMap["scan_wait"]; ok {
+		t.Fatalf("Expected scan_wait to not be set but was %v", optMap["scan_wait"])
+	}
+}
+
+func TestAnalyticsQueryOptionsScanWaitWithoutRequestPlus(t *testing.T) {
+	testCases := []*AnalyticsOptions{
+		{ScanWait: time.Second},
+		{ScanWait: time.Second, ScanConsistency: AnalyticsScanConsistencyNotBounded},
+	}
+
+	for _, opts := range testCases {
+		_, err := opts.toMap("select * from default")
+		if !IsInvalidArgumentsError(err) {
+			t.Fatalf("Expected error to be invalid arguments but was %v", err)
+		}
+	}
+}
+
 func TestAnalyticsQueryOptionsPositionalParams(t *testing.T) {
 	params := []interface{}{1, "imafish"}
 	opts := &AnalyticsOptions{
@@ -34,6 +34,7 @@ func newBucket(sb *stateBlock, bucketName string, opts BucketOptions) *Bucket {
 
 			UseServerDurations: sb.UseServerDurations,
 			Tracer:             sb.Tracer,
+			ActiveRequests:     sb.ActiveRequests,
 		},
 	}
 }
@@ -91,7 +92,7 @@ func (b *Bucket) ViewIndexes() (*ViewIndexManager, error) {
 
 	return &ViewIndexManager{
 		bucketName:           b.Name(),
-		httpClient:           provider,
+		httpClient:           b.sb.ActiveRequests.wrapHTTPProvider(provider, "mgmt"),
 		globalTimeout:        b.sb.ManagementTimeout,
 		defaultRetryStrategy: b.sb.RetryStrategyWrapper,
 		tracer:               b.sb.Tracer,
@@ -107,7 +108,7 @@ func (b *Bucket) CollectionManager() (*CollectionManager, error) {
 	}
 
 	return &CollectionManager{
-		httpClient:           provider,
+		httpClient:           b.sb.ActiveRequests.wrapHTTPProvider(provider, "mgmt"),
 		bucketName:           b.Name(),
 		globalTimeout:        b.sb.ManagementTimeout,
 		defaultRetryStrategy: b.sb.RetryStrategyWrapper,
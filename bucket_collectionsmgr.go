@@ -87,7 +87,7 @@ func (cm *CollectionManager) CollectionExists(spec CollectionSpec, opts *Collect
 	}
 
 	retryStrategy := cm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -210,7 +210,7 @@ func (cm *CollectionManager) ScopeExists(scopeName string, opts *ScopeExistsOpti
 	}
 
 	retryStrategy := cm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -319,7 +319,7 @@ func (cm *CollectionManager) GetScope(scopeName string, opts *GetScopeOptions) (
 	}
 
 	retryStrategy := cm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -450,7 +450,7 @@ func (cm *CollectionManager) GetAllScopes(opts *GetAllScopesOptions) ([]ScopeSpe
 	}
 
 	retryStrategy := cm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -582,7 +582,7 @@ func (cm *CollectionManager) CreateCollection(spec CollectionSpec, opts *CreateC
 	}
 
 	retryStrategy := cm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -677,7 +677,7 @@ func (cm *CollectionManager) DropCollection(spec CollectionSpec, opts *DropColle
 	}
 
 	retryStrategy := cm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -761,7 +761,7 @@ func (cm *CollectionManager) CreateScope(scopeName string, opts *CreateScopeOpti
 	}
 
 	retryStrategy := cm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -844,7 +844,7 @@ func (cm *CollectionManager) DropScope(scopeName string, opts *DropScopeOptions)
 	}
 
 	retryStrategy := cm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
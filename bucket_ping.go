@@ -3,6 +3,7 @@ package gocb
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
@@ -98,7 +99,8 @@ func (jsonReport *jsonPingReport) toReport() *PingResult {
 	return report
 }
 
-func (b *Bucket) pingKv(provider kvProvider) (pingsOut *gocbcore.PingKvResult, errOut error) {
+func pingKv(ctx context.Context, provider kvProvider, timeout time.Duration) (pingsOut *gocbcore.PingKvResult,
+	errOut error) {
 	signal := make(chan bool, 1)
 
 	op, err := provider.PingKvEx(gocbcore.PingKvOptions{}, func(result *gocbcore.PingKvResult, err error) {
@@ -115,7 +117,7 @@ func (b *Bucket) pingKv(provider kvProvider) (pingsOut *gocbcore.PingKvResult, e
 		return nil, err
 	}
 
-	timeoutTmr := gocbcore.AcquireTimer(b.sb.KvTimeout)
+	timeoutTmr := gocbcore.AcquireTimer(timeout)
 	select {
 	case <-signal:
 		gocbcore.ReleaseTimer(timeoutTmr, false)
@@ -127,6 +129,13 @@ func (b *Bucket) pingKv(provider kvProvider) (pingsOut *gocbcore.PingKvResult, e
 			return
 		}
 		return nil, timeoutError{}
+	case <-ctx.Done():
+		gocbcore.ReleaseTimer(timeoutTmr, false)
+		if !op.Cancel() {
+			<-signal
+			return
+		}
+		return nil, timeoutError{}
 	}
 }
 
@@ -145,6 +154,11 @@ func (b *Bucket) Ping(opts *PingOptions) (*PingResult, error) {
 		opts = &PingOptions{}
 	}
 
+	return ping(context.Background(), b.sb.getCachedClient(), &b.sb, opts)
+}
+
+// ping pings the requested services using cli, no individual ping will outlive ctx.
+func ping(ctx context.Context, cli client, sb *stateBlock, opts *PingOptions) (*PingResult, error) {
 	numServices := 0
 	waitCh := make(chan error, 10)
 	report := &PingResult{
@@ -170,7 +184,6 @@ func (b *Bucket) Ping(opts *PingOptions) (*PingResult, error) {
 	httpReq := func(service ServiceType, url string) (time.Duration, string, error) {
 		startTime := time.Now()
 
-		cli := b.sb.getCachedClient()
 		provider, err := cli.getHTTPProvider()
 		if err != nil {
 			return 0, "", err
@@ -178,14 +191,14 @@ func (b *Bucket) Ping(opts *PingOptions) (*PingResult, error) {
 
 		timeout := 60 * time.Second
 		if service == QueryService {
-			timeout = b.sb.QueryTimeout
+			timeout = sb.QueryTimeout
 		} else if service == SearchService {
-			timeout = b.sb.SearchTimeout
+			timeout = sb.SearchTimeout
 		} else if service == AnalyticsService {
-			timeout = b.sb.AnalyticsTimeout
+			timeout = sb.AnalyticsTimeout
 		}
 
-		ctx, cancelFunc := context.WithTimeout(context.Background(), timeout)
+		ctx, cancelFunc := context.WithTimeout(ctx, timeout)
 		defer cancelFunc()
 
 		req := gocbcore.HttpRequest{
@@ -207,6 +220,12 @@ func (b *Bucket) Ping(opts *PingOptions) (*PingResult, error) {
 
 		pingLatency := time.Now().Sub(startTime)
 
+		if resp.StatusCode != 200 {
+			return 0, req.Endpoint, clientError{
+				message: fmt.Sprintf("ping responded with status code %d", resp.StatusCode),
+			}
+		}
+
 		return pingLatency, req.Endpoint, err
 	}
 
@@ -215,7 +234,6 @@ func (b *Bucket) Ping(opts *PingOptions) (*PingResult, error) {
 		case KeyValueService:
 			numServices++
 			go func() {
-				cli := b.sb.getCachedClient()
 				provider, err := cli.getKvProvider()
 				if err != nil {
 					logWarnf("Failed to get KV provider for report: %s", err)
@@ -223,7 +241,7 @@ func (b *Bucket) Ping(opts *PingOptions) (*PingResult, error) {
 					return
 				}
 
-				pings, err := b.pingKv(provider)
+				pings, err := pingKv(ctx, provider, sb.KvTimeout)
 				if err != nil {
 					logWarnf("Failed to ping KV for report: %s", err)
 					waitCh <- nil
@@ -260,6 +278,30 @@ func (b *Bucket) Ping(opts *PingOptions) (*PingResult, error) {
 			}()
 		case CapiService:
 			// View Service is not currently supported as a ping target
+		case MgmtService:
+			numServices++
+			go func() {
+				pingLatency, endpoint, err := httpReq(MgmtService, "/pools")
+
+				reportLock.Lock()
+				report.Services[MgmtService] = make([]PingServiceEntry, 0)
+				if err != nil {
+					report.Services[MgmtService] = append(report.Services[MgmtService], PingServiceEntry{
+						RemoteAddr: endpoint,
+						State:      "error",
+						Detail:     err.Error(),
+					})
+				} else {
+					report.Services[MgmtService] = append(report.Services[MgmtService], PingServiceEntry{
+						RemoteAddr: endpoint,
+						State:      "ok",
+						Latency:    pingLatency,
+					})
+				}
+				reportLock.Unlock()
+
+				waitCh <- nil
+			}()
 		case QueryService:
 			numServices++
 			go func() {
@@ -252,3 +252,45 @@ func TestPingTimeoutQueryOnly(t *testing.T) {
 		t.Fatalf("Expected service latency to be 0 but was %d", service.Latency)
 	}
 }
+
+func TestPingHTTPStatusNotOK(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		req.Endpoint = "http://localhost:8093"
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 503,
+			Body:       &testReadCloser{bytes.NewBufferString("Service Unavailable"), nil},
+		}, nil
+	}
+
+	cli := &mockClient{
+		bucketName:       "mock",
+		mockHTTPProvider: &mockHTTPProvider{doFn: doHTTP},
+	}
+
+	b := &Bucket{
+		sb: stateBlock{
+			clientStateBlock: clientStateBlock{
+				BucketName: "mock",
+			},
+
+			QueryTimeout: 10 * time.Second,
+			cachedClient: cli,
+		},
+	}
+
+	report, err := b.Ping(&PingOptions{ServiceTypes: []ServiceType{QueryService}})
+	if err != nil {
+		t.Fatalf("Expected ping to not return error but was %v", err)
+	}
+
+	// A service which is still coming up responds but isn't ready, so must not be reported as ok.
+	service := report.Services[QueryService][0]
+	if service.State != "error" {
+		t.Fatalf("Expected service State to be error, was %s", service.State)
+	}
+
+	if service.Detail != "ping responded with status code 503" {
+		t.Fatalf("Expected service Detail to report the status code but was %s", service.Detail)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"sort"
 	"strings"
 	"time"
 
@@ -50,6 +50,71 @@ func (v View) hasReduce() bool {
 type DesignDocument struct {
 	Name  string          `json:"-"`
 	Views map[string]View `json:"views,omitempty"`
+
+	// Rev is the revision of the design document, populated by GetDesignDocument and GetAllDesignDocuments. It is
+	// used by UpsertDesignDocument when IfMatchRev is set.
+	Rev string `json:"-"`
+
+	// Extra holds any fields of the design document which are not otherwise modelled,
+	// such as spatial views or options, so that they are preserved when the document
+	// is upserted back to the server.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// MarshalJSON marshals this design document to JSON, including any unmodelled fields.
+func (ddoc DesignDocument) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]json.RawMessage, len(ddoc.Extra)+1)
+	for k, v := range ddoc.Extra {
+		fields[k] = v
+	}
+
+	if len(ddoc.Views) > 0 {
+		views, err := json.Marshal(ddoc.Views)
+		if err != nil {
+			return nil, err
+		}
+		fields["views"] = views
+	} else {
+		delete(fields, "views")
+	}
+
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON unmarshals a design document from JSON, retaining any unmodelled fields.
+func (ddoc *DesignDocument) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	var views map[string]View
+	if viewsData, ok := fields["views"]; ok {
+		err = json.Unmarshal(viewsData, &views)
+		if err != nil {
+			return err
+		}
+		delete(fields, "views")
+	}
+
+	var rev string
+	if revData, ok := fields["_rev"]; ok {
+		err = json.Unmarshal(revData, &rev)
+		if err != nil {
+			return err
+		}
+		delete(fields, "_rev")
+	}
+
+	ddoc.Views = views
+	ddoc.Rev = rev
+	ddoc.Extra = nil
+	if len(fields) > 0 {
+		ddoc.Extra = fields
+	}
+
+	return nil
 }
 
 // GetDesignDocumentOptions is the set of options available to the ViewIndexManager GetDesignDocument operation.
@@ -62,7 +127,7 @@ type GetDesignDocumentOptions struct {
 func (vm *ViewIndexManager) ddocName(name string, isProd DesignDocumentNamespace) string {
 	if isProd {
 		if strings.HasPrefix(name, "dev_") {
-			name = strings.TrimLeft(name, "dev_")
+			name = strings.TrimPrefix(name, "dev_")
 		}
 	} else {
 		if !strings.HasPrefix(name, "dev_") {
@@ -96,7 +161,7 @@ func (vm *ViewIndexManager) getDesignDocument(tracectx requestSpanContext, name
 	name = vm.ddocName(name, namespace)
 
 	retryStrategy := vm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -111,7 +176,7 @@ func (vm *ViewIndexManager) getDesignDocument(tracectx requestSpanContext, name
 	}
 
 	dspan := vm.tracer.StartSpan("dispatch", tracectx)
-	resp, err := vm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(vm.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -127,21 +192,9 @@ func (vm *ViewIndexManager) getDesignDocument(tracectx requestSpanContext, name
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-
-		return nil, viewIndexError{
-			statusCode:   resp.StatusCode,
-			message:      string(data),
-			indexMissing: resp.StatusCode == 404,
-		}
+	err = decodeMgmtError(resp, 200)
+	if err != nil {
+		return nil, makeViewIndexError(err, true)
 	}
 
 	ddocObj := DesignDocument{}
@@ -155,6 +208,29 @@ func (vm *ViewIndexManager) getDesignDocument(tracectx requestSpanContext, name
 	return &ddocObj, nil
 }
 
+// DesignDocumentExists verifies whether or not a design document exists for the given bucket, returning false
+// rather than an error if it does not.
+func (vm *ViewIndexManager) DesignDocumentExists(name string, namespace DesignDocumentNamespace,
+	opts *GetDesignDocumentOptions) (bool, error) {
+	if opts == nil {
+		opts = &GetDesignDocumentOptions{}
+	}
+
+	span := vm.tracer.StartSpan("DesignDocumentExists", nil).SetTag("couchbase.service", "view")
+	defer span.Finish()
+
+	_, err := vm.getDesignDocument(span.Context(), name, namespace, time.Now(), opts)
+	if err != nil {
+		if IsDesignDocumentNotFoundError(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
 // GetAllDesignDocumentsOptions is the set of options available to the ViewIndexManager GetAllDesignDocuments operation.
 type GetAllDesignDocumentsOptions struct {
 	Timeout       time.Duration
@@ -178,7 +254,7 @@ func (vm *ViewIndexManager) GetAllDesignDocuments(namespace DesignDocumentNamesp
 	}
 
 	retryStrategy := vm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -192,7 +268,7 @@ func (vm *ViewIndexManager) GetAllDesignDocuments(namespace DesignDocumentNamesp
 	}
 
 	espan := vm.tracer.StartSpan("encode", span.Context())
-	resp, err := vm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(vm.httpClient, req)
 	espan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -208,23 +284,17 @@ func (vm *ViewIndexManager) GetAllDesignDocuments(namespace DesignDocumentNamesp
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return nil, viewIndexError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp, 200)
+	if err != nil {
+		return nil, makeViewIndexError(err, false)
 	}
 
 	var ddocsObj struct {
 		Rows []struct {
 			Doc struct {
 				Meta struct {
-					Id string
+					Id  string
+					Rev string
 				}
 				Json DesignDocument
 			}
@@ -242,6 +312,9 @@ func (vm *ViewIndexManager) GetAllDesignDocuments(namespace DesignDocumentNamesp
 		isProd := !strings.HasPrefix(ddoc.Name, "dev_")
 		if isProd == bool(namespace) {
 			ddoc.Name = strings.TrimPrefix(ddocData.Doc.Meta.Id[8:], "dev_")
+			if ddoc.Rev == "" {
+				ddoc.Rev = ddocData.Doc.Meta.Rev
+			}
 			ddocs = append(ddocs, ddoc)
 		}
 	}
@@ -254,6 +327,13 @@ type UpsertDesignDocumentOptions struct {
 	Timeout       time.Duration
 	Context       context.Context
 	RetryStrategy RetryStrategy
+	// BuildAndWait, if set, waits up to this long after the upsert for the views of the design document to be built,
+	// by querying one of them with stale=false until it responds successfully.
+	BuildAndWait time.Duration
+	// IfMatchRev, if set, only updates the design document if its revision on the server is still the Rev of the
+	// design document being upserted. If it is not then an error for which IsDesignDocumentConflictError is true is
+	// returned, allowing concurrent changes to be detected rather than overwritten.
+	IfMatchRev bool
 }
 
 // UpsertDesignDocument will insert a design document to the given bucket, or update
@@ -266,11 +346,110 @@ func (vm *ViewIndexManager) UpsertDesignDocument(ddoc DesignDocument, namespace
 	span := vm.tracer.StartSpan("UpsertDesignDocument", nil).SetTag("couchbase.service", "view")
 	defer span.Finish()
 
-	return vm.upsertDesignDocument(span.Context(), ddoc, namespace, time.Now(), opts)
+	err := vm.upsertDesignDocument(span.Context(), ddoc, namespace, time.Now(), opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.BuildAndWait > 0 {
+		return vm.waitForDesignDocument(span.Context(), ddoc, namespace, opts)
+	}
+
+	return nil
+}
+
+// waitForDesignDocument queries a view of ddoc with stale=false, forcing the index to be built, until the query
+// succeeds or BuildAndWait has passed.
+func (vm *ViewIndexManager) waitForDesignDocument(tracectx requestSpanContext, ddoc DesignDocument,
+	namespace DesignDocumentNamespace, opts *UpsertDesignDocumentOptions) error {
+	if len(ddoc.Views) == 0 {
+		return nil
+	}
+
+	viewNames := make([]string, 0, len(ddoc.Views))
+	for name := range ddoc.Views {
+		viewNames = append(viewNames, name)
+	}
+	sort.Strings(viewNames)
+
+	startTime := time.Now()
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, opts.BuildAndWait)
+	defer cancel()
+
+	retryStrategy := vm.defaultRetryStrategy
+	if opts.RetryStrategy != nil {
+		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
+	}
+
+	path := fmt.Sprintf("/_design/%s/_view/%s?stale=false&limit=1", vm.ddocName(ddoc.Name, namespace), viewNames[0])
+	curInterval := 50 * time.Millisecond
+	for {
+		req := &gocbcore.HttpRequest{
+			Service:       gocbcore.ServiceType(CapiService),
+			Path:          path,
+			Method:        "GET",
+			Context:       ctx,
+			IsIdempotent:  true,
+			RetryStrategy: retryStrategy,
+			UniqueId:      uuid.New().String(),
+		}
+
+		dspan := vm.tracer.StartSpan("dispatch", tracectx)
+		resp, err := doMgmtRequest(vm.httpClient, req)
+		dspan.Finish()
+		if err != nil && err != context.DeadlineExceeded {
+			return err
+		}
+
+		if err == nil {
+			if resp.StatusCode == 200 {
+				closeErr := resp.Body.Close()
+				if closeErr != nil {
+					logDebugf("Failed to close socket (%s)", closeErr)
+				}
+
+				return nil
+			}
+
+			logDebugf("Design document %s not yet ready, status code %d", ddoc.Name, resp.StatusCode)
+			closeErr := resp.Body.Close()
+			if closeErr != nil {
+				logDebugf("Failed to close socket (%s)", closeErr)
+			}
+		}
+
+		waitTmr := gocbcore.AcquireTimer(curInterval)
+		select {
+		case <-waitTmr.C:
+			gocbcore.ReleaseTimer(waitTmr, true)
+		case <-ctx.Done():
+			gocbcore.ReleaseTimer(waitTmr, false)
+			return timeoutError{
+				operationID:   req.UniqueId,
+				retryReasons:  req.RetryReasons(),
+				retryAttempts: req.RetryAttempts(),
+				operation:     "view",
+				elapsed:       time.Now().Sub(startTime),
+			}
+		}
+
+		curInterval += 50 * time.Millisecond
+		if curInterval > 500*time.Millisecond {
+			curInterval = 500 * time.Millisecond
+		}
+	}
 }
 
 func (vm *ViewIndexManager) upsertDesignDocument(tracectx requestSpanContext, ddoc DesignDocument, namespace DesignDocumentNamespace, startTime time.Time,
 	opts *UpsertDesignDocumentOptions) error {
+	if opts.IfMatchRev && ddoc.Rev == "" {
+		return invalidArgumentsError{message: "design document revision must be set to upsert with IfMatchRev"}
+	}
+
 	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, vm.globalTimeout)
 	if cancel != nil {
 		defer cancel()
@@ -286,7 +465,7 @@ func (vm *ViewIndexManager) upsertDesignDocument(tracectx requestSpanContext, dd
 	ddoc.Name = vm.ddocName(ddoc.Name, namespace)
 
 	retryStrategy := vm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -299,8 +478,12 @@ func (vm *ViewIndexManager) upsertDesignDocument(tracectx requestSpanContext, dd
 		RetryStrategy: retryStrategy,
 	}
 
+	if opts.IfMatchRev {
+		req.Headers = map[string]string{"If-Match": ddoc.Rev}
+	}
+
 	dspan := vm.tracer.StartSpan("dispatch", nil)
-	resp, err := vm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(vm.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -316,16 +499,17 @@ func (vm *ViewIndexManager) upsertDesignDocument(tracectx requestSpanContext, dd
 		return err
 	}
 
-	if resp.StatusCode != 201 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
+	// The server responds with 201 when the design document is created, some versions respond with 200 rather than
+	// 201 when an existing design document is replaced.
+	err = decodeMgmtError(resp, 200, 201)
+	if err != nil {
+		err = makeViewIndexError(err, false)
+		if indexErr, ok := err.(viewIndexError); ok && (indexErr.statusCode == 409 || indexErr.statusCode == 412) {
+			indexErr.revConflict = true
+			return indexErr
 		}
-		return viewIndexError{statusCode: resp.StatusCode, message: string(data)}
+
+		return err
 	}
 
 	return nil
@@ -360,7 +544,7 @@ func (vm *ViewIndexManager) dropDesignDocument(tracectx requestSpanContext, name
 	name = vm.ddocName(name, namespace)
 
 	retryStrategy := vm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -373,7 +557,7 @@ func (vm *ViewIndexManager) dropDesignDocument(tracectx requestSpanContext, name
 	}
 
 	dspan := vm.tracer.StartSpan("dispatch", tracectx)
-	resp, err := vm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(vm.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -389,20 +573,9 @@ func (vm *ViewIndexManager) dropDesignDocument(tracectx requestSpanContext, name
 		return err
 	}
 
-	if resp.StatusCode != 200 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return viewIndexError{
-			statusCode:   resp.StatusCode,
-			message:      string(data),
-			indexMissing: resp.StatusCode == 404,
-		}
+	err = decodeMgmtError(resp, 200)
+	if err != nil {
+		return makeViewIndexError(err, true)
 	}
 
 	return nil
@@ -450,7 +623,85 @@ func (vm *ViewIndexManager) PublishDesignDocument(name string, opts *PublishDesi
 		RetryStrategy: opts.RetryStrategy,
 	})
 	if err != nil {
-		return errors.Wrap(err, "failed to create ")
+		return errors.Wrapf(err, "failed to publish design document %s", name)
+	}
+
+	return nil
+}
+
+// CopyDesignDocumentOptions is the set of options available to the ViewIndexManager CopyDesignDocument operation.
+type CopyDesignDocumentOptions struct {
+	Timeout       time.Duration
+	Context       context.Context
+	RetryStrategy RetryStrategy
+
+	// Destination is the manager of the bucket to copy the design document into. If not set then the design
+	// document is copied within this bucket.
+	Destination *ViewIndexManager
+	// Overwrite replaces the destination design document if it already exists, rather than failing with an error
+	// for which IsDesignDocumentExistsError is true.
+	Overwrite bool
+}
+
+// CopyDesignDocument copies a design document to a new name and namespace, optionally in another bucket. Any fields
+// of the design document which are not modelled, such as spatial views or options, are copied along with its views.
+func (vm *ViewIndexManager) CopyDesignDocument(srcName string, srcNamespace DesignDocumentNamespace, dstName string,
+	dstNamespace DesignDocumentNamespace, opts *CopyDesignDocumentOptions) error {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &CopyDesignDocumentOptions{}
+	}
+
+	dst := opts.Destination
+	if dst == nil {
+		dst = vm
+	}
+
+	if dst.bucketName == vm.bucketName && vm.ddocName(srcName, srcNamespace) == vm.ddocName(dstName, dstNamespace) {
+		return invalidArgumentsError{message: "design document cannot be copied onto itself"}
+	}
+
+	span := vm.tracer.StartSpan("CopyDesignDocument", nil).
+		SetTag("couchbase.service", "view")
+	defer span.Finish()
+
+	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, vm.globalTimeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	ddoc, err := vm.getDesignDocument(span.Context(), srcName, srcNamespace, startTime, &GetDesignDocumentOptions{
+		Context:       ctx,
+		RetryStrategy: opts.RetryStrategy,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !opts.Overwrite {
+		_, err = dst.getDesignDocument(span.Context(), dstName, dstNamespace, startTime, &GetDesignDocumentOptions{
+			Context:       ctx,
+			RetryStrategy: opts.RetryStrategy,
+		})
+		if err == nil {
+			return viewIndexError{
+				message:     fmt.Sprintf("Design document %s already exists", dst.ddocName(dstName, dstNamespace)),
+				indexExists: true,
+			}
+		}
+		if !IsDesignDocumentNotFoundError(err) {
+			return err
+		}
+	}
+
+	ddoc.Name = dstName
+	ddoc.Rev = ""
+	err = dst.upsertDesignDocument(span.Context(), *ddoc, dstNamespace, startTime, &UpsertDesignDocumentOptions{
+		Context:       ctx,
+		RetryStrategy: opts.RetryStrategy,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy design document %s", srcName)
 	}
 
 	return nil
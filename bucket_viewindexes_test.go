@@ -0,0 +1,578 @@
+package gocb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+)
+
+func TestViewIndexManagerDesignDocumentRoundTrip(t *testing.T) {
+	ddocData := []byte(`{
+		"views":{"sample":{"map":"function (doc, meta) { emit(meta.id, null); }"}},
+		"spatial":{"points":"function (doc) { emit({type: \"Point\", coordinates: doc.loc}, null); }"},
+		"options":{"updateMinChanges":10}
+	}`)
+
+	var putBody []byte
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Path != "/_design/dev_test" {
+			t.Fatalf("Expected path to be /_design/dev_test but was %s", req.Path)
+		}
+
+		switch req.Method {
+		case "GET":
+			return &gocbcore.HttpResponse{
+				Endpoint:   "http://localhost:8092",
+				StatusCode: 200,
+				Body:       &testReadCloser{bytes.NewBuffer(ddocData), nil},
+			}, nil
+		case "PUT":
+			putBody = req.Body
+			return &gocbcore.HttpResponse{
+				Endpoint:   "http://localhost:8092",
+				StatusCode: 201,
+				Body:       &testReadCloser{bytes.NewBufferString(`{"ok":true}`), nil},
+			}, nil
+		}
+
+		t.Fatalf("Unexpected method %s", req.Method)
+		return nil, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	mgr := &ViewIndexManager{
+		bucketName:    "default",
+		httpClient:    provider,
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	ddoc, err := mgr.GetDesignDocument("test", DevelopmentDesignDocumentNamespace, nil)
+	if err != nil {
+		t.Fatalf("Expected GetDesignDocument to succeed but was %v", err)
+	}
+
+	if len(ddoc.Views) != 1 {
+		t.Fatalf("Expected design document to have 1 view but had %d", len(ddoc.Views))
+	}
+
+	err = mgr.UpsertDesignDocument(*ddoc, DevelopmentDesignDocumentNamespace, nil)
+	if err != nil {
+		t.Fatalf("Expected UpsertDesignDocument to succeed but was %v", err)
+	}
+
+	var expected, actual map[string]interface{}
+	err = json.Unmarshal(ddocData, &expected)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal expected design document: %v", err)
+	}
+
+	err = json.Unmarshal(putBody, &actual)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal upserted design document: %v", err)
+	}
+
+	for _, field := range []string{"views", "spatial", "options"} {
+		expectedField, _ := json.Marshal(expected[field])
+		actualField, _ := json.Marshal(actual[field])
+		if string(expectedField) != string(actualField) {
+			t.Fatalf("Expected %s to be %s but was %s", field, expectedField, actualField)
+		}
+	}
+}
+
+func TestDesignDocumentMarshalWithoutExtra(t *testing.T) {
+	ddoc := DesignDocument{
+		Name: "test",
+		Views: map[string]View{
+			"sample": {Map: "function (doc, meta) { emit(meta.id, null); }"},
+		},
+	}
+
+	data, err := json.Marshal(ddoc)
+	if err != nil {
+		t.Fatalf("Failed to marshal design document: %v", err)
+	}
+
+	expected := `{"views":{"sample":{"map":"function (doc, meta) { emit(meta.id, null); }"}}}`
+	if string(data) != expected {
+		t.Fatalf("Expected design document to be %s but was %s", expected, data)
+	}
+}
+
+func TestViewIndexManagerPublishDesignDocument(t *testing.T) {
+	for _, status := range []int{200, 201} {
+		t.Run(fmt.Sprintf("%d", status), func(t *testing.T) {
+			ddocData := []byte(`{"views":{"sample":{"map":"function (doc, meta) { emit(meta.id, null); }"}}}`)
+
+			var published bool
+			doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+				switch {
+				case req.Method == "GET" && req.Path == "/_design/dev_test":
+					return &gocbcore.HttpResponse{
+						Endpoint:   "http://localhost:8092",
+						StatusCode: 200,
+						Body:       &testReadCloser{bytes.NewBuffer(ddocData), nil},
+					}, nil
+				case req.Method == "PUT" && req.Path == "/_design/test":
+					published = true
+					return &gocbcore.HttpResponse{
+						Endpoint:   "http://localhost:8092",
+						StatusCode: status,
+						Body:       &testReadCloser{bytes.NewBufferString(`{"ok":true,"id":"_design/test"}`), nil},
+					}, nil
+				}
+
+				t.Fatalf("Unexpected request %s %s", req.Method, req.Path)
+				return nil, nil
+			}
+
+			provider := &mockHTTPProvider{
+				doFn: doHTTP,
+			}
+
+			mgr := &ViewIndexManager{
+				bucketName:    "default",
+				httpClient:    provider,
+				globalTimeout: 10 * time.Second,
+				tracer:        &noopTracer{},
+			}
+
+			err := mgr.PublishDesignDocument("test", nil)
+			if err != nil {
+				t.Fatalf("Expected PublishDesignDocument to succeed but was %v", err)
+			}
+
+			if !published {
+				t.Fatalf("Expected design document to be published")
+			}
+		})
+	}
+}
+
+func TestViewIndexManagerDesignDocumentExists(t *testing.T) {
+	type tCase struct {
+		name      string
+		namespace DesignDocumentNamespace
+		path      string
+		status    int
+		exists    bool
+		expectErr bool
+	}
+
+	testCases := []tCase{
+		{name: "test", namespace: ProductionDesignDocumentNamespace, path: "/_design/test", status: 200, exists: true},
+		{name: "dev_test", namespace: ProductionDesignDocumentNamespace, path: "/_design/test", status: 200, exists: true},
+		{name: "test", namespace: DevelopmentDesignDocumentNamespace, path: "/_design/dev_test", status: 200, exists: true},
+		{name: "devices", namespace: DevelopmentDesignDocumentNamespace, path: "/_design/dev_devices", status: 200, exists: true},
+		{name: "dev_ddoc", namespace: ProductionDesignDocumentNamespace, path: "/_design/ddoc", status: 404},
+		{name: "test", namespace: DevelopmentDesignDocumentNamespace, path: "/_design/dev_test", status: 404},
+		{name: "test", namespace: ProductionDesignDocumentNamespace, path: "/_design/test", status: 500, expectErr: true},
+		{name: "test", namespace: DevelopmentDesignDocumentNamespace, path: "/_design/dev_test", status: 500, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s-%d", tc.path, tc.status), func(t *testing.T) {
+			doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+				if req.Path != tc.path {
+					t.Fatalf("Expected path to be %s but was %s", tc.path, req.Path)
+				}
+
+				body := `{"views":{}}`
+				if tc.status != 200 {
+					body = `{"error":"not_found","reason":"missing"}`
+				}
+
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8092",
+					StatusCode: tc.status,
+					Body:       &testReadCloser{bytes.NewBufferString(body), nil},
+				}, nil
+			}
+
+			mgr := &ViewIndexManager{
+				bucketName:    "default",
+				httpClient:    &mockHTTPProvider{doFn: doHTTP},
+				globalTimeout: 10 * time.Second,
+				tracer:        &noopTracer{},
+			}
+
+			exists, err := mgr.DesignDocumentExists(tc.name, tc.namespace, nil)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("Expected DesignDocumentExists to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected DesignDocumentExists to succeed but was %v", err)
+			}
+
+			if exists != tc.exists {
+				t.Fatalf("Expected exists to be %t but was %t", tc.exists, exists)
+			}
+		})
+	}
+}
+
+func testViewIndexManagerForBuild(t *testing.T, readyAfter int, warmups *int) *ViewIndexManager {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Method == "PUT" {
+			if req.Path != "/_design/dev_test" {
+				t.Fatalf("Expected path to be /_design/dev_test but was %s", req.Path)
+			}
+
+			return &gocbcore.HttpResponse{
+				Endpoint:   "http://localhost:8092",
+				StatusCode: 201,
+				Body:       &testReadCloser{bytes.NewBufferString(`{"ok":true}`), nil},
+			}, nil
+		}
+
+		if req.Path != "/_design/dev_test/_view/a?stale=false&limit=1" {
+			t.Fatalf("Expected warm-up query to be against the first view but was %s", req.Path)
+		}
+
+		*warmups++
+		if *warmups <= readyAfter {
+			return &gocbcore.HttpResponse{
+				Endpoint:   "http://localhost:8092",
+				StatusCode: 404,
+				Body:       &testReadCloser{bytes.NewBufferString(`{"error":"not_found","reason":"missing"}`), nil},
+			}, nil
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8092",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(`{"total_rows":0,"rows":[]}`), nil},
+		}, nil
+	}
+
+	return &ViewIndexManager{
+		bucketName:           "default",
+		httpClient:           &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout:        10 * time.Second,
+		defaultRetryStrategy: newRetryStrategyWrapper(NewBestEffortRetryStrategy(nil)),
+		tracer:               &noopTracer{},
+	}
+}
+
+func TestViewIndexManagerUpsertDesignDocumentBuildAndWait(t *testing.T) {
+	var warmups int
+	mgr := testViewIndexManagerForBuild(t, 2, &warmups)
+
+	err := mgr.UpsertDesignDocument(DesignDocument{
+		Name: "test",
+		Views: map[string]View{
+			"b": {Map: "function (doc, meta) { emit(meta.id, null); }"},
+			"a": {Map: "function (doc, meta) { emit(meta.id, null); }"},
+		},
+	}, DevelopmentDesignDocumentNamespace, &UpsertDesignDocumentOptions{
+		BuildAndWait: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Expected UpsertDesignDocument to succeed but was %v", err)
+	}
+
+	if warmups != 3 {
+		t.Fatalf("Expected warm-up query to be run 3 times but was run %d times", warmups)
+	}
+}
+
+func TestViewIndexManagerUpsertDesignDocumentBuildAndWaitTimeout(t *testing.T) {
+	var warmups int
+	mgr := testViewIndexManagerForBuild(t, 1000, &warmups)
+
+	err := mgr.UpsertDesignDocument(DesignDocument{
+		Name:  "test",
+		Views: map[string]View{"a": {Map: "function (doc, meta) { emit(meta.id, null); }"}},
+	}, DevelopmentDesignDocumentNamespace, &UpsertDesignDocumentOptions{
+		BuildAndWait: 200 * time.Millisecond,
+	})
+	if !IsTimeoutError(err) {
+		t.Fatalf("Expected error to be timeout but was %v", err)
+	}
+
+	if warmups == 0 {
+		t.Fatalf("Expected warm-up query to have been run")
+	}
+}
+
+// testViewIndexManagerForRev returns a manager for a server holding a single design document at revision *rev,
+// upserts with a mismatched If-Match header are rejected and successful upserts move the revision on.
+func testViewIndexManagerForRev(t *testing.T, rev *int, ifMatch *string) *ViewIndexManager {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Path != "/_design/dev_test" {
+			t.Fatalf("Expected path to be /_design/dev_test but was %s", req.Path)
+		}
+
+		currentRev := fmt.Sprintf("%d-5e0bf3a1", *rev)
+		switch req.Method {
+		case "GET":
+			body := `{"_rev":"` + currentRev + `","views":{"a":{"map":"function (doc, meta) { emit(meta.id, null); }"}}}`
+			return &gocbcore.HttpResponse{
+				Endpoint:   "http://localhost:8092",
+				StatusCode: 200,
+				Body:       &testReadCloser{bytes.NewBufferString(body), nil},
+			}, nil
+		case "PUT":
+			var body map[string]interface{}
+			err := json.Unmarshal(req.Body, &body)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal upserted design document: %v", err)
+			}
+			if _, ok := body["_rev"]; ok {
+				t.Fatalf("Expected revision not to be sent in the design document body")
+			}
+
+			*ifMatch = req.Headers["If-Match"]
+			if *ifMatch != "" && *ifMatch != currentRev {
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8092",
+					StatusCode: 409,
+					Body: &testReadCloser{
+						bytes.NewBufferString(`{"error":"conflict","reason":"Document update conflict."}`), nil},
+				}, nil
+			}
+
+			*rev++
+			return &gocbcore.HttpResponse{
+				Endpoint:   "http://localhost:8092",
+				StatusCode: 201,
+				Body:       &testReadCloser{bytes.NewBufferString(`{"ok":true}`), nil},
+			}, nil
+		}
+
+		t.Fatalf("Unexpected method %s", req.Method)
+		return nil, nil
+	}
+
+	return &ViewIndexManager{
+		bucketName:    "default",
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+}
+
+func TestViewIndexManagerUpsertDesignDocumentIfMatchRev(t *testing.T) {
+	rev := 3
+	var ifMatch string
+	mgr := testViewIndexManagerForRev(t, &rev, &ifMatch)
+
+	ddoc, err := mgr.GetDesignDocument("test", DevelopmentDesignDocumentNamespace, nil)
+	if err != nil {
+		t.Fatalf("Expected GetDesignDocument to succeed but was %v", err)
+	}
+
+	if ddoc.Rev != "3-5e0bf3a1" {
+		t.Fatalf("Expected design document revision to be 3-5e0bf3a1 but was %s", ddoc.Rev)
+	}
+
+	if _, ok := ddoc.Extra["_rev"]; ok {
+		t.Fatalf("Expected revision not to be held as an unmodelled field")
+	}
+
+	ddoc.Views["b"] = View{Map: "function (doc, meta) { emit(doc.name, null); }"}
+	err = mgr.UpsertDesignDocument(*ddoc, DevelopmentDesignDocumentNamespace,
+		&UpsertDesignDocumentOptions{IfMatchRev: true})
+	if err != nil {
+		t.Fatalf("Expected UpsertDesignDocument to succeed but was %v", err)
+	}
+
+	if ifMatch != "3-5e0bf3a1" {
+		t.Fatalf("Expected If-Match to be 3-5e0bf3a1 but was %s", ifMatch)
+	}
+
+	if rev != 4 {
+		t.Fatalf("Expected design document to have been updated")
+	}
+}
+
+func TestViewIndexManagerUpsertDesignDocumentIfMatchRevConflict(t *testing.T) {
+	rev := 3
+	var ifMatch string
+	mgr := testViewIndexManagerForRev(t, &rev, &ifMatch)
+
+	ddoc, err := mgr.GetDesignDocument("test", DevelopmentDesignDocumentNamespace, nil)
+	if err != nil {
+		t.Fatalf("Expected GetDesignDocument to succeed but was %v", err)
+	}
+
+	// Another admin updates the design document in the meantime.
+	rev++
+
+	err = mgr.UpsertDesignDocument(*ddoc, DevelopmentDesignDocumentNamespace,
+		&UpsertDesignDocumentOptions{IfMatchRev: true})
+	if !IsDesignDocumentConflictError(err) {
+		t.Fatalf("Expected error to be a design document conflict but was %v", err)
+	}
+
+	if rev != 4 {
+		t.Fatalf("Expected design document not to have been updated")
+	}
+
+	// Without IfMatchRev the upsert overwrites the other change.
+	err = mgr.UpsertDesignDocument(*ddoc, DevelopmentDesignDocumentNamespace, nil)
+	if err != nil {
+		t.Fatalf("Expected UpsertDesignDocument to succeed but was %v", err)
+	}
+
+	if ifMatch != "" {
+		t.Fatalf("Expected If-Match not to be sent but was %s", ifMatch)
+	}
+}
+
+func TestViewIndexManagerUpsertDesignDocumentIfMatchRevWithoutRev(t *testing.T) {
+	rev := 1
+	var ifMatch string
+	mgr := testViewIndexManagerForRev(t, &rev, &ifMatch)
+
+	err := mgr.UpsertDesignDocument(DesignDocument{Name: "test"}, DevelopmentDesignDocumentNamespace,
+		&UpsertDesignDocumentOptions{IfMatchRev: true})
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
+// testViewIndexManagerForCopy returns a manager backed by an in memory set of design documents, keyed by path.
+func testViewIndexManagerForCopy(t *testing.T, bucketName string, ddocs map[string][]byte) *ViewIndexManager {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		switch req.Method {
+		case "GET":
+			data, ok := ddocs[req.Path]
+			if !ok {
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8092",
+					StatusCode: 404,
+					Body:       &testReadCloser{bytes.NewBufferString(`{"error":"not_found","reason":"missing"}`), nil},
+				}, nil
+			}
+
+			return &gocbcore.HttpResponse{
+				Endpoint:   "http://localhost:8092",
+				StatusCode: 200,
+				Body:       &testReadCloser{bytes.NewBuffer(data), nil},
+			}, nil
+		case "PUT":
+			ddocs[req.Path] = req.Body
+			return &gocbcore.HttpResponse{
+				Endpoint:   "http://localhost:8092",
+				StatusCode: 201,
+				Body:       &testReadCloser{bytes.NewBufferString(`{"ok":true}`), nil},
+			}, nil
+		}
+
+		t.Fatalf("Unexpected request %s %s", req.Method, req.Path)
+		return nil, nil
+	}
+
+	return &ViewIndexManager{
+		bucketName:    bucketName,
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+}
+
+func testAssertDesignDocumentCopied(t *testing.T, expectedData, actualData []byte) {
+	var expected, actual map[string]interface{}
+	err := json.Unmarshal(expectedData, &expected)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal expected design document: %v", err)
+	}
+
+	err = json.Unmarshal(actualData, &actual)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal copied design document: %v", err)
+	}
+
+	if _, ok := actual["_rev"]; ok {
+		t.Fatalf("Expected the revision not to be copied")
+	}
+
+	for _, field := range []string{"views", "spatial", "options"} {
+		expectedField, _ := json.Marshal(expected[field])
+		actualField, _ := json.Marshal(actual[field])
+		if string(expectedField) != string(actualField) {
+			t.Fatalf("Expected %s to be %s but was %s", field, expectedField, actualField)
+		}
+	}
+}
+
+func TestViewIndexManagerCopyDesignDocumentDevToProd(t *testing.T) {
+	ddocData := []byte(`{
+		"_rev":"3-a1b2c3d4",
+		"views":{"sample":{"map":"function (doc, meta) { emit(meta.id, null); }"}},
+		"spatial":{"points":"function (doc) { emit({type: \"Point\", coordinates: doc.loc}, null); }"},
+		"options":{"updateMinChanges":10}
+	}`)
+	ddocs := map[string][]byte{"/_design/dev_test": ddocData}
+	mgr := testViewIndexManagerForCopy(t, "default", ddocs)
+
+	err := mgr.CopyDesignDocument("test", DevelopmentDesignDocumentNamespace, "test", ProductionDesignDocumentNamespace,
+		nil)
+	if err != nil {
+		t.Fatalf("Expected CopyDesignDocument to succeed but was %v", err)
+	}
+
+	copied, ok := ddocs["/_design/test"]
+	if !ok {
+		t.Fatalf("Expected design document to be copied to production")
+	}
+	testAssertDesignDocumentCopied(t, ddocData, copied)
+
+	err = mgr.CopyDesignDocument("test", DevelopmentDesignDocumentNamespace, "test", ProductionDesignDocumentNamespace,
+		nil)
+	if !IsDesignDocumentExistsError(err) {
+		t.Fatalf("Expected error to be design document exists but was %v", err)
+	}
+
+	err = mgr.CopyDesignDocument("test", DevelopmentDesignDocumentNamespace, "test", ProductionDesignDocumentNamespace,
+		&CopyDesignDocumentOptions{Overwrite: true})
+	if err != nil {
+		t.Fatalf("Expected CopyDesignDocument to overwrite but was %v", err)
+	}
+
+	err = mgr.CopyDesignDocument("test", DevelopmentDesignDocumentNamespace, "dev_test",
+		DevelopmentDesignDocumentNamespace, &CopyDesignDocumentOptions{Overwrite: true})
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected copying onto itself to be invalid arguments but was %v", err)
+	}
+}
+
+func TestViewIndexManagerCopyDesignDocumentRename(t *testing.T) {
+	ddocData := []byte(`{
+		"views":{"by_name":{"map":"function (doc, meta) { emit(doc.name, null); }","reduce":"_count"}},
+		"options":{"updateInterval":5000}
+	}`)
+	srcDdocs := map[string][]byte{"/_design/beers": ddocData}
+	src := testViewIndexManagerForCopy(t, "beer-sample", srcDdocs)
+
+	dstDdocs := make(map[string][]byte)
+	dst := testViewIndexManagerForCopy(t, "beer-archive", dstDdocs)
+
+	err := src.CopyDesignDocument("beers", ProductionDesignDocumentNamespace, "archived_beers",
+		DevelopmentDesignDocumentNamespace, &CopyDesignDocumentOptions{Destination: dst})
+	if err != nil {
+		t.Fatalf("Expected CopyDesignDocument to succeed but was %v", err)
+	}
+
+	copied, ok := dstDdocs["/_design/dev_archived_beers"]
+	if !ok {
+		t.Fatalf("Expected design document to be copied under the new name but was %v", dstDdocs)
+	}
+	testAssertDesignDocumentCopied(t, ddocData, copied)
+
+	if len(srcDdocs) != 1 {
+		t.Fatalf("Expected the source bucket to be unchanged but was %v", srcDdocs)
+	}
+}
@@ -1,11 +1,15 @@
 package gocb
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,7 +22,18 @@ type viewResponse struct {
 	Rows      []json.RawMessage `json:"rows,omitempty"`
 	Error     string            `json:"error,omitempty"`
 	Reason    string            `json:"reason,omitempty"`
-	Errors    []viewError       `json:"errors,omitempty"`
+	Errors    []ViewError       `json:"errors,omitempty"`
+}
+
+// ViewError describes a failure on a single node whilst gathering the results of a view query.
+type ViewError struct {
+	Node   string `json:"from"`
+	Reason string `json:"reason"`
+}
+
+// Phase is the phase of view execution that the error originated in, e.g. map or reduce.
+func (e ViewError) Phase() ViewErrorPhase {
+	return viewErrorPhase(e.Reason)
 }
 
 // ViewRow provides access to a single view query row.
@@ -34,6 +49,7 @@ type ViewRow struct {
 type ViewMetadata struct {
 	totalRows int
 	debug     interface{}
+	stale     bool
 }
 
 // ViewResult implements an iterator interface which can be used to iterate over the rows of the query results.
@@ -43,11 +59,14 @@ type ViewResult struct {
 	errMessage string
 	startTime  time.Time
 	endpoint   string
+	httpStatus int
 
-	ctx          context.Context
-	cancel       context.CancelFunc
-	streamResult *streamingResult
-	err          error
+	ctx             context.Context
+	cancel          context.CancelFunc
+	streamResult    *streamingResult
+	err             error
+	errors          []ViewError
+	continueOnError bool
 
 	serializer JSONSerializer
 }
@@ -58,6 +77,11 @@ func (r *ViewResult) Next(rowPtr *ViewRow) bool {
 		return false
 	}
 
+	if rowPtr == nil {
+		r.err = invalidArgumentsError{message: "rows must be decoded into a non-nil *ViewRow"}
+		return false
+	}
+
 	row := r.NextBytes()
 	if row == nil {
 		return false
@@ -110,7 +134,7 @@ func (r *ViewRow) Key(keyPtr interface{}) error {
 		return clientError{message: "no data to scan"}
 	}
 
-	err := r.serializer.Deserialize(r.key, keyPtr)
+	err := decodeRow(r.serializer, r.key, keyPtr)
 	if err != nil {
 		return err
 	}
@@ -124,7 +148,7 @@ func (r *ViewRow) Value(valuePtr interface{}) error {
 		return clientError{message: "no data to scan"}
 	}
 
-	err := r.serializer.Deserialize(r.value, valuePtr)
+	err := decodeRow(r.serializer, r.value, valuePtr)
 	if err != nil {
 		return err
 	}
@@ -147,13 +171,20 @@ func (r *ViewResult) NextBytes() []byte {
 	return raw
 }
 
+// Errors returns the errors that occurred on individual nodes whilst gathering the results, if any errors are
+// present then the results are incomplete. This is only populated once the results have been closed.
+func (r *ViewResult) Errors() []ViewError {
+	return r.errors
+}
+
 // Close marks the results as closed, returning any errors that occurred during reading the results.
+// Errors that occur on individual nodes are not returned if the query was set to continue on error, see Errors.
 func (r *ViewResult) Close() error {
 	if r.streamResult == nil || r.streamResult.Closed() {
 		return r.makeError()
 	}
 
-	err := r.streamResult.Close()
+	err := r.streamResult.closeWithContext(r.ctx)
 	ctxErr := r.ctx.Err()
 	if r.cancel != nil {
 		r.cancel()
@@ -178,7 +209,9 @@ func (r *ViewResult) makeError() error {
 			ErrorReason:  r.errReason,
 		}
 		return viewMultiError{
-			errors: []ViewQueryError{err},
+			errors:     []ViewQueryError{err},
+			httpStatus: r.httpStatus,
+			endpoint:   r.endpoint,
 		}
 	}
 
@@ -203,21 +236,25 @@ func (r *ViewResult) readAttribute(decoder *json.Decoder, t json.Token) (bool, e
 			return false, err
 		}
 	case "errors":
-		var respErrs []viewError
-		err := decoder.Decode(&respErrs)
+		err := decoder.Decode(&r.errors)
 		if err != nil {
 			return false, err
 		}
-		if len(respErrs) > 0 {
-			errs := make([]ViewQueryError, len(respErrs))
-			for i, e := range errs {
-				errs[i] = e
-			}
-			endErrs := viewMultiError{
-				errors: errs,
+		// When continuing on error the rows are still usable so the errors are only made available through Errors.
+		if len(r.errors) > 0 && !r.continueOnError {
+			errs := make([]ViewQueryError, len(r.errors))
+			for i, e := range r.errors {
+				errs[i] = viewError{
+					ErrorNode:   e.Node,
+					ErrorReason: e.Reason,
+				}
 			}
 
-			r.err = endErrs
+			r.err = viewMultiError{
+				errors:   errs,
+				endpoint: r.endpoint,
+				partial:  true,
+			}
 		}
 	case "rows":
 		// read the opening [, this prevents the decoder from loading the entire results array into memory
@@ -272,6 +309,16 @@ func (r *ViewResult) One(rowPtr *ViewRow) error {
 	return nil
 }
 
+// Drain reads and discards any remaining rows so that the metadata is fully populated, then closes the results,
+// returning any error that occurred whilst streaming them. This is useful when only the metadata, such as the
+// total number of rows, is needed.
+func (r *ViewResult) Drain() error {
+	for r.NextBytes() != nil {
+	}
+
+	return r.Close()
+}
+
 // Metadata returns metadata for this result.
 func (r *ViewResult) Metadata() (*ViewMetadata, error) {
 	if r.streamResult != nil && !r.streamResult.Closed() {
@@ -291,6 +338,17 @@ func (r *ViewMetadata) Debug() interface{} {
 	return r.debug
 }
 
+// Stale returns whether the results may have been served from an index which was not up to date with the latest
+// mutations, which is the case unless the query used ViewScanConsistencyRequestPlus. With
+// ViewScanConsistencyUpdateAfter, or no scan consistency which the server treats the same, the index is updated
+// after the query so the data may still be updating.
+func (r *ViewMetadata) Stale() bool {
+	return r.stale
+}
+
+// viewServerTimeoutMargin is how much sooner than the client the server is told to time out a view query.
+const viewServerTimeoutMargin = 25 * time.Millisecond
+
 // ViewQuery performs a view query and returns a list of rows or an error.
 func (b *Bucket) ViewQuery(designDoc string, viewName string, opts *ViewOptions) (*ViewResult, error) {
 	startTime := time.Now()
@@ -331,6 +389,24 @@ func (b *Bucket) ViewQuery(designDoc string, viewName string, opts *ViewOptions)
 		return nil, errors.Wrap(err, "could not parse query options")
 	}
 
+	// The server is given slightly less time than we are so that it aborts the query, rather than carrying on with
+	// work that nobody is waiting for.
+	if urlValues.Get("connection_timeout") == "" {
+		deadline, _ := ctx.Deadline()
+		serverTimeout := time.Until(deadline) - viewServerTimeoutMargin
+		if serverTimeout < time.Millisecond {
+			serverTimeout = time.Millisecond
+		}
+		urlValues.Set("connection_timeout", strconv.FormatInt(int64(serverTimeout/time.Millisecond), 10))
+	}
+
+	// Large key lists can exceed URL length limits so they are sent in the request body instead.
+	body, err := opts.keysBody(urlValues)
+	if err != nil {
+		cancel()
+		return nil, errors.Wrap(err, "could not parse query options")
+	}
+
 	if opts.Serializer == nil {
 		opts.Serializer = b.sb.Serializer
 	}
@@ -340,7 +416,7 @@ func (b *Bucket) ViewQuery(designDoc string, viewName string, opts *ViewOptions)
 		wrapper = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
-	res, err := b.executeViewQuery(ctx, span.Context(), "_view", designDoc, viewName, *urlValues, provider, cancel,
+	res, err := b.executeViewQuery(ctx, span.Context(), "_view", designDoc, viewName, *urlValues, body, provider, cancel,
 		opts.Serializer, wrapper, startTime)
 	if err != nil {
 		cancel()
@@ -350,8 +426,60 @@ func (b *Bucket) ViewQuery(designDoc string, viewName string, opts *ViewOptions)
 	return res, nil
 }
 
+// nonJSONSnippetLen is the most of a non-JSON response body which is read to be reported in the error.
+const nonJSONSnippetLen = 256
+
+// checkJSONResponse checks that the first non-whitespace byte of the body of resp opens a JSON object or array and
+// returns a NonJSONResponseError if it doesn't. gocbcore doesn't expose the response headers so the content type is
+// detected from the body. Only as much of the body as the check needs is waited for, so a streamed response isn't
+// held up, the snippet is only read once the check has failed. The returned body must be read in place of resp.Body.
+func checkJSONResponse(resp *gocbcore.HttpResponse) (io.ReadCloser, error) {
+	reader := bufio.NewReaderSize(resp.Body, nonJSONSnippetLen)
+	body := struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}
+
+	for {
+		// An error here is either an empty body, which is left to be decoded as is, or will be seen again when the
+		// body is read.
+		first, err := reader.Peek(1)
+		if err != nil {
+			return body, nil
+		}
+
+		switch first[0] {
+		case ' ', '\t', '\r', '\n':
+			// Leading whitespace is insignificant to the JSON decoder so can be dropped.
+			_, err = reader.Discard(1)
+			if err != nil {
+				return body, nil
+			}
+			continue
+		case '{', '[':
+			return body, nil
+		}
+
+		break
+	}
+
+	// A short body is reported as is.
+	peeked, _ := reader.Peek(nonJSONSnippetLen)
+	err := resp.Body.Close()
+	if err != nil {
+		logDebugf("Failed to close socket (%s)", err)
+	}
+
+	return nil, nonJSONResponseError{
+		httpStatus:  resp.StatusCode,
+		contentType: http.DetectContentType(peeked),
+		snippet:     string(bytes.TrimSpace(peeked)),
+		endpoint:    resp.Endpoint,
+	}
+}
+
 func (b *Bucket) executeViewQuery(ctx context.Context, tracectx requestSpanContext, viewType, ddoc, viewName string,
-	options url.Values, provider httpProvider, cancel context.CancelFunc, serializer JSONSerializer,
+	options url.Values, body []byte, provider httpProvider, cancel context.CancelFunc, serializer JSONSerializer,
 	wrapper *retryStrategyWrapper, startTime time.Time) (*ViewResult, error) {
 	reqUri := fmt.Sprintf("/_design/%s/%s/%s?%s", ddoc, viewType, viewName, options.Encode())
 	req := &gocbcore.HttpRequest{
@@ -362,6 +490,15 @@ func (b *Bucket) executeViewQuery(ctx context.Context, tracectx requestSpanConte
 		IsIdempotent:  true,
 		RetryStrategy: wrapper,
 	}
+	if body != nil {
+		req.Method = "POST"
+		req.Body = body
+		req.ContentType = "application/json"
+
+		if b.sb.LogRequestBodies {
+			logRequestBody(LogFields{LogFieldService: "view", LogFieldBucket: b.sb.BucketName}, body)
+		}
+	}
 
 	dspan := b.sb.Tracer.StartSpan("dispatch", tracectx)
 	resp, err := provider.DoHttpRequest(req)
@@ -385,11 +522,20 @@ func (b *Bucket) executeViewQuery(ctx context.Context, tracectx requestSpanConte
 		return nil, err
 	}
 
+	resp.Body, err = checkJSONResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
 	queryResults := &ViewResult{
-		serializer: serializer,
-		startTime:  startTime,
-		endpoint:   resp.Endpoint,
+		serializer:      serializer,
+		startTime:       startTime,
+		endpoint:        resp.Endpoint,
+		httpStatus:      resp.StatusCode,
+		continueOnError: options.Get("on_error") == "continue",
 	}
+	// The server does not report whether the index was updated, only stale=false waits for it to be.
+	queryResults.metadata.stale = options.Get("stale") != "false"
 
 	if resp.StatusCode == 500 {
 		// We have to handle the views 500 case as a special case because the body can be of form [] or {}
@@ -434,12 +580,40 @@ func (b *Bucket) executeViewQuery(ctx context.Context, tracectx requestSpanConte
 			}
 
 			return nil, err
-		} else if t == '{' {
-			queryResults.streamResult = &streamingResult{
+		} else if delim == '{' {
+			streamResult := &streamingResult{
 				decoder:     decoder,
 				stream:      resp.Body,
 				attributeCb: queryResults.readAttribute,
 			}
+
+			err = streamResult.readAttributes()
+			if err != nil {
+				bodyErr := streamResult.Close()
+				if bodyErr != nil {
+					logDebugf("Failed to close socket (%s)", bodyErr.Error())
+				}
+
+				return nil, err
+			}
+
+			queryResults.streamResult = streamResult
+
+			if streamResult.HasRows() {
+				queryResults.ctx = ctx
+				queryResults.cancel = cancel
+				return queryResults, nil
+			}
+
+			bodyErr := streamResult.Close()
+			if bodyErr != nil {
+				logDebugf("Failed to close response body, %s", bodyErr.Error())
+			}
+
+			err = queryResults.makeError()
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		return queryResults, nil
@@ -3,8 +3,12 @@ package gocb
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -331,6 +335,72 @@ func TestViewQuery500Error(t *testing.T) {
 	}
 }
 
+func TestViewQueryNonJSONResponse(t *testing.T) {
+	body := "\n<html><head><title>Bad Gateway</title></head><body>upstream unavailable</body></html>"
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		testAssertViewQueryRequest(t, req)
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8092",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(body), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	bucket := testGetBucketForHTTP(provider, 10*time.Second)
+
+	_, err := bucket.ViewQuery("test", "test", nil)
+	if !IsNonJSONResponseError(err) {
+		t.Fatalf("Expected error to be non-JSON response but was %v", err)
+	}
+
+	jsonErr, ok := err.(NonJSONResponseError)
+	if !ok {
+		t.Fatalf("Expected error to be NonJSONResponseError but was %s", reflect.TypeOf(err).String())
+	}
+
+	if jsonErr.HTTPStatus() != 200 {
+		t.Fatalf("Expected error HTTP status to be 200 but was %d", jsonErr.HTTPStatus())
+	}
+
+	if !strings.HasPrefix(jsonErr.ContentType(), "text/html") {
+		t.Fatalf("Expected error content type to be text/html but was %s", jsonErr.ContentType())
+	}
+
+	if !strings.HasPrefix(jsonErr.Snippet(), "<html><head><title>Bad Gateway") {
+		t.Fatalf("Expected error snippet to be the start of the body but was %s", jsonErr.Snippet())
+	}
+}
+
+func TestViewQueryCheckJSONResponseDoesNotWaitForSnippet(t *testing.T) {
+	// The rest of the body is still being streamed, checking it must not wait for more than its first bytes.
+	stream := newTestBlockingReadCloser([]byte("\n  {\"total_rows\":"))
+	defer stream.Close()
+
+	resultCh := make(chan error, 1)
+	go func() {
+		_, err := checkJSONResponse(&gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8092",
+			StatusCode: 200,
+			Body:       stream,
+		})
+		resultCh <- err
+	}()
+
+	select {
+	case err := <-resultCh:
+		if err != nil {
+			t.Fatalf("Expected body to be accepted as JSON but was %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected check to not wait for the rest of the body")
+	}
+}
+
 func TestViewServiceNotFound(t *testing.T) {
 	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
 		return nil, gocbcore.ErrNoCapiService
@@ -396,6 +466,59 @@ func TestViewQueryTimeout(t *testing.T) {
 	}
 }
 
+func TestViewQueryServerTimeout(t *testing.T) {
+	timeout := 2 * time.Second
+
+	var connectionTimeout string
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		testAssertViewQueryRequest(t, req)
+
+		reqURL, err := url.Parse(req.Path)
+		if err != nil {
+			t.Fatalf("Failed to parse request path: %v", err)
+		}
+		connectionTimeout = reqURL.Query().Get("connection_timeout")
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8092",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(`{"total_rows":0,"rows":[]}`), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	bucket := testGetBucketForHTTP(provider, 50*time.Second)
+
+	res, err := bucket.ViewQuery("test", "test", &ViewOptions{
+		Timeout: timeout,
+	})
+	if err != nil {
+		t.Fatalf("Expected ViewQuery to succeed but was %v", err)
+	}
+
+	err = res.Close()
+	if err != nil {
+		t.Fatalf("Expected Close to succeed but was %v", err)
+	}
+
+	if connectionTimeout == "" {
+		t.Fatalf("Expected connection_timeout to be set")
+	}
+
+	ms, err := strconv.Atoi(connectionTimeout)
+	if err != nil {
+		t.Fatalf("Could not parse connection_timeout: %v", err)
+	}
+
+	dur := time.Duration(ms) * time.Millisecond
+	if dur >= timeout || dur < timeout-100*time.Millisecond {
+		t.Fatalf("Expected connection_timeout to be just under %s but was %s", timeout, dur)
+	}
+}
+
 func TestViewQueryContextTimeout(t *testing.T) {
 	timeout := 2000 * time.Millisecond
 	clusterTimeout := 50 * time.Second
@@ -514,6 +637,162 @@ func TestViewQueryClusterTimeoutNoTimeoutValue(t *testing.T) {
 	}
 }
 
+func TestViewQueryLargeKeysUsesPost(t *testing.T) {
+	var keys []interface{}
+	for i := 0; i < 500; i++ {
+		keys = append(keys, fmt.Sprintf("airline_%d", i))
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Method != "POST" {
+			t.Fatalf("Request method should have been POST but was %s", req.Method)
+		}
+
+		if strings.Contains(req.Path, "keys=") {
+			t.Fatalf("Request path should not have contained keys but was %s", req.Path)
+		}
+
+		var body struct {
+			Keys []interface{} `json:"keys"`
+		}
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal request body: %v", err)
+		}
+
+		if !reflect.DeepEqual(body.Keys, keys) {
+			t.Fatalf("Expected request body keys to be %v but was %v", keys, body.Keys)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8092",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString("{\"total_rows\":0,\"rows\":[]}"), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	bucket := testGetBucketForHTTP(provider, 10*time.Second)
+
+	res, err := bucket.ViewQuery("test", "test", &ViewOptions{
+		Keys: keys,
+	})
+	if err != nil {
+		t.Fatalf("Expected query to not return error but was %v", err)
+	}
+
+	err = res.Close()
+	if err != nil {
+		t.Fatalf("results close had error: %v", err)
+	}
+}
+
+func TestViewQuerySmallKeysUsesGet(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		testAssertViewQueryRequest(t, req)
+
+		if req.Body != nil {
+			t.Fatalf("Request body should have been nil but was %s", req.Body)
+		}
+
+		if !strings.Contains(req.Path, "keys=") {
+			t.Fatalf("Request path should have contained keys but was %s", req.Path)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8092",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString("{\"total_rows\":0,\"rows\":[]}"), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	bucket := testGetBucketForHTTP(provider, 10*time.Second)
+
+	res, err := bucket.ViewQuery("test", "test", &ViewOptions{
+		Keys: []interface{}{"airline_1", "airline_2"},
+	})
+	if err != nil {
+		t.Fatalf("Expected query to not return error but was %v", err)
+	}
+
+	err = res.Close()
+	if err != nil {
+		t.Fatalf("results close had error: %v", err)
+	}
+}
+
+func TestViewQueryStaleMetadata(t *testing.T) {
+	type tCase struct {
+		name        string
+		opts        ViewOptions
+		expectStale string
+		stale       bool
+	}
+
+	testCases := []tCase{
+		{name: "unset", opts: ViewOptions{}, expectStale: "", stale: true},
+		{name: "not bounded", opts: ViewOptions{ScanConsistency: ViewScanConsistencyNotBounded}, expectStale: "ok",
+			stale: true},
+		{name: "request plus", opts: ViewOptions{ScanConsistency: ViewScanConsistencyRequestPlus},
+			expectStale: "false", stale: false},
+		{name: "update after", opts: ViewOptions{ScanConsistency: ViewScanConsistencyUpdateAfter},
+			expectStale: "update_after", stale: true},
+		{name: "raw", opts: ViewOptions{Raw: map[string]string{"stale": "false"}}, expectStale: "false",
+			stale: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+				testAssertViewQueryRequest(t, req)
+
+				reqURL, err := url.Parse(req.Path)
+				if err != nil {
+					t.Fatalf("Failed to parse request path: %v", err)
+				}
+				if stale := reqURL.Query().Get("stale"); stale != tc.expectStale {
+					t.Fatalf("Expected stale to be %s but was %s", tc.expectStale, stale)
+				}
+
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8092",
+					StatusCode: 200,
+					Body:       &testReadCloser{bytes.NewBufferString("{\"total_rows\":0,\"rows\":[]}"), nil},
+				}, nil
+			}
+
+			bucket := testGetBucketForHTTP(&mockHTTPProvider{doFn: doHTTP}, 10*time.Second)
+
+			opts := tc.opts
+			res, err := bucket.ViewQuery("test", "test", &opts)
+			if err != nil {
+				t.Fatalf("Expected query to not return error but was %v", err)
+			}
+
+			err = res.Close()
+			if err != nil {
+				t.Fatalf("results close had error: %v", err)
+			}
+
+			metadata, err := res.Metadata()
+			if err != nil {
+				t.Fatalf("Expected metadata to not return error but was %v", err)
+			}
+
+			if metadata.Stale() != tc.stale {
+				t.Fatalf("Expected stale to be %t but was %t", tc.stale, metadata.Stale())
+			}
+		})
+	}
+}
+
 func testAssertViewQueryRequest(t *testing.T, req *gocbcore.HttpRequest) {
 	if req.Service != gocbcore.CapiService {
 		t.Fatalf("Service should have been QueryService but was %d", req.Service)
@@ -549,3 +828,237 @@ func testGetBucketForHTTP(provider *mockHTTPProvider, viewTimeout time.Duration)
 
 	return b
 }
+
+func TestViewQueryPartialErrors(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("view_partial_errors")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	expectedErrors := []ViewError{
+		{Node: "10.112.191.102:8092", Reason: "timeout"},
+		{Node: "10.112.191.103:8092", Reason: "{not_found, missing_named_view}"},
+	}
+
+	for _, mode := range []ViewErrorMode{0, ViewErrorModeContinue, ViewErrorModeStop} {
+		t.Run(fmt.Sprintf("%d", mode), func(t *testing.T) {
+			doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+				testAssertViewQueryRequest(t, req)
+
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8092",
+					StatusCode: 200,
+					Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+				}, nil
+			}
+
+			bucket := testGetBucketForHTTP(&mockHTTPProvider{doFn: doHTTP}, 50*time.Second)
+
+			res, err := bucket.ViewQuery("test", "test", &ViewOptions{
+				OnError: mode,
+			})
+			if err != nil {
+				t.Fatalf("Expected ViewQuery to succeed but was %v", err)
+			}
+
+			var ids []string
+			var row ViewRow
+			for res.Next(&row) {
+				ids = append(ids, row.ID)
+			}
+
+			err = res.Close()
+			if mode == ViewErrorModeContinue && err != nil {
+				t.Fatalf("Expected Close to succeed when continuing on error but was %v", err)
+			}
+			if mode != ViewErrorModeContinue {
+				vErr, ok := err.(ViewQueryErrors)
+				if !ok {
+					t.Fatalf("Expected Close to return ViewQueryErrors when not continuing on error but was %v", err)
+				}
+
+				if len(vErr.Errors()) != len(expectedErrors) {
+					t.Fatalf("Expected %d errors but was %d", len(expectedErrors), len(vErr.Errors()))
+				}
+
+				for i, expected := range expectedErrors {
+					if vErr.Errors()[i].Node() != expected.Node || vErr.Errors()[i].Reason() != expected.Reason {
+						t.Fatalf("Expected error %d to be from %s with reason %s but was %v", i, expected.Node,
+							expected.Reason, vErr.Errors()[i])
+					}
+
+					if vErr.Errors()[i].Message() != "" {
+						t.Fatalf("Expected error %d to have no message but was %s", i, vErr.Errors()[i].Message())
+					}
+				}
+			}
+
+			if len(ids) != 2 {
+				t.Fatalf("Expected 2 rows but was %v", ids)
+			}
+
+			errs := res.Errors()
+			if len(errs) != len(expectedErrors) {
+				t.Fatalf("Expected %d errors but was %d", len(expectedErrors), len(errs))
+			}
+			for i, expected := range expectedErrors {
+				if errs[i] != expected {
+					t.Fatalf("Expected error %d to be %v but was %v", i, expected, errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestViewQueryReduceError(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("view_reduce_error")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		testAssertViewQueryRequest(t, req)
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8092",
+			StatusCode: 500,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	bucket := testGetBucketForHTTP(&mockHTTPProvider{doFn: doHTTP}, 50*time.Second)
+
+	_, err = bucket.ViewQuery("test", "test", nil)
+	if err == nil {
+		t.Fatalf("Expected query to return error")
+	}
+
+	queryErrs, ok := err.(ViewQueryErrors)
+	if !ok {
+		t.Fatalf("Expected error to be ViewQueryErrors but was %s", reflect.TypeOf(err).String())
+	}
+
+	if queryErrs.HTTPStatus() != 500 {
+		t.Fatalf("Expected error HTTP status to be 500 but was %d", queryErrs.HTTPStatus())
+	}
+
+	if len(queryErrs.Errors()) != 1 {
+		t.Fatalf("Expected errors to contain 1 error but contained %d", len(queryErrs.Errors()))
+	}
+
+	queryErr := queryErrs.Errors()[0]
+	if queryErr.Message() != "reduce_error" {
+		t.Fatalf("Expected error message to be reduce_error but was %s", queryErr.Message())
+	}
+
+	if queryErr.Phase() != ViewErrorPhaseReduce {
+		t.Fatalf("Expected error phase to be reduce but was %s", queryErr.Phase())
+	}
+}
+
+func TestViewErrorPhase(t *testing.T) {
+	type tCase struct {
+		message  string
+		reason   string
+		expected ViewErrorPhase
+	}
+
+	testCases := []tCase{
+		{message: "reduce_error", reason: "ReferenceError: total is not defined", expected: ViewErrorPhaseReduce},
+		{message: "error", reason: "{reduce_overflow_error, <<\"reduction too large\">>}", expected: ViewErrorPhaseReduce},
+		{message: "map_function_error", reason: "TypeError: doc.name is undefined", expected: ViewErrorPhaseMap},
+		{message: "error", reason: "function raised exception (emit called with invalid key)", expected: ViewErrorPhaseMap},
+		{message: "not_found", reason: "missing", expected: ViewErrorPhaseUnknown},
+	}
+
+	for _, tc := range testCases {
+		err := viewError{ErrorMessage: tc.message, ErrorReason: tc.reason}
+		if err.Phase() != tc.expected {
+			t.Fatalf("Expected phase of %s to be %q but was %q", err.Error(), tc.expected, err.Phase())
+		}
+	}
+
+	nodeErr := ViewError{Node: "10.112.191.102:8092", Reason: "{reduce_overflow_error, too large}"}
+	if nodeErr.Phase() != ViewErrorPhaseReduce {
+		t.Fatalf("Expected node error phase to be reduce but was %q", nodeErr.Phase())
+	}
+}
+
+func TestViewQueryRowNonPointerTarget(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8092",
+			StatusCode: 200,
+			Body: &testReadCloser{
+				bytes.NewBufferString(`{"total_rows":1,"rows":[{"id":"beer","key":"beer","value":{"abv":5}}]}`), nil},
+		}, nil
+	}
+
+	bucket := testGetBucketForHTTP(&mockHTTPProvider{doFn: doHTTP}, 10*time.Second)
+
+	res, err := bucket.ViewQuery("test", "test", &ViewOptions{Serializer: &DefaultJSONSerializer{}})
+	if err != nil {
+		t.Fatalf("Expected query to not return error but was %v", err)
+	}
+
+	var row ViewRow
+	if !res.Next(&row) {
+		t.Fatalf("Expected a row but Next failed: %v", res.Close())
+	}
+
+	var value map[string]int
+	err = row.Value(value)
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+
+	err = row.Value(&value)
+	if err != nil {
+		t.Fatalf("Expected decoding into a pointer to succeed but was %v", err)
+	}
+
+	if value["abv"] != 5 {
+		t.Fatalf("Expected abv to be 5 but was %v", value)
+	}
+
+	if res.Next(nil) {
+		t.Fatalf("Expected Next to fail with a nil row")
+	}
+
+	err = res.Close()
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
+func TestViewResultDrain(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8092",
+			StatusCode: 200,
+			Body: &testReadCloser{bytes.NewBufferString(`{"total_rows":3,"rows":[{"id":"a","key":"a","value":1},` +
+				`{"id":"b","key":"b","value":2},{"id":"c","key":"c","value":3}]}`), nil},
+		}, nil
+	}
+
+	bucket := testGetBucketForHTTP(&mockHTTPProvider{doFn: doHTTP}, 10*time.Second)
+
+	res, err := bucket.ViewQuery("test", "test", nil)
+	if err != nil {
+		t.Fatalf("Expected query to not return error but was %v", err)
+	}
+
+	err = res.Drain()
+	if err != nil {
+		t.Fatalf("Expected Drain to succeed but was %v", err)
+	}
+
+	metadata, err := res.Metadata()
+	if err != nil {
+		t.Fatalf("Expected metadata to not return error but was %v", err)
+	}
+
+	if metadata.TotalRows() != 3 {
+		t.Fatalf("Expected total rows to be 3 but was %d", metadata.TotalRows())
+	}
+}
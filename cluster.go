@@ -20,8 +20,12 @@ type Cluster struct {
 	connections     map[string]client
 	clusterClient   client
 
-	clusterLock sync.RWMutex
-	queryCache  map[string]*n1qlCache
+	clusterLock         sync.RWMutex
+	queryCache          map[string]*n1qlCache
+	analyticsQueryCache map[string]string
+
+	signatureCache *signatureCache
+	configWatcher  *configWatcher
 
 	sb stateBlock
 
@@ -46,7 +50,9 @@ type ClusterOptions struct {
 	// will default to DefaultJSONSerializer. NOTE: This is entirely independent of Transcoder.
 	Serializer            JSONSerializer
 	DisableMutationTokens bool
-	RetryStrategy         RetryStrategy
+	// RetryStrategy is used by all operations which do not specify their own, including those performed by the
+	// bucket, user, view and index managers. This will default to a BestEffortRetryStrategy.
+	RetryStrategy RetryStrategy
 
 	// Orphan logging records when the SDK receives responses for requests that are no longer in the system (usually
 	// due to being timed out).
@@ -58,6 +64,15 @@ type ClusterOptions struct {
 	ThresholdLoggingOptions *ThresholdLoggingOptions
 
 	CircuitBreakerConfig CircuitBreakerConfig
+
+	// SignatureCacheSize is the number of query and analytics statements for which the result signature is cached,
+	// so that repeated runs of a statement skip parsing it. If zero then signatures are not cached.
+	SignatureCacheSize int
+
+	// LogRequestBodies causes the bodies of query, analytics and view requests to be logged, pretty printed, at
+	// debug level. Credentials within the bodies are removed and the rest is redacted according to the log
+	// redaction level. This is intended for debugging only.
+	LogRequestBodies bool
 }
 
 // ClusterCloseOptions is the set of options available when disconnecting from a Cluster.
@@ -92,6 +107,8 @@ type ClusterCloseOptions struct {
 //   orphaned_response_logging (bool) - Whether to enable orphan response logging.
 //   orphaned_response_logging_interval (int) - How often to log orphan responses in ms.
 //   orphaned_response_logging_sample_size (int) - The number of samples to include in each orphaned response log.
+// The timeouts kv_timeout, query_timeout, analytics_timeout, search_timeout, view_timeout and management_timeout
+// can also be set, overriding those in opts, either as a duration such as 5s or as a number of milliseconds.
 func Connect(connStr string, opts ClusterOptions) (*Cluster, error) {
 	connSpec, err := gocbconnstr.Parse(connStr)
 	if err != nil {
@@ -174,9 +191,16 @@ func Connect(connStr string, opts ClusterOptions) (*Cluster, error) {
 			UseServerDurations:     useServerDurations,
 			Tracer:                 initialTracer,
 			CircuitBreakerConfig:   opts.CircuitBreakerConfig,
+			ActiveRequests:         newActiveRequestRegistry(),
+			LogRequestBodies:       opts.LogRequestBodies,
 		},
 
-		queryCache: make(map[string]*n1qlCache),
+		queryCache:          make(map[string]*n1qlCache),
+		analyticsQueryCache: make(map[string]string),
+	}
+
+	if opts.SignatureCacheSize > 0 {
+		cluster.signatureCache = newSignatureCache(opts.SignatureCacheSize)
 	}
 
 	err = cluster.parseExtraConnStrOptions(connSpec)
@@ -203,6 +227,8 @@ func Connect(connStr string, opts ClusterOptions) (*Cluster, error) {
 	return cluster, nil
 }
 
+// parseExtraConnStrOptions applies the connection string options which are handled by gocb rather than gocbcore,
+// any other options are left for gocbcore.
 func (c *Cluster) parseExtraConnStrOptions(spec gocbconnstr.ConnSpec) error {
 	fetchOption := func(name string) (string, bool) {
 		optValue := spec.Options[name]
@@ -212,39 +238,56 @@ func (c *Cluster) parseExtraConnStrOptions(spec gocbconnstr.ConnSpec) error {
 		return optValue[len(optValue)-1], true
 	}
 
-	if valStr, ok := fetchOption("n1ql_timeout"); ok {
-		val, err := strconv.ParseInt(valStr, 10, 64)
-		if err != nil {
-			return fmt.Errorf("n1ql_timeout option must be a number")
+	// n1ql_timeout is the original name of query_timeout, query_timeout takes precedence if both are set.
+	timeoutOptions := []struct {
+		name    string
+		timeout *time.Duration
+	}{
+		{"kv_timeout", &c.sb.KvTimeout},
+		{"n1ql_timeout", &c.sb.QueryTimeout},
+		{"query_timeout", &c.sb.QueryTimeout},
+		{"analytics_timeout", &c.sb.AnalyticsTimeout},
+		{"search_timeout", &c.sb.SearchTimeout},
+		{"view_timeout", &c.sb.ViewTimeout},
+		{"management_timeout", &c.sb.ManagementTimeout},
+	}
+
+	for _, opt := range timeoutOptions {
+		valStr, ok := fetchOption(opt.name)
+		if !ok {
+			continue
 		}
-		c.sb.QueryTimeout = time.Duration(val) * time.Millisecond
-	}
 
-	if valStr, ok := fetchOption("analytics_timeout"); ok {
-		val, err := strconv.ParseInt(valStr, 10, 64)
+		val, err := parseConnStrDuration(valStr)
 		if err != nil {
-			return fmt.Errorf("analytics_timeout option must be a number")
+			return fmt.Errorf("%s option must be a duration, e.g. 2500ms or 5s, or a number of milliseconds", opt.name)
 		}
-		c.sb.AnalyticsTimeout = time.Duration(val) * time.Millisecond
+		*opt.timeout = val
 	}
 
-	if valStr, ok := fetchOption("search_timeout"); ok {
-		val, err := strconv.ParseInt(valStr, 10, 64)
-		if err != nil {
-			return fmt.Errorf("search_timeout option must be a number")
+	return nil
+}
+
+// parseConnStrDuration parses a duration from a connection string option, which is either a Go duration string or
+// a number of milliseconds.
+func parseConnStrDuration(valStr string) (time.Duration, error) {
+	val, err := strconv.ParseInt(valStr, 10, 64)
+	if err == nil {
+		if val < 0 {
+			return 0, fmt.Errorf("duration must not be negative")
 		}
-		c.sb.SearchTimeout = time.Duration(val) * time.Millisecond
+		return time.Duration(val) * time.Millisecond, nil
 	}
 
-	if valStr, ok := fetchOption("view_timeout"); ok {
-		val, err := strconv.ParseInt(valStr, 10, 64)
-		if err != nil {
-			return fmt.Errorf("view_timeout option must be a number")
-		}
-		c.sb.ViewTimeout = time.Duration(val) * time.Millisecond
+	duration, err := time.ParseDuration(valStr)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("duration must not be negative")
 	}
 
-	return nil
+	return duration, nil
 }
 
 // Bucket connects the cluster to server(s) and returns a new Bucket instance.
@@ -253,6 +296,7 @@ func (c *Cluster) Bucket(bucketName string, opts *BucketOptions) *Bucket {
 		opts = &BucketOptions{}
 	}
 	b := newBucket(&c.sb, bucketName, *opts)
+	b.sb.executeQuery = c.query
 	cli := c.takeClusterClient()
 	if cli == nil {
 		// We've already taken the cluster client for a different bucket or something like that so
@@ -354,6 +398,10 @@ func (c *Cluster) Close(opts *ClusterCloseOptions) error {
 	var overallErr error
 
 	c.clusterLock.Lock()
+	if c.configWatcher != nil {
+		c.configWatcher.close()
+		c.configWatcher = nil
+	}
 	for key, conn := range c.connections {
 		err := conn.close()
 		if err != nil && gocbcore.ErrorCause(err) != gocbcore.ErrShutdown {
@@ -457,6 +505,12 @@ func (c *Cluster) setSupportsEnhancedPreparedStatements(supports bool) {
 	}
 }
 
+// ActiveRequests returns information about the analytics and management requests which are currently in-flight.
+// Volatile: This API is subject to change at any time.
+func (c *Cluster) ActiveRequests() []RequestInfo {
+	return c.sb.ActiveRequests.snapshot()
+}
+
 // Users returns a UserManager for managing users.
 // Volatile: This API is subject to change at any time.
 func (c *Cluster) Users() (*UserManager, error) {
@@ -466,7 +520,7 @@ func (c *Cluster) Users() (*UserManager, error) {
 	}
 
 	return &UserManager{
-		httpClient:           provider,
+		httpClient:           c.sb.ActiveRequests.wrapHTTPProvider(provider, "mgmt"),
 		globalTimeout:        c.sb.ManagementTimeout,
 		defaultRetryStrategy: c.sb.RetryStrategyWrapper,
 		tracer:               c.sb.Tracer,
@@ -482,7 +536,7 @@ func (c *Cluster) Buckets() (*BucketManager, error) {
 	}
 
 	return &BucketManager{
-		httpClient:           provider,
+		httpClient:           c.sb.ActiveRequests.wrapHTTPProvider(provider, "mgmt"),
 		globalTimeout:        c.sb.ManagementTimeout,
 		defaultRetryStrategy: c.sb.RetryStrategyWrapper,
 		tracer:               c.sb.Tracer,
@@ -497,7 +551,7 @@ func (c *Cluster) AnalyticsIndexes() (*AnalyticsIndexManager, error) {
 		return nil, err
 	}
 	return &AnalyticsIndexManager{
-		httpClient:           provider,
+		httpClient:           c.sb.ActiveRequests.wrapHTTPProvider(provider, "mgmt"),
 		executeQuery:         c.analyticsQuery,
 		globalTimeout:        c.sb.ManagementTimeout,
 		defaultRetryStrategy: c.sb.RetryStrategyWrapper,
@@ -508,7 +562,12 @@ func (c *Cluster) AnalyticsIndexes() (*AnalyticsIndexManager, error) {
 // QueryIndexes returns a QueryIndexManager for managing N1QL indexes.
 // Volatile: This API is subject to change at any time.
 func (c *Cluster) QueryIndexes() (*QueryIndexManager, error) {
+	provider, err := c.getHTTPProvider()
+	if err != nil {
+		return nil, err
+	}
 	return &QueryIndexManager{
+		httpClient:           c.sb.ActiveRequests.wrapHTTPProvider(provider, "mgmt"),
 		executeQuery:         c.query,
 		globalTimeout:        c.sb.ManagementTimeout,
 		defaultRetryStrategy: c.sb.RetryStrategyWrapper,
@@ -516,6 +575,21 @@ func (c *Cluster) QueryIndexes() (*QueryIndexManager, error) {
 	}, nil
 }
 
+// QuerySettings returns a QuerySettingsManager for managing the settings of the query service.
+// Volatile: This API is subject to change at any time.
+func (c *Cluster) QuerySettings() (*QuerySettingsManager, error) {
+	provider, err := c.getHTTPProvider()
+	if err != nil {
+		return nil, err
+	}
+	return &QuerySettingsManager{
+		httpClient:           c.sb.ActiveRequests.wrapHTTPProvider(provider, "mgmt"),
+		globalTimeout:        c.sb.ManagementTimeout,
+		defaultRetryStrategy: c.sb.RetryStrategyWrapper,
+		tracer:               c.sb.Tracer,
+	}, nil
+}
+
 // SearchIndexes returns a SearchIndexManager for managing Search indexes.
 // Volatile: This API is subject to change at any time.
 func (c *Cluster) SearchIndexes() (*SearchIndexManager, error) {
@@ -524,7 +598,7 @@ func (c *Cluster) SearchIndexes() (*SearchIndexManager, error) {
 		return nil, err
 	}
 	return &SearchIndexManager{
-		httpClient:           provider,
+		httpClient:           c.sb.ActiveRequests.wrapHTTPProvider(provider, "mgmt"),
 		globalTimeout:        c.sb.ManagementTimeout,
 		defaultRetryStrategy: c.sb.RetryStrategyWrapper,
 		tracer:               c.sb.Tracer,
@@ -280,6 +280,9 @@ type GetAllAnalyticsDatasetsOptions struct {
 	Timeout       time.Duration
 	Context       context.Context
 	RetryStrategy RetryStrategy
+
+	// IncludeMetadata includes the system datasets of the Metadata dataverse in the results.
+	IncludeMetadata bool
 }
 
 // GetAllDatasets gets all analytics datasets.
@@ -298,9 +301,12 @@ func (am *AnalyticsIndexManager) GetAllDatasets(opts *GetAllAnalyticsDatasetsOpt
 		defer cancel()
 	}
 
-	result, err := am.executeQuery(span.Context(),
-		"SELECT d.* FROM Metadata.`Dataset` d WHERE d.DataverseName <> \"Metadata\"",
-		startTime,
+	statement := "SELECT d.* FROM Metadata.`Dataset` d"
+	if !opts.IncludeMetadata {
+		statement += " WHERE d.DataverseName <> \"Metadata\""
+	}
+
+	result, err := am.executeQuery(span.Context(), statement, startTime,
 		&AnalyticsOptions{
 			Context:       ctx,
 			ReadOnly:      true,
@@ -319,8 +325,11 @@ func (am *AnalyticsIndexManager) GetAllDatasets(opts *GetAllAnalyticsDatasetsOpt
 	}
 
 	var datasets []AnalyticsDataset
-	var dataset AnalyticsDataset
-	for result.Next(&dataset) {
+	for {
+		var dataset AnalyticsDataset
+		if !result.Next(&dataset) {
+			break
+		}
 		datasets = append(datasets, dataset)
 	}
 
@@ -645,7 +654,7 @@ func (am *AnalyticsIndexManager) GetPendingMutations(opts *GetPendingMutationsAn
 	}
 
 	retryStrategy := am.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
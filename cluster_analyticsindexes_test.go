@@ -1,6 +1,12 @@
 package gocb
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
 
 func TestAnalyticsIndexesCrud(t *testing.T) {
 	if !globalCluster.SupportsFeature(AnalyticsIndexFeature) {
@@ -202,3 +208,64 @@ func TestAnalyticsIndexesCrud(t *testing.T) {
 		t.Fatalf("Expected error to be dataverse not found but was %v", err)
 	}
 }
+
+func TestAnalyticsIndexManagerGetAllDatasets(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("analytics_datasets")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	var statements []string
+	mgr := &AnalyticsIndexManager{
+		executeQuery: func(tracectx requestSpanContext, statement string, startTime time.Time,
+			opts *AnalyticsOptions) (*AnalyticsResult, error) {
+			statements = append(statements, statement)
+
+			result := &AnalyticsResult{
+				serializer: &DefaultJSONSerializer{},
+				ctx:        context.Background(),
+			}
+
+			streamResult, err := newStreamingResults(&testReadCloser{bytes.NewBuffer(dataBytes), nil}, result.readAttribute)
+			if err != nil {
+				t.Fatalf("Failed to create streaming results: %v", err)
+			}
+
+			err = streamResult.readAttributes()
+			if err != nil {
+				t.Fatalf("Failed to read attributes: %v", err)
+			}
+			result.streamResult = streamResult
+
+			return result, nil
+		},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	datasets, err := mgr.GetAllDatasets(nil)
+	if err != nil {
+		t.Fatalf("Expected GetAllDatasets to succeed but was %v", err)
+	}
+
+	expected := []AnalyticsDataset{
+		{Name: "breweries", DataverseName: "Default", LinkName: "Local", BucketName: "beer-sample"},
+		{Name: "airports", DataverseName: "travel", LinkName: "Local", BucketName: "travel-sample"},
+	}
+	if !reflect.DeepEqual(datasets, expected) {
+		t.Fatalf("Expected datasets to be %v but was %v", expected, datasets)
+	}
+
+	_, err = mgr.GetAllDatasets(&GetAllAnalyticsDatasetsOptions{IncludeMetadata: true})
+	if err != nil {
+		t.Fatalf("Expected GetAllDatasets to succeed but was %v", err)
+	}
+
+	expectedStatements := []string{
+		"SELECT d.* FROM Metadata.`Dataset` d WHERE d.DataverseName <> \"Metadata\"",
+		"SELECT d.* FROM Metadata.`Dataset` d",
+	}
+	if !reflect.DeepEqual(statements, expectedStatements) {
+		t.Fatalf("Expected statements to be %v but was %v", expectedStatements, statements)
+	}
+}
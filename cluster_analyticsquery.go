@@ -1,9 +1,13 @@
 package gocb
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"io"
 	"net/url"
+	"sort"
 	"strconv"
 	"time"
 
@@ -75,10 +79,13 @@ type AnalyticsResult struct {
 
 	streamResult *streamingResult
 	cancel       context.CancelFunc
+	deregister   func()
 	httpProvider httpProvider
 	ctx          context.Context
 
-	serializer JSONSerializer
+	serializer     JSONSerializer
+	signatureCache *signatureCache
+	signatureKey   string
 }
 
 // Next assigns the next result from the results into the value pointer, returning whether the read was successful.
@@ -92,7 +99,7 @@ func (r *AnalyticsResult) Next(valuePtr interface{}) bool {
 		return false
 	}
 
-	r.err = r.serializer.Deserialize(row, valuePtr)
+	r.err = decodeRow(r.serializer, row, valuePtr)
 	if r.err != nil {
 		return false
 	}
@@ -115,17 +122,32 @@ func (r *AnalyticsResult) NextBytes() []byte {
 	return raw
 }
 
+// Raw returns the undecoded response body so that it can be passed straight through, e.g. to an HTTP client,
+// without decoding each row. Raw cannot be combined with Next, NextBytes or One, and Metadata is not available
+// once it has been called. Closing the returned reader closes the result.
+func (r *AnalyticsResult) Raw() (io.ReadCloser, error) {
+	raw, err := r.streamResult.Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	return &rawResultReader{Reader: raw, closeFn: r.Close}, nil
+}
+
 // Close marks the results as closed, returning any errors that occurred during reading the results.
 func (r *AnalyticsResult) Close() error {
 	if r.streamResult.Closed() {
 		return r.err
 	}
 
-	err := r.streamResult.Close()
+	err := r.streamResult.closeWithContext(r.ctx)
 	ctxErr := r.ctx.Err()
 	if r.cancel != nil {
 		r.cancel()
 	}
+	if r.deregister != nil {
+		r.deregister()
+	}
 	if ctxErr == context.DeadlineExceeded {
 		return timeoutError{
 			operationID: r.metadata.clientContextID,
@@ -157,6 +179,11 @@ func (r *AnalyticsResult) Close() error {
 // of, at most, length 1.
 func (r *AnalyticsResult) One(valuePtr interface{}) error {
 	if !r.Next(valuePtr) {
+		// Next may have stopped on a failed row rather than the end of the
+		// rows, purge whatever is left so that the metadata is still read.
+		for r.NextBytes() != nil {
+		}
+
 		err := r.Close()
 		if err != nil {
 			return err
@@ -177,11 +204,203 @@ func (r *AnalyticsResult) One(valuePtr interface{}) error {
 	return nil
 }
 
+// CSVExportOptions are the options available to AnalyticsResult WriteCSV.
+type CSVExportOptions struct {
+	// Columns are the fields to write, in order. If not set then the fields named in the result signature are used,
+	// or if the signature does not name them then the fields of the first row in the order that they appear.
+	Columns []string
+	// Comma is the field delimiter, e.g. '\t' for TSV. Defaults to ','.
+	Comma rune
+	// OmitHeader specifies whether to skip writing the header line of column names.
+	OmitHeader bool
+}
+
+// WriteCSV streams each of the remaining rows to w as a line of CSV, without decoding them into Go values. The
+// results are expected to have a flat schema, any nested object or array values are written as JSON within the
+// cell and missing or null values are written as empty cells. The results are closed once all rows are written.
+func (r *AnalyticsResult) WriteCSV(w io.Writer, opts *CSVExportOptions) error {
+	if opts == nil {
+		opts = &CSVExportOptions{}
+	}
+
+	csvWriter := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		csvWriter.Comma = opts.Comma
+	}
+
+	columns := opts.Columns
+	if columns == nil {
+		columns = analyticsSignatureColumns(r.metadata.signature)
+	}
+
+	wroteHeader := opts.OmitHeader
+	for row := r.NextBytes(); row != nil; row = r.NextBytes() {
+		if columns == nil {
+			var err error
+			columns, err = jsonObjectKeys(row)
+			if err != nil {
+				r.purgeAndClose()
+				return err
+			}
+		}
+
+		if !wroteHeader {
+			err := csvWriter.Write(columns)
+			if err != nil {
+				r.purgeAndClose()
+				return err
+			}
+			wroteHeader = true
+		}
+
+		record, err := csvRecordFromRow(row, columns)
+		if err != nil {
+			r.purgeAndClose()
+			return err
+		}
+
+		err = csvWriter.Write(record)
+		if err != nil {
+			r.purgeAndClose()
+			return err
+		}
+	}
+
+	if !wroteHeader && columns != nil {
+		err := csvWriter.Write(columns)
+		if err != nil {
+			r.purgeAndClose()
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		r.purgeAndClose()
+		return err
+	}
+
+	return r.Close()
+}
+
+func (r *AnalyticsResult) purgeAndClose() {
+	for r.NextBytes() != nil {
+	}
+
+	err := r.Close()
+	if err != nil {
+		logDebugf("Failed to close analytics result (%s)", err)
+	}
+}
+
+// analyticsSignatureColumns returns the field names from a result signature, or nil if the signature does not
+// name the fields, e.g. {"*":"*"}.
+func analyticsSignatureColumns(signature interface{}) []string {
+	fields, ok := signature.(map[string]interface{})
+	if !ok || len(fields) == 0 {
+		return nil
+	}
+
+	if _, ok := fields["*"]; ok {
+		return nil
+	}
+
+	columns := make([]string, 0, len(fields))
+	for field := range fields {
+		columns = append(columns, field)
+	}
+	sort.Strings(columns)
+
+	return columns
+}
+
+// jsonObjectKeys returns the keys of a JSON object in the order that they appear.
+func jsonObjectKeys(data []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	t, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '{' {
+		return nil, clientError{message: "expected row to be a JSON object"}
+	}
+
+	var keys []string
+	for decoder.More() {
+		t, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, t.(string))
+
+		var ignore json.RawMessage
+		err = decoder.Decode(&ignore)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+func csvRecordFromRow(row []byte, columns []string) ([]string, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(row, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		value, ok := fields[column]
+		if !ok {
+			continue
+		}
+
+		value = bytes.TrimSpace(value)
+		switch {
+		case bytes.Equal(value, []byte("null")):
+		case len(value) > 0 && value[0] == '"':
+			var str string
+			err := json.Unmarshal(value, &str)
+			if err != nil {
+				return nil, err
+			}
+			record[i] = str
+		case len(value) > 0 && (value[0] == '{' || value[0] == '['):
+			var buf bytes.Buffer
+			err := json.Compact(&buf, value)
+			if err != nil {
+				return nil, err
+			}
+			record[i] = buf.String()
+		default:
+			record[i] = string(value)
+		}
+	}
+
+	return record, nil
+}
+
+// Drain reads and discards any remaining rows so that the metadata is fully populated, then closes the results,
+// returning any error that occurred whilst streaming them. This is useful for statements which are only run for
+// their side effects, such as creating a dataset.
+func (r *AnalyticsResult) Drain() error {
+	for r.NextBytes() != nil {
+	}
+
+	return r.Close()
+}
+
 // Metadata returns metadata for this result.
 func (r *AnalyticsResult) Metadata() (*AnalyticsMetadata, error) {
 	if !r.streamResult.Closed() {
 		return nil, clientError{message: "result must be closed before accessing meta-data"}
 	}
+	if r.streamResult.raw {
+		return nil, clientError{message: "meta-data is not available once the raw response has been requested"}
+	}
 
 	return &r.metadata, nil
 }
@@ -216,6 +435,23 @@ func (r *AnalyticsMetadata) ClientContextID() string {
 	return r.clientContextID
 }
 
+// makeAnalyticsStatusError attaches the response status to an error from the analytics service, as the status
+// decides whether or not the request can be retried. Errors for requests with a fatal status become
+// analyticsFatalError.
+func makeAnalyticsStatusError(err error, status string) error {
+	aErr, ok := err.(analyticsQueryError)
+	if !ok {
+		return err
+	}
+
+	aErr.status = status
+	if status == "fatal" {
+		return analyticsFatalError{analyticsQueryError: aErr}
+	}
+
+	return aErr
+}
+
 func (r *AnalyticsResult) readAttribute(decoder *json.Decoder, t json.Token) (bool, error) {
 	switch t {
 	case "requestID":
@@ -286,7 +522,7 @@ func (r *AnalyticsResult) readAttribute(decoder *json.Decoder, t json.Token) (bo
 			return false, err
 		}
 	case "signature":
-		err := decoder.Decode(&r.metadata.signature)
+		_, err := r.signatureCache.decode(decoder, r.signatureKey, &r.metadata.signature)
 		if err != nil {
 			return false, err
 		}
@@ -320,6 +556,9 @@ func (c *Cluster) AnalyticsQuery(statement string, opts *AnalyticsOptions) (*Ana
 	return c.analyticsQuery(span.Context(), statement, startTime, opts)
 }
 
+// analyticsServerTimeoutDelta is how much longer than the client deadline an analytics request is given server side.
+const analyticsServerTimeoutDelta = 25 * time.Millisecond
+
 func (c *Cluster) analyticsQuery(tracectx requestSpanContext, statement string, startTime time.Time,
 	opts *AnalyticsOptions) (*AnalyticsResult, error) {
 
@@ -333,31 +572,15 @@ func (c *Cluster) analyticsQuery(tracectx requestSpanContext, statement string,
 		return nil, errors.Wrap(err, "could not parse query options")
 	}
 
-	timeout := c.sb.AnalyticsTimeout
-	tmostr, castok := queryOpts["timeout"].(string)
-	if castok {
-		timeout, err = time.ParseDuration(tmostr)
-		if err != nil {
-			return nil, errors.Wrap(err, "could not parse timeout value")
-		}
-	}
-
 	if opts.Context == nil {
 		opts.Context = context.Background()
 	}
 
-	ctx, cancel := context.WithTimeout(opts.Context, timeout)
-
-	now := time.Now()
-	d, _ := ctx.Deadline()
-	newTimeout := d.Sub(now)
-
-	// We need to take the shorter of the timeouts here so that the server can try to timeout first, if the context
-	// already had a shorter deadline then there's not much we can do about it.
-	if newTimeout > timeout {
-		queryOpts["timeout"] = timeout.String()
-	} else {
-		queryOpts["timeout"] = newTimeout.String()
+	// The server is given slightly longer than we are so that our deadline fires first, rather than the server
+	// truncating the response.
+	ctx, cancel, err := contextWithServerTimeout(opts.Context, queryOpts, c.sb.AnalyticsTimeout, analyticsServerTimeoutDelta)
+	if err != nil {
+		return nil, err
 	}
 
 	if opts.Serializer == nil {
@@ -369,9 +592,19 @@ func (c *Cluster) analyticsQuery(tracectx requestSpanContext, statement string,
 		retryWrapper = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
-	res, err := c.executeAnalyticsQuery(ctx, tracectx, queryOpts, provider, cancel, opts.ReadOnly, opts.Serializer,
-		retryWrapper, startTime)
+	contextID, _ := queryOpts["client_context_id"].(string)
+	deregister := c.sb.ActiveRequests.register("cbas", statement, contextID, startTime, cancel)
+
+	var res *AnalyticsResult
+	if opts.Prepared {
+		res, err = c.doPreparedAnalyticsQuery(ctx, tracectx, queryOpts, provider, cancel, opts.ReadOnly,
+			opts.Serializer, retryWrapper, opts.MaxRetries, startTime)
+	} else {
+		res, err = c.executeAnalyticsQuery(ctx, tracectx, queryOpts, provider, cancel, opts.ReadOnly, opts.Serializer,
+			retryWrapper, opts.MaxRetries, startTime)
+	}
 	if err != nil {
+		deregister()
 		// only cancel on error, if we cancel when things have gone to plan then we'll prematurely close the stream
 		if cancel != nil {
 			cancel()
@@ -379,12 +612,135 @@ func (c *Cluster) analyticsQuery(tracectx requestSpanContext, statement string,
 		return nil, err
 	}
 
+	// The request stays active until the rows have been read and the result closed.
+	if res.streamResult.Closed() {
+		deregister()
+	} else {
+		res.deregister = deregister
+	}
+
 	return res, nil
 }
 
+// analyticsSignatureKey returns the key to cache the result signature of an analytics query under, this is the
+// statement or the handle of the prepared statement being executed.
+func analyticsSignatureKey(opts map[string]interface{}) string {
+	queryContext, _ := opts["query_context"].(string)
+	if statement, ok := opts["statement"].(string); ok {
+		return "cbas:" + queryContext + "\x00" + statement
+	}
+	if prepared, ok := opts["prepared"].(string); ok {
+		return "cbas-prepared:" + queryContext + "\x00" + prepared
+	}
+
+	return ""
+}
+
+func (c *Cluster) doPreparedAnalyticsQuery(ctx context.Context, tracectx requestSpanContext,
+	queryOpts map[string]interface{}, provider httpProvider, cancel context.CancelFunc, idempotent bool,
+	serializer JSONSerializer, retryWrapper *retryStrategyWrapper, maxRetries int,
+	startTime time.Time) (*AnalyticsResult, error) {
+	stmtStr, isStr := queryOpts["statement"].(string)
+	if !isStr {
+		return nil, invalidArgumentsError{message: "analytics statement could not be parsed"}
+	}
+
+	// Unqualified dataset names in the statement resolve differently depending on the query context so the same
+	// statement must be prepared separately for each.
+	cacheKey := stmtStr
+	if queryContext, ok := queryOpts["query_context"].(string); ok {
+		cacheKey = queryContext + " " + stmtStr
+	}
+
+	c.clusterLock.RLock()
+	handle, cached := c.analyticsQueryCache[cacheKey]
+	c.clusterLock.RUnlock()
+
+	if cached {
+		// Attempt to execute our cached prepared statement
+		results, err := c.executeAnalyticsQuery(ctx, tracectx, analyticsPreparedOpts(queryOpts, handle), provider,
+			cancel, idempotent, serializer, retryWrapper, maxRetries, startTime)
+		if err == nil {
+			return results, nil
+		}
+
+		if !isAnalyticsPlanError(err) {
+			return nil, err
+		}
+
+		// The server no longer knows the prepared statement so drop it and prepare the statement again.
+		c.clusterLock.Lock()
+		if c.analyticsQueryCache[cacheKey] == handle {
+			delete(c.analyticsQueryCache, cacheKey)
+		}
+		c.clusterLock.Unlock()
+	}
+
+	handle, err := c.prepareAnalyticsQuery(ctx, tracectx, queryOpts, provider, retryWrapper, maxRetries, startTime)
+	if err != nil {
+		return nil, err
+	}
+
+	c.clusterLock.Lock()
+	c.analyticsQueryCache[cacheKey] = handle
+	c.clusterLock.Unlock()
+
+	return c.executeAnalyticsQuery(ctx, tracectx, analyticsPreparedOpts(queryOpts, handle), provider, cancel,
+		idempotent, serializer, retryWrapper, maxRetries, startTime)
+}
+
+// prepareAnalyticsQuery prepares the statement in queryOpts, returning the handle of the prepared statement.
+// Unlike N1QL the analytics service returns no plan to send back, the handle alone identifies the statement.
+func (c *Cluster) prepareAnalyticsQuery(ctx context.Context, tracectx requestSpanContext,
+	queryOpts map[string]interface{}, provider httpProvider, retryWrapper *retryStrategyWrapper, maxRetries int,
+	startTime time.Time) (string, error) {
+	prepOpts := make(map[string]interface{}, len(queryOpts))
+	for k, v := range queryOpts {
+		prepOpts[k] = v
+	}
+	prepOpts["statement"] = "PREPARE " + queryOpts["statement"].(string)
+
+	// There's no need to pass cancel here, if there's an error then we'll cancel further up the stack
+	// and if there isn't then we run another query later where we will cancel
+	prepRes, err := c.executeAnalyticsQuery(ctx, tracectx, prepOpts, provider, nil, true, &DefaultJSONSerializer{},
+		retryWrapper, maxRetries, startTime)
+	if err != nil {
+		return "", err
+	}
+
+	var preped analyticsPrepData
+	err = prepRes.One(&preped)
+	if err != nil {
+		return "", err
+	}
+
+	if preped.Handle == "" {
+		return "", clientError{message: "analytics prepare response did not contain a handle"}
+	}
+
+	return preped.Handle, nil
+}
+
+// analyticsPreparedOpts returns a copy of queryOpts which executes the prepared statement with the given handle in
+// place of the statement.
+func analyticsPreparedOpts(queryOpts map[string]interface{}, handle string) map[string]interface{} {
+	execOpts := make(map[string]interface{}, len(queryOpts))
+	for k, v := range queryOpts {
+		execOpts[k] = v
+	}
+	delete(execOpts, "statement")
+	execOpts["prepared"] = handle
+
+	return execOpts
+}
+
+type analyticsPrepData struct {
+	Handle string `json:"handle"`
+}
+
 func (c *Cluster) executeAnalyticsQuery(ctx context.Context, tracectx requestSpanContext, opts map[string]interface{},
 	provider httpProvider, cancel context.CancelFunc, idempotent bool, serializer JSONSerializer,
-	retryWrapper *retryStrategyWrapper, startTime time.Time) (*AnalyticsResult, error) {
+	retryWrapper *retryStrategyWrapper, maxRetries int, startTime time.Time) (*AnalyticsResult, error) {
 	// priority is sent as a header not in the body
 	priority, priorityCastOK := opts["priority"].(int)
 	if priorityCastOK {
@@ -421,6 +777,11 @@ func (c *Cluster) executeAnalyticsQuery(ctx context.Context, tracectx requestSpa
 		req.Headers["Analytics-Priority"] = strconv.Itoa(priority)
 	}
 
+	if c.sb.LogRequestBodies {
+		logRequestBody(LogFields{LogFieldOperationID: req.UniqueId, LogFieldService: "cbas"}, reqJSON)
+	}
+
+	var retries uint32
 	for {
 		dspan := c.sb.Tracer.StartSpan("dispatch", tracectx)
 		resp, err := provider.DoHttpRequest(req)
@@ -456,10 +817,12 @@ func (c *Cluster) executeAnalyticsQuery(ctx context.Context, tracectx requestSpa
 			metadata: AnalyticsMetadata{
 				sourceAddr: epInfo.Host,
 			},
-			httpStatus:   resp.StatusCode,
-			httpProvider: provider,
-			serializer:   serializer,
-			startTime:    startTime,
+			httpStatus:     resp.StatusCode,
+			httpProvider:   provider,
+			serializer:     serializer,
+			startTime:      startTime,
+			signatureCache: c.signatureCache,
+			signatureKey:   analyticsSignatureKey(opts),
 		}
 
 		streamResult, err := newStreamingResults(resp.Body, results.readAttribute)
@@ -488,13 +851,24 @@ func (c *Cluster) executeAnalyticsQuery(ctx context.Context, tracectx requestSpa
 				logDebugf("Failed to close response body, %s", bodyErr.Error())
 			}
 
+			results.err = makeAnalyticsStatusError(results.err, results.metadata.status)
+
 			if results.err != nil {
 				// If this isn't retryable then return immediately, otherwise attempt a retry. If that fails then return
 				// immediately.
 				if IsRetryableError(results.err) {
+					if maxRetries > 0 && retries >= uint32(maxRetries) {
+						return nil, retriesExhaustedError{
+							retryAttempts: retries,
+							lastErr:       results.err,
+							operation:     "cbas",
+						}
+					}
+
 					shouldRetry, retryErr := shouldRetryHTTPRequest(ctx, req, gocbcore.ServiceResponseCodeIndicatedRetryReason,
 						retryWrapper, provider, startTime)
 					if shouldRetry {
+						retries++
 						continue
 					}
 
@@ -511,6 +885,8 @@ func (c *Cluster) executeAnalyticsQuery(ctx context.Context, tracectx requestSpa
 	}
 }
 
+// shouldRetryHTTPRequest asks the provider to retry req, which will wait for the duration given by retryWrapper
+// before signalling that the request can be sent again. It returns false if the strategy declined to retry.
 func shouldRetryHTTPRequest(ctx context.Context, req *gocbcore.HttpRequest, reason gocbcore.RetryReason,
 	retryWrapper *retryStrategyWrapper, provider httpProvider, startTime time.Time) (bool, error) {
 	waitCh := make(chan struct{})
@@ -4,6 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -212,6 +217,10 @@ func testSimpleAnalyticsQueryError(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Expected execute query to error")
 	}
+
+	if !IsAnalyticsCompilationError(err) {
+		t.Fatalf("Expected error to be a compilation error but was %v", err)
+	}
 }
 
 func testAnalyticsQueryNamedParameters(t *testing.T) {
@@ -521,6 +530,53 @@ func TestBasicAnalyticsQuerySerializerError(t *testing.T) {
 	}
 }
 
+func TestAnalyticsQueryOneNoResults(t *testing.T) {
+	dataBytes := []byte(`{"requestID":"a1b2c3","results":[],"status":"success","metrics":{"elapsedTime":"1ms","executionTime":"1ms","resultCount":0,"resultSize":0}}`)
+	timeout := 60 * time.Second
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, 0, timeout, 0)
+
+	res, err := cluster.AnalyticsQuery("SELECT * FROM `beer-sample` WHERE `type` = \"nothing\"", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sample interface{}
+	err = res.One(&sample)
+	if !IsNoResultsError(err) {
+		t.Fatalf("Expected error to be no results but was %v", err)
+	}
+
+	if sample != nil {
+		t.Fatalf("Expected sample to be nil but was %v", sample)
+	}
+
+	metadata, err := res.Metadata()
+	if err != nil {
+		t.Fatalf("Metadata had error: %v", err)
+	}
+
+	if metadata.RequestID() != "a1b2c3" {
+		t.Fatalf("Expected RequestID to be a1b2c3 but was %s", metadata.RequestID())
+	}
+
+	if metadata.Status() != "success" {
+		t.Fatalf("Expected Status to be success but was %s", metadata.Status())
+	}
+}
+
 func TestAnalyticsQueryServiceNotFound(t *testing.T) {
 	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
 		return nil, gocbcore.ErrNoCbasService
@@ -707,6 +763,192 @@ func TestAnalyticsQueryStreamTimeout(t *testing.T) {
 	}
 }
 
+func TestAnalyticsQueryMaxRetries(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("analytics_timeout")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	attempts := 0
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		attempts++
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 0, 50*time.Second, 0)
+
+	startTime := time.Now()
+	_, err = cluster.AnalyticsQuery("SELECT 1=1", &AnalyticsOptions{
+		MaxRetries: 3,
+	})
+	if !IsRetriesExhaustedError(err) {
+		t.Fatalf("Expected error to be retries exhausted but was %v", err)
+	}
+
+	if time.Since(startTime) > 10*time.Second {
+		t.Fatalf("Expected retries to stop before the timeout")
+	}
+
+	if attempts != 4 {
+		t.Fatalf("Expected query to be sent 4 times but was %d", attempts)
+	}
+
+	rErr := err.(RetriesExhaustedError)
+	if rErr.RetryAttempts() != uint32(3) {
+		t.Fatalf("Expected RetryAttempts to be 3 but was %d", rErr.RetryAttempts())
+	}
+
+	if _, ok := rErr.LastError().(AnalyticsQueryError); !ok {
+		t.Fatalf("Expected LastError to be the analytics error but was %v", rErr.LastError())
+	}
+
+	if IsTimeoutError(err) {
+		t.Fatalf("Expected error to not be a timeout")
+	}
+}
+
+func TestAnalyticsQueryCloseStalledStream(t *testing.T) {
+	// The rows are complete but the server stalls before sending the trailing metadata.
+	dataBytes := []byte(`{"requestID":"a1b2c3","results":[{"name":"stalled"}],`)
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       newTestBlockingReadCloser(dataBytes),
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, 0, 100*time.Millisecond, 0)
+
+	res, err := cluster.AnalyticsQuery("SELECT 1=1", &AnalyticsOptions{
+		ClientContextID: "testclientcontext",
+	})
+	if err != nil {
+		t.Fatalf("Expected query to succeed but was %v", err)
+	}
+
+	var row map[string]interface{}
+	if !res.Next(&row) {
+		t.Fatalf("Expected a row but was %v", res.Close())
+	}
+
+	if res.Next(&row) {
+		t.Fatalf("Expected only 1 row")
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- res.Close()
+	}()
+
+	select {
+	case err = <-errCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close did not return for a stalled stream")
+	}
+
+	if !IsTimeoutError(err) {
+		t.Fatalf("Expected error to be timeout but was %v", err)
+	}
+
+	// The metadata must not be written to by the abandoned read once Close has returned.
+	_, err = res.Metadata()
+	if err != nil {
+		t.Fatalf("Expected metadata to be accessible after close but was %v", err)
+	}
+
+	if len(cluster.ActiveRequests()) != 0 {
+		t.Fatalf("Expected no active requests after close but was %v", cluster.ActiveRequests())
+	}
+}
+
+func testAnalyticsResultForDataset(t *testing.T, dataset string) *AnalyticsResult {
+	dataBytes, err := loadRawTestDataset(dataset)
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, 0, 10*time.Second, 0)
+
+	res, err := cluster.AnalyticsQuery("SELECT b.* FROM breweries b", nil)
+	if err != nil {
+		t.Fatalf("Expected query to succeed but was %v", err)
+	}
+
+	return res
+}
+
+func TestAnalyticsResultWriteCSV(t *testing.T) {
+	expected, err := ioutil.ReadFile("testdata/analytics_flat_breweries.csv")
+	if err != nil {
+		t.Fatalf("Could not read expected CSV: %v", err)
+	}
+
+	res := testAnalyticsResultForDataset(t, "analytics_flat_breweries")
+
+	var buf bytes.Buffer
+	err = res.WriteCSV(&buf, nil)
+	if err != nil {
+		t.Fatalf("Expected WriteCSV to succeed but was %v", err)
+	}
+
+	if buf.String() != string(expected) {
+		t.Fatalf("Expected CSV to be:\n%s\nbut was:\n%s", expected, buf.String())
+	}
+
+	metadata, err := res.Metadata()
+	if err != nil {
+		t.Fatalf("Expected result to be closed but was %v", err)
+	}
+
+	if metadata.Metrics().ResultCount != 4 {
+		t.Fatalf("Expected result count to be 4 but was %d", metadata.Metrics().ResultCount)
+	}
+}
+
+func TestAnalyticsResultWriteCSVColumns(t *testing.T) {
+	res := testAnalyticsResultForDataset(t, "analytics_flat_breweries")
+
+	var buf bytes.Buffer
+	err := res.WriteCSV(&buf, &CSVExportOptions{
+		Columns:    []string{"city", "name", "missing"},
+		Comma:      '\t',
+		OmitHeader: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected WriteCSV to succeed but was %v", err)
+	}
+
+	expected := "Austin\t(512) Brewing Company\t\n" +
+		"San Francisco\t21st Amendment Brewery Cafe\t\n" +
+		"\t357\t\n" +
+		"Drammen\t\"Aass Brewery, \"\"Bryggeri\"\"\"\t\n"
+	if buf.String() != expected {
+		t.Fatalf("Expected TSV to be:\n%q\nbut was:\n%q", expected, buf.String())
+	}
+}
+
 func TestAnalyticsQueryConnectContextTimeout(t *testing.T) {
 	statement := "select `beer-sample`.* from `beer-sample` WHERE `type` = ? ORDER BY brewery_id, name"
 	timeout := 50 * time.Second
@@ -917,6 +1159,72 @@ func TestAnalyticsQueryConnectClusterTimeoutContextWins(t *testing.T) {
 	}
 }
 
+func TestAnalyticsQueryServerTimeoutExceedsDeadline(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("beer_sample_analytics_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	statement := "select `beer-sample`.* from `beer-sample` WHERE `type` = ? ORDER BY brewery_id, name"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		testAssertAnalyticsQueryRequest(t, req)
+
+		var opts map[string]interface{}
+		err := json.Unmarshal(req.Body, &opts)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal request body %v", err)
+		}
+
+		optsTimeout, ok := opts["timeout"]
+		if !ok {
+			t.Fatalf("Request query options missing timeout")
+		}
+
+		dur, err := time.ParseDuration(optsTimeout.(string))
+		if err != nil {
+			t.Fatalf("Could not parse timeout: %v", err)
+		}
+
+		d, ok := req.Context.Deadline()
+		if !ok {
+			t.Fatalf("Expected request context to have a deadline")
+		}
+
+		margin := dur - d.Sub(time.Now())
+		if margin < analyticsServerTimeoutDelta || margin > analyticsServerTimeoutDelta+50*time.Millisecond {
+			t.Fatalf("Expected timeout to exceed the client deadline by %s but was %s", analyticsServerTimeoutDelta, margin)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, 0, 60*time.Second, 0)
+
+	res, err := cluster.AnalyticsQuery(statement, &AnalyticsOptions{
+		Context:              ctx,
+		PositionalParameters: []interface{}{"brewery"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = res.Close()
+	if err != nil {
+		t.Fatalf("Expected Close to succeed but was %v", err)
+	}
+}
+
 func testAssertAnalyticsQueryRequest(t *testing.T, req *gocbcore.HttpRequest) {
 	if req.Service != gocbcore.CbasService {
 		t.Fatalf("Service should have been AnalyticsService but was %d", req.Service)
@@ -1035,3 +1343,513 @@ func testAssertAnalyticsQueryResult(t *testing.T, expectedResult *analyticsRespo
 		t.Fatalf("Expected metrics ProcessedObjects to be %d but was %d", metrics.ProcessedObjects, expectedResult.Metrics.ProcessedObjects)
 	}
 }
+
+func TestAnalyticsQueryStatusRetryClassification(t *testing.T) {
+	type tCase struct {
+		name          string
+		dataset       string
+		expectRetry   bool
+		expectedFatal bool
+	}
+
+	testCases := []tCase{
+		{name: "fatal", dataset: "analytics_fatal_error", expectRetry: false, expectedFatal: true},
+		{name: "timeout", dataset: "analytics_timeout_status", expectRetry: true},
+	}
+
+	successBytes, err := loadRawTestDataset("beer_sample_analytics_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errBytes, err := loadRawTestDataset(tc.dataset)
+			if err != nil {
+				t.Fatalf("Could not read test dataset: %v", err)
+			}
+
+			var dispatches int
+			doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+				dispatches++
+
+				body := errBytes
+				if dispatches > 1 {
+					body = successBytes
+				}
+
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8095",
+					StatusCode: 200,
+					Body:       &testReadCloser{bytes.NewBuffer(body), nil},
+				}, nil
+			}
+
+			cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 0, 10*time.Second, 0)
+
+			res, err := cluster.AnalyticsQuery("SELECT 1", nil)
+			if tc.expectRetry {
+				if err != nil {
+					t.Fatalf("Expected AnalyticsQuery to succeed after retrying but was %v", err)
+				}
+
+				err = res.Close()
+				if err != nil {
+					t.Fatalf("Expected Close to succeed but was %v", err)
+				}
+
+				if dispatches != 2 {
+					t.Fatalf("Expected request to be retried once but was dispatched %d times", dispatches)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Expected AnalyticsQuery to fail")
+			}
+
+			if dispatches != 1 {
+				t.Fatalf("Expected request to not be retried but was dispatched %d times", dispatches)
+			}
+
+			if IsAnalyticsFatalError(err) != tc.expectedFatal {
+				t.Fatalf("Expected fatal error to be %t but was %v", tc.expectedFatal, err)
+			}
+
+			if IsRetryableError(err) {
+				t.Fatalf("Expected fatal error to not be retryable")
+			}
+
+			if _, ok := err.(AnalyticsQueryError); !ok {
+				t.Fatalf("Expected error to be AnalyticsQueryError but was %T", err)
+			}
+		})
+	}
+}
+
+func TestAnalyticsQueryUseNumber(t *testing.T) {
+	dataBytes := []byte(`{"requestID":"a1b2c3","results":[{"counter":9223372036854775807}],"status":"success"}`)
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, 0, 60*time.Second, 0)
+	cluster.sb.Serializer = &DefaultJSONSerializer{UseNumber: true}
+
+	res, err := cluster.AnalyticsQuery("SELECT counter FROM dataset", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var row map[string]interface{}
+	err = res.One(&row)
+	if err != nil {
+		t.Fatalf("Expected One to succeed but was %v", err)
+	}
+
+	counter, ok := row["counter"].(json.Number)
+	if !ok {
+		t.Fatalf("Expected counter to be json.Number but was %T", row["counter"])
+	}
+
+	if counter.String() != "9223372036854775807" {
+		t.Fatalf("Expected counter to be 9223372036854775807 but was %s", counter)
+	}
+}
+
+func TestAnalyticsResultRaw(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("beer_sample_analytics_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	body := &testTrackingReadCloser{Reader: bytes.NewReader(dataBytes)}
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       body,
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, 0, 10*time.Second, 0)
+
+	res, err := cluster.AnalyticsQuery("SELECT b.* FROM breweries b", nil)
+	if err != nil {
+		t.Fatalf("Expected query to succeed but was %v", err)
+	}
+
+	raw, err := res.Raw()
+	if err != nil {
+		t.Fatalf("Expected Raw to succeed but was %v", err)
+	}
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, raw)
+	if err != nil {
+		t.Fatalf("Expected copy to succeed but was %v", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), dataBytes) {
+		t.Fatalf("Expected raw response to match the dataset but was %s", buf.String())
+	}
+
+	var row interface{}
+	if res.Next(&row) {
+		t.Fatalf("Expected Next to fail once the raw response has been requested")
+	}
+
+	if _, err := res.Raw(); err == nil {
+		t.Fatalf("Expected a second Raw to fail")
+	}
+
+	err = raw.Close()
+	if err == nil {
+		t.Fatalf("Expected Close to report the failed Next")
+	}
+
+	if !body.closed {
+		t.Fatalf("Expected response body to be closed")
+	}
+
+	if len(cluster.ActiveRequests()) != 0 {
+		t.Fatalf("Expected no active requests after close but was %v", cluster.ActiveRequests())
+	}
+}
+
+func TestAnalyticsResultRawAfterNext(t *testing.T) {
+	res := testAnalyticsResultForDataset(t, "beer_sample_analytics_dataset")
+
+	var row interface{}
+	if !res.Next(&row) {
+		t.Fatalf("Expected Next to succeed but was %v", res.Close())
+	}
+
+	if _, err := res.Raw(); err == nil {
+		t.Fatalf("Expected Raw to fail once rows have been read")
+	}
+
+	err := res.Close()
+	if err != nil {
+		t.Fatalf("Expected Close to succeed but was %v", err)
+	}
+}
+
+// testPreparedAnalyticsServer models the analytics service for prepared statements, each PREPARE creates a new
+// handle and handles can be dropped to have executions report error 24120.
+type testPreparedAnalyticsServer struct {
+	t        *testing.T
+	prepares int
+	requests []map[string]interface{}
+	dropped  map[string]bool
+}
+
+func (s *testPreparedAnalyticsServer) doHTTP(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+	testAssertAnalyticsQueryRequest(s.t, req)
+
+	var body map[string]interface{}
+	err := json.Unmarshal(req.Body, &body)
+	if err != nil {
+		s.t.Fatalf("Failed to unmarshal request body: %v", err)
+	}
+	s.requests = append(s.requests, body)
+
+	var resp string
+	if statement, ok := body["statement"].(string); ok && strings.HasPrefix(statement, "PREPARE ") {
+		s.prepares++
+		resp = fmt.Sprintf(`{"results":[{"handle":"handle%d"}],"status":"success"}`, s.prepares)
+	} else if handle, ok := body["prepared"].(string); ok && s.dropped[handle] {
+		resp = `{"errors":[{"code":24120,"msg":"Unknown prepared statement: ` + handle + `"}],"status":"fatal"}`
+	} else {
+		resp = `{"results":[{"id":1}],"status":"success"}`
+	}
+
+	return &gocbcore.HttpResponse{
+		Endpoint:   "http://localhost:8095",
+		StatusCode: 200,
+		Body:       &testReadCloser{bytes.NewBufferString(resp), nil},
+	}, nil
+}
+
+func (s *testPreparedAnalyticsServer) cluster() *Cluster {
+	return testGetClusterForHTTP(&mockHTTPProvider{doFn: s.doHTTP}, 0, 10*time.Second, 0)
+}
+
+func (s *testPreparedAnalyticsServer) query(cluster *Cluster, statement string) {
+	res, err := cluster.AnalyticsQuery(statement, &AnalyticsOptions{
+		Prepared:             true,
+		PositionalParameters: []interface{}{1},
+	})
+	if err != nil {
+		s.t.Fatalf("Expected query execution to not error %v", err)
+	}
+
+	var row map[string]int
+	err = res.One(&row)
+	if err != nil {
+		s.t.Fatalf("Expected query to return a row but was %v", err)
+	}
+
+	if row["id"] != 1 {
+		s.t.Fatalf("Expected row id to be 1 but was %d", row["id"])
+	}
+}
+
+func TestPreparedAnalyticsQueryFirstPrepare(t *testing.T) {
+	statement := "SELECT * FROM breweries WHERE id = ?"
+	server := &testPreparedAnalyticsServer{t: t}
+	cluster := server.cluster()
+
+	server.query(cluster, statement)
+
+	if len(server.requests) != 2 {
+		t.Fatalf("Expected a prepare and an execute request but was %d requests", len(server.requests))
+	}
+
+	if server.requests[0]["statement"] != "PREPARE "+statement {
+		t.Fatalf("Expected first request to prepare the statement but was %v", server.requests[0]["statement"])
+	}
+
+	if _, ok := server.requests[1]["statement"]; ok {
+		t.Fatalf("Expected execute request not to contain the statement")
+	}
+
+	if server.requests[1]["prepared"] != "handle1" {
+		t.Fatalf("Expected execute request to be for handle1 but was %v", server.requests[1])
+	}
+
+	if !reflect.DeepEqual(server.requests[1]["args"], []interface{}{float64(1)}) {
+		t.Fatalf("Expected execute request to contain the parameters but was %v", server.requests[1])
+	}
+
+	if cluster.analyticsQueryCache[statement] != "handle1" {
+		t.Fatalf("Expected cached handle to be handle1 but was %s", cluster.analyticsQueryCache[statement])
+	}
+}
+
+func TestPreparedAnalyticsQueryCacheHit(t *testing.T) {
+	statement := "SELECT * FROM breweries WHERE id = ?"
+	server := &testPreparedAnalyticsServer{t: t}
+	cluster := server.cluster()
+
+	server.query(cluster, statement)
+	server.query(cluster, statement)
+
+	if server.prepares != 1 {
+		t.Fatalf("Expected statement to be prepared once but was prepared %d times", server.prepares)
+	}
+
+	if len(server.requests) != 3 {
+		t.Fatalf("Expected 3 requests but was %d", len(server.requests))
+	}
+
+	if server.requests[2]["prepared"] != "handle1" {
+		t.Fatalf("Expected cached handle to be executed but was %v", server.requests[2])
+	}
+}
+
+func TestPreparedAnalyticsQueryCacheQueryContext(t *testing.T) {
+	statement := "SELECT * FROM breweries WHERE id = ?"
+	server := &testPreparedAnalyticsServer{t: t}
+	cluster := server.cluster()
+
+	queryContexts := []string{"default:`beer-sample`.inventory", "default:`beer-sample`.tenant"}
+	for i := 0; i < 2; i++ {
+		for _, queryContext := range queryContexts {
+			res, err := cluster.AnalyticsQuery(statement, &AnalyticsOptions{
+				Prepared:     true,
+				QueryContext: queryContext,
+			})
+			if err != nil {
+				t.Fatalf("Expected query execution to not error %v", err)
+			}
+
+			err = res.Close()
+			if err != nil {
+				t.Fatalf("Expected Close to succeed but was %v", err)
+			}
+		}
+	}
+
+	if server.prepares != 2 {
+		t.Fatalf("Expected statement to be prepared once per query context but was prepared %d times",
+			server.prepares)
+	}
+
+	for i, queryContext := range queryContexts {
+		expected := fmt.Sprintf("handle%d", i+1)
+		if handle := cluster.analyticsQueryCache[queryContext+" "+statement]; handle != expected {
+			t.Fatalf("Expected cached handle for %s to be %s but was %s", queryContext, expected, handle)
+		}
+	}
+
+	// prepare and execute in each query context, then execute the cached handle in each.
+	for i, expected := range []string{"handle1", "handle2"} {
+		request := server.requests[4+i]
+		if request["prepared"] != expected || request["query_context"] != queryContexts[i] {
+			t.Fatalf("Expected %s to be executed in %s but was %v", expected, queryContexts[i], request)
+		}
+	}
+}
+
+func TestPreparedAnalyticsQueryReprepareAfterInvalidation(t *testing.T) {
+	statement := "SELECT * FROM breweries WHERE id = ?"
+	server := &testPreparedAnalyticsServer{t: t}
+	cluster := server.cluster()
+
+	server.query(cluster, statement)
+
+	server.dropped = map[string]bool{"handle1": true}
+	server.query(cluster, statement)
+
+	if server.prepares != 2 {
+		t.Fatalf("Expected statement to be prepared twice but was prepared %d times", server.prepares)
+	}
+
+	// prepare, execute handle1, execute handle1 (24120), prepare, execute handle2
+	if len(server.requests) != 5 {
+		t.Fatalf("Expected 5 requests but was %d", len(server.requests))
+	}
+
+	if server.requests[4]["prepared"] != "handle2" {
+		t.Fatalf("Expected new handle to be executed but was %v", server.requests[4])
+	}
+
+	if cluster.analyticsQueryCache[statement] != "handle2" {
+		t.Fatalf("Expected cached handle to be handle2 but was %s", cluster.analyticsQueryCache[statement])
+	}
+}
+
+func TestAnalyticsQueryOneNilPointerTarget(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("beer_sample_analytics_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 0, 60*time.Second, 0)
+
+	res, err := cluster.AnalyticsQuery("SELECT * FROM `beer-sample`", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var row *testBreweryDocument
+	err = res.One(row)
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
+func TestAnalyticsResultDrain(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("beer_sample_analytics_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 0, 60*time.Second, 0)
+
+	res, err := cluster.AnalyticsQuery("SELECT * FROM `beer-sample`", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = res.Drain()
+	if err != nil {
+		t.Fatalf("Expected Drain to succeed but was %v", err)
+	}
+
+	metadata, err := res.Metadata()
+	if err != nil {
+		t.Fatalf("Metadata had error: %v", err)
+	}
+
+	if metadata.RequestID() != "30f6bcdf-2288-4fe1-bea1-361bb96984a4" {
+		t.Fatalf("Expected RequestID to be read but was %s", metadata.RequestID())
+	}
+
+	if metadata.Metrics().ResultCount != 5 || metadata.Metrics().ProcessedObjects != 26 {
+		t.Fatalf("Expected metrics to be read but were %v", metadata.Metrics())
+	}
+}
+
+func TestAnalyticsQueryCompilationError(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("analytics_compilation_error")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	var dispatches int
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		dispatches++
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 0, 10*time.Second, 0)
+
+	_, err = cluster.AnalyticsQuery("SELECT `travel-sample`. FROM `travel-sample` LIMIT 10000;", nil)
+	if !IsAnalyticsCompilationError(err) {
+		t.Fatalf("Expected error to be a compilation error but was %v", err)
+	}
+
+	if IsRetryableError(err) {
+		t.Fatalf("Expected compilation error to not be retryable")
+	}
+
+	if dispatches != 1 {
+		t.Fatalf("Expected query to be sent once but was %d", dispatches)
+	}
+
+	aErr, ok := err.(AnalyticsQueryError)
+	if !ok {
+		t.Fatalf("Expected error to be AnalyticsQueryError but was %v", err)
+	}
+
+	if aErr.Code() != 24000 {
+		t.Fatalf("Expected code to be 24000 but was %d", aErr.Code())
+	}
+
+	notCompilation := []error{
+		analyticsQueryError{ErrorCode: 23000, ErrorMessage: "Analytics Service is temporarily unavailable"},
+		analyticsQueryError{ErrorCode: analyticsPreparedStatementNotFoundCode, ErrorMessage: "No statement with handle"},
+		analyticsQueryError{ErrorCode: 25000, ErrorMessage: "Internal error"},
+		queryError{ErrorCode: 24000, ErrorMessage: "not analytics"},
+	}
+	for _, err := range notCompilation {
+		if IsAnalyticsCompilationError(err) {
+			t.Fatalf("Expected %v to not be a compilation error", err)
+		}
+	}
+}
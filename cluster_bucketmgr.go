@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/url"
 	"time"
 
@@ -89,6 +88,11 @@ type bucketDataIn struct {
 	EvictionPolicy         string `json:"evictionPolicy"`
 	MaxTTL                 int    `json:"maxTTL"`
 	CompressionMode        string `json:"compressionMode"`
+	Rank                   int    `json:"rank"`
+	Nodes                  []struct {
+		Hostname string `json:"hostname"`
+		Status   string `json:"status"`
+	} `json:"nodes"`
 }
 
 // BucketSettings holds information about the settings for a bucket.
@@ -97,7 +101,8 @@ type BucketSettings struct {
 	Name string
 	// FlushEnabled specifies whether or not to enable flush on the bucket.
 	FlushEnabled bool
-	// ReplicaIndexDisabled specifies whether or not to disable replica index.
+	// ReplicaIndexDisabled specifies whether or not to disable replica index. Replica indexes only exist for
+	// Couchbase buckets, setting this for any other bucket type is an error.
 	ReplicaIndexDisabled bool // inverted so that zero value matches server default.
 	//  is the memory quota to assign to the bucket and is required.
 	RAMQuotaMB int
@@ -109,25 +114,32 @@ type BucketSettings struct {
 	EvictionPolicy  EvictionPolicyType
 	MaxTTL          int
 	CompressionMode CompressionMode
+	// ConflictResolutionType is the conflict resolution in use by the bucket. This is only sent by CreateBucket and is
+	// populated by GetBucket and GetAllBuckets, it cannot be changed once a bucket has been created.
+	ConflictResolutionType ConflictResolutionType
+	// Rank is the priority of the bucket relative to others when the server is under resource pressure, buckets with
+	// a higher rank are prioritized. This requires Couchbase Server 7.6 or above, it is not sent if left as 0.
+	// NOTE: As 0 is never sent UpdateBucket cannot reset the rank of a bucket back to 0 once it has been set.
+	Rank int
 }
 
 // CreateBucketSettings are the settings available when creating a bucket.
 type CreateBucketSettings struct {
 	BucketSettings
-	ConflictResolutionType ConflictResolutionType
 }
 
 func bucketDataInToSettings(bucketData *bucketDataIn) (string, BucketSettings) {
 	settings := BucketSettings{
 		Name: bucketData.Name,
 		// Password:               bucketData.SaslPassword,
-		FlushEnabled:         bucketData.Controllers.Flush != "",
-		ReplicaIndexDisabled: !bucketData.ReplicaIndex,
-		RAMQuotaMB:           bucketData.Quota.RawRam,
-		NumReplicas:          bucketData.ReplicaNumber,
-		EvictionPolicy:       EvictionPolicyType(bucketData.EvictionPolicy),
-		MaxTTL:               bucketData.MaxTTL,
-		CompressionMode:      CompressionMode(bucketData.CompressionMode),
+		FlushEnabled:    bucketData.Controllers.Flush != "",
+		RAMQuotaMB:      bucketData.Quota.RawRam,
+		EvictionPolicy:  EvictionPolicyType(bucketData.EvictionPolicy),
+		MaxTTL:          bucketData.MaxTTL,
+		CompressionMode: CompressionMode(bucketData.CompressionMode),
+		Rank:            bucketData.Rank,
+
+		ConflictResolutionType: ConflictResolutionType(bucketData.ConflictResolutionType),
 	}
 
 	if settings.RAMQuotaMB > 0 {
@@ -145,9 +157,94 @@ func bucketDataInToSettings(bucketData *bucketDataIn) (string, BucketSettings) {
 		logDebugf("Unrecognized bucket type string.")
 	}
 
+	// Replica settings are only populated for the bucket types which support them, the server still reports a
+	// replica number for memcached buckets but it is meaningless. Leaving them unset for other types allows the
+	// settings to be passed back to UpdateBucket.
+	switch settings.BucketType {
+	case CouchbaseBucketType:
+		settings.NumReplicas = bucketData.ReplicaNumber
+		settings.ReplicaIndexDisabled = !bucketData.ReplicaIndex
+	case EphemeralBucketType:
+		settings.NumReplicas = bucketData.ReplicaNumber
+	}
+
 	return bucketData.Name, settings
 }
 
+// doMgmtRequest dispatches a management request. A response with a status for which isRetryableMgmtStatus is true is
+// transient, e.g. the service being unavailable during a rebalance, so an idempotent request is retried according to
+// its retry strategy. Once the strategy gives up the final response is returned to be decoded as any other would be.
+func doMgmtRequest(provider httpProvider, req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for {
+		resp, err := provider.DoHttpRequest(req)
+		if err != nil {
+			return nil, makeMgmtDispatchError(req, err)
+		}
+		if !isRetryableMgmtStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		// The server may have applied a non-idempotent request before failing, so re-sending it isn't safe.
+		if !req.IsIdempotent {
+			return resp, nil
+		}
+
+		// A nil wrapper must not be handed to gocbcore as a non-nil strategy, there's nothing to consult anyway.
+		retryWrapper, ok := req.RetryStrategy.(*retryStrategyWrapper)
+		if !ok || retryWrapper == nil || retryWrapper.wrapped == nil {
+			return resp, nil
+		}
+
+		waitCh := make(chan struct{}, 1)
+		retried := provider.MaybeRetryRequest(req, gocbcore.ServiceResponseCodeIndicatedRetryReason, retryWrapper,
+			func() {
+				waitCh <- struct{}{}
+			})
+		if !retried {
+			return resp, nil
+		}
+
+		logDebugFieldsf(LogFields{LogFieldOperationID: req.UniqueId, LogFieldService: "mgmt"},
+			"Retrying management request after status %d", resp.StatusCode)
+		err = resp.Body.Close()
+		if err != nil {
+			logDebugf("Failed to close socket (%s)", err)
+		}
+
+		select {
+		case <-waitCh:
+		case <-ctx.Done():
+			req.CancelRetry()
+			return nil, makeMgmtDispatchError(req, ctx.Err())
+		}
+	}
+}
+
+// makeMgmtDispatchError converts the caller cancelling the context of req into an operationCancelledError, other
+// errors, including context.DeadlineExceeded which each manager turns into a timeoutError, are returned as is.
+func makeMgmtDispatchError(req *gocbcore.HttpRequest, err error) error {
+	if err != context.Canceled {
+		return err
+	}
+
+	operation := "mgmt"
+	if req.Service == gocbcore.CapiService {
+		operation = "view"
+	}
+
+	return operationCancelledError{
+		operationID:   req.UniqueId,
+		retryReasons:  req.RetryReasons(),
+		retryAttempts: req.RetryAttempts(),
+		operation:     operation,
+	}
+}
+
 func contextFromMaybeTimeout(ctx context.Context, timeout time.Duration, globalTimeout time.Duration) (context.Context, context.CancelFunc) {
 	if timeout == 0 {
 		// no operation level timeouts set, use global level
@@ -163,11 +260,27 @@ func contextFromMaybeTimeout(ctx context.Context, timeout time.Duration, globalT
 	return context.WithTimeout(ctx, timeout)
 }
 
+// bucketMgrLogFields returns the fields attached to log messages about a bucket manager request.
+func bucketMgrLogFields(req *gocbcore.HttpRequest, bucketName string) LogFields {
+	fields := LogFields{
+		LogFieldOperationID: req.UniqueId,
+		LogFieldService:     "mgmt",
+	}
+	if bucketName != "" {
+		fields[LogFieldBucket] = bucketName
+	}
+
+	return fields
+}
+
 // GetBucketOptions is the set of options available to the bucket manager GetBucket operation.
 type GetBucketOptions struct {
 	Timeout       time.Duration
 	Context       context.Context
 	RetryStrategy RetryStrategy
+	// TargetEndpoint, if set, sends the request to this management endpoint (e.g. http://10.0.0.1:8091)
+	// rather than to one chosen by the SDK.
+	TargetEndpoint string
 }
 
 // GetBucket returns settings for a bucket on the cluster.
@@ -186,18 +299,31 @@ func (bm *BucketManager) GetBucket(bucketName string, opts *GetBucketOptions) (*
 	}
 
 	retryStrategy := bm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
-	return bm.get(ctx, span.Context(), bucketName, retryStrategy)
+	return bm.get(ctx, span.Context(), bucketName, opts.TargetEndpoint, retryStrategy)
 }
 
-func (bm *BucketManager) get(ctx context.Context, tracectx requestSpanContext, bucketName string,
+func (bm *BucketManager) get(ctx context.Context, tracectx requestSpanContext, bucketName, endpoint string,
 	strategy *retryStrategyWrapper) (*BucketSettings, error) {
+	bucketData, err := bm.getBucketData(ctx, tracectx, bucketName, endpoint, strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	_, settings := bucketDataInToSettings(bucketData)
+
+	return &settings, nil
+}
+
+func (bm *BucketManager) getBucketData(ctx context.Context, tracectx requestSpanContext, bucketName, endpoint string,
+	strategy *retryStrategyWrapper) (*bucketDataIn, error) {
 	startTime := time.Now()
 	req := &gocbcore.HttpRequest{
 		Service:       gocbcore.ServiceType(MgmtService),
+		Endpoint:      endpoint,
 		Path:          fmt.Sprintf("/pools/default/buckets/%s", bucketName),
 		Method:        "GET",
 		Context:       ctx,
@@ -207,7 +333,7 @@ func (bm *BucketManager) get(ctx context.Context, tracectx requestSpanContext, b
 	}
 
 	dspan := bm.tracer.StartSpan("dispatch", tracectx)
-	resp, err := bm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(bm.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -223,16 +349,9 @@ func (bm *BucketManager) get(ctx context.Context, tracectx requestSpanContext, b
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return nil, bucketManagerError{message: string(data), statusCode: resp.StatusCode}
+	err = decodeMgmtError(resp, 200)
+	if err != nil {
+		return nil, makeBucketManagerError(req, err)
 	}
 
 	var bucketData *bucketDataIn
@@ -244,12 +363,80 @@ func (bm *BucketManager) get(ctx context.Context, tracectx requestSpanContext, b
 
 	err = resp.Body.Close()
 	if err != nil {
-		logDebugf("Failed to close socket (%s)", err)
+		logDebugFieldsf(bucketMgrLogFields(req, bucketName), "Failed to close socket (%s)", err)
 	}
 
-	_, settings := bucketDataInToSettings(bucketData)
+	return bucketData, nil
+}
 
-	return &settings, nil
+// BucketNodeHealth is the status of a bucket on a single node.
+type BucketNodeHealth struct {
+	Hostname string
+	// Status is the status of the node as reported by the server, e.g. healthy or warmup.
+	Status string
+}
+
+// BucketHealth describes whether a bucket is ready for use across the nodes in the cluster.
+type BucketHealth struct {
+	Nodes []BucketNodeHealth
+	// Ready is true when the bucket is on at least one node and is healthy on all of them.
+	Ready bool
+}
+
+// BucketHealthOptions is the set of options available to the bucket manager BucketHealth operation.
+type BucketHealthOptions struct {
+	Timeout       time.Duration
+	Context       context.Context
+	RetryStrategy RetryStrategy
+	// TargetEndpoint, if set, sends the request to this management endpoint (e.g. http://10.0.0.1:8091)
+	// rather than to one chosen by the SDK.
+	TargetEndpoint string
+}
+
+// BucketHealth returns the status of a bucket on each node, such as whether it is still warming up.
+func (bm *BucketManager) BucketHealth(bucketName string, opts *BucketHealthOptions) (*BucketHealth, error) {
+	if opts == nil {
+		opts = &BucketHealthOptions{}
+	}
+
+	span := bm.tracer.StartSpan("BucketHealth", nil).
+		SetTag("couchbase.service", "mgmt")
+	defer span.Finish()
+
+	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, bm.globalTimeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	retryStrategy := bm.defaultRetryStrategy
+	if opts.RetryStrategy != nil {
+		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
+	}
+
+	bucketData, err := bm.getBucketData(ctx, span.Context(), bucketName, opts.TargetEndpoint, retryStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	return bucketDataInToHealth(bucketData), nil
+}
+
+func bucketDataInToHealth(bucketData *bucketDataIn) *BucketHealth {
+	health := &BucketHealth{
+		Ready: len(bucketData.Nodes) > 0,
+	}
+	for _, node := range bucketData.Nodes {
+		health.Nodes = append(health.Nodes, BucketNodeHealth{
+			Hostname: node.Hostname,
+			Status:   node.Status,
+		})
+
+		if node.Status != "healthy" {
+			health.Ready = false
+		}
+	}
+
+	return health
 }
 
 // GetAllBucketsOptions is the set of options available to the bucket manager GetAll operation.
@@ -257,16 +444,60 @@ type GetAllBucketsOptions struct {
 	Timeout       time.Duration
 	Context       context.Context
 	RetryStrategy RetryStrategy
+	// TargetEndpoint, if set, sends the request to this management endpoint (e.g. http://10.0.0.1:8091)
+	// rather than to one chosen by the SDK.
+	TargetEndpoint string
 }
 
 // GetAllBuckets returns a list of all active buckets on the cluster.
 func (bm *BucketManager) GetAllBuckets(opts *GetAllBucketsOptions) (map[string]BucketSettings, error) {
+	bucketsData, err := bm.getAllBucketsData("GetAllBuckets", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[string]BucketSettings, len(bucketsData))
+	for _, bucketData := range bucketsData {
+		name, settings := bucketDataInToSettings(bucketData)
+		buckets[name] = settings
+	}
+
+	return buckets, nil
+}
+
+// NamedBucketSettings pairs the settings of a bucket with its name.
+type NamedBucketSettings struct {
+	Name     string
+	Settings BucketSettings
+}
+
+// GetAllBucketsOrdered returns all active buckets on the cluster in the order that the server lists them, which
+// reflects the order in which they were created.
+func (bm *BucketManager) GetAllBucketsOrdered(opts *GetAllBucketsOptions) ([]NamedBucketSettings, error) {
+	bucketsData, err := bm.getAllBucketsData("GetAllBucketsOrdered", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]NamedBucketSettings, len(bucketsData))
+	for i, bucketData := range bucketsData {
+		name, settings := bucketDataInToSettings(bucketData)
+		buckets[i] = NamedBucketSettings{
+			Name:     name,
+			Settings: settings,
+		}
+	}
+
+	return buckets, nil
+}
+
+func (bm *BucketManager) getAllBucketsData(opName string, opts *GetAllBucketsOptions) ([]*bucketDataIn, error) {
 	startTime := time.Now()
 	if opts == nil {
 		opts = &GetAllBucketsOptions{}
 	}
 
-	span := bm.tracer.StartSpan("GetAllBuckets", nil).
+	span := bm.tracer.StartSpan(opName, nil).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -276,12 +507,13 @@ func (bm *BucketManager) GetAllBuckets(opts *GetAllBucketsOptions) (map[string]B
 	}
 
 	retryStrategy := bm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
 	req := &gocbcore.HttpRequest{
 		Service:       gocbcore.ServiceType(MgmtService),
+		Endpoint:      opts.TargetEndpoint,
 		Path:          "/pools/default/buckets",
 		Method:        "GET",
 		Context:       ctx,
@@ -291,7 +523,7 @@ func (bm *BucketManager) GetAllBuckets(opts *GetAllBucketsOptions) (map[string]B
 	}
 
 	dspan := bm.tracer.StartSpan("dispatch", span.Context())
-	resp, err := bm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(bm.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -307,16 +539,9 @@ func (bm *BucketManager) GetAllBuckets(opts *GetAllBucketsOptions) (map[string]B
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return nil, bucketManagerError{message: string(data), statusCode: resp.StatusCode}
+	err = decodeMgmtError(resp, 200)
+	if err != nil {
+		return nil, makeBucketManagerError(req, err)
 	}
 
 	var bucketsData []*bucketDataIn
@@ -328,16 +553,10 @@ func (bm *BucketManager) GetAllBuckets(opts *GetAllBucketsOptions) (map[string]B
 
 	err = resp.Body.Close()
 	if err != nil {
-		logDebugf("Failed to close socket (%s)", err)
-	}
-
-	buckets := make(map[string]BucketSettings, len(bucketsData))
-	for _, bucketData := range bucketsData {
-		name, settings := bucketDataInToSettings(bucketData)
-		buckets[name] = settings
+		logDebugFieldsf(bucketMgrLogFields(req, ""), "Failed to close socket (%s)", err)
 	}
 
-	return buckets, nil
+	return bucketsData, nil
 }
 
 // CreateBucketOptions is the set of options available to the bucket manager CreateBucket operation.
@@ -345,6 +564,9 @@ type CreateBucketOptions struct {
 	Timeout       time.Duration
 	Context       context.Context
 	RetryStrategy RetryStrategy
+	// TargetEndpoint, if set, sends the request to this management endpoint (e.g. http://10.0.0.1:8091)
+	// rather than to one chosen by the SDK.
+	TargetEndpoint string
 }
 
 // CreateBucket creates a bucket on the cluster.
@@ -364,7 +586,7 @@ func (bm *BucketManager) CreateBucket(settings CreateBucketSettings, opts *Creat
 	}
 
 	retryStrategy := bm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -379,6 +601,7 @@ func (bm *BucketManager) CreateBucket(settings CreateBucketSettings, opts *Creat
 
 	req := &gocbcore.HttpRequest{
 		Service:       gocbcore.ServiceType(MgmtService),
+		Endpoint:      opts.TargetEndpoint,
 		Path:          "/pools/default/buckets",
 		Method:        "POST",
 		Body:          []byte(posts.Encode()),
@@ -389,7 +612,7 @@ func (bm *BucketManager) CreateBucket(settings CreateBucketSettings, opts *Creat
 	}
 
 	dspan := bm.tracer.StartSpan("dispatch", span.Context())
-	resp, err := bm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(bm.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -405,25 +628,14 @@ func (bm *BucketManager) CreateBucket(settings CreateBucketSettings, opts *Creat
 		return err
 	}
 
-	if resp.StatusCode != 202 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		bodyMessage := string(data)
-		return bucketManagerError{
-			message:    bodyMessage,
-			statusCode: resp.StatusCode,
-		}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return makeBucketManagerError(req, err)
 	}
 
 	err = resp.Body.Close()
 	if err != nil {
-		logDebugf("Failed to close socket (%s)", err)
+		logDebugFieldsf(bucketMgrLogFields(req, settings.Name), "Failed to close socket (%s)", err)
 	}
 
 	return nil
@@ -434,9 +646,13 @@ type UpdateBucketOptions struct {
 	Timeout       time.Duration
 	Context       context.Context
 	RetryStrategy RetryStrategy
+	// TargetEndpoint, if set, sends the request to this management endpoint (e.g. http://10.0.0.1:8091)
+	// rather than to one chosen by the SDK.
+	TargetEndpoint string
 }
 
 // UpdateBucket updates a bucket on the cluster.
+// NOTE: A Rank of 0 is not sent, so this cannot be used to reset the rank of a bucket back to 0.
 func (bm *BucketManager) UpdateBucket(settings BucketSettings, opts *UpdateBucketOptions) error {
 	startTime := time.Now()
 	if opts == nil {
@@ -453,7 +669,7 @@ func (bm *BucketManager) UpdateBucket(settings BucketSettings, opts *UpdateBucke
 	}
 
 	retryStrategy := bm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -464,6 +680,7 @@ func (bm *BucketManager) UpdateBucket(settings BucketSettings, opts *UpdateBucke
 
 	req := &gocbcore.HttpRequest{
 		Service:       gocbcore.ServiceType(MgmtService),
+		Endpoint:      opts.TargetEndpoint,
 		Path:          fmt.Sprintf("/pools/default/buckets/%s", settings.Name),
 		Method:        "POST",
 		Body:          []byte(posts.Encode()),
@@ -474,7 +691,7 @@ func (bm *BucketManager) UpdateBucket(settings BucketSettings, opts *UpdateBucke
 	}
 
 	dspan := bm.tracer.StartSpan("dispatch", span.Context())
-	resp, err := bm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(bm.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -490,21 +707,14 @@ func (bm *BucketManager) UpdateBucket(settings BucketSettings, opts *UpdateBucke
 		return err
 	}
 
-	if resp.StatusCode != 200 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return bucketManagerError{message: string(data), statusCode: resp.StatusCode}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return makeBucketManagerError(req, err)
 	}
 
 	err = resp.Body.Close()
 	if err != nil {
-		logDebugf("Failed to close socket (%s)", err)
+		logDebugFieldsf(bucketMgrLogFields(req, settings.Name), "Failed to close socket (%s)", err)
 	}
 
 	return nil
@@ -515,6 +725,9 @@ type DropBucketOptions struct {
 	Timeout       time.Duration
 	Context       context.Context
 	RetryStrategy RetryStrategy
+	// TargetEndpoint, if set, sends the request to this management endpoint (e.g. http://10.0.0.1:8091)
+	// rather than to one chosen by the SDK.
+	TargetEndpoint string
 }
 
 // DropBucket will delete a bucket from the cluster by name.
@@ -534,12 +747,13 @@ func (bm *BucketManager) DropBucket(name string, opts *DropBucketOptions) error
 	}
 
 	retryStrategy := bm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
 	req := &gocbcore.HttpRequest{
 		Service:       gocbcore.ServiceType(MgmtService),
+		Endpoint:      opts.TargetEndpoint,
 		Path:          fmt.Sprintf("/pools/default/buckets/%s", name),
 		Method:        "DELETE",
 		Context:       ctx,
@@ -548,7 +762,7 @@ func (bm *BucketManager) DropBucket(name string, opts *DropBucketOptions) error
 	}
 
 	dspan := bm.tracer.StartSpan("dispatch", span.Context())
-	resp, err := bm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(bm.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -564,21 +778,14 @@ func (bm *BucketManager) DropBucket(name string, opts *DropBucketOptions) error
 		return err
 	}
 
-	if resp.StatusCode != 200 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return bucketManagerError{message: string(data), statusCode: resp.StatusCode}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return makeBucketManagerError(req, err)
 	}
 
 	err = resp.Body.Close()
 	if err != nil {
-		logDebugf("Failed to close socket (%s)", err)
+		logDebugFieldsf(bucketMgrLogFields(req, name), "Failed to close socket (%s)", err)
 	}
 
 	return nil
@@ -589,6 +796,9 @@ type FlushBucketOptions struct {
 	Timeout       time.Duration
 	Context       context.Context
 	RetryStrategy RetryStrategy
+	// TargetEndpoint, if set, sends the request to this management endpoint (e.g. http://10.0.0.1:8091)
+	// rather than to one chosen by the SDK.
+	TargetEndpoint string
 }
 
 // FlushBucket will delete all the of the data from a bucket.
@@ -609,12 +819,13 @@ func (bm *BucketManager) FlushBucket(name string, opts *FlushBucketOptions) erro
 	}
 
 	retryStrategy := bm.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
 	req := &gocbcore.HttpRequest{
 		Service:       gocbcore.ServiceType(MgmtService),
+		Endpoint:      opts.TargetEndpoint,
 		Path:          fmt.Sprintf("/pools/default/buckets/%s/controller/doFlush", name),
 		Method:        "POST",
 		Context:       ctx,
@@ -623,7 +834,7 @@ func (bm *BucketManager) FlushBucket(name string, opts *FlushBucketOptions) erro
 	}
 
 	dspan := bm.tracer.StartSpan("dispatch", span.Context())
-	resp, err := bm.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(bm.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -639,26 +850,23 @@ func (bm *BucketManager) FlushBucket(name string, opts *FlushBucketOptions) erro
 		return err
 	}
 
-	if resp.StatusCode != 200 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return bucketManagerError{message: string(data), statusCode: resp.StatusCode}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return makeBucketManagerError(req, err)
 	}
 
 	err = resp.Body.Close()
 	if err != nil {
-		logDebugf("Failed to close socket (%s)", err)
+		logDebugFieldsf(bucketMgrLogFields(req, name), "Failed to close socket (%s)", err)
 	}
 
 	return nil
 }
 
+// minBucketRAMQuotaMB is the smallest per node memory quota that the server accepts for couchbase and ephemeral
+// buckets.
+const minBucketRAMQuotaMB = 100
+
 func (bm *BucketManager) settingsToPostData(settings *BucketSettings) (url.Values, error) {
 	posts := url.Values{}
 
@@ -666,8 +874,13 @@ func (bm *BucketManager) settingsToPostData(settings *BucketSettings) (url.Value
 		return nil, invalidArgumentsError{message: "Name invalid, must be set."}
 	}
 
-	if settings.RAMQuotaMB < 100 {
-		return nil, invalidArgumentsError{message: "Memory quota invalid, must be greater than 100MB"}
+	// The quota is per node, memcached buckets are left for the server to validate as their quota is applied
+	// differently.
+	if settings.BucketType != MemcachedBucketType && settings.RAMQuotaMB < minBucketRAMQuotaMB {
+		return nil, invalidArgumentsError{
+			message: fmt.Sprintf("Memory quota invalid, %s buckets require at least %dMB per node but was %dMB",
+				settings.BucketType, minBucketRAMQuotaMB, settings.RAMQuotaMB),
+		}
 	}
 
 	posts.Add("name", settings.Name)
@@ -679,24 +892,29 @@ func (bm *BucketManager) settingsToPostData(settings *BucketSettings) (url.Value
 		posts.Add("flushEnabled", "0")
 	}
 
-	if settings.ReplicaIndexDisabled {
-		posts.Add("replicaIndex", "0")
-	} else {
-		posts.Add("replicaIndex", "1")
-	}
-
 	switch settings.BucketType {
 	case CouchbaseBucketType:
 		posts.Add("bucketType", string(settings.BucketType))
 		posts.Add("replicaNumber", fmt.Sprintf("%d", settings.NumReplicas))
+		if settings.ReplicaIndexDisabled {
+			posts.Add("replicaIndex", "0")
+		} else {
+			posts.Add("replicaIndex", "1")
+		}
 	case MemcachedBucketType:
 		posts.Add("bucketType", string(settings.BucketType))
 		if settings.NumReplicas > 0 {
 			return nil, invalidArgumentsError{message: "replicas cannot be used with memcached buckets"}
 		}
+		if settings.ReplicaIndexDisabled {
+			return nil, invalidArgumentsError{message: "replica indexes cannot be configured for memcached buckets"}
+		}
 	case EphemeralBucketType:
 		posts.Add("bucketType", string(settings.BucketType))
 		posts.Add("replicaNumber", fmt.Sprintf("%d", settings.NumReplicas))
+		if settings.ReplicaIndexDisabled {
+			return nil, invalidArgumentsError{message: "replica indexes cannot be configured for ephemeral buckets"}
+		}
 	default:
 		return nil, invalidArgumentsError{message: "Unrecognized bucket type"}
 	}
@@ -715,5 +933,11 @@ func (bm *BucketManager) settingsToPostData(settings *BucketSettings) (url.Value
 		posts.Add("compressionMode", string(settings.CompressionMode))
 	}
 
+	// 0 is the server default so it is omitted to avoid sending rank to servers which don't support it, this does mean
+	// that a rank can never be reset to 0 through UpdateBucket.
+	if settings.Rank != 0 {
+		posts.Add("rank", fmt.Sprintf("%d", settings.Rank))
+	}
+
 	return posts, nil
 }
@@ -1,6 +1,11 @@
 package gocb
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,8 +37,9 @@ func TestBucketMgrOps(t *testing.T) {
 			MaxTTL:               10,
 			CompressionMode:      CompressionModeActive,
 			ReplicaIndexDisabled: true,
+
+			ConflictResolutionType: ConflictResolutionTypeSequenceNumber,
 		},
-		ConflictResolutionType: ConflictResolutionTypeSequenceNumber,
 	}, nil)
 	if err != nil {
 		t.Fatalf("Failed to create bucket manager %v", err)
@@ -101,3 +107,690 @@ func TestBucketMgrOps(t *testing.T) {
 		t.Fatalf("Failed to drop bucket manager %v", err)
 	}
 }
+
+func TestBucketMgrGetBucketConflictResolutionType(t *testing.T) {
+	for _, crType := range []ConflictResolutionType{ConflictResolutionTypeTimestamp, ConflictResolutionTypeSequenceNumber} {
+		t.Run(string(crType), func(t *testing.T) {
+			body := fmt.Sprintf(`{"name":"test","bucketType":"membase","replicaNumber":1,"conflictResolutionType":"%s"}`,
+				crType)
+
+			doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+				if req.Path != "/pools/default/buckets/test" {
+					t.Fatalf("Expected path to be /pools/default/buckets/test but was %s", req.Path)
+				}
+
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8091",
+					StatusCode: 200,
+					Body:       &testReadCloser{bytes.NewBufferString(body), nil},
+				}, nil
+			}
+
+			mgr := &BucketManager{
+				httpClient:    &mockHTTPProvider{doFn: doHTTP},
+				globalTimeout: 10 * time.Second,
+				tracer:        &noopTracer{},
+			}
+
+			settings, err := mgr.GetBucket("test", nil)
+			if err != nil {
+				t.Fatalf("Expected GetBucket to succeed but was %v", err)
+			}
+
+			if settings.ConflictResolutionType != crType {
+				t.Fatalf("Expected ConflictResolutionType to be %s but was %s", crType, settings.ConflictResolutionType)
+			}
+		})
+	}
+}
+
+func TestBucketMgrCreateBucketConflictResolutionType(t *testing.T) {
+	var body []byte
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		body = req.Body
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 202,
+			Body:       &testReadCloser{bytes.NewBuffer(nil), nil},
+		}, nil
+	}
+
+	mgr := &BucketManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	err := mgr.CreateBucket(CreateBucketSettings{
+		BucketSettings: BucketSettings{
+			Name:       "test",
+			RAMQuotaMB: 100,
+			BucketType: CouchbaseBucketType,
+
+			ConflictResolutionType: ConflictResolutionTypeTimestamp,
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected CreateBucket to succeed but was %v", err)
+	}
+
+	if !strings.Contains(string(body), "conflictResolutionType=lww") {
+		t.Fatalf("Expected conflictResolutionType to be sent as lww but body was %s", body)
+	}
+}
+
+func TestBucketMgrBucketHealth(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("bucket_health_warmup")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Path != "/pools/default/buckets/test" {
+			t.Fatalf("Expected path to be /pools/default/buckets/test but was %s", req.Path)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	mgr := &BucketManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	health, err := mgr.BucketHealth("test", nil)
+	if err != nil {
+		t.Fatalf("Expected BucketHealth to succeed but was %v", err)
+	}
+
+	if health.Ready {
+		t.Fatalf("Expected bucket not to be ready whilst a node is warming up")
+	}
+
+	expected := []BucketNodeHealth{
+		{Hostname: "10.112.191.101:8091", Status: "healthy"},
+		{Hostname: "10.112.191.102:8091", Status: "warmup"},
+	}
+	if len(health.Nodes) != len(expected) {
+		t.Fatalf("Expected %d nodes but was %d", len(expected), len(health.Nodes))
+	}
+
+	for i, node := range expected {
+		if health.Nodes[i] != node {
+			t.Fatalf("Expected node %d to be %v but was %v", i, node, health.Nodes[i])
+		}
+	}
+}
+
+func TestBucketDataInToHealthReady(t *testing.T) {
+	var data bucketDataIn
+	err := json.Unmarshal([]byte(`{"nodes":[{"hostname":"a:8091","status":"healthy"},{"hostname":"b:8091","status":"healthy"}]}`), &data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal bucket data: %v", err)
+	}
+
+	if !bucketDataInToHealth(&data).Ready {
+		t.Fatalf("Expected bucket to be ready when all nodes are healthy")
+	}
+
+	if bucketDataInToHealth(&bucketDataIn{}).Ready {
+		t.Fatalf("Expected bucket not to be ready without any nodes")
+	}
+}
+
+func TestBucketMgrTargetEndpoint(t *testing.T) {
+	endpoint := "http://10.112.191.102:8091"
+	var endpoints []string
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		endpoints = append(endpoints, req.Endpoint)
+
+		body := `{"name":"test","bucketType":"membase","replicaNumber":1}`
+		if req.Path == "/pools/default/buckets" {
+			body = "[" + body + "]"
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   req.Endpoint,
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(body), nil},
+		}, nil
+	}
+
+	mgr := &BucketManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	_, err := mgr.GetBucket("test", &GetBucketOptions{TargetEndpoint: endpoint})
+	if err != nil {
+		t.Fatalf("Expected GetBucket to succeed but was %v", err)
+	}
+
+	_, err = mgr.GetAllBuckets(&GetAllBucketsOptions{TargetEndpoint: endpoint})
+	if err != nil {
+		t.Fatalf("Expected GetAllBuckets to succeed but was %v", err)
+	}
+
+	err = mgr.FlushBucket("test", &FlushBucketOptions{TargetEndpoint: endpoint})
+	if err != nil {
+		t.Fatalf("Expected FlushBucket to succeed but was %v", err)
+	}
+
+	_, err = mgr.GetBucket("test", nil)
+	if err != nil {
+		t.Fatalf("Expected GetBucket to succeed but was %v", err)
+	}
+
+	expected := []string{endpoint, endpoint, endpoint, ""}
+	if len(endpoints) != len(expected) {
+		t.Fatalf("Expected %d requests but was %d", len(expected), len(endpoints))
+	}
+
+	for i, e := range expected {
+		if endpoints[i] != e {
+			t.Fatalf("Expected request %d to target endpoint %q but was %q", i, e, endpoints[i])
+		}
+	}
+}
+
+func TestBucketMgrSettingsToPostDataRAMQuota(t *testing.T) {
+	type tCase struct {
+		name       string
+		bucketType BucketType
+		quota      int
+		expectErr  bool
+	}
+
+	testCases := []tCase{
+		{name: "couchbase", bucketType: CouchbaseBucketType, quota: 100},
+		{name: "couchbase too small", bucketType: CouchbaseBucketType, quota: 99, expectErr: true},
+		{name: "ephemeral", bucketType: EphemeralBucketType, quota: 100},
+		{name: "ephemeral too small", bucketType: EphemeralBucketType, quota: 50, expectErr: true},
+		{name: "memcached", bucketType: MemcachedBucketType, quota: 64},
+	}
+
+	mgr := &BucketManager{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			posts, err := mgr.settingsToPostData(&BucketSettings{
+				Name:       "test",
+				BucketType: tc.bucketType,
+				RAMQuotaMB: tc.quota,
+			})
+			if tc.expectErr {
+				if !IsInvalidArgumentsError(err) {
+					t.Fatalf("Expected error to be invalid arguments but was %v", err)
+				}
+
+				if !strings.Contains(err.Error(), "100MB per node") {
+					t.Fatalf("Expected error to explain the per node minimum but was %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected settings to be valid but was %v", err)
+			}
+
+			if posts.Get("ramQuotaMB") != fmt.Sprintf("%d", tc.quota) {
+				t.Fatalf("Expected ramQuotaMB to be %d but was %s", tc.quota, posts.Get("ramQuotaMB"))
+			}
+		})
+	}
+}
+
+func TestBucketMgrGetAllBucketsOrdered(t *testing.T) {
+	body := `[
+		{"name":"travel-sample","bucketType":"membase","replicaNumber":1,"quota":{"ram":209715200,"rawRAM":209715200}},
+		{"name":"beer-sample","bucketType":"membase","replicaNumber":2,"quota":{"ram":104857600,"rawRAM":104857600}},
+		{"name":"cache","bucketType":"memcached","replicaNumber":0,"quota":{"ram":104857600,"rawRAM":104857600}},
+		{"name":"analytics","bucketType":"ephemeral","replicaNumber":1,"quota":{"ram":314572800,"rawRAM":314572800}}
+	]`
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Path != "/pools/default/buckets" {
+			t.Fatalf("Expected path to be /pools/default/buckets but was %s", req.Path)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(body), nil},
+		}, nil
+	}
+
+	mgr := &BucketManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	buckets, err := mgr.GetAllBucketsOrdered(nil)
+	if err != nil {
+		t.Fatalf("Expected GetAllBucketsOrdered to succeed but was %v", err)
+	}
+
+	expected := []struct {
+		name       string
+		bucketType BucketType
+		replicas   int
+		ramQuotaMB int
+	}{
+		{"travel-sample", CouchbaseBucketType, 1, 200},
+		{"beer-sample", CouchbaseBucketType, 2, 100},
+		{"cache", MemcachedBucketType, 0, 100},
+		{"analytics", EphemeralBucketType, 1, 300},
+	}
+
+	if len(buckets) != len(expected) {
+		t.Fatalf("Expected %d buckets but was %d", len(expected), len(buckets))
+	}
+
+	for i, bucket := range buckets {
+		if bucket.Name != expected[i].name || bucket.Settings.Name != expected[i].name {
+			t.Fatalf("Expected bucket %d to be %s but was %s", i, expected[i].name, bucket.Name)
+		}
+		if bucket.Settings.BucketType != expected[i].bucketType {
+			t.Fatalf("Expected bucket %s to be type %s but was %s", bucket.Name, expected[i].bucketType,
+				bucket.Settings.BucketType)
+		}
+		if bucket.Settings.NumReplicas != expected[i].replicas {
+			t.Fatalf("Expected bucket %s to have %d replicas but was %d", bucket.Name, expected[i].replicas,
+				bucket.Settings.NumReplicas)
+		}
+		if bucket.Settings.RAMQuotaMB != expected[i].ramQuotaMB {
+			t.Fatalf("Expected bucket %s to have a %dMB quota but was %d", bucket.Name, expected[i].ramQuotaMB,
+				bucket.Settings.RAMQuotaMB)
+		}
+	}
+}
+
+func TestBucketMgrSettingsToPostDataReplicaIndex(t *testing.T) {
+	type tCase struct {
+		name                 string
+		bucketType           BucketType
+		replicaIndexDisabled bool
+		expectErr            bool
+		expectReplicaIndex   string
+	}
+
+	testCases := []tCase{
+		{name: "couchbase", bucketType: CouchbaseBucketType, expectReplicaIndex: "1"},
+		{name: "couchbase disabled", bucketType: CouchbaseBucketType, replicaIndexDisabled: true, expectReplicaIndex: "0"},
+		{name: "ephemeral", bucketType: EphemeralBucketType},
+		{name: "ephemeral disabled", bucketType: EphemeralBucketType, replicaIndexDisabled: true, expectErr: true},
+		{name: "memcached", bucketType: MemcachedBucketType},
+		{name: "memcached disabled", bucketType: MemcachedBucketType, replicaIndexDisabled: true, expectErr: true},
+	}
+
+	mgr := &BucketManager{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			posts, err := mgr.settingsToPostData(&BucketSettings{
+				Name:                 "test",
+				BucketType:           tc.bucketType,
+				RAMQuotaMB:           100,
+				ReplicaIndexDisabled: tc.replicaIndexDisabled,
+			})
+			if tc.expectErr {
+				if !IsInvalidArgumentsError(err) {
+					t.Fatalf("Expected error to be invalid arguments but was %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected settings to be valid but was %v", err)
+			}
+
+			replicaIndex, ok := posts["replicaIndex"]
+			if tc.expectReplicaIndex == "" {
+				if ok {
+					t.Fatalf("Expected replicaIndex not to be sent but was %v", replicaIndex)
+				}
+
+				return
+			}
+
+			if posts.Get("replicaIndex") != tc.expectReplicaIndex {
+				t.Fatalf("Expected replicaIndex to be %s but was %v", tc.expectReplicaIndex, replicaIndex)
+			}
+		})
+	}
+}
+
+func TestBucketMgrEphemeralSettingsRoundTrip(t *testing.T) {
+	_, settings := bucketDataInToSettings(&bucketDataIn{
+		Name:       "cache",
+		BucketType: "ephemeral",
+	})
+
+	if settings.ReplicaIndexDisabled {
+		t.Fatalf("Expected replica index not to be reported as disabled for an ephemeral bucket")
+	}
+
+	settings.RAMQuotaMB = 100
+	_, err := (&BucketManager{}).settingsToPostData(&settings)
+	if err != nil {
+		t.Fatalf("Expected settings fetched from the server to be valid but was %v", err)
+	}
+}
+
+func TestBucketMgrGetMemcachedBucket(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("bucket_memcached")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	mgr := &BucketManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	settings, err := mgr.GetBucket("sessions", nil)
+	if err != nil {
+		t.Fatalf("Expected GetBucket to succeed but was %v", err)
+	}
+
+	if settings.BucketType != MemcachedBucketType {
+		t.Fatalf("Expected bucket type to be memcached but was %s", settings.BucketType)
+	}
+
+	if settings.NumReplicas != 0 {
+		t.Fatalf("Expected replicas not to be reported for a memcached bucket but was %d", settings.NumReplicas)
+	}
+
+	if settings.ReplicaIndexDisabled {
+		t.Fatalf("Expected replica index not to be reported as disabled for a memcached bucket")
+	}
+
+	if settings.RAMQuotaMB != 100 || !settings.FlushEnabled {
+		t.Fatalf("Expected common settings to be decoded but were %+v", settings)
+	}
+
+	_, err = mgr.settingsToPostData(settings)
+	if err != nil {
+		t.Fatalf("Expected settings fetched from the server to be valid but was %v", err)
+	}
+}
+
+func TestBucketMgrRankRoundTrip(t *testing.T) {
+	var bucketData bucketDataIn
+	err := json.Unmarshal([]byte(`{"name":"orders","bucketType":"membase","quota":{"rawRAM":104857600},"rank":10}`),
+		&bucketData)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal bucket data: %v", err)
+	}
+
+	_, settings := bucketDataInToSettings(&bucketData)
+	if settings.Rank != 10 {
+		t.Fatalf("Expected rank to be 10 but was %d", settings.Rank)
+	}
+
+	posts, err := (&BucketManager{}).settingsToPostData(&settings)
+	if err != nil {
+		t.Fatalf("Expected settings fetched from the server to be valid but was %v", err)
+	}
+
+	if posts.Get("rank") != "10" {
+		t.Fatalf("Expected rank to be sent as 10 but was %s", posts.Get("rank"))
+	}
+
+	settings.Rank = 0
+	posts, err = (&BucketManager{}).settingsToPostData(&settings)
+	if err != nil {
+		t.Fatalf("Expected settings without a rank to be valid but was %v", err)
+	}
+
+	if _, ok := posts["rank"]; ok {
+		t.Fatalf("Expected rank not to be sent when unset")
+	}
+}
+
+func testBucketMgrStatusSequence(statuses []int, attempts *int) *BucketManager {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		status := statuses[*attempts]
+		*attempts++
+
+		body := `{"name":"test","bucketType":"membase"}`
+		if status != 200 {
+			body = "request failed"
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: status,
+			Body:       &testReadCloser{bytes.NewBufferString(body), nil},
+		}, nil
+	}
+
+	return &BucketManager{
+		httpClient:           &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout:        10 * time.Second,
+		defaultRetryStrategy: newRetryStrategyWrapper(NewBestEffortRetryStrategy(nil)),
+		tracer:               &noopTracer{},
+	}
+}
+
+func TestBucketMgrRetriesServiceUnavailable(t *testing.T) {
+	var attempts int
+	mgr := testBucketMgrStatusSequence([]int{503, 500, 200}, &attempts)
+
+	bucket, err := mgr.GetBucket("test", nil)
+	if err != nil {
+		t.Fatalf("Expected GetBucket to succeed after retrying but was %v", err)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("Expected 3 attempts but was %d", attempts)
+	}
+
+	if bucket.Name != "test" {
+		t.Fatalf("Expected bucket to be test but was %s", bucket.Name)
+	}
+}
+
+func TestBucketMgrRetriesServiceUnavailableUntilTimeout(t *testing.T) {
+	var attempts int
+	statuses := make([]int, 1000)
+	for i := range statuses {
+		statuses[i] = 503
+	}
+	mgr := testBucketMgrStatusSequence(statuses, &attempts)
+
+	_, err := mgr.GetBucket("test", &GetBucketOptions{Timeout: 50 * time.Millisecond})
+	if !IsTimeoutError(err) {
+		t.Fatalf("Expected error to be timeout but was %v", err)
+	}
+
+	if attempts < 2 {
+		t.Fatalf("Expected request to be retried but was attempted %d times", attempts)
+	}
+}
+
+func TestBucketMgrDoesNotRetryBadRequest(t *testing.T) {
+	var attempts int
+	mgr := testBucketMgrStatusSequence([]int{400, 200}, &attempts)
+
+	_, err := mgr.GetBucket("test", nil)
+	if err == nil {
+		t.Fatalf("Expected GetBucket to fail")
+	}
+
+	if attempts != 1 {
+		t.Fatalf("Expected 1 attempt but was %d", attempts)
+	}
+
+	if IsRetryableError(err) {
+		t.Fatalf("Expected error not to be retryable")
+	}
+
+	bErr, ok := err.(BucketManagerError)
+	if !ok || bErr.HTTPStatus() != 400 {
+		t.Fatalf("Expected a bucket manager error with status 400 but was %v", err)
+	}
+}
+
+func TestBucketMgrDoesNotRetryNonIdempotentRequest(t *testing.T) {
+	var attempts int
+	mgr := testBucketMgrStatusSequence([]int{503, 200}, &attempts)
+
+	err := mgr.CreateBucket(CreateBucketSettings{
+		BucketSettings: BucketSettings{
+			Name:       "test",
+			RAMQuotaMB: 100,
+			BucketType: CouchbaseBucketType,
+		},
+	}, nil)
+	if err == nil {
+		t.Fatalf("Expected CreateBucket to fail")
+	}
+
+	if attempts != 1 {
+		t.Fatalf("Expected 1 attempt but was %d", attempts)
+	}
+
+	if IsRetryableError(err) {
+		t.Fatalf("Expected error not to be retryable")
+	}
+
+	bErr, ok := err.(BucketManagerError)
+	if !ok || bErr.HTTPStatus() != 503 {
+		t.Fatalf("Expected a bucket manager error with status 503 but was %v", err)
+	}
+}
+
+func TestBucketMgrServiceUnavailableWithoutRetryStrategy(t *testing.T) {
+	var attempts int
+	mgr := testBucketMgrStatusSequence([]int{503, 200}, &attempts)
+	mgr.defaultRetryStrategy = nil
+
+	_, err := mgr.GetBucket("test", nil)
+	if !IsRetryableError(err) {
+		t.Fatalf("Expected error to be retryable but was %v", err)
+	}
+
+	if attempts != 1 {
+		t.Fatalf("Expected 1 attempt but was %d", attempts)
+	}
+}
+
+// testBlockingMgmtHTTPProvider returns a provider whose requests block until their context is done, as gocbcore does
+// for a request which is in flight when its context is cancelled.
+func testBlockingMgmtHTTPProvider() *mockHTTPProvider {
+	return &mockHTTPProvider{
+		doFn: func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+			<-req.Context.Done()
+			return nil, req.Context.Err()
+		},
+	}
+}
+
+// testCancelAfter returns a context which is cancelled shortly after the request using it is dispatched.
+func testCancelAfter() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	return ctx
+}
+
+func TestMgmtCancelledContext(t *testing.T) {
+	bucketMgr := &BucketManager{
+		httpClient:    testBlockingMgmtHTTPProvider(),
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+	userMgr := &UserManager{
+		httpClient:    testBlockingMgmtHTTPProvider(),
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+	viewMgr := &ViewIndexManager{
+		bucketName:    "default",
+		httpClient:    testBlockingMgmtHTTPProvider(),
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	ops := map[string]func() error{
+		"GetBucket": func() error {
+			_, err := bucketMgr.GetBucket("test", &GetBucketOptions{Context: testCancelAfter()})
+			return err
+		},
+		"GetUser": func() error {
+			_, err := userMgr.GetUser("barry", &GetUserOptions{Context: testCancelAfter()})
+			return err
+		},
+		"GetDesignDocument": func() error {
+			_, err := viewMgr.GetDesignDocument("test", DevelopmentDesignDocumentNamespace,
+				&GetDesignDocumentOptions{Context: testCancelAfter()})
+			return err
+		},
+	}
+
+	for name, op := range ops {
+		err := op()
+		if !IsCancelledError(err) {
+			t.Fatalf("Expected %s error to be cancelled but was %v", name, err)
+		}
+
+		if IsTimeoutError(err) {
+			t.Fatalf("Expected %s error not to be a timeout", name)
+		}
+	}
+}
+
+func TestMgmtCancelledContextDuringRetry(t *testing.T) {
+	mgr := &BucketManager{
+		httpClient: &mockHTTPProvider{
+			doFn: func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8091",
+					StatusCode: 503,
+					Body:       &testReadCloser{bytes.NewBufferString("service unavailable"), nil},
+				}, nil
+			},
+		},
+		globalTimeout:        10 * time.Second,
+		defaultRetryStrategy: newRetryStrategyWrapper(NewBestEffortRetryStrategy(nil)),
+		tracer:               &noopTracer{},
+	}
+
+	_, err := mgr.GetBucket("test", &GetBucketOptions{Context: testCancelAfter()})
+	if !IsCancelledError(err) {
+		t.Fatalf("Expected error to be cancelled but was %v", err)
+	}
+}
+
+func TestMgmtTimeoutIsNotCancelled(t *testing.T) {
+	mgr := &BucketManager{
+		httpClient:    testBlockingMgmtHTTPProvider(),
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	_, err := mgr.GetBucket("test", &GetBucketOptions{Timeout: 20 * time.Millisecond})
+	if !IsTimeoutError(err) {
+		t.Fatalf("Expected error to be a timeout but was %v", err)
+	}
+
+	if IsCancelledError(err) {
+		t.Fatalf("Expected timeout not to be reported as cancelled")
+	}
+}
@@ -0,0 +1,158 @@
+package gocb
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// configWatcherInterval is how often the config revision is checked for changes.
+	configWatcherInterval = 1 * time.Second
+	// configWatcherBufferSize is the number of revision changes which can be waiting to be dispatched to callbacks.
+	configWatcherBufferSize = 16
+)
+
+// configWatcher polls the config revision reported by gocbcore and notifies callbacks whenever it changes. Callbacks
+// are invoked from a separate dispatch routine through a buffered channel so that a slow callback never holds up
+// detecting further changes.
+type configWatcher struct {
+	interval    time.Duration
+	getProvider func() (diagnosticsProvider, error)
+
+	lock      sync.Mutex
+	callbacks []func(rev int64)
+	started   bool
+	lastRev   int64
+
+	revCh     chan int64
+	killCh    chan struct{}
+	watchDone sync.WaitGroup
+}
+
+func newConfigWatcher(interval time.Duration, getProvider func() (diagnosticsProvider, error)) *configWatcher {
+	return &configWatcher{
+		interval:    interval,
+		getProvider: getProvider,
+		lastRev:     -1,
+		revCh:       make(chan int64, configWatcherBufferSize),
+		killCh:      make(chan struct{}),
+	}
+}
+
+// subscribe registers fn to be called on each config revision change, starting the watcher if it is not running.
+func (w *configWatcher) subscribe(fn func(rev int64)) {
+	w.lock.Lock()
+	w.callbacks = append(w.callbacks, fn)
+	start := !w.started
+	w.started = true
+	w.lock.Unlock()
+
+	if start {
+		w.watchDone.Add(1)
+		go w.watchRoutine()
+		go w.dispatchRoutine()
+	}
+}
+
+// close stops the watcher, waiting for any in progress revision check to complete. A callback which is already running
+// is not waited for but no further callbacks are started.
+func (w *configWatcher) close() {
+	w.lock.Lock()
+	started := w.started
+	w.started = false
+	w.lock.Unlock()
+
+	if started {
+		close(w.killCh)
+		w.watchDone.Wait()
+	}
+}
+
+func (w *configWatcher) watchRoutine() {
+	defer w.watchDone.Done()
+
+	for {
+		w.checkRevision()
+
+		select {
+		case <-time.After(w.interval):
+		case <-w.killCh:
+			return
+		}
+	}
+}
+
+func (w *configWatcher) checkRevision() {
+	provider, err := w.getProvider()
+	if err != nil {
+		logDebugf("Failed to get diagnostics provider to check config revision (%s)", err)
+		return
+	}
+
+	info, err := provider.Diagnostics()
+	if err != nil {
+		logDebugf("Failed to get diagnostics to check config revision (%s)", err)
+		return
+	}
+
+	w.lock.Lock()
+	lastRev := w.lastRev
+	if info.ConfigRev > lastRev {
+		w.lastRev = info.ConfigRev
+	}
+	w.lock.Unlock()
+
+	// The first revision seen is where the watcher starts from rather than a change.
+	if lastRev < 0 || info.ConfigRev <= lastRev {
+		return
+	}
+
+	select {
+	case w.revCh <- info.ConfigRev:
+	default:
+		// The callbacks have fallen behind, drop the oldest change rather than waiting for them.
+		select {
+		case <-w.revCh:
+		default:
+		}
+		select {
+		case w.revCh <- info.ConfigRev:
+		default:
+		}
+	}
+}
+
+func (w *configWatcher) dispatchRoutine() {
+	for {
+		select {
+		case rev := <-w.revCh:
+			w.lock.Lock()
+			callbacks := make([]func(rev int64), len(w.callbacks))
+			copy(callbacks, w.callbacks)
+			w.lock.Unlock()
+
+			for _, callback := range callbacks {
+				callback(rev)
+			}
+		case <-w.killCh:
+			return
+		}
+	}
+}
+
+// OnConfigChange registers fn to be called whenever the cluster config revision changes, e.g. following a rebalance
+// or failover, so that applications can react to topology changes. Callbacks are called in the order that they were
+// registered, one change at a time, from a routine separate to the one which checks the revision, and are not
+// called for the revision in place when the first callback is registered. Callbacks stop when the cluster is closed.
+//
+// Volatile: This API is subject to change at any time.
+func (c *Cluster) OnConfigChange(fn func(rev int64)) {
+	c.clusterLock.Lock()
+	if c.configWatcher == nil {
+		c.configWatcher = newConfigWatcher(configWatcherInterval, c.getDiagnosticsProvider)
+	}
+	watcher := c.configWatcher
+	c.clusterLock.Unlock()
+
+	watcher.subscribe(fn)
+}
@@ -0,0 +1,131 @@
+package gocb
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocbcore/v8"
+)
+
+// testRevDiagnosticsProvider reports whichever config revision it has most recently been set to.
+type testRevDiagnosticsProvider struct {
+	rev int64
+}
+
+func (provider *testRevDiagnosticsProvider) Diagnostics() (*gocbcore.DiagnosticInfo, error) {
+	return &gocbcore.DiagnosticInfo{ConfigRev: atomic.LoadInt64(&provider.rev)}, nil
+}
+
+func (provider *testRevDiagnosticsProvider) setRev(rev int64) {
+	atomic.StoreInt64(&provider.rev, rev)
+}
+
+func testWaitForConfigRev(t *testing.T, revs chan int64, expected int64) {
+	select {
+	case rev := <-revs:
+		if rev != expected {
+			t.Fatalf("Expected callback for revision %d but was %d", expected, rev)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for callback for revision %d", expected)
+	}
+}
+
+func TestConfigWatcherCallbackPerChange(t *testing.T) {
+	provider := &testRevDiagnosticsProvider{rev: 5}
+	watcher := newConfigWatcher(5*time.Millisecond, func() (diagnosticsProvider, error) {
+		return provider, nil
+	})
+	defer watcher.close()
+
+	revs := make(chan int64, 10)
+	watcher.subscribe(func(rev int64) {
+		revs <- rev
+	})
+
+	// Give the watcher time to see the initial revision, which should not be reported as a change.
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case rev := <-revs:
+		t.Fatalf("Expected no callback for the initial revision but got %d", rev)
+	default:
+	}
+
+	for _, rev := range []int64{6, 7, 9} {
+		provider.setRev(rev)
+		testWaitForConfigRev(t, revs, rev)
+	}
+
+	// An unchanged revision should not cause any further callbacks.
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case rev := <-revs:
+		t.Fatalf("Expected no callback for an unchanged revision but got %d", rev)
+	default:
+	}
+}
+
+func TestConfigWatcherSlowCallbackDoesNotBlock(t *testing.T) {
+	provider := &testRevDiagnosticsProvider{rev: 1}
+	watcher := newConfigWatcher(1*time.Millisecond, func() (diagnosticsProvider, error) {
+		return provider, nil
+	})
+	defer watcher.close()
+
+	release := make(chan struct{})
+	revs := make(chan int64, 100)
+	watcher.subscribe(func(rev int64) {
+		revs <- rev
+		<-release
+	})
+
+	time.Sleep(20 * time.Millisecond)
+	provider.setRev(2)
+	testWaitForConfigRev(t, revs, 2)
+
+	// The callback is now blocked, the watcher should carry on seeing changes rather than waiting for it.
+	for rev := int64(3); rev <= configWatcherBufferSize+10; rev++ {
+		provider.setRev(rev)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	watcher.lock.Lock()
+	lastRev := watcher.lastRev
+	watcher.lock.Unlock()
+	if lastRev != configWatcherBufferSize+10 {
+		t.Fatalf("Expected watcher to have seen revision %d but was %d", configWatcherBufferSize+10, lastRev)
+	}
+
+	close(release)
+}
+
+func TestClusterCloseStopsConfigWatcher(t *testing.T) {
+	provider := &testRevDiagnosticsProvider{rev: 1}
+	c := &Cluster{
+		connections: map[string]client{
+			"mock-false": &mockClient{mockDiagnosticsProvider: provider},
+		},
+	}
+
+	c.OnConfigChange(func(rev int64) {})
+	watcher := c.configWatcher
+	if watcher == nil {
+		t.Fatalf("Expected OnConfigChange to start a config watcher")
+	}
+
+	err := c.Close(nil)
+	if err != nil {
+		t.Fatalf("Expected Close to succeed but was %v", err)
+	}
+
+	if c.configWatcher != nil {
+		t.Fatalf("Expected Close to remove the config watcher")
+	}
+
+	select {
+	case <-watcher.killCh:
+	default:
+		t.Fatalf("Expected Close to stop the config watcher")
+	}
+}
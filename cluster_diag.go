@@ -1,7 +1,9 @@
 package gocb
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -195,3 +197,182 @@ func (c *Cluster) Diagnostics(opts *DiagnosticsOptions) (*DiagnosticsResult, err
 
 	return report, nil
 }
+
+// WaitUntilReadyOptions are the options that are available for use with the WaitUntilReady operation.
+type WaitUntilReadyOptions struct {
+	// ServiceTypes are the services to wait for, the management service can also be waited for. If not set then
+	// the key value service is waited for along with whichever of the query, search and analytics services have
+	// endpoints in the cluster.
+	ServiceTypes []ServiceType
+}
+
+// serviceEndpointsProvider is implemented by providers which can report the endpoints of each service.
+type serviceEndpointsProvider interface {
+	NumServers() int
+	MgmtEps() []string
+	CapiEps() []string
+	N1qlEps() []string
+	FtsEps() []string
+	CbasEps() []string
+}
+
+// WaitUntilReady repeatedly pings the requested services until every endpoint of each of them reports as ready,
+// or the timeout is reached. On timeout a WaitUntilReadyTimeoutError is returned listing the unready services.
+//
+// Volatile: This API is subject to change at any time.
+func (c *Cluster) WaitUntilReady(timeout time.Duration, opts *WaitUntilReadyOptions) error {
+	if opts == nil {
+		opts = &WaitUntilReadyOptions{}
+	}
+
+	if timeout == 0 {
+		return invalidArgumentsError{message: "a timeout value must be supplied to wait until ready"}
+	}
+
+	for _, service := range opts.ServiceTypes {
+		switch service {
+		case KeyValueService, QueryService, SearchService, AnalyticsService, MgmtService:
+		default:
+			return invalidArgumentsError{
+				message: fmt.Sprintf("service %s cannot be waited for", diagServiceString(service)),
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	curInterval := 50 * time.Millisecond
+	for {
+		services := opts.ServiceTypes
+		var unready []ServiceType
+		cli, err := c.clusterOrRandomClient()
+		if err != nil {
+			logDebugf("Failed to get client to wait until ready: %s", err)
+			if len(services) == 0 {
+				services = []ServiceType{KeyValueService}
+			}
+			unready = services
+		} else {
+			if len(services) == 0 {
+				// The configured services are worked out each time as the cluster config may not be known yet.
+				services = configuredServices(cli)
+			}
+
+			report, err := ping(ctx, cli, &c.sb, &PingOptions{ServiceTypes: services})
+			if err != nil {
+				return err
+			}
+
+			unready = unreadyServices(report, services)
+		}
+
+		if len(unready) == 0 {
+			return nil
+		}
+
+		waitTmr := gocbcore.AcquireTimer(curInterval)
+		select {
+		case <-waitTmr.C:
+			gocbcore.ReleaseTimer(waitTmr, true)
+		case <-ctx.Done():
+			gocbcore.ReleaseTimer(waitTmr, false)
+			return waitUntilReadyTimeoutError{unreadyServices: unready}
+		}
+
+		curInterval += 50 * time.Millisecond
+		if curInterval > 500*time.Millisecond {
+			curInterval = 500 * time.Millisecond
+		}
+	}
+}
+
+// configuredServices returns the key value service along with whichever of the query, search and analytics services
+// have endpoints. If the endpoints cannot be determined then all of the services are returned.
+func configuredServices(cli client) []ServiceType {
+	allServices := []ServiceType{
+		KeyValueService,
+		QueryService,
+		SearchService,
+		AnalyticsService,
+	}
+
+	provider, err := cli.getHTTPProvider()
+	if err != nil {
+		return allServices
+	}
+
+	epsProvider, ok := provider.(serviceEndpointsProvider)
+	if !ok {
+		return allServices
+	}
+
+	services := []ServiceType{KeyValueService}
+	if len(epsProvider.N1qlEps()) > 0 {
+		services = append(services, QueryService)
+	}
+	if len(epsProvider.FtsEps()) > 0 {
+		services = append(services, SearchService)
+	}
+	if len(epsProvider.CbasEps()) > 0 {
+		services = append(services, AnalyticsService)
+	}
+
+	return services
+}
+
+// SupportsService returns whether the current cluster config has at least one endpoint for the service. This only
+// checks the config already held by the client, no request is made, so false is also returned if the cluster is not
+// yet connected.
+//
+// Volatile: This API is subject to change at any time.
+func (c *Cluster) SupportsService(service ServiceType) bool {
+	provider, err := c.getHTTPProvider()
+	if err != nil {
+		logDebugf("Failed to get provider to check for service support (%s)", err)
+		return false
+	}
+
+	epProvider, ok := provider.(serviceEndpointsProvider)
+	if !ok {
+		return false
+	}
+
+	switch service {
+	case KeyValueService:
+		return epProvider.NumServers() > 0
+	case MgmtService:
+		return len(epProvider.MgmtEps()) > 0
+	case CapiService:
+		return len(epProvider.CapiEps()) > 0
+	case QueryService:
+		return len(epProvider.N1qlEps()) > 0
+	case SearchService:
+		return len(epProvider.FtsEps()) > 0
+	case AnalyticsService:
+		return len(epProvider.CbasEps()) > 0
+	default:
+		return false
+	}
+}
+
+// unreadyServices returns those of services which do not have every endpoint in report in the ok state.
+func unreadyServices(report *PingResult, services []ServiceType) []ServiceType {
+	var unready []ServiceType
+	for _, service := range services {
+		entries := report.Services[service]
+		ready := len(entries) > 0
+		for _, entry := range entries {
+			if entry.State != "ok" {
+				ready = false
+				break
+			}
+		}
+
+		if !ready {
+			unready = append(unready, service)
+		}
+	}
+
+	return unready
+}
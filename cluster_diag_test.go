@@ -1,11 +1,14 @@
 package gocb
 
 import (
+	"bytes"
 	"encoding/json"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/couchbase/gocbcore/v8"
+	"github.com/pkg/errors"
 )
 
 type mockDiagnosticsProvider struct {
@@ -220,3 +223,257 @@ func TestDiagnosticsWithID(t *testing.T) {
 		t.Fatalf("Report ID should have been myreportid but was %s", report.ID)
 	}
 }
+
+func testGetClusterForWaitUntilReady(kvProvider kvProvider, provider httpProvider) *Cluster {
+	clients := make(map[string]client)
+	clients[""] = &mockClient{
+		mockKvProvider:   kvProvider,
+		mockHTTPProvider: provider,
+	}
+
+	c := &Cluster{
+		connections: clients,
+	}
+	c.sb.KvTimeout = time.Second
+	c.sb.QueryTimeout = time.Second
+	c.sb.SearchTimeout = time.Second
+	c.sb.AnalyticsTimeout = time.Second
+
+	return c
+}
+
+func TestWaitUntilReady(t *testing.T) {
+	var queryPings int32
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Service != gocbcore.N1qlService {
+			return nil, errors.New("unexpected service type")
+		}
+
+		req.Endpoint = "http://localhost:8093"
+		statusCode := 503
+		if atomic.AddInt32(&queryPings, 1) > 3 {
+			statusCode = 200
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   req.Endpoint,
+			StatusCode: statusCode,
+			Body:       &testReadCloser{bytes.NewBufferString(""), nil},
+		}, nil
+	}
+
+	kvProvider := &mockKvProvider{
+		value: &gocbcore.PingKvResult{
+			Services: []gocbcore.PingResult{
+				{Endpoint: "server1", Latency: time.Millisecond},
+			},
+		},
+	}
+
+	cluster := testGetClusterForWaitUntilReady(kvProvider, &mockHTTPProvider{doFn: doHTTP})
+
+	err := cluster.WaitUntilReady(5*time.Second, &WaitUntilReadyOptions{
+		ServiceTypes: []ServiceType{KeyValueService, QueryService},
+	})
+	if err != nil {
+		t.Fatalf("Expected WaitUntilReady to succeed but was %v", err)
+	}
+
+	if pings := atomic.LoadInt32(&queryPings); pings != 4 {
+		t.Fatalf("Expected query to be pinged 4 times but was %d", pings)
+	}
+}
+
+func TestWaitUntilReadyTimeout(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		req.Endpoint = "http://localhost:8093"
+		return &gocbcore.HttpResponse{
+			Endpoint:   req.Endpoint,
+			StatusCode: 503,
+			Body:       &testReadCloser{bytes.NewBufferString(""), nil},
+		}, nil
+	}
+
+	kvProvider := &mockKvProvider{
+		value: &gocbcore.PingKvResult{
+			Services: []gocbcore.PingResult{
+				{Endpoint: "server1", Latency: time.Millisecond},
+			},
+		},
+	}
+
+	cluster := testGetClusterForWaitUntilReady(kvProvider, &mockHTTPProvider{doFn: doHTTP})
+
+	err := cluster.WaitUntilReady(200*time.Millisecond, &WaitUntilReadyOptions{
+		ServiceTypes: []ServiceType{KeyValueService, QueryService},
+	})
+	if !IsTimeoutError(err) {
+		t.Fatalf("Expected error to be timeout but was %v", err)
+	}
+
+	readyErr, ok := err.(WaitUntilReadyTimeoutError)
+	if !ok {
+		t.Fatalf("Expected error to be WaitUntilReadyTimeoutError but was %T", err)
+	}
+
+	unready := readyErr.UnreadyServices()
+	if len(unready) != 1 || unready[0] != QueryService {
+		t.Fatalf("Expected only query to be unready but was %v", unready)
+	}
+}
+
+func TestWaitUntilReadyInvalidService(t *testing.T) {
+	cluster := testGetClusterForWaitUntilReady(&mockKvProvider{}, &mockHTTPProvider{})
+
+	err := cluster.WaitUntilReady(time.Second, &WaitUntilReadyOptions{
+		ServiceTypes: []ServiceType{CapiService},
+	})
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
+// testEndpointsHTTPProvider reports the endpoints of each service, a service without endpoints fails requests as
+// gocbcore does.
+type testEndpointsHTTPProvider struct {
+	*mockHTTPProvider
+	eps map[gocbcore.ServiceType][]string
+}
+
+var _ serviceEndpointsProvider = (*gocbcore.Agent)(nil)
+
+func (p *testEndpointsHTTPProvider) NumServers() int {
+	return len(p.eps[gocbcore.MemdService])
+}
+
+func (p *testEndpointsHTTPProvider) MgmtEps() []string {
+	return p.eps[gocbcore.MgmtService]
+}
+
+func (p *testEndpointsHTTPProvider) CapiEps() []string {
+	return p.eps[gocbcore.CapiService]
+}
+
+func (p *testEndpointsHTTPProvider) N1qlEps() []string {
+	return p.eps[gocbcore.N1qlService]
+}
+
+func (p *testEndpointsHTTPProvider) FtsEps() []string {
+	return p.eps[gocbcore.FtsService]
+}
+
+func (p *testEndpointsHTTPProvider) CbasEps() []string {
+	return p.eps[gocbcore.CbasService]
+}
+
+func TestWaitUntilReadySkipsServicesWithoutEndpoints(t *testing.T) {
+	var queryPings, otherPings int32
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Service != gocbcore.N1qlService {
+			atomic.AddInt32(&otherPings, 1)
+			return nil, gocbcore.ErrNoCbasService
+		}
+
+		req.Endpoint = "http://localhost:8093"
+		statusCode := 503
+		if atomic.AddInt32(&queryPings, 1) > 1 {
+			statusCode = 200
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   req.Endpoint,
+			StatusCode: statusCode,
+			Body:       &testReadCloser{bytes.NewBufferString(""), nil},
+		}, nil
+	}
+
+	kvProvider := &mockKvProvider{
+		value: &gocbcore.PingKvResult{
+			Services: []gocbcore.PingResult{
+				{Endpoint: "server1", Latency: time.Millisecond},
+			},
+		},
+	}
+
+	provider := &testEndpointsHTTPProvider{
+		mockHTTPProvider: &mockHTTPProvider{doFn: doHTTP},
+		eps: map[gocbcore.ServiceType][]string{
+			gocbcore.N1qlService: {"http://localhost:8093"},
+		},
+	}
+	cluster := testGetClusterForWaitUntilReady(kvProvider, provider)
+
+	err := cluster.WaitUntilReady(5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Expected WaitUntilReady to succeed but was %v", err)
+	}
+
+	if pings := atomic.LoadInt32(&queryPings); pings != 2 {
+		t.Fatalf("Expected query to be pinged 2 times but was %d", pings)
+	}
+
+	if pings := atomic.LoadInt32(&otherPings); pings != 0 {
+		t.Fatalf("Expected services without endpoints not to be pinged but were pinged %d times", pings)
+	}
+}
+
+func TestWaitUntilReadyMgmt(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Service != gocbcore.MgmtService || req.Path != "/pools" {
+			return nil, errors.New("unexpected request")
+		}
+
+		req.Endpoint = "http://localhost:8091"
+		return &gocbcore.HttpResponse{
+			Endpoint:   req.Endpoint,
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString("{}"), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForWaitUntilReady(&mockKvProvider{}, &mockHTTPProvider{doFn: doHTTP})
+
+	err := cluster.WaitUntilReady(5*time.Second, &WaitUntilReadyOptions{
+		ServiceTypes: []ServiceType{MgmtService},
+	})
+	if err != nil {
+		t.Fatalf("Expected WaitUntilReady to succeed but was %v", err)
+	}
+}
+
+func TestSupportsService(t *testing.T) {
+	provider := &testEndpointsHTTPProvider{
+		mockHTTPProvider: &mockHTTPProvider{},
+		eps: map[gocbcore.ServiceType][]string{
+			gocbcore.MemdService: {"localhost:11210"},
+			gocbcore.MgmtService: {"http://localhost:8091"},
+			gocbcore.N1qlService: {"http://localhost:8093"},
+		},
+	}
+	cluster := testGetClusterForWaitUntilReady(&mockKvProvider{}, provider)
+
+	for _, service := range []ServiceType{KeyValueService, MgmtService, QueryService} {
+		if !cluster.SupportsService(service) {
+			t.Fatalf("Expected service %s to be supported", diagServiceString(service))
+		}
+	}
+
+	for _, service := range []ServiceType{CapiService, SearchService, AnalyticsService} {
+		if cluster.SupportsService(service) {
+			t.Fatalf("Expected service %s not to be supported", diagServiceString(service))
+		}
+	}
+
+	provider.eps[gocbcore.CbasService] = []string{"http://localhost:8095"}
+	if !cluster.SupportsService(AnalyticsService) {
+		t.Fatalf("Expected analytics to be supported once it is in the cluster config")
+	}
+}
+
+func TestSupportsServiceNotConnected(t *testing.T) {
+	cluster := &Cluster{connections: make(map[string]client)}
+
+	if cluster.SupportsService(KeyValueService) {
+		t.Fatalf("Expected no service to be supported when not connected")
+	}
+}
@@ -3,6 +3,7 @@ package gocb
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/url"
 	"time"
 
@@ -80,7 +81,10 @@ type QueryResult struct {
 	ctx                context.Context
 	enhancedStatements bool
 
-	serializer JSONSerializer
+	serializer     JSONSerializer
+	signatureCache *signatureCache
+	signatureKey   string
+	rawSignature   json.RawMessage
 }
 
 // Next assigns the next result from the results into the value pointer, returning whether the read was successful.
@@ -94,7 +98,7 @@ func (r *QueryResult) Next(valuePtr interface{}) bool {
 		return false
 	}
 
-	r.err = r.serializer.Deserialize(row, valuePtr)
+	r.err = decodeRow(r.serializer, row, valuePtr)
 	if r.err != nil {
 		return false
 	}
@@ -117,13 +121,36 @@ func (r *QueryResult) NextBytes() []byte {
 	return raw
 }
 
+// Raw returns the undecoded response body so that it can be passed straight through, e.g. to an HTTP client,
+// without decoding each row. Raw cannot be combined with Next, NextBytes or One, and Metadata is not available
+// once it has been called. Closing the returned reader closes the result.
+func (r *QueryResult) Raw() (io.ReadCloser, error) {
+	raw, err := r.streamResult.Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	return &rawResultReader{Reader: raw, closeFn: r.Close}, nil
+}
+
+// Signature decodes the schema of the results into out. The signature is sent before any rows so, unlike Metadata,
+// it is available without reading or closing the results, e.g. to set up the columns of a table before the rows
+// are added to it.
+func (r *QueryResult) Signature(out interface{}) error {
+	if r.metadata.signature == nil {
+		return clientError{message: "no signature was returned for the query"}
+	}
+
+	return decodeRow(r.serializer, r.rawSignature, out)
+}
+
 // Close marks the results as closed, returning any errors that occurred during reading the results.
 func (r *QueryResult) Close() error {
 	if r.streamResult.Closed() {
 		return r.err
 	}
 
-	err := r.streamResult.Close()
+	err := r.streamResult.closeWithContext(r.ctx)
 	ctxErr := r.ctx.Err()
 	if r.cancel != nil {
 		r.cancel()
@@ -159,6 +186,11 @@ func (r *QueryResult) Close() error {
 // of, at most, length 1.
 func (r *QueryResult) One(valuePtr interface{}) error {
 	if !r.Next(valuePtr) {
+		// Next may have stopped on a failed row rather than the end of the
+		// rows, purge whatever is left so that the metadata is still read.
+		for r.NextBytes() != nil {
+		}
+
 		err := r.Close()
 		if err != nil {
 			return err
@@ -179,11 +211,24 @@ func (r *QueryResult) One(valuePtr interface{}) error {
 	return nil
 }
 
+// Drain reads and discards any remaining rows so that the metadata is fully populated, then closes the results,
+// returning any error that occurred whilst streaming them. This is useful when a query is only run for its side
+// effects.
+func (r *QueryResult) Drain() error {
+	for r.NextBytes() != nil {
+	}
+
+	return r.Close()
+}
+
 // Metadata returns metadata for this result.
 func (r *QueryResult) Metadata() (*QueryMetadata, error) {
 	if !r.streamResult.Closed() {
 		return nil, clientError{message: "result must be closed before accessing meta-data"}
 	}
+	if r.streamResult.raw {
+		return nil, clientError{message: "meta-data is not available once the raw response has been requested"}
+	}
 
 	return &r.metadata, nil
 }
@@ -293,10 +338,11 @@ func (r *QueryResult) readAttribute(decoder *json.Decoder, t json.Token) (bool,
 			return false, err
 		}
 	case "signature":
-		err := decoder.Decode(&r.metadata.signature)
+		signature, err := r.signatureCache.decode(decoder, r.signatureKey, &r.metadata.signature)
 		if err != nil {
 			return false, err
 		}
+		r.rawSignature = signature
 	case "profile":
 		err := decoder.Decode(&r.metadata.profile)
 		if err != nil {
@@ -353,6 +399,38 @@ func (c *Cluster) Query(statement string, opts *QueryOptions) (*QueryResult, err
 	return result, nil
 }
 
+// contextWithServerTimeout creates the context used to dispatch a query style request, using the timeout from the
+// request options or the default timeout if none was set. The timeout sent to the server is set to the shorter of
+// the timeout and the time remaining on the parent context, plus serverDelta.
+func contextWithServerTimeout(parent context.Context, queryOpts map[string]interface{}, defaultTimeout,
+	serverDelta time.Duration) (context.Context, context.CancelFunc, error) {
+	// Work out which timeout to use, the cluster level default or query specific one
+	timeout := defaultTimeout
+	tmostr, castok := queryOpts["timeout"].(string)
+	if castok {
+		var err error
+		timeout, err = time.ParseDuration(tmostr)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "could not parse timeout value")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+
+	now := time.Now()
+	d, _ := ctx.Deadline()
+	newTimeout := d.Sub(now)
+
+	// We need to take the shorter of the timeouts here, if the context already had a shorter deadline then there's
+	// not much we can do about it.
+	if newTimeout > timeout {
+		newTimeout = timeout
+	}
+	queryOpts["timeout"] = (newTimeout + serverDelta).String()
+
+	return ctx, cancel, nil
+}
+
 func (c *Cluster) query(tracectx requestSpanContext, statement string, startTime time.Time, opts *QueryOptions) (*QueryResult, error) {
 	provider, err := c.getHTTPProvider()
 	if err != nil {
@@ -364,32 +442,13 @@ func (c *Cluster) query(tracectx requestSpanContext, statement string, startTime
 		return nil, errors.Wrap(err, "could not parse query options")
 	}
 
-	// Work out which timeout to use, the cluster level default or query specific one
-	timeout := c.sb.QueryTimeout
-	tmostr, castok := queryOpts["timeout"].(string)
-	if castok {
-		timeout, err = time.ParseDuration(tmostr)
-		if err != nil {
-			return nil, errors.Wrap(err, "could not parse timeout value")
-		}
-	}
-
 	if opts.Context == nil {
 		opts.Context = context.Background()
 	}
 
-	ctx, cancel := context.WithTimeout(opts.Context, timeout)
-
-	now := time.Now()
-	d, _ := ctx.Deadline()
-	newTimeout := d.Sub(now)
-
-	// We need to take the shorter of the timeouts here so that the server can try to timeout first, if the context
-	// already had a shorter deadline then there's not much we can do about it.
-	if newTimeout > timeout {
-		queryOpts["timeout"] = timeout.String()
-	} else {
-		queryOpts["timeout"] = newTimeout.String()
+	ctx, cancel, err := contextWithServerTimeout(opts.Context, queryOpts, c.sb.QueryTimeout, 0)
+	if err != nil {
+		return nil, err
 	}
 
 	if opts.Serializer == nil {
@@ -410,7 +469,7 @@ func (c *Cluster) query(tracectx requestSpanContext, statement string, startTime
 		startTime:  startTime,
 	}
 	var res *QueryResult
-	if opts.AdHoc {
+	if opts.Prepared {
 		res, err = c.doPreparedN1qlQuery(ctx, cancel, settings)
 	} else {
 		res, err = c.executeN1qlQuery(ctx, cancel, settings)
@@ -443,8 +502,15 @@ func (c *Cluster) doPreparedN1qlQuery(ctx context.Context, cancel context.Cancel
 		return nil, invalidArgumentsError{message: "query statement could not be parsed"}
 	}
 
+	// Unqualified keyspaces in the statement resolve differently depending on the query context so the same
+	// statement must be prepared separately for each.
+	cacheKey := stmtStr
+	if queryContext, ok := settings.queryOpts["query_context"].(string); ok {
+		cacheKey = queryContext + " " + stmtStr
+	}
+
 	c.clusterLock.RLock()
-	cachedStmt := c.queryCache[stmtStr]
+	cachedStmt := c.queryCache[cacheKey]
 	c.clusterLock.RUnlock()
 
 	if cachedStmt != nil {
@@ -459,6 +525,21 @@ func (c *Cluster) doPreparedN1qlQuery(ctx context.Context, cancel context.Cancel
 		if err == nil {
 			return results, nil
 		}
+
+		if !isQueryPlanError(err) {
+			return nil, err
+		}
+
+		// The server no longer has a valid plan for the statement so drop it and prepare the statement again.
+		c.clusterLock.Lock()
+		if c.queryCache[cacheKey] == cachedStmt {
+			delete(c.queryCache, cacheKey)
+		}
+		c.clusterLock.Unlock()
+
+		settings.queryOpts["statement"] = stmtStr
+		delete(settings.queryOpts, "prepared")
+		delete(settings.queryOpts, "encoded_plan")
 	}
 
 	// Prepare the query
@@ -469,21 +550,21 @@ func (c *Cluster) doPreparedN1qlQuery(ctx context.Context, cancel context.Cancel
 		}
 
 		c.clusterLock.Lock()
-		c.queryCache[stmtStr] = &n1qlCache{enhanced: true, name: results.preparedName}
+		c.queryCache[cacheKey] = &n1qlCache{enhanced: true, name: results.preparedName}
 		c.clusterLock.Unlock()
 
 		return results, nil
 	}
 
 	var err error
-	cachedStmt, err = c.prepareN1qlQuery(ctx, cancel, settings)
+	cachedStmt, err = c.prepareN1qlQuery(ctx, settings)
 	if err != nil {
 		return nil, err
 	}
 
 	// Save new cached statement
 	c.clusterLock.Lock()
-	c.queryCache[stmtStr] = cachedStmt
+	c.queryCache[cacheKey] = cachedStmt
 	c.clusterLock.Unlock()
 
 	// Update with new prepared data
@@ -513,15 +594,16 @@ func (c *Cluster) prepareEnhancedN1qlQuery(ctx context.Context, cancel context.C
 	})
 }
 
-func (c *Cluster) prepareN1qlQuery(ctx context.Context, cancel context.CancelFunc,
-	settings querySettings) (*n1qlCache, error) {
+func (c *Cluster) prepareN1qlQuery(ctx context.Context, settings querySettings) (*n1qlCache, error) {
 	prepOpts := make(map[string]interface{})
 	for k, v := range settings.queryOpts {
 		prepOpts[k] = v
 	}
 	prepOpts["statement"] = "PREPARE " + settings.queryOpts["statement"].(string)
 
-	prepRes, err := c.executeN1qlQuery(ctx, cancel, querySettings{
+	// There's no need to pass cancel here, if there's an error then we'll cancel further up the stack
+	// and if there isn't then we run another query later where we will cancel
+	prepRes, err := c.executeN1qlQuery(ctx, nil, querySettings{
 		queryOpts:  prepOpts,
 		provider:   settings.provider,
 		serializer: &DefaultJSONSerializer{},
@@ -529,10 +611,6 @@ func (c *Cluster) prepareN1qlQuery(ctx context.Context, cancel context.CancelFun
 		wrapper:    settings.wrapper,
 		startTime:  settings.startTime,
 	})
-
-	// // There's no need to pass cancel here, if there's an error then we'll cancel further up the stack
-	// // and if there isn't then we run another query later where we will cancel
-	// prepRes, err := c.doRetryableQuery(ctx, nil, )
 	if err != nil {
 		return nil, err
 	}
@@ -549,6 +627,21 @@ func (c *Cluster) prepareN1qlQuery(ctx context.Context, cancel context.CancelFun
 	}, nil
 }
 
+// querySignatureKey returns the key to cache the result signature of a query under, this is the statement or the
+// name of the prepared statement being executed.
+func querySignatureKey(queryOpts map[string]interface{}) string {
+	// The same statement can refer to different keyspaces, and so have different signatures, in each query context.
+	queryContext, _ := queryOpts["query_context"].(string)
+	if statement, ok := queryOpts["statement"].(string); ok {
+		return "n1ql:" + queryContext + "\x00" + statement
+	}
+	if prepared, ok := queryOpts["prepared"].(string); ok {
+		return "n1ql-prepared:" + queryContext + "\x00" + prepared
+	}
+
+	return ""
+}
+
 type n1qlPrepData struct {
 	EncodedPlan string `json:"encoded_plan"`
 	Name        string `json:"name"`
@@ -591,6 +684,10 @@ func (c *Cluster) executeN1qlQuery(ctx context.Context, cancel context.CancelFun
 		logWarnf("Failed to assert analytics options client_context_id to string. Replacing with %s", req.UniqueId)
 	}
 
+	if c.sb.LogRequestBodies {
+		logRequestBody(LogFields{LogFieldOperationID: req.UniqueId, LogFieldService: "n1ql"}, reqJSON)
+	}
+
 	enhancedStatements := c.supportsEnhancedPreparedStatements()
 
 	for {
@@ -633,6 +730,8 @@ func (c *Cluster) executeN1qlQuery(ctx context.Context, cancel context.CancelFun
 			serializer:         settings.serializer,
 			enhancedStatements: c.supportsEnhancedPreparedStatements(),
 			startTime:          settings.startTime,
+			signatureCache:     c.signatureCache,
+			signatureKey:       querySignatureKey(settings.queryOpts),
 		}
 
 		streamResult, err := newStreamingResults(resp.Body, results.readAttribute)
@@ -669,6 +768,11 @@ func (c *Cluster) executeN1qlQuery(ctx context.Context, cancel context.CancelFun
 					}
 				}
 
+				if _, ok := settings.queryOpts["prepared"]; ok && isQueryPlanError(results.err) {
+					// Retrying won't help as the statement has to be prepared again, which is up to the caller.
+					return nil, results.err
+				}
+
 				if IsRetryableError(results.err) {
 					shouldRetry, retryErr := shouldRetryHTTPRequest(ctx, req, gocbcore.ServiceResponseCodeIndicatedRetryReason,
 						settings.wrapper, settings.provider, settings.startTime)
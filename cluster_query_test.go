@@ -5,7 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -245,7 +247,7 @@ func testSimpleQueryError(t *testing.T) {
 
 func testPreparedQuery(t *testing.T) {
 	query := "SELECT `travel-sample`.* FROM `travel-sample` LIMIT 10000;"
-	results, err := globalCluster.Query(query, &QueryOptions{AdHoc: true})
+	results, err := globalCluster.Query(query, &QueryOptions{Prepared: true})
 	if err != nil {
 		t.Fatalf("Failed to execute query: %v", err)
 	}
@@ -278,7 +280,7 @@ func testPreparedQuery(t *testing.T) {
 		t.Fatalf("Query should have been in query cache after prepared statement execution")
 	}
 
-	results, err = globalCluster.Query(query, &QueryOptions{AdHoc: true})
+	results, err = globalCluster.Query(query, &QueryOptions{Prepared: true})
 	if err != nil {
 		t.Fatalf("Failed to execute query: %v", err)
 	}
@@ -571,6 +573,53 @@ func TestBasicQuerySerializerError(t *testing.T) {
 	}
 }
 
+func TestQueryOneNoResults(t *testing.T) {
+	dataBytes := []byte(`{"requestID":"a1b2c3","results":[],"status":"success","metrics":{"elapsedTime":"1ms","executionTime":"1ms","resultCount":0,"resultSize":0}}`)
+	timeout := 60 * time.Second
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, timeout, 0, 0)
+
+	res, err := cluster.Query("SELECT * FROM `beer-sample` WHERE `type` = \"nothing\"", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sample interface{}
+	err = res.One(&sample)
+	if !IsNoResultsError(err) {
+		t.Fatalf("Expected error to be no results but was %v", err)
+	}
+
+	if sample != nil {
+		t.Fatalf("Expected sample to be nil but was %v", sample)
+	}
+
+	metadata, err := res.Metadata()
+	if err != nil {
+		t.Fatalf("Metadata had error: %v", err)
+	}
+
+	if metadata.RequestID() != "a1b2c3" {
+		t.Fatalf("Expected RequestID to be a1b2c3 but was %s", metadata.RequestID())
+	}
+
+	if metadata.Metrics() == nil {
+		t.Fatalf("Expected metrics to be read")
+	}
+}
+
 func TestQueryError(t *testing.T) {
 	dataBytes, err := loadRawTestDataset("beer_sample_query_error")
 	if err != nil {
@@ -1162,7 +1211,7 @@ func TestBasicEnhancedPreparedQuery(t *testing.T) {
 		},
 	}
 
-	_, err = cluster.Query(statement, &QueryOptions{AdHoc: true})
+	_, err = cluster.Query(statement, &QueryOptions{Prepared: true})
 	if err != nil {
 		t.Fatalf("Expected query execution to not error %v", err)
 	}
@@ -1232,7 +1281,7 @@ func TestBasicEnhancedPreparedQueryAlreadySupported(t *testing.T) {
 		},
 	}
 
-	_, err = cluster.Query(statement, &QueryOptions{AdHoc: true})
+	_, err = cluster.Query(statement, &QueryOptions{Prepared: true})
 	if err != nil {
 		t.Fatalf("Expected query execution to not error %v", err)
 	}
@@ -1292,7 +1341,7 @@ func TestBasicEnhancedPreparedQueryAlreadyCached(t *testing.T) {
 		},
 	}
 
-	_, err = cluster.Query(statement, &QueryOptions{AdHoc: true})
+	_, err = cluster.Query(statement, &QueryOptions{Prepared: true})
 	if err != nil {
 		t.Fatalf("Expected query execution to not error %v", err)
 	}
@@ -1359,7 +1408,7 @@ func TestBasicRetriesEnhancedPreparedNoRetry(t *testing.T) {
 		},
 	}
 
-	_, err = cluster.Query(statement, &QueryOptions{AdHoc: true})
+	_, err = cluster.Query(statement, &QueryOptions{Prepared: true})
 	if err == nil {
 		t.Fatal("Expected query execution to error")
 	}
@@ -1426,7 +1475,7 @@ func TestBasicRetriesEnhancedPreparedRetry(t *testing.T) {
 		},
 	}
 
-	_, err = cluster.Query(statement, &QueryOptions{AdHoc: true})
+	_, err = cluster.Query(statement, &QueryOptions{Prepared: true})
 	if err == nil {
 		t.Fatal("Expected query execution to error")
 	}
@@ -1440,6 +1489,183 @@ func TestBasicRetriesEnhancedPreparedRetry(t *testing.T) {
 	}
 }
 
+// testPreparedQueryServer models the query service for statements prepared without enhanced prepared statement
+// support, each PREPARE creates a new plan name and plans can be dropped to have executions report error 4040.
+type testPreparedQueryServer struct {
+	t        *testing.T
+	prepares int
+	requests []map[string]interface{}
+	dropped  map[string]bool
+}
+
+func (s *testPreparedQueryServer) doHTTP(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+	testAssertQueryRequest(s.t, req)
+
+	var body map[string]interface{}
+	err := json.Unmarshal(req.Body, &body)
+	if err != nil {
+		s.t.Fatalf("Failed to unmarshal request body: %v", err)
+	}
+	s.requests = append(s.requests, body)
+
+	var resp string
+	if statement, ok := body["statement"].(string); ok && len(statement) > 8 && statement[:8] == "PREPARE " {
+		s.prepares++
+		resp = fmt.Sprintf(`{"results":[{"name":"plan%d","encoded_plan":"encoded%d"}],"status":"success"}`,
+			s.prepares, s.prepares)
+	} else if name, ok := body["prepared"].(string); ok && s.dropped[name] {
+		resp = `{"errors":[{"code":4040,"msg":"No such prepared statement: ` + name + `"}],"status":"fatal"}`
+	} else {
+		resp = `{"results":[{"id":1}],"status":"success"}`
+	}
+
+	return &gocbcore.HttpResponse{
+		Endpoint:   "http://localhost:8093",
+		StatusCode: 200,
+		Body:       &testReadCloser{bytes.NewBufferString(resp), nil},
+	}, nil
+}
+
+func (s *testPreparedQueryServer) cluster() *Cluster {
+	provider := &mockHTTPProvider{
+		doFn: s.doHTTP,
+		supportFn: func(capability gocbcore.ClusterCapability) bool {
+			return false
+		},
+	}
+
+	cluster := testGetClusterForHTTP(provider, 10*time.Second, 0, 0)
+	cluster.queryCache = make(map[string]*n1qlCache)
+
+	return cluster
+}
+
+func (s *testPreparedQueryServer) query(cluster *Cluster, statement string) {
+	res, err := cluster.Query(statement, &QueryOptions{Prepared: true})
+	if err != nil {
+		s.t.Fatalf("Expected query execution to not error %v", err)
+	}
+
+	var row map[string]int
+	err = res.One(&row)
+	if err != nil {
+		s.t.Fatalf("Expected query to return a row but was %v", err)
+	}
+
+	if row["id"] != 1 {
+		s.t.Fatalf("Expected row id to be 1 but was %d", row["id"])
+	}
+}
+
+func TestPreparedQueryFirstPrepare(t *testing.T) {
+	statement := "SELECT * FROM default WHERE id = 1"
+	server := &testPreparedQueryServer{t: t}
+	cluster := server.cluster()
+
+	server.query(cluster, statement)
+
+	if len(server.requests) != 2 {
+		t.Fatalf("Expected a prepare and an execute request but was %d requests", len(server.requests))
+	}
+
+	if server.requests[0]["statement"] != "PREPARE "+statement {
+		t.Fatalf("Expected first request to prepare the statement but was %v", server.requests[0]["statement"])
+	}
+
+	if _, ok := server.requests[1]["statement"]; ok {
+		t.Fatalf("Expected execute request not to contain the statement")
+	}
+
+	if server.requests[1]["prepared"] != "plan1" || server.requests[1]["encoded_plan"] != "encoded1" {
+		t.Fatalf("Expected execute request to be for plan1 but was %v", server.requests[1])
+	}
+
+	cache, ok := cluster.queryCache[statement]
+	if !ok {
+		t.Fatal("Expected query cache to contain query")
+	}
+
+	if cache.name != "plan1" {
+		t.Fatalf("Expected cached plan name to be plan1 but was %s", cache.name)
+	}
+}
+
+func TestPreparedQueryCacheHit(t *testing.T) {
+	statement := "SELECT * FROM default WHERE id = 1"
+	server := &testPreparedQueryServer{t: t}
+	cluster := server.cluster()
+
+	server.query(cluster, statement)
+	server.query(cluster, statement)
+
+	if server.prepares != 1 {
+		t.Fatalf("Expected statement to be prepared once but was prepared %d times", server.prepares)
+	}
+
+	if len(server.requests) != 3 {
+		t.Fatalf("Expected 3 requests but was %d", len(server.requests))
+	}
+
+	if server.requests[2]["prepared"] != "plan1" {
+		t.Fatalf("Expected cached plan to be executed but was %v", server.requests[2])
+	}
+}
+
+func TestPreparedQueryReprepareAfterInvalidation(t *testing.T) {
+	statement := "SELECT * FROM default WHERE id = 1"
+	server := &testPreparedQueryServer{t: t}
+	cluster := server.cluster()
+
+	server.query(cluster, statement)
+
+	server.dropped = map[string]bool{"plan1": true}
+	server.query(cluster, statement)
+
+	if server.prepares != 2 {
+		t.Fatalf("Expected statement to be prepared twice but was prepared %d times", server.prepares)
+	}
+
+	// prepare, execute plan1, execute plan1 (4040), prepare, execute plan2
+	if len(server.requests) != 5 {
+		t.Fatalf("Expected 5 requests but was %d", len(server.requests))
+	}
+
+	if server.requests[3]["statement"] != "PREPARE "+statement {
+		t.Fatalf("Expected statement to be prepared again but was %v", server.requests[3])
+	}
+
+	if server.requests[4]["prepared"] != "plan2" {
+		t.Fatalf("Expected new plan to be executed but was %v", server.requests[4])
+	}
+
+	if cluster.queryCache[statement].name != "plan2" {
+		t.Fatalf("Expected cached plan name to be plan2 but was %s", cluster.queryCache[statement].name)
+	}
+}
+
+func TestQueryAdHocByDefault(t *testing.T) {
+	server := &testPreparedQueryServer{t: t}
+	cluster := server.cluster()
+
+	res, err := cluster.Query("SELECT 1", nil)
+	if err != nil {
+		t.Fatalf("Expected query execution to not error %v", err)
+	}
+
+	err = res.Close()
+	if err != nil {
+		t.Fatalf("Expected close to not error %v", err)
+	}
+
+	if server.prepares != 0 || len(server.requests) != 1 {
+		t.Fatalf("Expected ad hoc query to be executed without preparing but was %v", server.requests)
+	}
+
+	if len(cluster.queryCache) != 0 {
+		t.Fatalf("Query cache should have been empty but was %v", cluster.queryCache)
+	}
+}
+
 func testGetClusterForHTTP(provider *mockHTTPProvider, n1qlTimeout, analyticsTimeout, searchTimeout time.Duration) *Cluster {
 	clients := make(map[string]client)
 	cli := &mockClient{
@@ -1450,16 +1676,357 @@ func testGetClusterForHTTP(provider *mockHTTPProvider, n1qlTimeout, analyticsTim
 	}
 	clients[""] = cli
 	c := &Cluster{
-		connections: clients,
+		connections:         clients,
+		analyticsQueryCache: make(map[string]string),
 	}
 	c.sb.QueryTimeout = n1qlTimeout
 	c.sb.AnalyticsTimeout = analyticsTimeout
 	c.sb.SearchTimeout = searchTimeout
 	c.sb.RetryStrategyWrapper = newRetryStrategyWrapper(NewBestEffortRetryStrategy(nil))
 	c.sb.Tracer = &noopTracer{}
+	c.sb.ActiveRequests = newActiveRequestRegistry()
 
 	c.sb.Transcoder = NewJSONTranscoder(&DefaultJSONSerializer{})
 	c.sb.Serializer = &DefaultJSONSerializer{}
 
 	return c
 }
+
+func TestQueryResultRaw(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("beer_sample_query_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	body := &testTrackingReadCloser{Reader: bytes.NewReader(dataBytes)}
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       body,
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, 10*time.Second, 0, 0)
+
+	res, err := cluster.Query("SELECT * FROM `beer-sample`", nil)
+	if err != nil {
+		t.Fatalf("Expected query to succeed but was %v", err)
+	}
+
+	raw, err := res.Raw()
+	if err != nil {
+		t.Fatalf("Expected Raw to succeed but was %v", err)
+	}
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, raw)
+	if err != nil {
+		t.Fatalf("Expected copy to succeed but was %v", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), dataBytes) {
+		t.Fatalf("Expected raw response to match the dataset but was %s", buf.String())
+	}
+
+	if res.NextBytes() != nil {
+		t.Fatalf("Expected NextBytes to fail once the raw response has been requested")
+	}
+
+	err = raw.Close()
+	if err == nil {
+		t.Fatalf("Expected Close to report the failed NextBytes")
+	}
+
+	if !body.closed {
+		t.Fatalf("Expected response body to be closed")
+	}
+
+	if _, err := res.Metadata(); err == nil {
+		t.Fatalf("Expected Metadata to fail once the raw response has been requested")
+	}
+}
+
+// testGetScopeForQuery returns a scope which executes queries through cluster as if it had been opened from it.
+func testGetScopeForQuery(cluster *Cluster, bucketName, scopeName string) *Scope {
+	return &Scope{
+		sb: stateBlock{
+			clientStateBlock: clientStateBlock{
+				BucketName: bucketName,
+			},
+			ScopeName:    scopeName,
+			Tracer:       &noopTracer{},
+			executeQuery: cluster.query,
+		},
+	}
+}
+
+func TestScopeQueryContext(t *testing.T) {
+	server := &testPreparedQueryServer{t: t}
+	cluster := server.cluster()
+	scope := testGetScopeForQuery(cluster, "travel-sample", "inventory")
+
+	opts := &QueryOptions{}
+	_, err := scope.Query("SELECT * FROM airline", opts)
+	if err != nil {
+		t.Fatalf("Expected query to succeed but was %v", err)
+	}
+
+	if len(server.requests) != 1 {
+		t.Fatalf("Expected 1 request but was %d", len(server.requests))
+	}
+
+	expected := "default:`travel-sample`.`inventory`"
+	if server.requests[0]["query_context"] != expected {
+		t.Fatalf("Expected query context to be %s but was %v", expected, server.requests[0]["query_context"])
+	}
+
+	if opts.QueryContext != "" {
+		t.Fatalf("Expected query options passed in not to be modified but query context was %s", opts.QueryContext)
+	}
+}
+
+func TestCollectionQueryContext(t *testing.T) {
+	server := &testPreparedQueryServer{t: t}
+	cluster := server.cluster()
+	scope := testGetScopeForQuery(cluster, "travel-sample", "inventory")
+	col := &Collection{sb: scope.sb}
+	col.sb.CollectionName = "airline"
+
+	_, err := col.Query("SELECT * FROM airline", &QueryOptions{QueryContext: "default:`other`.`scope`"})
+	if err != nil {
+		t.Fatalf("Expected query to succeed but was %v", err)
+	}
+
+	expected := "default:`travel-sample`.`inventory`"
+	if server.requests[0]["query_context"] != expected {
+		t.Fatalf("Expected query context to be %s but was %v", expected, server.requests[0]["query_context"])
+	}
+}
+
+func TestScopeQueryPreparedPerScope(t *testing.T) {
+	statement := "SELECT * FROM airline"
+	server := &testPreparedQueryServer{t: t}
+	cluster := server.cluster()
+
+	for _, scopeName := range []string{"inventory", "tenant_agent_00", "inventory"} {
+		_, err := testGetScopeForQuery(cluster, "travel-sample", scopeName).Query(statement, &QueryOptions{Prepared: true})
+		if err != nil {
+			t.Fatalf("Expected query to succeed but was %v", err)
+		}
+	}
+
+	if server.prepares != 2 {
+		t.Fatalf("Expected the statement to be prepared once per scope but was prepared %d times", server.prepares)
+	}
+
+	last := server.requests[len(server.requests)-1]
+	if last["prepared"] != "plan1" {
+		t.Fatalf("Expected the inventory plan to be reused but was %v", last["prepared"])
+	}
+}
+
+func TestScopeQueryWithoutCluster(t *testing.T) {
+	scope := &Scope{sb: stateBlock{ScopeName: "inventory", Tracer: &noopTracer{}}}
+
+	_, err := scope.Query("SELECT 1", nil)
+	if err == nil {
+		t.Fatalf("Expected query on a scope not opened from a cluster to fail")
+	}
+}
+
+func TestQueryNextNonPointerTarget(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("beer_sample_query_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 60*time.Second, 0, 0)
+
+	res, err := cluster.Query("SELECT * FROM `beer-sample`", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var row map[string]interface{}
+	if res.Next(row) {
+		t.Fatalf("Expected Next to fail with a non-pointer target")
+	}
+
+	err = res.Close()
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "map[string]interface {}") {
+		t.Fatalf("Expected error to name the target type but was %v", err)
+	}
+}
+
+func TestQueryResultDrain(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("beer_sample_query_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 60*time.Second, 0, 0)
+
+	res, err := cluster.Query("SELECT * FROM `beer-sample`", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = res.Drain()
+	if err != nil {
+		t.Fatalf("Expected Drain to succeed but was %v", err)
+	}
+
+	metadata, err := res.Metadata()
+	if err != nil {
+		t.Fatalf("Metadata had error: %v", err)
+	}
+
+	if metadata.RequestID() != "e36e0202-7f4f-4083-9b73-993459353544" {
+		t.Fatalf("Expected RequestID to be read but was %s", metadata.RequestID())
+	}
+
+	if metadata.Metrics() == nil || metadata.Metrics().ResultCount != 10 {
+		t.Fatalf("Expected metrics to be read but were %v", metadata.Metrics())
+	}
+
+	if res.Next(&map[string]interface{}{}) {
+		t.Fatalf("Expected no rows to be left after draining")
+	}
+}
+
+func TestQueryResultDrainStreamError(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(`{"requestID":"a1b2c3","results":[{"a":1},{"a":`), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 60*time.Second, 0, 0)
+
+	res, err := cluster.Query("SELECT * FROM `beer-sample`", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = res.Drain()
+	if err == nil {
+		t.Fatalf("Expected Drain to return the stream error")
+	}
+}
+
+func TestQueryResultSignature(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("query_signature_dataset")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 60*time.Second, 0, 0)
+
+	res, err := cluster.Query("SELECT name, type FROM `beer-sample`", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The signature should be available before any rows have been read.
+	var signature map[string]string
+	err = res.Signature(&signature)
+	if err != nil {
+		t.Fatalf("Expected Signature to succeed but was %v", err)
+	}
+
+	expected := map[string]string{"name": "json", "type": "json"}
+	if !reflect.DeepEqual(signature, expected) {
+		t.Fatalf("Expected signature to be %v but was %v", expected, signature)
+	}
+
+	// The signature is decoded from the response as it was sent, rather than from a re-encoding of it.
+	var rawSignature json.RawMessage
+	err = res.Signature(&rawSignature)
+	if err != nil {
+		t.Fatalf("Expected Signature to succeed but was %v", err)
+	}
+
+	expectedRaw := "{\n    \"name\": \"json\",\n    \"type\": \"json\"\n  }"
+	if string(rawSignature) != expectedRaw {
+		t.Fatalf("Expected raw signature to be %s but was %s", expectedRaw, rawSignature)
+	}
+
+	var rows int
+	var row map[string]string
+	for res.Next(&row) {
+		rows++
+	}
+
+	err = res.Close()
+	if err != nil {
+		t.Fatalf("results close had error: %v", err)
+	}
+
+	if rows != 2 {
+		t.Fatalf("Expected 2 rows to be read after the signature but was %d", rows)
+	}
+}
+
+func TestQueryResultNoSignature(t *testing.T) {
+	dataBytes := []byte(`{"requestID":"a1b2c3","results":[],"status":"success"}`)
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 60*time.Second, 0, 0)
+
+	res, err := cluster.Query("CREATE PRIMARY INDEX ON `beer-sample`", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var signature interface{}
+	err = res.Signature(&signature)
+	if err == nil {
+		t.Fatalf("Expected Signature to fail without a signature but was %v", signature)
+	}
+
+	err = res.Close()
+	if err != nil {
+		t.Fatalf("results close had error: %v", err)
+	}
+}
@@ -2,13 +2,21 @@ package gocb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+	"github.com/google/uuid"
 )
 
 // QueryIndexManager provides methods for performing Couchbase N1ql index management.
 // Volatile: This API is subject to change at any time.
 type QueryIndexManager struct {
+	httpClient           httpProvider
 	executeQuery         func(requestSpanContext, string, time.Time, *QueryOptions) (*QueryResult, error)
 	globalTimeout        time.Duration
 	defaultRetryStrategy *retryStrategyWrapper
@@ -24,6 +32,19 @@ type QueryIndex struct {
 	Keyspace  string    `json:"keyspace_id"`
 	Namespace string    `json:"namespace_id"`
 	IndexKey  []string  `json:"index_key"`
+	// BucketName and ScopeName are only set for indexes on a collection, in which case Keyspace is the
+	// collection name.
+	BucketName string `json:"bucket_id"`
+	ScopeName  string `json:"scope_id"`
+}
+
+// keyspace returns the escaped keyspace that the index is on.
+func (index QueryIndex) keyspace() string {
+	if index.BucketName == "" {
+		return queryIndexKeyspace(index.Keyspace, "", "")
+	}
+
+	return queryIndexKeyspace(index.BucketName, index.ScopeName, index.Keyspace)
 }
 
 type createQueryIndexOptions struct {
@@ -32,13 +53,38 @@ type createQueryIndexOptions struct {
 
 	IgnoreIfExists bool
 	Deferred       bool
+	RawFields      []string
+	Where          string
+	ScopeName      string
+	CollectionName string
+	QueryContext   string
+}
+
+// queryIndexKeyspace returns the escaped keyspace for an index, which is the bucket itself unless a scope and
+// collection are given.
+func queryIndexKeyspace(bucketName, scopeName, collectionName string) string {
+	if scopeName == "" && collectionName == "" {
+		return "`" + bucketName + "`"
+	}
+
+	return "`" + bucketName + "`.`" + scopeName + "`.`" + collectionName + "`"
+}
+
+// queryIndexQueryContext returns the query context for index DDL against a scope, or an empty string if there is
+// no scope.
+func queryIndexQueryContext(bucketName, scopeName string) string {
+	if scopeName == "" {
+		return ""
+	}
+
+	return "default:`" + bucketName + "`.`" + scopeName + "`"
 }
 
 func (qm *QueryIndexManager) createIndex(tracectx requestSpanContext, bucketName, indexName string, fields []string,
 	startTime time.Time, opts createQueryIndexOptions) error {
 	var qs string
 
-	if len(fields) == 0 {
+	if len(fields) == 0 && len(opts.RawFields) == 0 {
 		qs += "CREATE PRIMARY INDEX"
 	} else {
 		qs += "CREATE INDEX"
@@ -46,8 +92,10 @@ func (qm *QueryIndexManager) createIndex(tracectx requestSpanContext, bucketName
 	if indexName != "" {
 		qs += " `" + indexName + "`"
 	}
-	qs += " ON `" + bucketName + "`"
-	if len(fields) > 0 {
+	qs += " ON " + queryIndexKeyspace(bucketName, opts.ScopeName, opts.CollectionName)
+	if len(opts.RawFields) > 0 {
+		qs += " (" + strings.Join(opts.RawFields, ", ") + ")"
+	} else if len(fields) > 0 {
 		qs += " ("
 		for i := 0; i < len(fields); i++ {
 			if i > 0 {
@@ -57,6 +105,9 @@ func (qm *QueryIndexManager) createIndex(tracectx requestSpanContext, bucketName
 		}
 		qs += ")"
 	}
+	if opts.Where != "" {
+		qs += " WHERE " + opts.Where
+	}
 	if opts.Deferred {
 		qs += " WITH {\"defer_build\": true}"
 	}
@@ -64,16 +115,12 @@ func (qm *QueryIndexManager) createIndex(tracectx requestSpanContext, bucketName
 	rows, err := qm.executeQuery(tracectx, qs, startTime, &QueryOptions{
 		RetryStrategy: opts.RetryStrategy,
 		Context:       opts.Context,
+		QueryContext:  opts.QueryContext,
 	})
 	if err != nil {
-		if strings.Contains(err.Error(), "already exist") {
-			if opts.IgnoreIfExists {
-				return nil
-			}
-			return queryIndexError{
-				statusCode: 409,
-				message:    err.Error(),
-			}
+		err = makeQueryIndexError(err)
+		if IsQueryIndexAlreadyExistsError(err) && opts.IgnoreIfExists {
+			return nil
 		}
 		return err
 	}
@@ -81,6 +128,65 @@ func (qm *QueryIndexManager) createIndex(tracectx requestSpanContext, bucketName
 	return rows.Close()
 }
 
+// makeQueryIndexError classifies an error returned by the query service by its error code, converting it into a
+// queryIndexError where it relates to the index or keyspace being managed.
+func makeQueryIndexError(err error) error {
+	qErr, ok := err.(QueryError)
+	if !ok {
+		return err
+	}
+
+	switch qErr.Code() {
+	case 4300:
+		return queryIndexError{statusCode: qErr.HTTPStatus(), message: err.Error(), indexExists: true}
+	case 12003:
+		return queryIndexError{statusCode: qErr.HTTPStatus(), message: err.Error(), keyspaceMissing: true}
+	case 12004:
+		return queryIndexError{statusCode: qErr.HTTPStatus(), message: err.Error(), indexMissing: true}
+	case 5000:
+		// GSI errors are reported under the generic 5000 code, so the message is all we have to go on.
+		msg := strings.ToLower(qErr.Message())
+		if strings.Contains(msg, "already exist") {
+			return queryIndexError{statusCode: qErr.HTTPStatus(), message: err.Error(), indexExists: true}
+		}
+		if strings.Contains(msg, "not found") {
+			return queryIndexError{statusCode: qErr.HTTPStatus(), message: err.Error(), indexMissing: true}
+		}
+	}
+
+	return err
+}
+
+// ExecuteRaw runs a N1QL index statement which isn't otherwise modelled by the manager, such as ALTER INDEX.
+// The statement is sent verbatim and any rows returned are discarded. Errors relating to the index or keyspace
+// are returned as QueryIndexesError.
+func (qm *QueryIndexManager) ExecuteRaw(statement string, opts *QueryOptions) error {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+
+	span := qm.tracer.StartSpan("ExecuteRaw", nil).
+		SetTag("couchbase.service", "n1ql")
+	defer span.Finish()
+
+	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, qm.globalTimeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	execOpts := *opts
+	execOpts.Context = ctx
+	execOpts.Prepared = false
+
+	rows, err := qm.executeQuery(span.Context(), statement, startTime, &execOpts)
+	if err != nil {
+		return makeQueryIndexError(err)
+	}
+
+	return rows.Close()
+}
+
 // CreateQueryIndexOptions is the set of options available to the query indexes CreateIndex operation.
 type CreateQueryIndexOptions struct {
 	Timeout       time.Duration
@@ -89,26 +195,31 @@ type CreateQueryIndexOptions struct {
 
 	IgnoreIfExists bool
 	Deferred       bool
+
+	// RawFields are index key expressions which are used verbatim, rather than being escaped as identifiers
+	// like fields are. This allows for creating array and functional indexes, e.g. LOWER(name).
+	// If set then fields is ignored.
+	RawFields []string
 }
 
 // CreateIndex creates an index over the specified fields.
 func (qm *QueryIndexManager) CreateIndex(bucketName, indexName string, fields []string, opts *CreateQueryIndexOptions) error {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &CreateQueryIndexOptions{}
+	}
+
 	if indexName == "" {
 		return invalidArgumentsError{
 			message: "an invalid index name was specified",
 		}
 	}
-	if len(fields) <= 0 {
+	if len(fields) <= 0 && len(opts.RawFields) <= 0 {
 		return invalidArgumentsError{
 			message: "you must specify at least one field to index",
 		}
 	}
 
-	startTime := time.Now()
-	if opts == nil {
-		opts = &CreateQueryIndexOptions{}
-	}
-
 	span := qm.tracer.StartSpan("CreateIndex", nil).
 		SetTag("couchbase.service", "n1ql")
 	defer span.Finish()
@@ -123,6 +234,7 @@ func (qm *QueryIndexManager) CreateIndex(bucketName, indexName string, fields []
 		Deferred:       opts.Deferred,
 		Context:        ctx,
 		RetryStrategy:  opts.RetryStrategy,
+		RawFields:      opts.RawFields,
 	})
 }
 
@@ -135,6 +247,11 @@ type CreatePrimaryQueryIndexOptions struct {
 	IgnoreIfExists bool
 	Deferred       bool
 	CustomName     string
+
+	// ScopeName and CollectionName specify the collection to create the primary index on. If neither is set then
+	// the index is created on the bucket itself.
+	ScopeName      string
+	CollectionName string
 }
 
 // CreatePrimaryIndex creates a primary index.  An empty customName uses the default naming.
@@ -144,6 +261,12 @@ func (qm *QueryIndexManager) CreatePrimaryIndex(bucketName string, opts *CreateP
 		opts = &CreatePrimaryQueryIndexOptions{}
 	}
 
+	if (opts.ScopeName == "") != (opts.CollectionName == "") {
+		return invalidArgumentsError{
+			message: "scope name and collection name must be specified together",
+		}
+	}
+
 	span := qm.tracer.StartSpan("CreatePrimaryIndex", nil).
 		SetTag("couchbase.service", "n1ql")
 	defer span.Finish()
@@ -158,9 +281,146 @@ func (qm *QueryIndexManager) CreatePrimaryIndex(bucketName string, opts *CreateP
 		Deferred:       opts.Deferred,
 		Context:        ctx,
 		RetryStrategy:  opts.RetryStrategy,
+		ScopeName:      opts.ScopeName,
+		CollectionName: opts.CollectionName,
+		QueryContext:   queryIndexQueryContext(bucketName, opts.ScopeName),
 	})
 }
 
+// QueryIndexSpec describes a single index to be created by CreateIndexes.
+type QueryIndexSpec struct {
+	// Name is the name of the index. It may be left empty for a primary index, in which case the default name
+	// is used.
+	Name string
+	// IsPrimary creates a primary index, in which case Fields, RawFields and Where are ignored.
+	IsPrimary bool
+	Fields    []string
+	// RawFields are index key expressions which are used verbatim, as with CreateQueryIndexOptions.
+	RawFields []string
+	// Where is a condition restricting the documents which are indexed, creating a partial index,
+	// e.g. `type` = "airline".
+	Where          string
+	IgnoreIfExists bool
+}
+
+// name returns the name that errors for the index are reported under.
+func (spec QueryIndexSpec) name() string {
+	if spec.Name == "" && spec.IsPrimary {
+		return "#primary"
+	}
+
+	return spec.Name
+}
+
+// CreateQueryIndexesOptions is the set of options available to the query indexes CreateIndexes operation.
+type CreateQueryIndexesOptions struct {
+	Timeout       time.Duration
+	Context       context.Context
+	RetryStrategy RetryStrategy
+
+	// BuildImmediately causes each index to be built as it is created. By default the indexes are created deferred
+	// so that they can be built together by BuildDeferredIndexes, which is much cheaper than building each in turn.
+	BuildImmediately bool
+	// Concurrency is the maximum number of indexes which are created at once, defaulting to 4.
+	Concurrency int
+}
+
+const defaultCreateQueryIndexesConcurrency = 4
+
+// CreateIndexes creates each of the given indexes, several at a time. The timeout applies to the batch as a whole.
+// Failing to create one index does not stop the others from being created, instead a CreateQueryIndexesError is
+// returned holding the error for each index which failed.
+func (qm *QueryIndexManager) CreateIndexes(bucketName string, specs []QueryIndexSpec, opts *CreateQueryIndexesOptions) error {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &CreateQueryIndexesOptions{}
+	}
+
+	names := make(map[string]struct{}, len(specs))
+	for _, spec := range specs {
+		if !spec.IsPrimary {
+			if spec.Name == "" {
+				return invalidArgumentsError{
+					message: "an invalid index name was specified",
+				}
+			}
+			if len(spec.Fields) == 0 && len(spec.RawFields) == 0 {
+				return invalidArgumentsError{
+					message: fmt.Sprintf("you must specify at least one field to index for %s", spec.Name),
+				}
+			}
+		}
+		if _, ok := names[spec.name()]; ok {
+			return invalidArgumentsError{
+				message: fmt.Sprintf("index %s was specified more than once", spec.name()),
+			}
+		}
+		names[spec.name()] = struct{}{}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultCreateQueryIndexesConcurrency
+	}
+	if concurrency > len(specs) {
+		concurrency = len(specs)
+	}
+
+	span := qm.tracer.StartSpan("CreateIndexes", nil).
+		SetTag("couchbase.service", "n1ql")
+	defer span.Finish()
+
+	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, qm.globalTimeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	specCh := make(chan QueryIndexSpec)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for spec := range specCh {
+				createOpts := createQueryIndexOptions{
+					IgnoreIfExists: spec.IgnoreIfExists,
+					Deferred:       !opts.BuildImmediately,
+					Context:        ctx,
+					RetryStrategy:  opts.RetryStrategy,
+				}
+				fields := spec.Fields
+				if spec.IsPrimary {
+					fields = nil
+				} else {
+					createOpts.RawFields = spec.RawFields
+					createOpts.Where = spec.Where
+				}
+
+				err := qm.createIndex(span.Context(), bucketName, spec.Name, fields, startTime, createOpts)
+				if err != nil {
+					lock.Lock()
+					errs[spec.name()] = err
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	for _, spec := range specs {
+		specCh <- spec
+	}
+	close(specCh)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return createQueryIndexesError{errors: errs}
+	}
+
+	return nil
+}
+
 type dropQueryIndexOptions struct {
 	Context       context.Context
 	RetryStrategy RetryStrategy
@@ -183,14 +443,9 @@ func (qm *QueryIndexManager) dropIndex(tracectx requestSpanContext, bucketName,
 		RetryStrategy: opts.RetryStrategy,
 	})
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			if opts.IgnoreIfNotExists {
-				return nil
-			}
-			return queryIndexError{
-				indexMissing: true,
-				message:      err.Error(),
-			}
+		err = makeQueryIndexError(err)
+		if IsQueryIndexNotFoundError(err) && opts.IgnoreIfNotExists {
+			return nil
 		}
 		return err
 	}
@@ -297,10 +552,11 @@ func (qm *QueryIndexManager) getAllIndexes(tracectx requestSpanContext, bucketNa
 		defer cancel()
 	}
 
-	q := "SELECT `indexes`.* FROM system:indexes WHERE keyspace_id=?"
+	// The keyspace_id of an index on a collection is the collection name, only bucket_id identifies its bucket.
+	q := "SELECT `indexes`.* FROM system:indexes WHERE (bucket_id=? OR (bucket_id IS MISSING AND keyspace_id=?))"
 	queryOpts := &QueryOptions{
 		Context:              ctx,
-		PositionalParameters: []interface{}{bucketName},
+		PositionalParameters: []interface{}{bucketName, bucketName},
 		RetryStrategy:        opts.RetryStrategy,
 		ReadOnly:             true,
 	}
@@ -323,6 +579,266 @@ func (qm *QueryIndexManager) getAllIndexes(tracectx requestSpanContext, bucketNa
 	return indexes, nil
 }
 
+type indexStatusIndex struct {
+	Name       string `json:"index"`
+	Bucket     string `json:"bucket"`
+	Status     string `json:"status"`
+	Definition string `json:"definition"`
+}
+
+type indexStatusResponse struct {
+	Indexes []indexStatusIndex `json:"indexes"`
+}
+
+func indexStatusToQueryIndex(index indexStatusIndex) QueryIndex {
+	var state string
+	switch index.Status {
+	case "Ready":
+		state = "online"
+	case "Created":
+		state = "deferred"
+	case "Building":
+		state = "building"
+	default:
+		state = strings.ToLower(index.Status)
+	}
+
+	return QueryIndex{
+		Name:      index.Name,
+		IsPrimary: strings.Contains(strings.ToUpper(index.Definition), "PRIMARY INDEX"),
+		Type:      IndexTypeN1ql,
+		State:     state,
+		Keyspace:  index.Bucket,
+		Namespace: "default",
+	}
+}
+
+// GetAllIndexesViaREST returns a list of all currently registered indexes, using the index status REST endpoint
+// rather than querying system:indexes. This allows indexes to be listed when the query service is unavailable.
+// IndexKey is not populated for indexes returned by this method.
+func (qm *QueryIndexManager) GetAllIndexesViaREST(bucketName string, opts *GetAllQueryIndexesOptions) ([]QueryIndex, error) {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &GetAllQueryIndexesOptions{}
+	}
+
+	span := qm.tracer.StartSpan("GetAllIndexesViaREST", nil).
+		SetTag("couchbase.service", "mgmt")
+	defer span.Finish()
+
+	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, qm.globalTimeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	retryStrategy := qm.defaultRetryStrategy
+	if opts.RetryStrategy != nil {
+		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
+	}
+
+	req := &gocbcore.HttpRequest{
+		Service:       gocbcore.ServiceType(MgmtService),
+		Path:          "/indexStatus",
+		Method:        "GET",
+		Context:       ctx,
+		IsIdempotent:  true,
+		RetryStrategy: retryStrategy,
+		UniqueId:      uuid.New().String(),
+	}
+
+	dspan := qm.tracer.StartSpan("dispatch", span.Context())
+	resp, err := doMgmtRequest(qm.httpClient, req)
+	dspan.Finish()
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			return nil, timeoutError{
+				operationID:   req.UniqueId,
+				retryReasons:  req.RetryReasons(),
+				retryAttempts: req.RetryAttempts(),
+				operation:     "mgmt",
+				elapsed:       time.Now().Sub(startTime),
+			}
+		}
+
+		return nil, err
+	}
+
+	err = decodeMgmtError(resp, 200)
+	if err != nil {
+		if mErr, ok := err.(mgmtHTTPError); ok {
+			return nil, queryIndexError{statusCode: mErr.statusCode, message: mErr.message}
+		}
+		return nil, err
+	}
+
+	var statusData indexStatusResponse
+	jsonDec := json.NewDecoder(resp.Body)
+	err = jsonDec.Decode(&statusData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = resp.Body.Close()
+	if err != nil {
+		logDebugf("Failed to close socket (%s)", err)
+	}
+
+	var indexes []QueryIndex
+	for _, index := range statusData.Indexes {
+		if index.Bucket != bucketName {
+			continue
+		}
+		indexes = append(indexes, indexStatusToQueryIndex(index))
+	}
+
+	return indexes, nil
+}
+
+// QueryIndexStats holds the operational statistics of a single index, as most recently sampled by the cluster.
+type QueryIndexStats struct {
+	// ItemsCount is the number of items in the index.
+	ItemsCount uint64
+	// NumDocsIndexed is the number of documents which have been indexed since the index was last built.
+	NumDocsIndexed uint64
+	// DataSize is the size, in bytes, of the data held by the index.
+	DataSize uint64
+	// MemoryUsed is the memory, in bytes, used by the index.
+	MemoryUsed uint64
+
+	// Raw holds the most recent sample of every statistic reported for the index, including those modelled above.
+	Raw map[string]float64
+}
+
+// indexStatsResponse is the response of the bucket index stats endpoint. Each sample is a series of values over
+// time, keyed as index/<index name>/<stat name> for per index stats.
+type indexStatsResponse struct {
+	Op struct {
+		Samples map[string][]float64 `json:"samples"`
+	} `json:"op"`
+}
+
+// indexStatsFromSamples groups the latest value of each per index sample by index name.
+func indexStatsFromSamples(samples map[string][]float64) map[string]QueryIndexStats {
+	stats := make(map[string]QueryIndexStats)
+	for key, values := range samples {
+		if !strings.HasPrefix(key, "index/") || len(values) == 0 {
+			continue
+		}
+
+		// Stats for the bucket as a whole have no index name, e.g. index/data_size.
+		key = strings.TrimPrefix(key, "index/")
+		sep := strings.LastIndex(key, "/")
+		if sep <= 0 {
+			continue
+		}
+		indexName, statName := key[:sep], key[sep+1:]
+		value := values[len(values)-1]
+
+		indexStats := stats[indexName]
+		if indexStats.Raw == nil {
+			indexStats.Raw = make(map[string]float64)
+		}
+		indexStats.Raw[statName] = value
+
+		switch statName {
+		case "items_count":
+			indexStats.ItemsCount = uint64(value)
+		case "num_docs_indexed":
+			indexStats.NumDocsIndexed = uint64(value)
+		case "data_size":
+			indexStats.DataSize = uint64(value)
+		case "memory_used":
+			indexStats.MemoryUsed = uint64(value)
+		}
+		stats[indexName] = indexStats
+	}
+
+	return stats
+}
+
+// GetQueryIndexStatsOptions is the set of options available to the query indexes GetIndexStats operation.
+type GetQueryIndexStatsOptions struct {
+	Timeout       time.Duration
+	Context       context.Context
+	RetryStrategy RetryStrategy
+}
+
+// GetIndexStats returns the statistics of each index on the bucket, keyed by index name. The stats are fetched from
+// the index statistics that the cluster manager collects from the indexer nodes, rather than from system:indexes.
+func (qm *QueryIndexManager) GetIndexStats(bucketName string, opts *GetQueryIndexStatsOptions) (map[string]QueryIndexStats, error) {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &GetQueryIndexStatsOptions{}
+	}
+
+	span := qm.tracer.StartSpan("GetIndexStats", nil).
+		SetTag("couchbase.service", "mgmt")
+	defer span.Finish()
+
+	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, qm.globalTimeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	retryStrategy := qm.defaultRetryStrategy
+	if opts.RetryStrategy != nil {
+		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
+	}
+
+	req := &gocbcore.HttpRequest{
+		Service:       gocbcore.ServiceType(MgmtService),
+		Path:          fmt.Sprintf("/pools/default/buckets/@index-%s/stats", bucketName),
+		Method:        "GET",
+		Context:       ctx,
+		IsIdempotent:  true,
+		RetryStrategy: retryStrategy,
+		UniqueId:      uuid.New().String(),
+	}
+
+	dspan := qm.tracer.StartSpan("dispatch", span.Context())
+	resp, err := doMgmtRequest(qm.httpClient, req)
+	dspan.Finish()
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			return nil, timeoutError{
+				operationID:   req.UniqueId,
+				retryReasons:  req.RetryReasons(),
+				retryAttempts: req.RetryAttempts(),
+				operation:     "mgmt",
+				elapsed:       time.Now().Sub(startTime),
+			}
+		}
+
+		return nil, err
+	}
+
+	err = decodeMgmtError(resp, 200)
+	if err != nil {
+		if mErr, ok := err.(mgmtHTTPError); ok {
+			return nil, queryIndexError{
+				statusCode:      mErr.statusCode,
+				message:         mErr.message,
+				keyspaceMissing: mErr.statusCode == 404,
+			}
+		}
+		return nil, err
+	}
+
+	var statsData indexStatsResponse
+	jsonDec := json.NewDecoder(resp.Body)
+	err = jsonDec.Decode(&statsData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = resp.Body.Close()
+	if err != nil {
+		logDebugf("Failed to close socket (%s)", err)
+	}
+
+	return indexStatsFromSamples(statsData.Op.Samples), nil
+}
+
 // BuildDeferredQueryIndexOptions is the set of options available to the query indexes BuildDeferredIndexes operation.
 type BuildDeferredQueryIndexOptions struct {
 	Timeout       time.Duration
@@ -330,7 +846,10 @@ type BuildDeferredQueryIndexOptions struct {
 	RetryStrategy RetryStrategy
 }
 
-// BuildDeferredIndexes builds all indexes which are currently in deferred state.
+// BuildDeferredIndexes builds all indexes which are currently in deferred state. One BUILD INDEX statement is issued
+// per keyspace and the names of the indexes built are returned ordered by keyspace and then name. Failing to build the
+// indexes on one keyspace does not stop the others from being built, instead the names of the indexes which were built
+// are returned along with a BuildDeferredQueryIndexesError holding the error for each keyspace which failed.
 func (qm *QueryIndexManager) BuildDeferredIndexes(bucketName string, opts *BuildDeferredQueryIndexOptions) ([]string, error) {
 	startTime := time.Now()
 	if opts == nil {
@@ -354,46 +873,95 @@ func (qm *QueryIndexManager) BuildDeferredIndexes(bucketName string, opts *Build
 		return nil, err
 	}
 
-	var deferredList []string
-	for i := 0; i < len(indexList); i++ {
-		var index = indexList[i]
-		if index.State == "deferred" || index.State == "pending" {
-			deferredList = append(deferredList, index.Name)
+	// Deferred indexes are grouped by the keyspace that they're on as a BUILD INDEX statement can only refer to a
+	// single keyspace. Keyspaces and the indexes within them are sorted so that the statements are deterministic.
+	deferredByKeyspace := make(map[string][]string)
+	for _, index := range indexList {
+		if index.State != "deferred" && index.State != "pending" {
+			continue
 		}
+
+		keyspace := index.keyspace()
+		deferredByKeyspace[keyspace] = append(deferredByKeyspace[keyspace], index.Name)
 	}
 
-	if len(deferredList) == 0 {
+	if len(deferredByKeyspace) == 0 {
 		// Don't try to build an empty index list
 		return nil, nil
 	}
 
-	var qs string
-	qs += "BUILD INDEX ON `" + bucketName + "`("
-	for i := 0; i < len(deferredList); i++ {
-		if i > 0 {
-			qs += ", "
-		}
-		qs += "`" + deferredList[i] + "`"
+	keyspaces := make([]string, 0, len(deferredByKeyspace))
+	for keyspace := range deferredByKeyspace {
+		keyspaces = append(keyspaces, keyspace)
 	}
-	qs += ")"
+	sort.Strings(keyspaces)
 
-	rows, err := qm.executeQuery(span.Context(), qs, startTime, &QueryOptions{
-		Context:       ctx,
-		RetryStrategy: opts.RetryStrategy,
-	})
-	if err != nil {
-		return nil, err
+	var deferredList []string
+	errs := make(map[string]error)
+	for _, keyspace := range keyspaces {
+		names := deferredByKeyspace[keyspace]
+		sort.Strings(names)
+
+		var uniqueNames []string
+		for i, name := range names {
+			if i == 0 || name != names[i-1] {
+				uniqueNames = append(uniqueNames, name)
+			}
+		}
+
+		qs := "BUILD INDEX ON " + keyspace + "("
+		for i, name := range uniqueNames {
+			if i > 0 {
+				qs += ", "
+			}
+			qs += "`" + name + "`"
+		}
+		qs += ")"
+
+		rows, err := qm.executeQuery(span.Context(), qs, startTime, &QueryOptions{
+			Context:       ctx,
+			RetryStrategy: opts.RetryStrategy,
+		})
+		if err == nil {
+			err = rows.Close()
+		}
+		if err != nil {
+			errs[keyspace] = err
+			continue
+		}
+
+		deferredList = append(deferredList, uniqueNames...)
 	}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
+	if len(errs) > 0 {
+		return deferredList, buildDeferredQueryIndexesError{errors: errs}
 	}
 
 	return deferredList, nil
 }
 
-func checkIndexesActive(indexes []QueryIndex, checkList []string) (bool, error) {
+// checkIndexesActive checks whether all of the indexes in checkList are online. If checkPrimary is set then the primary
+// index is also checked, it is resolved by its is_primary flag as it may have been created with a custom name.
+func checkIndexesActive(indexes []QueryIndex, checkList []string, checkPrimary bool) (bool, error) {
 	var checkIndexes []QueryIndex
+	if checkPrimary {
+		var found bool
+		for j := 0; j < len(indexes); j++ {
+			if indexes[j].IsPrimary {
+				checkIndexes = append(checkIndexes, indexes[j])
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false, queryIndexError{
+				indexMissing: true,
+				message:      "the primary index does not exist",
+			}
+		}
+	}
+
 	for i := 0; i < len(checkList); i++ {
 		indexName := checkList[i]
 
@@ -405,7 +973,12 @@ func checkIndexesActive(indexes []QueryIndex, checkList []string) (bool, error)
 		}
 	}
 
-	if len(checkIndexes) != len(checkList) {
+	expected := len(checkList)
+	if checkPrimary {
+		expected++
+	}
+
+	if len(checkIndexes) != expected {
 		return false, queryIndexError{
 			indexMissing: true,
 			message:      "the index specified does not exist",
@@ -454,10 +1027,6 @@ func (qm *QueryIndexManager) WatchIndexes(bucketName string, watchList []string,
 		defer cancel()
 	}
 
-	if opts.WatchPrimary {
-		watchList = append(watchList, "#primary")
-	}
-
 	curInterval := 50 * time.Millisecond
 	for {
 		indexes, err := qm.getAllIndexes(span.Context(), bucketName, startTime, &GetAllQueryIndexesOptions{
@@ -468,7 +1037,7 @@ func (qm *QueryIndexManager) WatchIndexes(bucketName string, watchList []string,
 			return err
 		}
 
-		allOnline, err := checkIndexesActive(indexes, watchList)
+		allOnline, err := checkIndexesActive(indexes, watchList, opts.WatchPrimary)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,836 @@
+package gocb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+)
+
+func TestQueryIndexManagerCreateIndexRawFieldsFunctional(t *testing.T) {
+	var statement string
+	mgr := testGetQueryIndexManager(func(stmt string, opts *QueryOptions) (*QueryResult, error) {
+		statement = stmt
+		return testQueryResultFromRows(t)
+	})
+
+	err := mgr.CreateIndex("travel-sample", "idx_lower_name", nil, &CreateQueryIndexOptions{
+		RawFields: []string{"LOWER(name)"},
+	})
+	if err != nil {
+		t.Fatalf("Expected CreateIndex to not error but was %v", err)
+	}
+
+	expected := "CREATE INDEX `idx_lower_name` ON `travel-sample` (LOWER(name))"
+	if statement != expected {
+		t.Fatalf("Expected statement to be %s but was %s", expected, statement)
+	}
+}
+
+func TestQueryIndexManagerCreateIndexRawFieldsArray(t *testing.T) {
+	var statement string
+	mgr := testGetQueryIndexManager(func(stmt string, opts *QueryOptions) (*QueryResult, error) {
+		statement = stmt
+		return testQueryResultFromRows(t)
+	})
+
+	err := mgr.CreateIndex("travel-sample", "idx_items", []string{"ignored"}, &CreateQueryIndexOptions{
+		RawFields: []string{"DISTINCT ARRAY v FOR v IN items END", "`type`"},
+		Deferred:  true,
+	})
+	if err != nil {
+		t.Fatalf("Expected CreateIndex to not error but was %v", err)
+	}
+
+	expected := "CREATE INDEX `idx_items` ON `travel-sample` (DISTINCT ARRAY v FOR v IN items END, `type`)" +
+		" WITH {\"defer_build\": true}"
+	if statement != expected {
+		t.Fatalf("Expected statement to be %s but was %s", expected, statement)
+	}
+}
+
+func TestQueryIndexManagerCreateIndexFields(t *testing.T) {
+	var statement string
+	mgr := testGetQueryIndexManager(func(stmt string, opts *QueryOptions) (*QueryResult, error) {
+		statement = stmt
+		return testQueryResultFromRows(t)
+	})
+
+	err := mgr.CreateIndex("travel-sample", "idx_name", []string{"name", "type"}, nil)
+	if err != nil {
+		t.Fatalf("Expected CreateIndex to not error but was %v", err)
+	}
+
+	expected := "CREATE INDEX `idx_name` ON `travel-sample` (`name`, `type`)"
+	if statement != expected {
+		t.Fatalf("Expected statement to be %s but was %s", expected, statement)
+	}
+}
+
+func TestQueryIndexManagerCreatePrimaryIndexStatements(t *testing.T) {
+	type tCase struct {
+		name     string
+		opts     *CreatePrimaryQueryIndexOptions
+		expected string
+	}
+
+	testCases := []tCase{
+		{
+			name:     "bucket",
+			opts:     nil,
+			expected: "CREATE PRIMARY INDEX ON `travel-sample`",
+		},
+		{
+			name:     "bucket custom name",
+			opts:     &CreatePrimaryQueryIndexOptions{CustomName: "my_primary"},
+			expected: "CREATE PRIMARY INDEX `my_primary` ON `travel-sample`",
+		},
+		{
+			name:     "collection",
+			opts:     &CreatePrimaryQueryIndexOptions{ScopeName: "inventory", CollectionName: "airline"},
+			expected: "CREATE PRIMARY INDEX ON `travel-sample`.`inventory`.`airline`",
+		},
+		{
+			name: "collection custom name",
+			opts: &CreatePrimaryQueryIndexOptions{
+				CustomName:     "my_primary",
+				ScopeName:      "inventory",
+				CollectionName: "airline",
+				Deferred:       true,
+			},
+			expected: "CREATE PRIMARY INDEX `my_primary` ON `travel-sample`.`inventory`.`airline`" +
+				" WITH {\"defer_build\": true}",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var statement string
+			mgr := testGetQueryIndexManager(func(stmt string, opts *QueryOptions) (*QueryResult, error) {
+				statement = stmt
+				return testQueryResultFromRows(t)
+			})
+
+			err := mgr.CreatePrimaryIndex("travel-sample", tc.opts)
+			if err != nil {
+				t.Fatalf("Expected CreatePrimaryIndex to not error but was %v", err)
+			}
+
+			if statement != tc.expected {
+				t.Fatalf("Expected statement to be %s but was %s", tc.expected, statement)
+			}
+		})
+	}
+}
+
+func TestQueryIndexManagerCreatePrimaryIndexQueryContext(t *testing.T) {
+	var queryContexts []string
+	mgr := testGetQueryIndexManager(func(stmt string, opts *QueryOptions) (*QueryResult, error) {
+		queryContexts = append(queryContexts, opts.QueryContext)
+		return testQueryResultFromRows(t)
+	})
+
+	err := mgr.CreatePrimaryIndex("travel-sample", &CreatePrimaryQueryIndexOptions{
+		ScopeName:      "inventory",
+		CollectionName: "airline",
+	})
+	if err != nil {
+		t.Fatalf("Expected CreatePrimaryIndex to not error but was %v", err)
+	}
+
+	err = mgr.CreatePrimaryIndex("travel-sample", nil)
+	if err != nil {
+		t.Fatalf("Expected CreatePrimaryIndex to not error but was %v", err)
+	}
+
+	expected := []string{"default:`travel-sample`.`inventory`", ""}
+	if !reflect.DeepEqual(queryContexts, expected) {
+		t.Fatalf("Expected query contexts to be %v but was %v", expected, queryContexts)
+	}
+}
+
+func TestQueryIndexManagerCreatePrimaryIndexScopeWithoutCollection(t *testing.T) {
+	mgr := testGetQueryIndexManager(func(stmt string, opts *QueryOptions) (*QueryResult, error) {
+		t.Fatalf("Expected no query to be executed")
+		return nil, nil
+	})
+
+	err := mgr.CreatePrimaryIndex("travel-sample", &CreatePrimaryQueryIndexOptions{ScopeName: "inventory"})
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
+func TestQueryIndexManagerGetAllIndexesViaREST(t *testing.T) {
+	data, err := loadRawTestDataset("index_status")
+	if err != nil {
+		t.Fatalf("Failed to load dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Service != gocbcore.MgmtService {
+			t.Fatalf("Service should have been MgmtService but was %d", req.Service)
+		}
+
+		if req.Path != "/indexStatus" {
+			t.Fatalf("Request path should have been /indexStatus but was %s", req.Path)
+		}
+
+		if req.Method != "GET" {
+			t.Fatalf("Request method should have been GET but was %s", req.Method)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(data), nil},
+		}, nil
+	}
+
+	mgr := testGetQueryIndexManager(nil)
+	mgr.httpClient = &mockHTTPProvider{doFn: doHTTP}
+
+	indexes, err := mgr.GetAllIndexesViaREST("travel-sample", nil)
+	if err != nil {
+		t.Fatalf("Expected GetAllIndexesViaREST to not error but was %v", err)
+	}
+
+	expected := []QueryIndex{
+		{
+			Name:      "def_primary",
+			IsPrimary: true,
+			Type:      IndexTypeN1ql,
+			State:     "online",
+			Keyspace:  "travel-sample",
+			Namespace: "default",
+		},
+		{
+			Name:      "def_airportname",
+			IsPrimary: false,
+			Type:      IndexTypeN1ql,
+			State:     "deferred",
+			Keyspace:  "travel-sample",
+			Namespace: "default",
+		},
+	}
+	if !reflect.DeepEqual(indexes, expected) {
+		t.Fatalf("Expected indexes to be %v but was %v", expected, indexes)
+	}
+}
+
+func TestQueryIndexManagerGetAllIndexesViaRESTError(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 403,
+			Body:       &testReadCloser{bytes.NewBufferString("Forbidden"), nil},
+		}, nil
+	}
+
+	mgr := testGetQueryIndexManager(nil)
+	mgr.httpClient = &mockHTTPProvider{doFn: doHTTP}
+
+	_, err := mgr.GetAllIndexesViaREST("travel-sample", nil)
+	if err == nil {
+		t.Fatalf("Expected GetAllIndexesViaREST to error")
+	}
+
+	idxErr, ok := err.(QueryIndexesError)
+	if !ok {
+		t.Fatalf("Expected error to be QueryIndexesError but was %v", err)
+	}
+
+	if idxErr.HTTPStatus() != 403 {
+		t.Fatalf("Expected error HTTP status to be 403 but was %d", idxErr.HTTPStatus())
+	}
+}
+
+func TestQueryIndexManagerGetAllIndexesViaRESTRetriesServiceUnavailable(t *testing.T) {
+	var attempts int
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		attempts++
+		if attempts == 1 {
+			return &gocbcore.HttpResponse{
+				Endpoint:   "http://localhost:8091",
+				StatusCode: 503,
+				Body:       &testReadCloser{bytes.NewBufferString("Service Unavailable"), nil},
+			}, nil
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(`{"indexes":[]}`), nil},
+		}, nil
+	}
+
+	mgr := testGetQueryIndexManager(nil)
+	mgr.httpClient = &mockHTTPProvider{doFn: doHTTP}
+	mgr.defaultRetryStrategy = newRetryStrategyWrapper(NewBestEffortRetryStrategy(nil))
+
+	_, err := mgr.GetAllIndexesViaREST("travel-sample", nil)
+	if err != nil {
+		t.Fatalf("Expected GetAllIndexesViaREST to succeed after retrying but was %v", err)
+	}
+
+	if attempts != 2 {
+		t.Fatalf("Expected 2 attempts but was %d", attempts)
+	}
+}
+
+func TestQueryIndexManagerGetAllIndexesViaRESTCancelled(t *testing.T) {
+	mgr := testGetQueryIndexManager(nil)
+	mgr.httpClient = testBlockingMgmtHTTPProvider()
+
+	_, err := mgr.GetAllIndexesViaREST("travel-sample", &GetAllQueryIndexesOptions{Context: testCancelAfter()})
+	if !IsCancelledError(err) {
+		t.Fatalf("Expected error to be cancelled but was %v", err)
+	}
+}
+
+func TestQueryIndexManagerCheckIndexesActivePrimary(t *testing.T) {
+	testCases := []struct {
+		name    string
+		indexes []QueryIndex
+	}{
+		{
+			name: "default",
+			indexes: []QueryIndex{
+				{Name: "#primary", IsPrimary: true, State: "online"},
+				{Name: "idx_name", State: "online"},
+			},
+		},
+		{
+			name: "custom",
+			indexes: []QueryIndex{
+				{Name: "my_primary", IsPrimary: true, State: "online"},
+				{Name: "idx_name", State: "online"},
+			},
+		},
+	}
+
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			online, err := checkIndexesActive(tCase.indexes, []string{"idx_name"}, true)
+			if err != nil {
+				t.Fatalf("Expected checkIndexesActive to not error but was %v", err)
+			}
+
+			if !online {
+				t.Fatalf("Expected indexes to be online")
+			}
+
+			tCase.indexes[0].State = "building"
+			online, err = checkIndexesActive(tCase.indexes, []string{"idx_name"}, true)
+			if err != nil {
+				t.Fatalf("Expected checkIndexesActive to not error but was %v", err)
+			}
+
+			if online {
+				t.Fatalf("Expected indexes to not be online")
+			}
+		})
+	}
+}
+
+func TestQueryIndexManagerCheckIndexesActiveNoPrimary(t *testing.T) {
+	indexes := []QueryIndex{
+		{Name: "idx_name", State: "online"},
+	}
+
+	_, err := checkIndexesActive(indexes, []string{"idx_name"}, true)
+	if err == nil {
+		t.Fatalf("Expected checkIndexesActive to error")
+	}
+
+	idxErr, ok := err.(QueryIndexesError)
+	if !ok {
+		t.Fatalf("Expected error to be QueryIndexesError but was %v", err)
+	}
+
+	if !idxErr.QueryIndexNotFoundError() {
+		t.Fatalf("Expected error to be index not found but was %v", err)
+	}
+}
+
+func TestQueryIndexManagerWatchIndexesCustomPrimary(t *testing.T) {
+	mgr := testGetQueryIndexManager(func(stmt string, opts *QueryOptions) (*QueryResult, error) {
+		return testQueryResultFromRows(t,
+			QueryIndex{Name: "my_primary", IsPrimary: true, State: "online"},
+			QueryIndex{Name: "idx_name", State: "online"},
+		)
+	})
+
+	err := mgr.WatchIndexes("travel-sample", []string{"idx_name"}, WatchQueryIndexTimeout{
+		Timeout: 5 * time.Second,
+	}, &WatchQueryIndexOptions{
+		WatchPrimary: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected WatchIndexes to not error but was %v", err)
+	}
+}
+
+func testGetQueryIndexManagerForFixture(t *testing.T, dataset string, statusCode int) *QueryIndexManager {
+	dataBytes, err := loadRawTestDataset(dataset)
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: statusCode,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, 10*time.Second, 0, 0)
+	cluster.sb.ManagementTimeout = 10 * time.Second
+
+	mgr, err := cluster.QueryIndexes()
+	if err != nil {
+		t.Fatalf("Failed to get query index manager: %v", err)
+	}
+
+	return mgr
+}
+
+func TestQueryIndexManagerCreateIndexExistsErrorCode(t *testing.T) {
+	mgr := testGetQueryIndexManagerForFixture(t, "query_index_exists_error", 500)
+
+	err := mgr.CreateIndex("travel-sample", "idx_name", []string{"name"}, nil)
+	if !IsQueryIndexAlreadyExistsError(err) {
+		t.Fatalf("Expected error to be index exists but was %v", err)
+	}
+
+	if IsKeyspaceNotFoundError(err) {
+		t.Fatalf("Expected error to not be keyspace not found")
+	}
+
+	err = mgr.CreateIndex("travel-sample", "idx_name", []string{"name"}, &CreateQueryIndexOptions{
+		IgnoreIfExists: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected CreateIndex to ignore the existing index but was %v", err)
+	}
+}
+
+func TestQueryIndexManagerCreateIndexKeyspaceNotFoundErrorCode(t *testing.T) {
+	mgr := testGetQueryIndexManagerForFixture(t, "query_keyspace_not_found_error", 404)
+
+	err := mgr.CreateIndex("missing-bucket", "idx_name", []string{"name"}, &CreateQueryIndexOptions{
+		IgnoreIfExists: true,
+	})
+	if !IsKeyspaceNotFoundError(err) {
+		t.Fatalf("Expected error to be keyspace not found but was %v", err)
+	}
+
+	if IsQueryIndexAlreadyExistsError(err) {
+		t.Fatalf("Expected error to not be index exists")
+	}
+
+	qiErr, ok := err.(QueryIndexesError)
+	if !ok {
+		t.Fatalf("Expected error to be QueryIndexesError but was %v", err)
+	}
+
+	if !qiErr.BucketNotFoundError() {
+		t.Fatalf("Expected error to be bucket not found")
+	}
+}
+
+func testGetQueryIndexManager(fn func(statement string, opts *QueryOptions) (*QueryResult, error)) *QueryIndexManager {
+	return &QueryIndexManager{
+		executeQuery: func(tracectx requestSpanContext, statement string, startTime time.Time,
+			opts *QueryOptions) (*QueryResult, error) {
+			return fn(statement, opts)
+		},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+}
+
+func testQueryResultFromRows(t *testing.T, rows ...interface{}) (*QueryResult, error) {
+	if rows == nil {
+		rows = []interface{}{}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"results": rows,
+		"status":  "success",
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal query rows: %v", err)
+	}
+
+	result := &QueryResult{
+		serializer: &DefaultJSONSerializer{},
+		ctx:        context.Background(),
+	}
+
+	streamResult, err := newStreamingResults(&testReadCloser{bytes.NewBuffer(body), nil}, result.readAttribute)
+	if err != nil {
+		t.Fatalf("Failed to create streaming results: %v", err)
+	}
+
+	err = streamResult.readAttributes()
+	if err != nil {
+		t.Fatalf("Failed to read attributes: %v", err)
+	}
+	result.streamResult = streamResult
+
+	return result, nil
+}
+
+func TestQueryIndexManagerExecuteRaw(t *testing.T) {
+	statement := "ALTER INDEX `travel-sample`.`idx_name` WITH {\"action\": \"move\", \"nodes\": [\"10.0.0.1:8091\"]}"
+
+	var dispatched string
+	var dispatchedOpts *QueryOptions
+	mgr := testGetQueryIndexManager(func(stmt string, opts *QueryOptions) (*QueryResult, error) {
+		dispatched = stmt
+		dispatchedOpts = opts
+		return testQueryResultFromRows(t)
+	})
+
+	err := mgr.ExecuteRaw(statement, &QueryOptions{
+		ClientContextID: "alter-index",
+	})
+	if err != nil {
+		t.Fatalf("Expected ExecuteRaw to succeed but was %v", err)
+	}
+
+	if dispatched != statement {
+		t.Fatalf("Expected statement to be %s but was %s", statement, dispatched)
+	}
+
+	if dispatchedOpts.ClientContextID != "alter-index" {
+		t.Fatalf("Expected options to be passed through but client context id was %s", dispatchedOpts.ClientContextID)
+	}
+
+	if _, ok := dispatchedOpts.Context.Deadline(); !ok {
+		t.Fatalf("Expected the manager timeout to be applied to the statement")
+	}
+}
+
+func TestQueryIndexManagerExecuteRawErrors(t *testing.T) {
+	mgr := testGetQueryIndexManagerForFixture(t, "query_index_exists_error", 500)
+
+	err := mgr.ExecuteRaw("CREATE INDEX `idx_name` ON `travel-sample`(name) PARTITION BY HASH(META().id)", nil)
+	if !IsQueryIndexAlreadyExistsError(err) {
+		t.Fatalf("Expected error to be index exists but was %v", err)
+	}
+
+	mgr = testGetQueryIndexManagerForFixture(t, "query_keyspace_not_found_error", 404)
+
+	err = mgr.ExecuteRaw("ALTER INDEX `missing-bucket`.`idx_name` WITH {\"action\": \"move\"}", nil)
+	if !IsKeyspaceNotFoundError(err) {
+		t.Fatalf("Expected error to be keyspace not found but was %v", err)
+	}
+}
+
+func TestQueryIndexManagerGetAllIndexesCollections(t *testing.T) {
+	var dispatched string
+	var dispatchedOpts *QueryOptions
+	mgr := testGetQueryIndexManager(func(statement string, opts *QueryOptions) (*QueryResult, error) {
+		dispatched = statement
+		dispatchedOpts = opts
+		return testQueryResultFromRows(t,
+			map[string]interface{}{"name": "idx_name", "state": "online", "keyspace_id": "travel-sample"},
+			map[string]interface{}{"name": "idx_airline", "state": "online", "keyspace_id": "airline",
+				"bucket_id": "travel-sample", "scope_id": "inventory"},
+		)
+	})
+
+	indexes, err := mgr.GetAllIndexes("travel-sample", nil)
+	if err != nil {
+		t.Fatalf("Expected GetAllIndexes to succeed but was %v", err)
+	}
+
+	expectedStatement := "SELECT `indexes`.* FROM system:indexes " +
+		"WHERE (bucket_id=? OR (bucket_id IS MISSING AND keyspace_id=?))"
+	if dispatched != expectedStatement {
+		t.Fatalf("Expected statement to be %s but was %s", expectedStatement, dispatched)
+	}
+
+	expectedParams := []interface{}{"travel-sample", "travel-sample"}
+	if !reflect.DeepEqual(dispatchedOpts.PositionalParameters, expectedParams) {
+		t.Fatalf("Expected parameters to be %v but was %v", expectedParams, dispatchedOpts.PositionalParameters)
+	}
+
+	if len(indexes) != 2 {
+		t.Fatalf("Expected 2 indexes but was %v", indexes)
+	}
+
+	collectionIndex := indexes[1]
+	if collectionIndex.Name != "idx_airline" || collectionIndex.BucketName != "travel-sample" ||
+		collectionIndex.ScopeName != "inventory" || collectionIndex.Keyspace != "airline" {
+		t.Fatalf("Expected the collection index to be returned but was %+v", collectionIndex)
+	}
+}
+
+func TestQueryIndexManagerBuildDeferredIndexes(t *testing.T) {
+	var statements []string
+	mgr := testGetQueryIndexManager(func(statement string, opts *QueryOptions) (*QueryResult, error) {
+		if strings.HasPrefix(statement, "SELECT") {
+			return testQueryResultFromRows(t,
+				map[string]interface{}{"name": "idx_zeta", "state": "deferred", "keyspace_id": "travel-sample"},
+				map[string]interface{}{"name": "idx_alpha", "state": "pending", "keyspace_id": "travel-sample"},
+				map[string]interface{}{"name": "idx_zeta", "state": "deferred", "keyspace_id": "travel-sample"},
+				map[string]interface{}{"name": "idx_online", "state": "online", "keyspace_id": "travel-sample"},
+				map[string]interface{}{"name": "idx_building", "state": "building", "keyspace_id": "travel-sample"},
+				map[string]interface{}{"name": "idx_alpha", "state": "deferred", "keyspace_id": "airline",
+					"bucket_id": "travel-sample", "scope_id": "inventory"},
+			)
+		}
+
+		statements = append(statements, statement)
+		return testQueryResultFromRows(t)
+	})
+
+	built, err := mgr.BuildDeferredIndexes("travel-sample", nil)
+	if err != nil {
+		t.Fatalf("Expected BuildDeferredIndexes to succeed but was %v", err)
+	}
+
+	expectedStatements := []string{
+		"BUILD INDEX ON `travel-sample`(`idx_alpha`, `idx_zeta`)",
+		"BUILD INDEX ON `travel-sample`.`inventory`.`airline`(`idx_alpha`)",
+	}
+	if len(statements) != len(expectedStatements) {
+		t.Fatalf("Expected %d statements but was %v", len(expectedStatements), statements)
+	}
+	for i, expected := range expectedStatements {
+		if statements[i] != expected {
+			t.Fatalf("Expected statement %d to be %s but was %s", i, expected, statements[i])
+		}
+	}
+
+	expectedBuilt := []string{"idx_alpha", "idx_zeta", "idx_alpha"}
+	if len(built) != len(expectedBuilt) {
+		t.Fatalf("Expected %d indexes to be built but was %v", len(expectedBuilt), built)
+	}
+	for i, name := range expectedBuilt {
+		if built[i] != name {
+			t.Fatalf("Expected built index %d to be %s but was %s", i, name, built[i])
+		}
+	}
+}
+
+func TestQueryIndexManagerBuildDeferredIndexesPartialFailure(t *testing.T) {
+	mgr := testGetQueryIndexManager(func(statement string, opts *QueryOptions) (*QueryResult, error) {
+		if strings.HasPrefix(statement, "SELECT") {
+			return testQueryResultFromRows(t,
+				map[string]interface{}{"name": "idx_zeta", "state": "deferred", "keyspace_id": "travel-sample"},
+				map[string]interface{}{"name": "idx_alpha", "state": "deferred", "keyspace_id": "airline",
+					"bucket_id": "travel-sample", "scope_id": "inventory"},
+			)
+		}
+
+		if strings.Contains(statement, "`airline`") {
+			return nil, queryIndexError{message: "build failed"}
+		}
+
+		return testQueryResultFromRows(t)
+	})
+
+	built, err := mgr.BuildDeferredIndexes("travel-sample", nil)
+	buildErr, ok := err.(BuildDeferredQueryIndexesError)
+	if !ok {
+		t.Fatalf("Expected error to be BuildDeferredQueryIndexesError but was %v", err)
+	}
+
+	if len(buildErr.Errors()) != 1 || buildErr.Errors()["`travel-sample`.`inventory`.`airline`"] == nil {
+		t.Fatalf("Expected only the airline keyspace to have failed but was %v", buildErr.Errors())
+	}
+
+	if len(built) != 1 || built[0] != "idx_zeta" {
+		t.Fatalf("Expected idx_zeta to have been built but was %v", built)
+	}
+}
+
+func TestQueryIndexManagerCreateIndexes(t *testing.T) {
+	var lock sync.Mutex
+	var statements []string
+	mgr := testGetQueryIndexManager(func(statement string, opts *QueryOptions) (*QueryResult, error) {
+		lock.Lock()
+		statements = append(statements, statement)
+		lock.Unlock()
+		return testQueryResultFromRows(t)
+	})
+
+	err := mgr.CreateIndexes("travel-sample", []QueryIndexSpec{
+		{IsPrimary: true},
+		{Name: "idx_name", Fields: []string{"name"}},
+		{Name: "idx_airline_country", Fields: []string{"country"}, Where: "`type` = \"airline\""},
+		{Name: "idx_lower_name", RawFields: []string{"LOWER(name)"}},
+	}, &CreateQueryIndexesOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("Expected CreateIndexes to succeed but was %v", err)
+	}
+
+	sort.Strings(statements)
+	expected := []string{
+		"CREATE INDEX `idx_airline_country` ON `travel-sample` (`country`) WHERE `type` = \"airline\" WITH {\"defer_build\": true}",
+		"CREATE INDEX `idx_lower_name` ON `travel-sample` (LOWER(name)) WITH {\"defer_build\": true}",
+		"CREATE INDEX `idx_name` ON `travel-sample` (`name`) WITH {\"defer_build\": true}",
+		"CREATE PRIMARY INDEX ON `travel-sample` WITH {\"defer_build\": true}",
+	}
+	if !reflect.DeepEqual(statements, expected) {
+		t.Fatalf("Expected statements to be %v but were %v", expected, statements)
+	}
+
+	statements = nil
+	err = mgr.CreateIndexes("travel-sample", []QueryIndexSpec{
+		{Name: "idx_name", Fields: []string{"name"}},
+	}, &CreateQueryIndexesOptions{BuildImmediately: true})
+	if err != nil {
+		t.Fatalf("Expected CreateIndexes to succeed but was %v", err)
+	}
+
+	if len(statements) != 1 || statements[0] != "CREATE INDEX `idx_name` ON `travel-sample` (`name`)" {
+		t.Fatalf("Expected index to be built immediately but statements were %v", statements)
+	}
+}
+
+func TestQueryIndexManagerCreateIndexesPartialFailure(t *testing.T) {
+	var lock sync.Mutex
+	var created []string
+	mgr := testGetQueryIndexManager(func(statement string, opts *QueryOptions) (*QueryResult, error) {
+		switch {
+		case strings.Contains(statement, "idx_exists"), strings.Contains(statement, "idx_ignored"):
+			return nil, queryError{ErrorCode: 4300, ErrorMessage: "The index already exists", httpStatus: 500}
+		case strings.Contains(statement, "idx_bad"):
+			return nil, queryError{ErrorCode: 3000, ErrorMessage: "syntax error", httpStatus: 400}
+		}
+
+		lock.Lock()
+		created = append(created, statement)
+		lock.Unlock()
+		return testQueryResultFromRows(t)
+	})
+
+	err := mgr.CreateIndexes("travel-sample", []QueryIndexSpec{
+		{Name: "idx_name", Fields: []string{"name"}},
+		{Name: "idx_exists", Fields: []string{"country"}},
+		{Name: "idx_ignored", Fields: []string{"city"}, IgnoreIfExists: true},
+		{Name: "idx_bad", RawFields: []string{"LOWER("}},
+		{Name: "idx_type", Fields: []string{"type"}},
+	}, nil)
+	if err == nil {
+		t.Fatalf("Expected CreateIndexes to fail")
+	}
+
+	cErr, ok := err.(CreateQueryIndexesError)
+	if !ok {
+		t.Fatalf("Expected error to be CreateQueryIndexesError but was %v", err)
+	}
+
+	if err.Error() != "failed to create 2 indexes: idx_bad, idx_exists" {
+		t.Fatalf("Expected error to list the failed indexes but was %s", err.Error())
+	}
+
+	errs := cErr.Errors()
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors but was %v", errs)
+	}
+
+	if !IsQueryIndexAlreadyExistsError(errs["idx_exists"]) {
+		t.Fatalf("Expected idx_exists error to be index exists but was %v", errs["idx_exists"])
+	}
+
+	if IsQueryIndexAlreadyExistsError(errs["idx_bad"]) {
+		t.Fatalf("Expected idx_bad error to not be index exists")
+	}
+
+	if len(created) != 2 {
+		t.Fatalf("Expected the remaining indexes to be created but were %v", created)
+	}
+}
+
+func TestQueryIndexManagerCreateIndexesInvalidSpec(t *testing.T) {
+	mgr := testGetQueryIndexManager(func(statement string, opts *QueryOptions) (*QueryResult, error) {
+		t.Fatalf("Expected no statements to be run but was %s", statement)
+		return nil, nil
+	})
+
+	specs := [][]QueryIndexSpec{
+		{{Fields: []string{"name"}}},
+		{{Name: "idx_name"}},
+		{{Name: "idx_name", Fields: []string{"name"}}, {Name: "idx_name", Fields: []string{"city"}}},
+	}
+	for _, spec := range specs {
+		err := mgr.CreateIndexes("travel-sample", spec, nil)
+		if !IsInvalidArgumentsError(err) {
+			t.Fatalf("Expected error to be invalid arguments for %v but was %v", spec, err)
+		}
+	}
+}
+
+func TestQueryIndexManagerGetIndexStats(t *testing.T) {
+	mgr := testGetQueryIndexManagerForFixture(t, "query_index_stats", 200)
+
+	stats, err := mgr.GetIndexStats("travel-sample", nil)
+	if err != nil {
+		t.Fatalf("Expected GetIndexStats to succeed but was %v", err)
+	}
+
+	if len(stats) != 2 {
+		t.Fatalf("Expected stats for 2 indexes but was %v", stats)
+	}
+
+	nameStats, ok := stats["def_name_type"]
+	if !ok {
+		t.Fatalf("Expected stats for def_name_type but was %v", stats)
+	}
+
+	if nameStats.ItemsCount != 7303 || nameStats.NumDocsIndexed != 31592 || nameStats.DataSize != 2428928 ||
+		nameStats.MemoryUsed != 4861952 {
+		t.Fatalf("Expected the latest samples for def_name_type but was %+v", nameStats)
+	}
+
+	if nameStats.Raw["disk_size"] != 2617344 {
+		t.Fatalf("Expected raw disk_size to be 2617344 but was %v", nameStats.Raw["disk_size"])
+	}
+
+	if stats["def_primary"].Raw["cache_hit_percent"] != 99.25 {
+		t.Fatalf("Expected raw cache_hit_percent to be 99.25 but was %v", stats["def_primary"].Raw["cache_hit_percent"])
+	}
+}
+
+func TestQueryIndexManagerGetIndexStatsBucketNotFound(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Path != "/pools/default/buckets/@index-missing-bucket/stats" {
+			t.Fatalf("Expected path to be the bucket index stats but was %s", req.Path)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 404,
+			Body:       &testReadCloser{bytes.NewBufferString("Requested resource not found.\r\n"), nil},
+		}, nil
+	}
+
+	mgr := &QueryIndexManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	_, err := mgr.GetIndexStats("missing-bucket", nil)
+	if !IsKeyspaceNotFoundError(err) {
+		t.Fatalf("Expected error to be keyspace not found but was %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package gocb
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+)
+
+// QuerySettingsManager provides methods for managing the cluster wide settings of the query service.
+// Volatile: This API is subject to change at any time.
+type QuerySettingsManager struct {
+	httpClient           httpProvider
+	globalTimeout        time.Duration
+	defaultRetryStrategy *retryStrategyWrapper
+	tracer               requestTracer
+}
+
+// QuerySettings holds the tunable settings of the query service. Fields left as their zero value are not sent
+// by UpdateQuerySettings, so unchanged settings keep whatever value the service already has.
+type QuerySettings struct {
+	// CompletedLimit is the number of requests which are kept in the completed requests catalog.
+	CompletedLimit int
+	// CompletedThreshold is how long a request must run for before it is kept in the completed requests catalog.
+	CompletedThreshold time.Duration
+	// LogLevel is the level that the query service logs at, e.g. "info" or "debug".
+	LogLevel string
+	// MaxParallelism is the maximum number of index partitions that a request will scan in parallel.
+	MaxParallelism int
+	// PipelineBatch is the number of items which are batched together when passed between operators.
+	PipelineBatch int
+	// PipelineCap is the maximum number of items each operator can buffer.
+	PipelineCap int
+	// PreparedLimit is the maximum number of prepared statements which are cached.
+	PreparedLimit int
+	// ScanCap is the maximum buffered channel size for index scans.
+	ScanCap int
+	// Timeout is the server side timeout applied to requests which do not specify their own.
+	Timeout time.Duration
+
+	// Raw holds any settings returned by the service which are not modelled above. Any entries in it are sent
+	// as they are by UpdateQuerySettings, which can be used to change settings not modelled here.
+	Raw map[string]interface{}
+}
+
+// querySettingsJSON maps QuerySettings to the keys used by the query service admin endpoint. The completed
+// threshold is in milliseconds whereas the timeout is in nanoseconds.
+type querySettingsJSON struct {
+	CompletedLimit     int    `json:"completed-limit,omitempty"`
+	CompletedThreshold int64  `json:"completed-threshold,omitempty"`
+	LogLevel           string `json:"loglevel,omitempty"`
+	MaxParallelism     int    `json:"max-parallelism,omitempty"`
+	PipelineBatch      int    `json:"pipeline-batch,omitempty"`
+	PipelineCap        int    `json:"pipeline-cap,omitempty"`
+	PreparedLimit      int    `json:"prepared-limit,omitempty"`
+	ScanCap            int    `json:"scan-cap,omitempty"`
+	Timeout            int64  `json:"timeout,omitempty"`
+}
+
+var querySettingsKeys = []string{
+	"completed-limit", "completed-threshold", "loglevel", "max-parallelism", "pipeline-batch", "pipeline-cap",
+	"prepared-limit", "scan-cap", "timeout",
+}
+
+func (s *QuerySettings) fromData(data []byte) error {
+	var settingsData querySettingsJSON
+	err := json.Unmarshal(data, &settingsData)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]interface{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	for _, key := range querySettingsKeys {
+		delete(raw, key)
+	}
+
+	s.CompletedLimit = settingsData.CompletedLimit
+	s.CompletedThreshold = time.Duration(settingsData.CompletedThreshold) * time.Millisecond
+	s.LogLevel = settingsData.LogLevel
+	s.MaxParallelism = settingsData.MaxParallelism
+	s.PipelineBatch = settingsData.PipelineBatch
+	s.PipelineCap = settingsData.PipelineCap
+	s.PreparedLimit = settingsData.PreparedLimit
+	s.ScanCap = settingsData.ScanCap
+	s.Timeout = time.Duration(settingsData.Timeout)
+	s.Raw = raw
+
+	return nil
+}
+
+func (s *QuerySettings) toData() ([]byte, error) {
+	settingsData, err := json.Marshal(querySettingsJSON{
+		CompletedLimit:     s.CompletedLimit,
+		CompletedThreshold: int64(s.CompletedThreshold / time.Millisecond),
+		LogLevel:           s.LogLevel,
+		MaxParallelism:     s.MaxParallelism,
+		PipelineBatch:      s.PipelineBatch,
+		PipelineCap:        s.PipelineCap,
+		PreparedLimit:      s.PreparedLimit,
+		ScanCap:            s.ScanCap,
+		Timeout:            s.Timeout.Nanoseconds(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.Raw) == 0 {
+		return settingsData, nil
+	}
+
+	// The modelled settings take precedence over the same keys in Raw.
+	settings := make(map[string]interface{}, len(s.Raw))
+	for key, value := range s.Raw {
+		settings[key] = value
+	}
+	var modelled map[string]interface{}
+	err = json.Unmarshal(settingsData, &modelled)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range modelled {
+		settings[key] = value
+	}
+
+	return json.Marshal(settings)
+}
+
+// GetQuerySettingsOptions is the set of options available to the query settings GetQuerySettings operation.
+type GetQuerySettingsOptions struct {
+	Timeout       time.Duration
+	Context       context.Context
+	RetryStrategy RetryStrategy
+}
+
+// GetQuerySettings retrieves the current settings of the query service.
+func (qsm *QuerySettingsManager) GetQuerySettings(opts *GetQuerySettingsOptions) (*QuerySettings, error) {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &GetQuerySettingsOptions{}
+	}
+
+	span := qsm.tracer.StartSpan("GetQuerySettings", nil).
+		SetTag("couchbase.service", "n1ql")
+	defer span.Finish()
+
+	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, qsm.globalTimeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	retryStrategy := qsm.defaultRetryStrategy
+	if opts.RetryStrategy != nil {
+		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
+	}
+
+	req := &gocbcore.HttpRequest{
+		Service:       gocbcore.ServiceType(QueryService),
+		Path:          "/admin/settings",
+		Method:        "GET",
+		Context:       ctx,
+		IsIdempotent:  true,
+		RetryStrategy: retryStrategy,
+		UniqueId:      uuid.New().String(),
+	}
+
+	dspan := qsm.tracer.StartSpan("dispatch", span.Context())
+	resp, err := doMgmtRequest(qsm.httpClient, req)
+	dspan.Finish()
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			return nil, timeoutError{
+				operationID:   req.UniqueId,
+				retryReasons:  req.RetryReasons(),
+				retryAttempts: req.RetryAttempts(),
+				operation:     "n1ql",
+				elapsed:       time.Now().Sub(startTime),
+			}
+		}
+
+		return nil, err
+	}
+
+	err = decodeMgmtError(resp, 200)
+	if err != nil {
+		return nil, makeQuerySettingsError(err)
+	}
+
+	var data json.RawMessage
+	jsonDec := json.NewDecoder(resp.Body)
+	err = jsonDec.Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = resp.Body.Close()
+	if err != nil {
+		logDebugf("Failed to close socket (%s)", err)
+	}
+
+	var settings QuerySettings
+	err = settings.fromData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// UpdateQuerySettingsOptions is the set of options available to the query settings UpdateQuerySettings operation.
+type UpdateQuerySettingsOptions struct {
+	Timeout       time.Duration
+	Context       context.Context
+	RetryStrategy RetryStrategy
+}
+
+// UpdateQuerySettings changes the settings of the query service. Only settings which are set in settings are
+// changed, see QuerySettings.
+func (qsm *QuerySettingsManager) UpdateQuerySettings(settings QuerySettings, opts *UpdateQuerySettingsOptions) error {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &UpdateQuerySettingsOptions{}
+	}
+
+	span := qsm.tracer.StartSpan("UpdateQuerySettings", nil).
+		SetTag("couchbase.service", "n1ql")
+	defer span.Finish()
+
+	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, qsm.globalTimeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	retryStrategy := qsm.defaultRetryStrategy
+	if opts.RetryStrategy != nil {
+		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
+	}
+
+	espan := qsm.tracer.StartSpan("encode", span.Context())
+	data, err := settings.toData()
+	espan.Finish()
+	if err != nil {
+		return err
+	}
+
+	req := &gocbcore.HttpRequest{
+		Service:       gocbcore.ServiceType(QueryService),
+		Path:          "/admin/settings",
+		Method:        "POST",
+		Body:          data,
+		ContentType:   "application/json",
+		Context:       ctx,
+		IsIdempotent:  true,
+		RetryStrategy: retryStrategy,
+		UniqueId:      uuid.New().String(),
+	}
+
+	dspan := qsm.tracer.StartSpan("dispatch", span.Context())
+	resp, err := doMgmtRequest(qsm.httpClient, req)
+	dspan.Finish()
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			return timeoutError{
+				operationID:   req.UniqueId,
+				retryReasons:  req.RetryReasons(),
+				retryAttempts: req.RetryAttempts(),
+				operation:     "n1ql",
+				elapsed:       time.Now().Sub(startTime),
+			}
+		}
+
+		return err
+	}
+
+	err = decodeMgmtError(resp, 200)
+	if err != nil {
+		return makeQuerySettingsError(err)
+	}
+
+	err = resp.Body.Close()
+	if err != nil {
+		logDebugf("Failed to close socket (%s)", err)
+	}
+
+	return nil
+}
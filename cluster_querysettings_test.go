@@ -0,0 +1,154 @@
+package gocb
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+)
+
+func TestQuerySettingsGet(t *testing.T) {
+	dataBytes, err := loadRawTestDataset("query_settings")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Service != gocbcore.N1qlService {
+			t.Fatalf("Expected request to go to the query service but was %d", req.Service)
+		}
+		if req.Method != "GET" || req.Path != "/admin/settings" {
+			t.Fatalf("Expected GET /admin/settings but was %s %s", req.Method, req.Path)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(dataBytes), nil},
+		}, nil
+	}
+
+	mgr := &QuerySettingsManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	settings, err := mgr.GetQuerySettings(nil)
+	if err != nil {
+		t.Fatalf("Expected GetQuerySettings to succeed but was %v", err)
+	}
+
+	expected := QuerySettings{
+		CompletedLimit:     4000,
+		CompletedThreshold: 1 * time.Second,
+		LogLevel:           "INFO",
+		MaxParallelism:     1,
+		PipelineBatch:      16,
+		PipelineCap:        512,
+		PreparedLimit:      16384,
+		ScanCap:            512,
+		Timeout:            2 * time.Minute,
+	}
+	raw := settings.Raw
+	settings.Raw = nil
+	if !reflect.DeepEqual(*settings, expected) {
+		t.Fatalf("Expected settings to be %+v but was %+v", expected, *settings)
+	}
+
+	if raw["servicers"] != float64(4) || raw["profile"] != "off" {
+		t.Fatalf("Expected unmodelled settings to be kept in Raw but was %v", raw)
+	}
+
+	for _, key := range querySettingsKeys {
+		if _, ok := raw[key]; ok {
+			t.Fatalf("Expected modelled setting %s not to be in Raw", key)
+		}
+	}
+}
+
+func TestQuerySettingsUpdate(t *testing.T) {
+	var body map[string]interface{}
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Service != gocbcore.N1qlService {
+			t.Fatalf("Expected request to go to the query service but was %d", req.Service)
+		}
+		if req.Method != "POST" || req.Path != "/admin/settings" {
+			t.Fatalf("Expected POST /admin/settings but was %s %s", req.Method, req.Path)
+		}
+		if req.ContentType != "application/json" {
+			t.Fatalf("Expected content type to be application/json but was %s", req.ContentType)
+		}
+
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal request body: %v", err)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString("{}"), nil},
+		}, nil
+	}
+
+	mgr := &QuerySettingsManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	err := mgr.UpdateQuerySettings(QuerySettings{
+		CompletedLimit:     5000,
+		CompletedThreshold: 2 * time.Second,
+		LogLevel:           "debug",
+		Timeout:            30 * time.Second,
+		Raw: map[string]interface{}{
+			"servicers": 8,
+			"loglevel":  "info",
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected UpdateQuerySettings to succeed but was %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"completed-limit":     float64(5000),
+		"completed-threshold": float64(2000),
+		"loglevel":            "debug",
+		"timeout":             float64(30000000000),
+		"servicers":           float64(8),
+	}
+	if !reflect.DeepEqual(body, expected) {
+		t.Fatalf("Expected request body to be %v but was %v", expected, body)
+	}
+}
+
+func TestQuerySettingsUpdateError(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 400,
+			Body:       &testReadCloser{bytes.NewBufferString(`{"error":"Unknown parameter: servicer"}`), nil},
+		}, nil
+	}
+
+	mgr := &QuerySettingsManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	err := mgr.UpdateQuerySettings(QuerySettings{Raw: map[string]interface{}{"servicer": 8}}, nil)
+	settingsErr, ok := err.(QuerySettingsError)
+	if !ok {
+		t.Fatalf("Expected error to be a QuerySettingsError but was %v", err)
+	}
+
+	if settingsErr.HTTPStatus() != 400 {
+		t.Fatalf("Expected status to be 400 but was %d", settingsErr.HTTPStatus())
+	}
+}
@@ -60,7 +60,7 @@ func (sim *SearchIndexManager) GetAllIndexes(opts *GetAllSearchIndexOptions) ([]
 	}
 
 	retryStrategy := sim.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -148,7 +148,7 @@ func (sim *SearchIndexManager) GetIndex(indexName string, opts *GetSearchIndexOp
 	}
 
 	retryStrategy := sim.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -260,7 +260,7 @@ func (sim *SearchIndexManager) UpsertIndex(indexDefinition SearchIndex, opts *Up
 	}
 
 	retryStrategy := sim.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -348,7 +348,7 @@ func (sim *SearchIndexManager) DropIndex(indexName string, opts *DropSearchIndex
 	}
 
 	retryStrategy := sim.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -425,7 +425,7 @@ func (sim *SearchIndexManager) AnalyzeDocument(indexName string, doc interface{}
 	}
 
 	retryStrategy := sim.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -519,7 +519,7 @@ func (sim *SearchIndexManager) GetIndexedDocumentsCount(indexName string, opts *
 	}
 
 	retryStrategy := sim.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -117,7 +117,7 @@ func (row *SearchRow) Fields(valuePtr interface{}) error {
 		return errors.New("no fields to scan")
 	}
 
-	err := row.serializer.Deserialize(row.fields, valuePtr)
+	err := decodeRow(row.serializer, row.fields, valuePtr)
 	if err != nil {
 		return err
 	}
@@ -218,7 +218,7 @@ func (r *SearchResult) Close() error {
 		return r.err
 	}
 
-	err := r.streamResult.Close()
+	err := r.streamResult.closeWithContext(r.ctx)
 	ctxErr := r.ctx.Err()
 	if r.cancel != nil {
 		r.cancel()
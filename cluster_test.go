@@ -0,0 +1,87 @@
+package gocb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbaselabs/gocbconnstr"
+)
+
+func testParseConnStrOptions(t *testing.T, connStr string) (*Cluster, error) {
+	spec, err := gocbconnstr.Parse(connStr)
+	if err != nil {
+		t.Fatalf("Failed to parse connection string: %v", err)
+	}
+
+	c := &Cluster{}
+	c.sb.KvTimeout = 2500 * time.Millisecond
+	c.sb.QueryTimeout = 75 * time.Second
+	c.sb.AnalyticsTimeout = 75 * time.Second
+	c.sb.SearchTimeout = 75 * time.Second
+	c.sb.ViewTimeout = 75 * time.Second
+	c.sb.ManagementTimeout = 75 * time.Second
+
+	return c, c.parseExtraConnStrOptions(spec)
+}
+
+func TestParseConnStrTimeouts(t *testing.T) {
+	c, err := testParseConnStrOptions(t, "couchbase://localhost?kv_timeout=5s&query_timeout=30s"+
+		"&analytics_timeout=2m&search_timeout=1500ms&view_timeout=20000&management_timeout=1m30s")
+	if err != nil {
+		t.Fatalf("Expected options to parse but was %v", err)
+	}
+
+	expected := map[string][2]time.Duration{
+		"kv":         {c.sb.KvTimeout, 5 * time.Second},
+		"query":      {c.sb.QueryTimeout, 30 * time.Second},
+		"analytics":  {c.sb.AnalyticsTimeout, 2 * time.Minute},
+		"search":     {c.sb.SearchTimeout, 1500 * time.Millisecond},
+		"view":       {c.sb.ViewTimeout, 20 * time.Second},
+		"management": {c.sb.ManagementTimeout, 90 * time.Second},
+	}
+	for service, timeouts := range expected {
+		if timeouts[0] != timeouts[1] {
+			t.Fatalf("Expected %s timeout to be %s but was %s", service, timeouts[1], timeouts[0])
+		}
+	}
+}
+
+func TestParseConnStrQueryTimeoutPrecedence(t *testing.T) {
+	c, err := testParseConnStrOptions(t, "couchbase://localhost?n1ql_timeout=10000&query_timeout=30s")
+	if err != nil {
+		t.Fatalf("Expected options to parse but was %v", err)
+	}
+
+	if c.sb.QueryTimeout != 30*time.Second {
+		t.Fatalf("Expected query_timeout to take precedence but query timeout was %s", c.sb.QueryTimeout)
+	}
+
+	c, err = testParseConnStrOptions(t, "couchbase://localhost?n1ql_timeout=10000")
+	if err != nil {
+		t.Fatalf("Expected options to parse but was %v", err)
+	}
+
+	if c.sb.QueryTimeout != 10*time.Second {
+		t.Fatalf("Expected query timeout to be 10s but was %s", c.sb.QueryTimeout)
+	}
+}
+
+func TestParseConnStrUnknownOption(t *testing.T) {
+	c, err := testParseConnStrOptions(t, "couchbase://localhost?kv_timout=5s&kv_pool_size=2")
+	if err != nil {
+		t.Fatalf("Expected unknown options to be ignored but was %v", err)
+	}
+
+	if c.sb.KvTimeout != 2500*time.Millisecond {
+		t.Fatalf("Expected kv timeout to be unchanged but was %s", c.sb.KvTimeout)
+	}
+}
+
+func TestParseConnStrMalformedTimeout(t *testing.T) {
+	for _, value := range []string{"5 seconds", "5x", "-5s", "-100"} {
+		_, err := testParseConnStrOptions(t, "couchbase://localhost?kv_timeout="+value)
+		if err == nil {
+			t.Fatalf("Expected kv_timeout of %s to fail to parse", value)
+		}
+	}
+}
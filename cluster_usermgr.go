@@ -1,15 +1,16 @@
 package gocb
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 
 	gocbcore "github.com/couchbase/gocbcore/v8"
 )
@@ -162,11 +163,22 @@ type GetAllUsersOptions struct {
 	RetryStrategy RetryStrategy
 
 	DomainName string
+	// PageSize is the number of users to fetch per request, users are always fetched from the server a page at a
+	// time. If not set then this defaults to 100. Timeout applies to the fetch of each page.
+	PageSize int
+}
+
+const defaultUserPageSize = 100
+
+type userPageJson struct {
+	Users []userMetadataJson `json:"users"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
 }
 
 // GetAllUsers returns a list of all the users from the cluster.
 func (um *UserManager) GetAllUsers(opts *GetAllUsersOptions) ([]UserAndMetadata, error) {
-	startTime := time.Now()
 	if opts == nil {
 		opts = &GetAllUsersOptions{}
 	}
@@ -175,36 +187,122 @@ func (um *UserManager) GetAllUsers(opts *GetAllUsersOptions) ([]UserAndMetadata,
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
-	if opts.DomainName == "" {
-		opts.DomainName = string(LocalDomain)
+	var users []UserAndMetadata
+	err := um.forEachUser(span.Context(), opts, func(user UserAndMetadata) error {
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, um.globalTimeout)
-	if cancel != nil {
-		defer cancel()
+	return users, nil
+}
+
+// ForEachUser calls fn for each user on the cluster, fetching users from the server a page at a time so that the
+// full set of users is never held in memory. If fn returns an error then iteration stops and the error is returned.
+func (um *UserManager) ForEachUser(fn func(UserAndMetadata) error, opts *GetAllUsersOptions) error {
+	if opts == nil {
+		opts = &GetAllUsersOptions{}
+	}
+
+	span := um.tracer.StartSpan("ForEachUser", nil).
+		SetTag("couchbase.service", "mgmt")
+	defer span.Finish()
+
+	return um.forEachUser(span.Context(), opts, fn)
+}
+
+// UsersWithStalePasswords returns the users whose password was last changed longer than maxAge ago. Users without
+// a password change date, such as those in the external domain, are not returned.
+func (um *UserManager) UsersWithStalePasswords(maxAge time.Duration, opts *GetAllUsersOptions) ([]UserAndMetadata, error) {
+	if opts == nil {
+		opts = &GetAllUsersOptions{}
+	}
+
+	span := um.tracer.StartSpan("UsersWithStalePasswords", nil).
+		SetTag("couchbase.service", "mgmt")
+	defer span.Finish()
+
+	cutoff := time.Now().Add(-maxAge)
+	var users []UserAndMetadata
+	err := um.forEachUser(span.Context(), opts, func(user UserAndMetadata) error {
+		if !user.PasswordChanged.IsZero() && user.PasswordChanged.Before(cutoff) {
+			users = append(users, user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// forEachUser calls fn for each user, a page at a time. The timeout applies to fetching each page rather than to the
+// whole iteration, so time spent in fn doesn't count against it.
+func (um *UserManager) forEachUser(tracectx requestSpanContext, opts *GetAllUsersOptions,
+	fn func(UserAndMetadata) error) error {
+	domainName := opts.DomainName
+	if domainName == "" {
+		domainName = string(LocalDomain)
+	}
+
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = defaultUserPageSize
 	}
 
 	retryStrategy := um.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
+	path := fmt.Sprintf("/settings/rbac/users/%s?pageSize=%d", domainName, pageSize)
+	for path != "" {
+		usersData, next, err := um.getUsersPage(tracectx, path, opts, retryStrategy)
+		if err != nil {
+			return err
+		}
+
+		for _, userData := range usersData {
+			err = fn(transformUserMetadataJson(&userData))
+			if err != nil {
+				return err
+			}
+		}
+
+		path = next
+	}
+
+	return nil
+}
+
+// getUsersPage fetches the users at path, returning the path of the next page if there is one.
+func (um *UserManager) getUsersPage(tracectx requestSpanContext, path string, opts *GetAllUsersOptions,
+	strategy *retryStrategyWrapper) ([]userMetadataJson, string, error) {
+	startTime := time.Now()
+	ctx, cancel := contextFromMaybeTimeout(opts.Context, opts.Timeout, um.globalTimeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
 	req := &gocbcore.HttpRequest{
 		Service:       gocbcore.ServiceType(MgmtService),
 		Method:        "GET",
-		Path:          fmt.Sprintf("/settings/rbac/users/%s", opts.DomainName),
+		Path:          path,
 		Context:       ctx,
 		IsIdempotent:  true,
-		RetryStrategy: retryStrategy,
+		RetryStrategy: strategy,
 		UniqueId:      uuid.New().String(),
 	}
 
-	dspan := um.tracer.StartSpan("dispatch", span.Context())
-	resp, err := um.httpClient.DoHttpRequest(req)
+	dspan := um.tracer.StartSpan("dispatch", tracectx)
+	resp, err := doMgmtRequest(um.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
-			return nil, timeoutError{
+			return nil, "", timeoutError{
 				operationID:   req.UniqueId,
 				retryReasons:  req.RetryReasons(),
 				retryAttempts: req.RetryAttempts(),
@@ -213,35 +311,36 @@ func (um *UserManager) GetAllUsers(opts *GetAllUsersOptions) ([]UserAndMetadata,
 			}
 		}
 
-		return nil, err
+		return nil, "", err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return nil, userManagerError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return nil, "", makeUserManagerError(req, err)
 	}
 
-	var usersData []userMetadataJson
+	// Servers which don't support paging ignore pageSize and respond with all of the users as a plain array.
+	var raw json.RawMessage
+	var page userPageJson
 	jsonDec := json.NewDecoder(resp.Body)
-	err = jsonDec.Decode(&usersData)
+	err = jsonDec.Decode(&raw)
+	if err == nil {
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(raw, &page.Users)
+		} else {
+			err = json.Unmarshal(raw, &page)
+		}
+	}
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	var users []UserAndMetadata
-	for _, userData := range usersData {
-		user := transformUserMetadataJson(&userData)
-		users = append(users, user)
+	err = resp.Body.Close()
+	if err != nil {
+		logDebugf("Failed to close socket (%s)", err)
 	}
 
-	return users, nil
+	return page.Users, page.Links.Next, nil
 }
 
 // GetUserOptions is the set of options available to the user manager Get operation.
@@ -274,7 +373,7 @@ func (um *UserManager) GetUser(name string, opts *GetUserOptions) (*UserAndMetad
 	}
 
 	retryStrategy := um.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -289,7 +388,7 @@ func (um *UserManager) GetUser(name string, opts *GetUserOptions) (*UserAndMetad
 	}
 
 	dspan := um.tracer.StartSpan("dispatch", span.Context())
-	resp, err := um.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(um.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -305,16 +404,9 @@ func (um *UserManager) GetUser(name string, opts *GetUserOptions) (*UserAndMetad
 		return nil, err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return nil, userManagerError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return nil, makeUserManagerError(req, err)
 	}
 
 	var userData userMetadataJson
@@ -335,6 +427,10 @@ type UpsertUserOptions struct {
 	RetryStrategy RetryStrategy
 
 	DomainName string
+
+	// DryRun validates the roles of the user against those supported by the cluster, without upserting the user.
+	// If any roles are unknown, or are not applied to a bucket correctly, then an InvalidRolesError is returned.
+	DryRun bool
 }
 
 // UpsertUser updates a built-in RBAC user on the cluster.
@@ -357,14 +453,30 @@ func (um *UserManager) UpsertUser(user User, opts *UpsertUserOptions) error {
 		defer cancel()
 	}
 
+	if opts.DryRun {
+		knownRoles, err := um.GetRoles(&GetRolesOptions{
+			Context:       ctx,
+			RetryStrategy: opts.RetryStrategy,
+		})
+		if err != nil {
+			return err
+		}
+
+		return validateUserRoles(user.Roles, knownRoles)
+	}
+
 	retryStrategy := um.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
 	var reqRoleStrs []string
 	for _, roleData := range user.Roles {
-		reqRoleStrs = append(reqRoleStrs, fmt.Sprintf("%s[%s]", roleData.Name, roleData.Bucket))
+		if roleData.Bucket == "" {
+			reqRoleStrs = append(reqRoleStrs, roleData.Name)
+		} else {
+			reqRoleStrs = append(reqRoleStrs, fmt.Sprintf("%s[%s]", roleData.Name, roleData.Bucket))
+		}
 	}
 
 	reqForm := make(url.Values)
@@ -389,7 +501,7 @@ func (um *UserManager) UpsertUser(user User, opts *UpsertUserOptions) error {
 	}
 
 	dspan := um.tracer.StartSpan("dispatch", span.Context())
-	resp, err := um.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(um.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -405,21 +517,44 @@ func (um *UserManager) UpsertUser(user User, opts *UpsertUserOptions) error {
 		return err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return userManagerError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return makeUserManagerError(req, err)
 	}
 
 	return nil
 }
 
+// validateUserRoles checks that each role is known to the cluster, and that bucket roles are applied to a bucket
+// whilst cluster roles are not.
+func validateUserRoles(roles []Role, knownRoles []RoleAndDescription) error {
+	invalid := invalidRoles(roles, knownRoles)
+	if len(invalid) > 0 {
+		return invalidRolesError{roles: invalid}
+	}
+
+	return nil
+}
+
+// invalidRoles returns those of roles which are unknown to the cluster, or which are bucket roles not applied to a
+// bucket or cluster roles applied to one, in the order that they were given.
+func invalidRoles(roles []Role, knownRoles []RoleAndDescription) []Role {
+	bucketRoles := make(map[string]bool)
+	for _, known := range knownRoles {
+		bucketRoles[known.Role.Name] = known.Role.Bucket != ""
+	}
+
+	var invalid []Role
+	for _, role := range roles {
+		isBucketRole, ok := bucketRoles[role.Name]
+		if !ok || isBucketRole != (role.Bucket != "") {
+			invalid = append(invalid, role)
+		}
+	}
+
+	return invalid
+}
+
 // DropUserOptions is the set of options available to the user manager Drop operation.
 type DropUserOptions struct {
 	Timeout       time.Duration
@@ -450,7 +585,7 @@ func (um *UserManager) DropUser(name string, opts *DropUserOptions) error {
 	}
 
 	retryStrategy := um.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -464,7 +599,7 @@ func (um *UserManager) DropUser(name string, opts *DropUserOptions) error {
 	}
 
 	dspan := um.tracer.StartSpan("dispatch", span.Context())
-	resp, err := um.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(um.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -480,16 +615,9 @@ func (um *UserManager) DropUser(name string, opts *DropUserOptions) error {
 		return err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return userManagerError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return makeUserManagerError(req, err)
 	}
 
 	return nil
@@ -519,7 +647,7 @@ func (um *UserManager) GetRoles(opts *GetRolesOptions) ([]RoleAndDescription, er
 	}
 
 	retryStrategy := um.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -534,7 +662,7 @@ func (um *UserManager) GetRoles(opts *GetRolesOptions) ([]RoleAndDescription, er
 	}
 
 	dspan := um.tracer.StartSpan("dispatch", span.Context())
-	resp, err := um.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(um.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -550,16 +678,9 @@ func (um *UserManager) GetRoles(opts *GetRolesOptions) ([]RoleAndDescription, er
 		return nil, err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return nil, userManagerError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return nil, makeUserManagerError(req, err)
 	}
 
 	var roleDatas []roleDescriptionsJson
@@ -586,6 +707,26 @@ func (um *UserManager) GetRoles(opts *GetRolesOptions) ([]RoleAndDescription, er
 	return roles, nil
 }
 
+// ValidateRoles checks roles against those supported by the cluster, fetching the supported roles once for the
+// whole set. The roles which are unknown, or are not applied to a bucket correctly, are returned together so that
+// a set of roles can be validated up front, if every role is valid then no roles are returned.
+func (um *UserManager) ValidateRoles(roles []Role, opts *GetRolesOptions) ([]Role, error) {
+	if opts == nil {
+		opts = &GetRolesOptions{}
+	}
+
+	span := um.tracer.StartSpan("ValidateRoles", nil).
+		SetTag("couchbase.service", "mgmt")
+	defer span.Finish()
+
+	knownRoles, err := um.GetRoles(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return invalidRoles(roles, knownRoles), nil
+}
+
 // GetGroupOptions is the set of options available to the group manager Get operation.
 type GetGroupOptions struct {
 	Timeout       time.Duration
@@ -613,7 +754,7 @@ func (um *UserManager) GetGroup(groupName string, opts *GetGroupOptions) (*Group
 	}
 
 	retryStrategy := um.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -628,7 +769,7 @@ func (um *UserManager) GetGroup(groupName string, opts *GetGroupOptions) (*Group
 	}
 
 	dspan := um.tracer.StartSpan("dispatch", span.Context())
-	resp, err := um.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(um.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -644,16 +785,9 @@ func (um *UserManager) GetGroup(groupName string, opts *GetGroupOptions) (*Group
 		return nil, err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return nil, userManagerError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return nil, makeUserManagerError(req, err)
 	}
 
 	var group Group
@@ -666,11 +800,36 @@ func (um *UserManager) GetGroup(groupName string, opts *GetGroupOptions) (*Group
 	return &group, nil
 }
 
+// GroupExists verifies whether a group exists on the server, a group which cannot be found is reported as not
+// existing rather than as an error.
+func (um *UserManager) GroupExists(groupName string, opts *GetGroupOptions) (bool, error) {
+	_, err := um.GetGroup(groupName, opts)
+	if err == nil {
+		return true, nil
+	}
+
+	if IsGroupNotFoundError(err) {
+		return false, nil
+	}
+
+	// Any other 404 also means that the group doesn't exist, except for the generic not found which older servers
+	// respond with when groups are not supported at all. That is returned as an error.
+	if umErr, ok := errors.Cause(err).(userManagerError); ok && umErr.statusCode == 404 &&
+		!umErr.FeatureNotFoundError() {
+		return false, nil
+	}
+
+	return false, err
+}
+
 // GetAllGroupsOptions is the set of options available to the group manager GetAll operation.
 type GetAllGroupsOptions struct {
 	Timeout       time.Duration
 	Context       context.Context
 	RetryStrategy RetryStrategy
+	// NameContains only returns groups whose names contain the given substring, if empty then all groups are
+	// returned.
+	NameContains string
 }
 
 // GetAllGroups fetches all groups from the server.
@@ -690,7 +849,7 @@ func (um *UserManager) GetAllGroups(opts *GetAllGroupsOptions) ([]Group, error)
 	}
 
 	retryStrategy := um.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -705,7 +864,7 @@ func (um *UserManager) GetAllGroups(opts *GetAllGroupsOptions) ([]Group, error)
 	}
 
 	dspan := um.tracer.StartSpan("dispatch", span.Context())
-	resp, err := um.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(um.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -721,16 +880,9 @@ func (um *UserManager) GetAllGroups(opts *GetAllGroupsOptions) ([]Group, error)
 		return nil, err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return nil, userManagerError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return nil, makeUserManagerError(req, err)
 	}
 
 	var groups []Group
@@ -740,7 +892,18 @@ func (um *UserManager) GetAllGroups(opts *GetAllGroupsOptions) ([]Group, error)
 		return nil, err
 	}
 
-	return groups, nil
+	if opts.NameContains == "" {
+		return groups, nil
+	}
+
+	var filtered []Group
+	for _, group := range groups {
+		if strings.Contains(group.Name, opts.NameContains) {
+			filtered = append(filtered, group)
+		}
+	}
+
+	return filtered, nil
 }
 
 // UpsertGroupOptions is the set of options available to the group manager Upsert operation.
@@ -770,7 +933,7 @@ func (um *UserManager) UpsertGroup(group Group, opts *UpsertGroupOptions) error
 	}
 
 	retryStrategy := um.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -800,7 +963,7 @@ func (um *UserManager) UpsertGroup(group Group, opts *UpsertGroupOptions) error
 	}
 
 	dspan := um.tracer.StartSpan("dispatch", span.Context())
-	resp, err := um.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(um.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -816,16 +979,9 @@ func (um *UserManager) UpsertGroup(group Group, opts *UpsertGroupOptions) error
 		return err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return userManagerError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return makeUserManagerError(req, err)
 	}
 
 	return nil
@@ -859,7 +1015,7 @@ func (um *UserManager) DropGroup(groupName string, opts *DropGroupOptions) error
 	}
 
 	retryStrategy := um.defaultRetryStrategy
-	if opts.RetryStrategy == nil {
+	if opts.RetryStrategy != nil {
 		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
@@ -873,7 +1029,7 @@ func (um *UserManager) DropGroup(groupName string, opts *DropGroupOptions) error
 	}
 
 	dspan := um.tracer.StartSpan("dispatch", span.Context())
-	resp, err := um.httpClient.DoHttpRequest(req)
+	resp, err := doMgmtRequest(um.httpClient, req)
 	dspan.Finish()
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -889,16 +1045,9 @@ func (um *UserManager) DropGroup(groupName string, opts *DropGroupOptions) error
 		return err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		err = resp.Body.Close()
-		if err != nil {
-			logDebugf("Failed to close socket (%s)", err)
-		}
-		return userManagerError{statusCode: resp.StatusCode, message: string(data)}
+	err = decodeMgmtError(resp)
+	if err != nil {
+		return makeUserManagerError(req, err)
 	}
 
 	return nil
@@ -1,7 +1,14 @@
 package gocb
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
 	"testing"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
 )
 
 func TestUserManagerGroupCrud(t *testing.T) {
@@ -321,6 +328,318 @@ func TestUserManagerAvailableRoles(t *testing.T) {
 	}
 }
 
+// testUserManagerForRoles returns a manager for a cluster supporting the admin cluster role and the data reader and
+// writer bucket roles, only the roles may be fetched.
+func testUserManagerForRoles(t *testing.T) *UserManager {
+	rolesData := []byte(`[
+		{"role":"admin","name":"Full Admin","desc":"Can manage all cluster features."},
+		{"role":"data_reader","bucket_name":"*","name":"Data Reader","desc":"Can read data."},
+		{"role":"data_writer","bucket_name":"*","name":"Data Writer","desc":"Can write data."}
+	]`)
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Method != "GET" || req.Path != "/settings/rbac/roles" {
+			t.Fatalf("Expected only roles to be fetched but request was %s %s", req.Method, req.Path)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(rolesData), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	return &UserManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+}
+
+func testUserManagerDryRun(t *testing.T, user User) error {
+	return testUserManagerForRoles(t).UpsertUser(user, &UpsertUserOptions{
+		DryRun: true,
+	})
+}
+
+func TestUserManagerUpsertUserDryRun(t *testing.T) {
+	err := testUserManagerDryRun(t, User{
+		Username: "barry",
+		Roles: []Role{
+			{Name: "admin"},
+			{Name: "data_reader", Bucket: "default"},
+			{Name: "data_writer", Bucket: "*"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected dry run to succeed but was %v", err)
+	}
+}
+
+func TestUserManagerUpsertUserDryRunInvalidRoles(t *testing.T) {
+	err := testUserManagerDryRun(t, User{
+		Username: "barry",
+		Roles: []Role{
+			{Name: "data_reders", Bucket: "default"},
+			{Name: "data_writer", Bucket: "default"},
+			{Name: "data_reader"},
+			{Name: "admin", Bucket: "default"},
+		},
+	})
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+
+	rolesErr, ok := err.(InvalidRolesError)
+	if !ok {
+		t.Fatalf("Expected error to be InvalidRolesError but was %v", err)
+	}
+
+	expected := []Role{
+		{Name: "data_reders", Bucket: "default"},
+		{Name: "data_reader"},
+		{Name: "admin", Bucket: "default"},
+	}
+	invalid := rolesErr.InvalidRoles()
+	if len(invalid) != len(expected) {
+		t.Fatalf("Expected invalid roles to be %v but was %v", expected, invalid)
+	}
+	for i, role := range expected {
+		if invalid[i] != role {
+			t.Fatalf("Expected invalid roles to be %v but was %v", expected, invalid)
+		}
+	}
+}
+
+func TestUserManagerValidateRoles(t *testing.T) {
+	invalid, err := testUserManagerForRoles(t).ValidateRoles([]Role{
+		{Name: "admin"},
+		{Name: "data_reader", Bucket: "default"},
+		{Name: "data_writer", Bucket: "*"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected ValidateRoles to succeed but was %v", err)
+	}
+
+	if len(invalid) != 0 {
+		t.Fatalf("Expected no invalid roles but was %v", invalid)
+	}
+}
+
+func TestUserManagerValidateRolesInvalid(t *testing.T) {
+	invalid, err := testUserManagerForRoles(t).ValidateRoles([]Role{
+		{Name: "data_reders", Bucket: "default"},
+		{Name: "data_writer", Bucket: "default"},
+		{Name: "data_reader"},
+		{Name: "admin", Bucket: "default"},
+		{Name: "admin"},
+		{Name: "bucket_full_access", Bucket: "default"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected ValidateRoles to succeed but was %v", err)
+	}
+
+	expected := []Role{
+		{Name: "data_reders", Bucket: "default"},
+		{Name: "data_reader"},
+		{Name: "admin", Bucket: "default"},
+		{Name: "bucket_full_access", Bucket: "default"},
+	}
+	if len(invalid) != len(expected) {
+		t.Fatalf("Expected invalid roles to be %v but was %v", expected, invalid)
+	}
+	for i, role := range expected {
+		if invalid[i] != role {
+			t.Fatalf("Expected invalid roles to be %v but was %v", expected, invalid)
+		}
+	}
+}
+
+func TestUserManagerUpsertUserRoleEncoding(t *testing.T) {
+	var body []byte
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Method != "PUT" || req.Path != "/settings/rbac/users/local/barry" {
+			t.Fatalf("Unexpected request %s %s", req.Method, req.Path)
+		}
+
+		body = req.Body
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(""), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	mgr := &UserManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+
+	err := mgr.UpsertUser(User{
+		Username: "barry",
+		Roles: []Role{
+			{Name: "data_reader", Bucket: "default"},
+			{Name: "admin"},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected UpsertUser to succeed but was %v", err)
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Fatalf("Failed to parse request body: %v", err)
+	}
+
+	if form.Get("roles") != "data_reader[default],admin" {
+		t.Fatalf("Expected roles to be data_reader[default],admin but was %s", form.Get("roles"))
+	}
+}
+
+func testUserManagerPaged(t *testing.T) *UserManager {
+	pages := map[string]string{
+		"/settings/rbac/users/local?pageSize=2": `{"total":3,"links":{"next":"/settings/rbac/users/local?pageSize=2&startFrom=carol&startFromDomain=local"},
+			"users":[{"id":"alice","domain":"local","roles":[]},{"id":"bob","domain":"local","roles":[]}]}`,
+		"/settings/rbac/users/local?pageSize=2&startFrom=carol&startFromDomain=local": `{"total":3,"links":{},
+			"users":[{"id":"carol","domain":"local","roles":[]}]}`,
+	}
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		page, ok := pages[req.Path]
+		if !ok {
+			t.Fatalf("Unexpected request for %s", req.Path)
+		}
+
+		if req.Context.Err() != nil {
+			return nil, req.Context.Err()
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(page), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	return &UserManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+}
+
+func TestUserManagerGetAllUsersPaged(t *testing.T) {
+	mgr := testUserManagerPaged(t)
+
+	users, err := mgr.GetAllUsers(&GetAllUsersOptions{
+		PageSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("Expected GetAllUsers to succeed but was %v", err)
+	}
+
+	expected := []string{"alice", "bob", "carol"}
+	if len(users) != len(expected) {
+		t.Fatalf("Expected %d users but was %d", len(expected), len(users))
+	}
+	for i, name := range expected {
+		if users[i].User.Username != name {
+			t.Fatalf("Expected user %d to be %s but was %s", i, name, users[i].User.Username)
+		}
+	}
+}
+
+func TestUserManagerForEachUser(t *testing.T) {
+	mgr := testUserManagerPaged(t)
+
+	var names []string
+	err := mgr.ForEachUser(func(user UserAndMetadata) error {
+		names = append(names, user.User.Username)
+		return nil
+	}, &GetAllUsersOptions{
+		PageSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("Expected ForEachUser to succeed but was %v", err)
+	}
+
+	if len(names) != 3 {
+		t.Fatalf("Expected 3 users but was %v", names)
+	}
+
+	stopErr := errors.New("stop")
+	names = nil
+	err = mgr.ForEachUser(func(user UserAndMetadata) error {
+		names = append(names, user.User.Username)
+		return stopErr
+	}, &GetAllUsersOptions{
+		PageSize: 2,
+	})
+	if err != stopErr {
+		t.Fatalf("Expected ForEachUser to return the callback error but was %v", err)
+	}
+
+	if len(names) != 1 {
+		t.Fatalf("Expected iteration to stop after 1 user but was %v", names)
+	}
+}
+
+func TestUserManagerForEachUserTimeoutPerPage(t *testing.T) {
+	mgr := testUserManagerPaged(t)
+
+	// Time spent handling each user must not count against the timeout for fetching the next page.
+	var names []string
+	err := mgr.ForEachUser(func(user UserAndMetadata) error {
+		names = append(names, user.User.Username)
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}, &GetAllUsersOptions{
+		PageSize: 2,
+		Timeout:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Expected ForEachUser to succeed but was %v", err)
+	}
+
+	if len(names) != 3 {
+		t.Fatalf("Expected 3 users but was %v", names)
+	}
+}
+
+func TestUserManagerGetAllUsersDefaultPageSize(t *testing.T) {
+	var paths []string
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		paths = append(paths, req.Path)
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body: &testReadCloser{bytes.NewBufferString(`{"total":1,"links":{},
+				"users":[{"id":"alice","domain":"local","roles":[]}]}`), nil},
+		}, nil
+	}
+
+	mgr := &UserManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	users, err := mgr.GetAllUsers(nil)
+	if err != nil {
+		t.Fatalf("Expected GetAllUsers to succeed but was %v", err)
+	}
+
+	if len(users) != 1 || users[0].User.Username != "alice" {
+		t.Fatalf("Expected alice to be returned but was %v", users)
+	}
+
+	if len(paths) != 1 || paths[0] != "/settings/rbac/users/local?pageSize=100" {
+		t.Fatalf("Expected a single request for a page of 100 users but was %v", paths)
+	}
+}
+
 func assertUser(t *testing.T, user *UserAndMetadata, expected *UserAndMetadata) {
 	if user.User.Username != expected.User.Username {
 		t.Fatalf("Expected user Username to be %s but was %s", expected.User.Username, user.User.Username)
@@ -356,3 +675,262 @@ func assertUser(t *testing.T, user *UserAndMetadata, expected *UserAndMetadata)
 		t.Fatalf("Expected user EffectiveRolesAndOrigins to be length %v but was %v", expected.EffectiveRolesAndOrigins, user.EffectiveRolesAndOrigins)
 	}
 }
+
+func TestUserManagerUsersWithStalePasswords(t *testing.T) {
+	now := time.Now()
+	changed := func(age time.Duration) string {
+		return now.Add(-age).UTC().Format(time.RFC3339)
+	}
+
+	body := fmt.Sprintf(`[
+		{"id":"fresh","domain":"local","roles":[],"password_change_date":"%s"},
+		{"id":"stale","domain":"local","roles":[],"password_change_date":"%s"},
+		{"id":"ancient","domain":"local","roles":[],"password_change_date":"%s"},
+		{"id":"ldapuser","domain":"external","roles":[]}
+	]`, changed(24*time.Hour), changed(100*24*time.Hour), changed(400*24*time.Hour))
+
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Path != "/settings/rbac/users/local?pageSize=100" {
+			t.Fatalf("Expected path to be /settings/rbac/users/local?pageSize=100 but was %s", req.Path)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(body), nil},
+		}, nil
+	}
+
+	mgr := &UserManager{
+		httpClient:    &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	users, err := mgr.UsersWithStalePasswords(90*24*time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Expected UsersWithStalePasswords to succeed but was %v", err)
+	}
+
+	expected := []string{"stale", "ancient"}
+	if len(users) != len(expected) {
+		t.Fatalf("Expected %d users but was %d", len(expected), len(users))
+	}
+	for i, name := range expected {
+		if users[i].User.Username != name {
+			t.Fatalf("Expected user %d to be %s but was %s", i, name, users[i].User.Username)
+		}
+	}
+
+	users, err = mgr.UsersWithStalePasswords(500*24*time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Expected UsersWithStalePasswords to succeed but was %v", err)
+	}
+
+	if len(users) != 0 {
+		t.Fatalf("Expected no users to have stale passwords but was %d", len(users))
+	}
+}
+
+func testUserManagerForResponse(t *testing.T, expectedPath string, statusCode int, body string) *UserManager {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Method != "GET" || req.Path != expectedPath {
+			t.Fatalf("Expected request to be GET %s but was %s %s", expectedPath, req.Method, req.Path)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: statusCode,
+			Body:       &testReadCloser{bytes.NewBufferString(body), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	return &UserManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+}
+
+func TestUserManagerGroupExists(t *testing.T) {
+	type tCase struct {
+		name       string
+		statusCode int
+		body       string
+		exists     bool
+		expectErr  bool
+	}
+
+	testCases := []tCase{
+		{
+			name:       "exists",
+			statusCode: 200,
+			body:       `{"id":"admins","description":"","roles":[{"role":"admin"}],"ldap_group_ref":""}`,
+			exists:     true,
+		},
+		{
+			name:       "absent",
+			statusCode: 404,
+			body:       `"Unknown group."`,
+			exists:     false,
+		},
+		{
+			name:       "groups unsupported",
+			statusCode: 404,
+			body:       `Not Found.`,
+			expectErr:  true,
+		},
+		{
+			name:       "server error",
+			statusCode: 500,
+			body:       `Unexpected server error`,
+			expectErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mgr := testUserManagerForResponse(t, "/settings/rbac/groups/admins", tc.statusCode, tc.body)
+
+			exists, err := mgr.GroupExists("admins", nil)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("Expected GroupExists to error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected GroupExists to succeed but was %v", err)
+			}
+
+			if exists != tc.exists {
+				t.Fatalf("Expected exists to be %t but was %t", tc.exists, exists)
+			}
+		})
+	}
+}
+
+func TestUserManagerGetAllGroupsNameContains(t *testing.T) {
+	body := `[
+		{"id":"app-readers","description":"","roles":[],"ldap_group_ref":""},
+		{"id":"admins","description":"","roles":[],"ldap_group_ref":""},
+		{"id":"app-writers","description":"","roles":[],"ldap_group_ref":""}
+	]`
+
+	mgr := testUserManagerForResponse(t, "/settings/rbac/groups", 200, body)
+
+	groups, err := mgr.GetAllGroups(&GetAllGroupsOptions{NameContains: "app-"})
+	if err != nil {
+		t.Fatalf("Expected GetAllGroups to succeed but was %v", err)
+	}
+
+	if len(groups) != 2 || groups[0].Name != "app-readers" || groups[1].Name != "app-writers" {
+		t.Fatalf("Expected app-readers and app-writers to be returned but was %v", groups)
+	}
+
+	groups, err = mgr.GetAllGroups(nil)
+	if err != nil {
+		t.Fatalf("Expected GetAllGroups to succeed but was %v", err)
+	}
+
+	if len(groups) != 3 {
+		t.Fatalf("Expected all 3 groups to be returned but was %d", len(groups))
+	}
+}
+
+func testUserManagerUpsertRejected(t *testing.T, body []byte) error {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 400,
+			Body:       &testReadCloser{bytes.NewBuffer(body), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	mgr := &UserManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+
+	err := mgr.UpsertUser(User{
+		Username: "barry",
+		Password: "weak",
+		Roles:    []Role{{Name: "admin"}},
+	}, nil)
+	if err == nil {
+		t.Fatalf("Expected UpsertUser to fail")
+	}
+
+	return err
+}
+
+func TestUserManagerUpsertUserPasswordPolicy(t *testing.T) {
+	body, err := loadRawTestDataset("user_password_policy_error")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	err = testUserManagerUpsertRejected(t, body)
+	if !IsPasswordPolicyError(err) {
+		t.Fatalf("Expected error to be a password policy error but was %v", err)
+	}
+
+	if IsUserNotFoundError(err) {
+		t.Fatalf("Expected error not to be user not found")
+	}
+}
+
+func TestUserManagerUpsertUserOtherValidationError(t *testing.T) {
+	err := testUserManagerUpsertRejected(t, []byte(`{"errors":{"roles":"Cannot assign roles to user because the following roles are unknown, malformed or role parameters are undefined: [bogus]"}}`))
+	if IsPasswordPolicyError(err) {
+		t.Fatalf("Expected error not to be a password policy error but was %v", err)
+	}
+
+	err = testUserManagerUpsertRejected(t, []byte("Bad Request"))
+	if IsPasswordPolicyError(err) {
+		t.Fatalf("Expected error not to be a password policy error but was %v", err)
+	}
+}
+
+func TestUserManagerDropUserDoesNotRetry(t *testing.T) {
+	var attempts int
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		attempts++
+		if req.Method != "DELETE" {
+			t.Fatalf("Expected request to be DELETE but was %s", req.Method)
+		}
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 503,
+			Body:       &testReadCloser{bytes.NewBufferString("request failed"), nil},
+		}, nil
+	}
+
+	mgr := &UserManager{
+		httpClient:           &mockHTTPProvider{doFn: doHTTP},
+		globalTimeout:        10 * time.Second,
+		defaultRetryStrategy: newRetryStrategyWrapper(NewBestEffortRetryStrategy(nil)),
+		tracer:               &noopTracer{},
+	}
+
+	err := mgr.DropUser("barry", nil)
+	if err == nil {
+		t.Fatalf("Expected DropUser to fail")
+	}
+
+	if attempts != 1 {
+		t.Fatalf("Expected 1 attempt but was %d", attempts)
+	}
+
+	if IsRetryableError(err) {
+		t.Fatalf("Expected error not to be retryable")
+	}
+
+	uErr, ok := err.(UserManagerError)
+	if !ok || uErr.HTTPStatus() != 503 {
+		t.Fatalf("Expected a user manager error with status 503 but was %v", err)
+	}
+}
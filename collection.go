@@ -52,6 +52,14 @@ func (c *Collection) Name() string {
 	return c.sb.CollectionName
 }
 
+// Query executes the N1QL query statement against the scope containing the collection, so the collection can be
+// referred to in the statement by its name alone, e.g. SELECT * FROM `airline`. The query context cannot be narrower
+// than a scope so the collection must still be named in the statement. Any QueryContext set in opts is ignored.
+// Volatile: This API is subject to change at any time.
+func (c *Collection) Query(statement string, opts *QueryOptions) (*QueryResult, error) {
+	return scopeQuery(&c.sb, statement, opts)
+}
+
 func (c *Collection) startKvOpTrace(operationName string, tracectx requestSpanContext) requestSpan {
 	if tracectx == nil {
 		return c.sb.Tracer.StartSpan(operationName, nil).
@@ -65,3 +73,12 @@ func (c *Collection) startKvOpTrace(operationName string, tracectx requestSpanCo
 		SetTag("couchbase.collection", c.sb.CollectionName).
 		SetTag("couchbase.service", "kv")
 }
+
+// kvOpLogFields returns the fields attached to log messages about a key value operation.
+func (c *Collection) kvOpLogFields(operationName string) LogFields {
+	return LogFields{
+		LogFieldOperation: operationName,
+		LogFieldService:   "kv",
+		LogFieldBucket:    c.sb.BucketName,
+	}
+}
@@ -253,7 +253,7 @@ type CounterOptions struct {
 
 // Increment performs an atomic addition for an integer document. Passing a
 // non-negative `initial` value will cause the document to be created if it did not
-// already exist.
+// already exist, otherwise a key not found error is returned.
 func (c *BinaryCollection) Increment(id string, opts *CounterOptions) (countOut *CounterResult, errOut error) {
 	startTime := time.Now()
 	if opts == nil {
@@ -269,6 +269,11 @@ func (c *BinaryCollection) Increment(id string, opts *CounterOptions) (countOut
 		defer cancel()
 	}
 
+	err := c.collection.verifyObserveOptions(opts.PersistTo, opts.ReplicateTo, opts.DurabilityLevel)
+	if err != nil {
+		return nil, err
+	}
+
 	res, err := c.increment(ctx, span.Context(), id, startTime, *opts)
 	if err != nil {
 		return nil, err
@@ -329,7 +334,7 @@ func (c *BinaryCollection) increment(ctx context.Context, tracectx requestSpanCo
 		TraceContext:           tracectx,
 	}, func(res *gocbcore.CounterResult, err error) {
 		if err != nil {
-			errOut = err
+			errOut = maybeEnhanceKVErr(err, id, false)
 			ctrl.resolve()
 			return
 		}
@@ -362,7 +367,8 @@ func (c *BinaryCollection) increment(ctx context.Context, tracectx requestSpanCo
 
 // Decrement performs an atomic subtraction for an integer document. Passing a
 // non-negative `initial` value will cause the document to be created if it did not
-// already exist.
+// already exist, otherwise a key not found error is returned. The server will not
+// decrement the value below zero.
 func (c *BinaryCollection) Decrement(id string, opts *CounterOptions) (countOut *CounterResult, errOut error) {
 	startTime := time.Now()
 	if opts == nil {
@@ -443,7 +449,7 @@ func (c *BinaryCollection) decrement(ctx context.Context, tracectx requestSpanCo
 		TraceContext:           tracectx,
 	}, func(res *gocbcore.CounterResult, err error) {
 		if err != nil {
-			errOut = err
+			errOut = maybeEnhanceKVErr(err, id, false)
 			ctrl.resolve()
 			return
 		}
@@ -220,3 +220,88 @@ func TestBinaryDecrement(t *testing.T) {
 		t.Fatalf("Expected counter value to be 80 but was %d", res.Content())
 	}
 }
+
+func TestBinaryIncrementMockCreatesWithInitial(t *testing.T) {
+	provider := testStoreProvider(nil)
+	col := testGetCollection(t, provider)
+
+	res, err := col.Binary().Increment("counter", &CounterOptions{
+		Initial: 10,
+		Delta:   5,
+		Expiry:  60,
+	})
+	if err != nil {
+		t.Fatalf("Expected Increment to succeed but was %v", err)
+	}
+
+	if res.Content() != 10 {
+		t.Fatalf("Expected counter to be created with initial value 10 but was %d", res.Content())
+	}
+
+	if res.Cas() != 1 {
+		t.Fatalf("Expected cas to be 1 but was %d", res.Cas())
+	}
+
+	opts := provider.counters[0]
+	if opts.Initial != 10 || opts.Delta != 5 || opts.Expiry != 60 {
+		t.Fatalf("Expected initial 10, delta 5 and expiry 60 to be sent but was %v", opts)
+	}
+
+	if doc := provider.store.docs["counter"]; string(doc.value) != "10" || doc.expiry != 60 {
+		t.Fatalf("Expected counter to be stored as 10 with expiry 60 but was %s with %d", doc.value, doc.expiry)
+	}
+}
+
+func TestBinaryIncrementMockExisting(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"counter": {cas: 1, value: []byte("10")}})
+	col := testGetCollection(t, provider)
+
+	res, err := col.Binary().Increment("counter", &CounterOptions{
+		Initial: 100,
+		Delta:   5,
+	})
+	if err != nil {
+		t.Fatalf("Expected Increment to succeed but was %v", err)
+	}
+
+	if res.Content() != 15 {
+		t.Fatalf("Expected counter to be 15 but was %d", res.Content())
+	}
+}
+
+func TestBinaryDecrementMockClampsAtZero(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"counter": {cas: 1, value: []byte("3")}})
+	col := testGetCollection(t, provider)
+
+	res, err := col.Binary().Decrement("counter", &CounterOptions{
+		Delta: 5,
+	})
+	if err != nil {
+		t.Fatalf("Expected Decrement to succeed but was %v", err)
+	}
+
+	if res.Content() != 0 {
+		t.Fatalf("Expected counter to be clamped to 0 but was %d", res.Content())
+	}
+}
+
+func TestBinaryCounterMockNoInitial(t *testing.T) {
+	provider := testStoreProvider(nil)
+	col := testGetCollection(t, provider)
+
+	_, err := col.Binary().Increment("counter", &CounterOptions{
+		Initial: -1,
+		Delta:   1,
+	})
+	if !IsKeyNotFoundError(err) {
+		t.Fatalf("Expected Increment error to be key not found but was %v", err)
+	}
+
+	_, err = col.Binary().Decrement("counter", &CounterOptions{
+		Initial: -1,
+		Delta:   1,
+	})
+	if !IsKeyNotFoundError(err) {
+		t.Fatalf("Expected Decrement error to be key not found but was %v", err)
+	}
+}
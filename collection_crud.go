@@ -3,9 +3,13 @@ package gocb
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	gocbcore "github.com/couchbase/gocbcore/v8"
+	"github.com/pkg/errors"
 )
 
 type kvProvider interface {
@@ -537,6 +541,33 @@ func (c *Collection) Get(id string, opts *GetOptions) (docOut *GetResult, errOut
 	span := c.startKvOpTrace("Get", nil)
 	defer span.Finish()
 
+	return c.getWithProjections(span.Context(), id, startTime, opts, false)
+}
+
+// GetWithProjections fetches only the given fields of a document, each of which is a subdocument path such as
+// "address.city" or "tags[0]", and returns a GetResult whose content is a partial document holding just those
+// fields at their original paths. Fields which do not exist in the document are left out of the result rather
+// than failing the operation. Up to 16 fields are fetched using a single subdocument lookup, for more fields than
+// that the whole document is fetched and the fields are extracted from it. If fields is empty then this is the
+// same as a full document Get. Any Project set in opts is ignored.
+func (c *Collection) GetWithProjections(id string, fields []string, opts *GetOptions) (docOut *GetResult, errOut error) {
+	startTime := time.Now()
+	var getOpts GetOptions
+	if opts != nil {
+		getOpts = *opts
+	}
+	getOpts.Project = fields
+
+	span := c.startKvOpTrace("GetWithProjections", nil)
+	defer span.Finish()
+
+	return c.getWithProjections(span.Context(), id, startTime, &getOpts, true)
+}
+
+// getWithProjections performs a Get, when skipMissing is set any projected paths missing from the document are
+// left out of the result rather than causing an error.
+func (c *Collection) getWithProjections(tracectx requestSpanContext, id string, startTime time.Time,
+	opts *GetOptions, skipMissing bool) (docOut *GetResult, errOut error) {
 	ctx, cancel := c.context(opts.Context, opts.Timeout)
 	if cancel != nil {
 		defer cancel()
@@ -549,7 +580,7 @@ func (c *Collection) Get(id string, opts *GetOptions) (docOut *GetResult, errOut
 	projections := opts.Project
 	if len(projections) == 0 && !opts.WithExpiry {
 		// Standard fulldoc
-		doc, err := c.get(ctx, span.Context(), id, startTime, opts)
+		doc, err := c.get(ctx, tracectx, id, startTime, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -569,7 +600,7 @@ func (c *Collection) Get(id string, opts *GetOptions) (docOut *GetResult, errOut
 	lookupOpts := &LookupInOptions{Context: ctx}
 
 	if opts.WithExpiry {
-		ops = append(ops, GetSpec("$document.exptime", &GetSpecOptions{IsXattr: true}))
+		ops = append(ops, GetSpec(expiryXattrPath, &GetSpecOptions{IsXattr: true}))
 	}
 
 	if len(projections) == 0 {
@@ -580,7 +611,7 @@ func (c *Collection) Get(id string, opts *GetOptions) (docOut *GetResult, errOut
 		}
 	}
 
-	result, err := c.lookupIn(ctx, span.Context(), id, ops, startTime, *lookupOpts)
+	result, err := c.lookupIn(ctx, tracectx, id, ops, startTime, *lookupOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -599,12 +630,12 @@ func (c *Collection) Get(id string, opts *GetOptions) (docOut *GetResult, errOut
 	doc.transcoder = opts.Transcoder
 	doc.cas = result.cas
 	if projections == nil {
-		err = doc.fromFullProjection(ops, result, opts.Project)
+		err = doc.fromFullProjection(ops, result, opts.Project, skipMissing)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		err = doc.fromSubDoc(ops, result)
+		err = doc.fromSubDoc(ops, result, skipMissing)
 		if err != nil {
 			return nil, err
 		}
@@ -663,6 +694,134 @@ func (c *Collection) get(ctx context.Context, tracectx requestSpanContext, id st
 	return
 }
 
+// GetWithChecksumOptions are the options available to a GetWithChecksum operation.
+type GetWithChecksumOptions struct {
+	Timeout       time.Duration
+	Context       context.Context
+	Transcoder    Transcoder
+	RetryStrategy RetryStrategy
+}
+
+// GetWithChecksum fetches the full document along with the CRC32c checksum of its value, as computed by the
+// server, allowing callers to verify that the content was not corrupted in transit.
+func (c *Collection) GetWithChecksum(id string, opts *GetWithChecksumOptions) (docOut *GetResult, checksumOut uint32,
+	errOut error) {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &GetWithChecksumOptions{}
+	}
+
+	span := c.startKvOpTrace("GetWithChecksum", nil)
+	defer span.Finish()
+
+	ctx, cancel := c.context(opts.Context, opts.Timeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	transcoder := opts.Transcoder
+	if transcoder == nil {
+		transcoder = c.sb.Transcoder
+	}
+
+	ops := GetDocAndXattrsSpec([]string{"$document.value_crc32c"})
+	result, err := c.lookupIn(ctx, span.Context(), id, ops, startTime, LookupInOptions{
+		Context:       ctx,
+		RetryStrategy: opts.RetryStrategy,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if result.contents[0].err != nil {
+		return nil, 0, result.contents[0].err
+	}
+
+	var checksumStr string
+	err = result.ContentAt(1, &checksumStr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	checksum, err := strconv.ParseUint(strings.TrimPrefix(checksumStr, "0x"), 16, 32)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not parse value checksum %s", checksumStr)
+	}
+
+	doc := &GetResult{
+		Result: Result{
+			cas: result.cas,
+		},
+		transcoder: transcoder,
+		contents:   result.contents[0].data,
+	}
+
+	return doc, uint32(checksum), nil
+}
+
+// GetMetaOptions are the options available to a GetMeta operation.
+type GetMetaOptions struct {
+	Timeout       time.Duration
+	Context       context.Context
+	RetryStrategy RetryStrategy
+}
+
+// getMetaXattrPaths are the $document virtual extended attribute fields fetched by GetMeta, in the order that
+// they are requested. The CAS is taken from the response itself rather than the hex encoded $document.CAS.
+var getMetaXattrPaths = []string{
+	"$document.exptime",
+	"$document.flags",
+	"$document.datatype",
+	"$document.deleted",
+}
+
+// GetMeta fetches the metadata of a document, its cas, expiry, flags and datatype, without fetching its body. The
+// metadata of a deleted document is also fetched while its tombstone remains, see MetaResult.Deleted.
+func (c *Collection) GetMeta(id string, opts *GetMetaOptions) (docOut *MetaResult, errOut error) {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &GetMetaOptions{}
+	}
+
+	span := c.startKvOpTrace("GetMeta", nil)
+	defer span.Finish()
+
+	ctx, cancel := c.context(opts.Context, opts.Timeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	ops := make([]LookupInSpec, len(getMetaXattrPaths))
+	for i, path := range getMetaXattrPaths {
+		ops[i] = GetSpec(path, &GetSpecOptions{IsXattr: true})
+	}
+
+	result, err := c.lookupIn(ctx, span.Context(), id, ops, startTime, LookupInOptions{
+		Context:       ctx,
+		RetryStrategy: opts.RetryStrategy,
+		AccessDeleted: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &MetaResult{
+		Result: Result{
+			cas: result.cas,
+		},
+	}
+
+	fields := []interface{}{&meta.expiry, &meta.flags, &meta.datatype, &meta.deleted}
+	for i, field := range fields {
+		err = result.ContentAt(i, field)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not decode %s", getMetaXattrPaths[i])
+		}
+	}
+
+	return meta, nil
+}
+
 // ExistsOptions are the options available to the Exists command.
 type ExistsOptions struct {
 	Timeout       time.Duration
@@ -739,6 +898,84 @@ func (c *Collection) exists(ctx context.Context, tracectx requestSpanContext, id
 	return
 }
 
+// ExistsMultiOptions are the options available to the ExistsMulti command.
+type ExistsMultiOptions struct {
+	Timeout       time.Duration
+	Context       context.Context
+	RetryStrategy RetryStrategy
+
+	// Concurrency is the maximum number of existence checks which are performed at once, defaulting to 16.
+	Concurrency int
+}
+
+const defaultExistsMultiConcurrency = 16
+
+// ExistsMulti checks whether each of the given keys exist, without fetching their bodies. The existence checks are
+// performed several at a time and the timeout applies to the batch as a whole. If the check fails for any key then the
+// results for the remaining keys are still returned, along with an ExistsMultiError holding the error for each key
+// which failed.
+func (c *Collection) ExistsMulti(keys []string, opts *ExistsMultiOptions) (map[string]bool, error) {
+	startTime := time.Now()
+	if opts == nil {
+		opts = &ExistsMultiOptions{}
+	}
+
+	span := c.startKvOpTrace("ExistsMulti", nil)
+	defer span.Finish()
+
+	ctx, cancel := c.context(opts.Context, opts.Timeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	existsOpts := ExistsOptions{
+		RetryStrategy: opts.RetryStrategy,
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultExistsMultiConcurrency
+	}
+	if concurrency > len(keys) {
+		concurrency = len(keys)
+	}
+
+	keyCh := make(chan string)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]bool, len(keys))
+	errs := make(map[string]error)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for key := range keyCh {
+				res, err := c.exists(ctx, span.Context(), key, startTime, existsOpts)
+
+				lock.Lock()
+				if err != nil {
+					errs[key] = err
+				} else {
+					results[key] = res != nil && res.Exists()
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, key := range keys {
+		keyCh <- key
+	}
+	close(keyCh)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return results, existsMultiError{errors: errs}
+	}
+
+	return results, nil
+}
+
 // GetAnyReplicaOptions are the options available to the GetAnyReplica command.
 type GetAnyReplicaOptions struct {
 	Timeout       time.Duration
@@ -883,9 +1120,13 @@ type RemoveOptions struct {
 	ReplicateTo     uint
 	DurabilityLevel DurabilityLevel
 	RetryStrategy   RetryStrategy
+	// DurabilityTimeout bounds how long to wait for PersistTo and ReplicateTo to be met once the document
+	// has been removed, independently of Timeout. If not set then the durability timeout of the cluster is used.
+	DurabilityTimeout time.Duration
 }
 
-// Remove removes a document from the collection.
+// Remove removes a document from the collection. If Cas is set then the document is only removed if its cas
+// still matches, otherwise a cas mismatch error is returned.
 func (c *Collection) Remove(id string, opts *RemoveOptions) (mutOut *MutationResult, errOut error) {
 	startTime := time.Now()
 	if opts == nil {
@@ -913,6 +1154,11 @@ func (c *Collection) Remove(id string, opts *RemoveOptions) (mutOut *MutationRes
 	if opts.PersistTo == 0 && opts.ReplicateTo == 0 {
 		return res, nil
 	}
+
+	if res.MutationToken() == nil {
+		return res, durabilityError{reason: "Remove did not return a mutation token to observe."}
+	}
+
 	return res, c.durability(durabilitySettings{
 		ctx:            opts.Context,
 		key:            id,
@@ -923,6 +1169,7 @@ func (c *Collection) Remove(id string, opts *RemoveOptions) (mutOut *MutationRes
 		forDelete:      true,
 		scopeName:      c.scopeName(),
 		collectionName: c.name(),
+		timeout:        c.observeTimeout(opts.DurabilityTimeout),
 	})
 }
 
@@ -1157,7 +1404,9 @@ type UnlockOptions struct {
 	RetryStrategy RetryStrategy
 }
 
-// Unlock unlocks a document which was locked with GetAndLock.
+// Unlock unlocks a document which was locked with GetAndLock, cas must be the CAS returned by GetAndLock. If cas is
+// stale, because the lock has already expired or the document has since changed, or the document is not locked then
+// an error satisfying IsCasMismatchError is returned.
 func (c *Collection) Unlock(id string, cas Cas, opts *UnlockOptions) (mutOut *MutationResult, errOut error) {
 	startTime := time.Now()
 	if opts == nil {
@@ -1187,10 +1436,14 @@ func (c *Collection) unlock(ctx context.Context, tracectx requestSpanContext, id
 		return nil, err
 	}
 
-	retryWrapper := c.sb.RetryStrategyWrapper
+	var strategy RetryStrategy
+	if c.sb.RetryStrategyWrapper != nil {
+		strategy = c.sb.RetryStrategyWrapper.wrapped
+	}
 	if opts.RetryStrategy != nil {
-		retryWrapper = newRetryStrategyWrapper(opts.RetryStrategy)
+		strategy = opts.RetryStrategy
 	}
+	retryWrapper := newRetryStrategyWrapper(&unlockRetryStrategy{wrapped: strategy})
 
 	ctrl := c.newOpManager(ctx, startTime, "Unlock")
 	err = ctrl.wait(agent.UnlockEx(gocbcore.UnlockOptions{
@@ -1203,6 +1456,10 @@ func (c *Collection) unlock(ctx context.Context, tracectx requestSpanContext, id
 	}, func(res *gocbcore.UnlockResult, err error) {
 		if err != nil {
 			errOut = maybeEnhanceKVErr(err, id, false)
+			if kvErr, ok := errOut.(kvError); ok && isUnlockCasMismatchStatus(kvErr.status) {
+				kvErr.status = gocbcore.StatusKeyExists
+				errOut = kvErr
+			}
 			ctrl.resolve()
 			return
 		}
@@ -1227,6 +1484,26 @@ func (c *Collection) unlock(ctx context.Context, tracectx requestSpanContext, id
 	return
 }
 
+// isUnlockCasMismatchStatus returns whether a status returned for an unlock indicates that the cas did not match the
+// lock, the server reports this, and unlocking a document which is not locked, as locked or a temporary failure.
+func isUnlockCasMismatchStatus(status gocbcore.StatusCode) bool {
+	return status == gocbcore.StatusLocked || status == gocbcore.StatusTmpFail
+}
+
+// unlockRetryStrategy prevents Unlock from being retried when the server responds with a status which indicates a
+// cas mismatch, retrying cannot help so the mismatch is reported straight away. Anything else is left to wrapped.
+type unlockRetryStrategy struct {
+	wrapped RetryStrategy
+}
+
+func (rs *unlockRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	if reason == KVLockedRetryReason || reason == KVTemporaryFailureRetryReason || rs.wrapped == nil {
+		return &NoRetryRetryAction{}
+	}
+
+	return rs.wrapped.RetryAfter(req, reason)
+}
+
 // TouchOptions are the options available to the Touch operation.
 type TouchOptions struct {
 	Timeout       time.Duration
@@ -1234,7 +1511,9 @@ type TouchOptions struct {
 	RetryStrategy RetryStrategy
 }
 
-// Touch touches a document, specifying a new expiry time for it.
+// Touch touches a document, specifying a new expiry time for it without fetching the document. The expiry is a
+// number of seconds from now, unless it is greater than 30 days (2592000 seconds) in which case the server treats it
+// as an absolute Unix timestamp. An expiry of 0 removes any expiry from the document.
 func (c *Collection) Touch(id string, expiry uint32, opts *TouchOptions) (mutOut *MutationResult, errOut error) {
 	startTime := time.Now()
 	if opts == nil {
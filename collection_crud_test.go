@@ -3,7 +3,9 @@ package gocb
 import (
 	"context"
 	"encoding/json"
+	"hash/crc32"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
@@ -1069,34 +1071,17 @@ func TestUnlockInvalidCas(t *testing.T) {
 		t.Fatalf("Unlock should have failed")
 	}
 
-	// The server and the mock do not agree on the error for locked documents.
-	if !IsKeyLockedError(err) && !IsTemporaryFailureError(err) {
-		t.Fatalf("Expected error to be TempFailError or IsKeyLockedError but was %s", reflect.TypeOf(err).String())
+	if !IsCasMismatchError(err) {
+		t.Fatalf("Expected error to be CasMismatchError but was %s", reflect.TypeOf(err).String())
 	}
 
+	// A stale cas cannot succeed on retry so should be reported straight away, even when retries are allowed.
 	_, err = globalCollection.Unlock("unlockInvalidCas", lockedDoc.Cas()+1, &UnlockOptions{
 		RetryStrategy: NewBestEffortRetryStrategy(nil),
-		Timeout:       10 * time.Millisecond,
+		Timeout:       10 * time.Second,
 	})
-	if err == nil {
-		t.Fatalf("Unlock should have failed")
-	}
-
-	if !IsTimeoutError(err) {
-		t.Fatalf("Expected error to be TimeoutError but was %s", reflect.TypeOf(err).String())
-	}
-
-	tErr, ok := err.(TimeoutErrorWithDetail)
-	if !ok {
-		t.Fatalf("Expected error to be TimeoutErrorWithDetail but was %+v", err)
-	}
-
-	if len(tErr.RetryReasons()) != 1 {
-		t.Fatalf("Expected 1 retry reason but was %v", tErr.RetryReasons())
-	}
-
-	if tErr.OperationID() == "" {
-		t.Fatalf("Expected OperationID to be not empty")
+	if !IsCasMismatchError(err) {
+		t.Fatalf("Expected error to be CasMismatchError but was %v", err)
 	}
 }
 
@@ -1678,3 +1663,949 @@ func TestCollectionContext(t *testing.T) {
 		})
 	}
 }
+
+func TestExistsMulti(t *testing.T) {
+	provider := &mockKvProvider{
+		store: &mockDocStore{
+			docs: map[string]*mockDocument{
+				"found":   {cas: 1, value: []byte(`{}`)},
+				"deleted": {cas: 2, deleted: true},
+			},
+			errs: map[string]error{
+				"failed": &gocbcore.KvError{Code: gocbcore.StatusAccessError},
+			},
+		},
+	}
+	col := testGetCollection(t, provider)
+
+	res, err := col.ExistsMulti([]string{"found", "notfound", "deleted", "failed"}, nil)
+	if err == nil {
+		t.Fatalf("Expected ExistsMulti to return an error for the failed key")
+	}
+
+	multiErr, ok := err.(ExistsMultiError)
+	if !ok {
+		t.Fatalf("Expected error to be ExistsMultiError but was %v", err)
+	}
+
+	if len(multiErr.Errors()) != 1 || multiErr.Errors()["failed"] == nil {
+		t.Fatalf("Expected only failed to have an error but was %v", multiErr.Errors())
+	}
+
+	expected := map[string]bool{
+		"found":    true,
+		"notfound": false,
+		"deleted":  false,
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("Expected results to be %v but was %v", expected, res)
+	}
+}
+
+func TestExistsMultiNoErrors(t *testing.T) {
+	provider := &mockKvProvider{
+		store: &mockDocStore{
+			docs: map[string]*mockDocument{
+				"found": {cas: 1, value: []byte(`{}`)},
+			},
+		},
+	}
+	col := testGetCollection(t, provider)
+
+	res, err := col.ExistsMulti([]string{"found", "missing"}, nil)
+	if err != nil {
+		t.Fatalf("Expected ExistsMulti to succeed but was %v", err)
+	}
+
+	if !res["found"] || res["missing"] {
+		t.Fatalf("Expected only found to exist but was %v", res)
+	}
+}
+
+func TestExistsMultiConcurrency(t *testing.T) {
+	provider := &mockKvProvider{
+		opWait: time.Millisecond,
+		store:  &mockDocStore{},
+	}
+	col := testGetCollection(t, provider)
+
+	var keys []string
+	for i := 0; i < 20; i++ {
+		keys = append(keys, "key"+strconv.Itoa(i))
+	}
+
+	res, err := col.ExistsMulti(keys, &ExistsMultiOptions{
+		Concurrency: 3,
+	})
+	if err != nil {
+		t.Fatalf("Expected ExistsMulti to succeed but was %v", err)
+	}
+
+	if len(res) != len(keys) {
+		t.Fatalf("Expected %d results but was %d", len(keys), len(res))
+	}
+
+	if provider.maxInFlight > 3 {
+		t.Fatalf("Expected at most 3 checks at once but was %d", provider.maxInFlight)
+	}
+}
+
+func TestTouchMock(t *testing.T) {
+	provider := &mockKvProvider{
+		cas: gocbcore.Cas(42),
+		mt: gocbcore.MutationToken{
+			VbId:   1,
+			VbUuid: 2,
+			SeqNo:  3,
+		},
+	}
+	col := testGetCollection(t, provider)
+
+	res, err := col.Touch("touchDoc", 10, nil)
+	if err != nil {
+		t.Fatalf("Expected Touch to succeed but was %v", err)
+	}
+
+	if res.Cas() != 42 {
+		t.Fatalf("Expected cas to be 42 but was %d", res.Cas())
+	}
+
+	if res.MutationToken() == nil || res.MutationToken().SequenceNumber() != 3 {
+		t.Fatalf("Expected mutation token with sequence number 3 but was %v", res.MutationToken())
+	}
+}
+
+func TestTouchMockKeyNotFound(t *testing.T) {
+	provider := &mockKvProvider{
+		err: &gocbcore.KvError{Code: gocbcore.StatusKeyNotFound},
+	}
+	col := testGetCollection(t, provider)
+
+	res, err := col.Touch("missingDoc", 10, nil)
+	if !IsKeyNotFoundError(err) {
+		t.Fatalf("Expected error to be key not found but was %v", err)
+	}
+
+	if res != nil {
+		t.Fatalf("Expected result to be nil but was %v", res)
+	}
+}
+
+func TestTouchMockExpiryBoundary(t *testing.T) {
+	provider := &mockKvProvider{}
+	col := testGetCollection(t, provider)
+
+	// Expiries up to 30 days are relative, anything above is sent as-is for the server to treat as a Unix timestamp.
+	thirtyDays := uint32(30 * 24 * 60 * 60)
+	absolute := uint32(time.Now().Add(time.Hour).Unix())
+	expiries := []uint32{0, thirtyDays, thirtyDays + 1, absolute}
+	for _, expiry := range expiries {
+		_, err := col.Touch("touchDoc", expiry, nil)
+		if err != nil {
+			t.Fatalf("Expected Touch to succeed but was %v", err)
+		}
+	}
+
+	var sent []uint32
+	for _, opts := range provider.touches {
+		sent = append(sent, opts.Expiry)
+	}
+	if !reflect.DeepEqual(sent, expiries) {
+		t.Fatalf("Expected expiries to be %v but was %v", expiries, sent)
+	}
+}
+
+func TestRemoveMock(t *testing.T) {
+	provider := &mockKvProvider{
+		cas: gocbcore.Cas(42),
+		mt: gocbcore.MutationToken{
+			VbId:   1,
+			VbUuid: 2,
+			SeqNo:  3,
+		},
+	}
+	col := testGetCollection(t, provider)
+	col.sb.UseMutationTokens = true
+
+	res, err := col.Remove("removeDoc", nil)
+	if err != nil {
+		t.Fatalf("Expected Remove to succeed but was %v", err)
+	}
+
+	if res.Cas() != 42 {
+		t.Fatalf("Expected cas to be 42 but was %d", res.Cas())
+	}
+
+	if res.MutationToken() == nil || res.MutationToken().SequenceNumber() != 3 {
+		t.Fatalf("Expected mutation token with sequence number 3 but was %v", res.MutationToken())
+	}
+
+	if provider.deletes[0].Cas != 0 {
+		t.Fatalf("Expected unconditional remove to send a zero cas but was %d", provider.deletes[0].Cas)
+	}
+
+	if string(provider.deletes[0].Key) != "removeDoc" {
+		t.Fatalf("Expected key to be removeDoc but was %s", provider.deletes[0].Key)
+	}
+
+	if len(provider.observeVbs) != 0 {
+		t.Fatalf("Expected remove without durability not to observe but observed %d times", len(provider.observeVbs))
+	}
+}
+
+func TestRemoveMockCasMismatch(t *testing.T) {
+	provider := &mockKvProvider{
+		store: &mockDocStore{
+			docs: map[string]*mockDocument{
+				"removeDoc": {cas: 42, value: []byte(`{}`)},
+			},
+		},
+	}
+	col := testGetCollection(t, provider)
+
+	res, err := col.Remove("removeDoc", &RemoveOptions{Cas: Cas(41)})
+	if !IsCasMismatchError(err) {
+		t.Fatalf("Expected error to be cas mismatch but was %v", err)
+	}
+
+	if res != nil {
+		t.Fatalf("Expected result to be nil but was %v", res)
+	}
+
+	if provider.deletes[0].Cas != gocbcore.Cas(41) {
+		t.Fatalf("Expected cas 41 to be sent but was %d", provider.deletes[0].Cas)
+	}
+
+	if provider.store.docs["removeDoc"].deleted {
+		t.Fatalf("Expected document not to be removed")
+	}
+}
+
+func TestRemoveMockKeyNotFound(t *testing.T) {
+	provider := &mockKvProvider{
+		err: &gocbcore.KvError{Code: gocbcore.StatusKeyNotFound},
+	}
+	col := testGetCollection(t, provider)
+
+	res, err := col.Remove("missingDoc", nil)
+	if !IsKeyNotFoundError(err) {
+		t.Fatalf("Expected error to be key not found but was %v", err)
+	}
+
+	if res != nil {
+		t.Fatalf("Expected result to be nil but was %v", res)
+	}
+}
+
+func TestRemoveMockPersistToMajority(t *testing.T) {
+	provider := &mockKvProvider{
+		cas: gocbcore.Cas(42),
+		mt:  gocbcore.MutationToken{VbId: 1, VbUuid: 2, SeqNo: 3},
+	}
+	col := testGetCollection(t, provider)
+
+	_, err := col.Remove("removeDoc", &RemoveOptions{
+		DurabilityLevel: DurabilityLevelPersistToMajority,
+	})
+	if err != nil {
+		t.Fatalf("Expected Remove to succeed but was %v", err)
+	}
+
+	if provider.deletes[0].DurabilityLevel != gocbcore.DurabilityLevel(DurabilityLevelPersistToMajority) {
+		t.Fatalf("Expected durability level to be persist to majority but was %d", provider.deletes[0].DurabilityLevel)
+	}
+
+	if provider.deletes[0].DurabilityLevelTimeout == 0 {
+		t.Fatalf("Expected durability level timeout to be set")
+	}
+}
+
+func TestRemoveMockPersistToWaitsForPersistence(t *testing.T) {
+	provider := &mockKvProvider{
+		cas:          gocbcore.Cas(42),
+		mt:           gocbcore.MutationToken{VbId: 1, VbUuid: 2, SeqNo: 3},
+		persistAfter: 2,
+	}
+	col := testGetCollection(t, provider)
+	col.sb.UseMutationTokens = true
+	col.sb.DuraTimeout = 10 * time.Second
+	col.sb.DuraPollTimeout = 5 * time.Millisecond
+
+	res, err := col.Remove("removeDoc", &RemoveOptions{
+		PersistTo: 1,
+	})
+	if err != nil {
+		t.Fatalf("Expected Remove to succeed but was %v", err)
+	}
+
+	if res.Cas() != 42 {
+		t.Fatalf("Expected cas to be 42 but was %d", res.Cas())
+	}
+
+	provider.lock.Lock()
+	observed := len(provider.observeVbs)
+	provider.lock.Unlock()
+	if observed != provider.persistAfter+1 {
+		t.Fatalf("Expected remove to be observed %d times but was %d", provider.persistAfter+1, observed)
+	}
+
+	_, err = col.Remove("removeDoc", &RemoveOptions{
+		PersistTo:         1,
+		DurabilityTimeout: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Expected Remove to succeed once persisted but was %v", err)
+	}
+}
+
+func TestGetWithChecksum(t *testing.T) {
+	value := []byte(`{"name":"beer"}`)
+	provider := &mockKvProvider{
+		store: &mockDocStore{
+			docs: map[string]*mockDocument{
+				"checksumDoc": {cas: 7, value: value},
+			},
+		},
+	}
+	col := testGetCollection(t, provider)
+
+	res, checksum, err := col.GetWithChecksum("checksumDoc", nil)
+	if err != nil {
+		t.Fatalf("Expected GetWithChecksum to succeed but was %v", err)
+	}
+
+	ops := provider.lookupIns[0].Ops
+	if len(ops) != 2 {
+		t.Fatalf("Expected 2 ops to be dispatched but was %d", len(ops))
+	}
+
+	if ops[0].Path != "$document.value_crc32c" || ops[0].Flags&gocbcore.SubdocFlag(SubdocFlagXattr) == 0 {
+		t.Fatalf("Expected checksum xattr to be requested first but was %v", ops[0])
+	}
+
+	if ops[1].Op != gocbcore.SubDocOpGetDoc {
+		t.Fatalf("Expected full document to be requested but was %v", ops[1])
+	}
+
+	expected := crc32.Checksum(value, crc32.MakeTable(crc32.Castagnoli))
+	if checksum != expected {
+		t.Fatalf("Expected checksum to be %#x but was %#x", expected, checksum)
+	}
+
+	if res.Cas() != 7 {
+		t.Fatalf("Expected cas to be 7 but was %d", res.Cas())
+	}
+
+	var doc map[string]string
+	err = res.Content(&doc)
+	if err != nil {
+		t.Fatalf("Expected Content to succeed but was %v", err)
+	}
+
+	if doc["name"] != "beer" {
+		t.Fatalf("Expected name to be beer but was %s", doc["name"])
+	}
+}
+
+func TestGetWithChecksumInvalidChecksum(t *testing.T) {
+	provider := &mockKvProvider{
+		cas: gocbcore.Cas(7),
+		value: []gocbcore.SubDocResult{
+			{Value: []byte(`"not-a-checksum"`)},
+			{Value: []byte(`{"name":"beer"}`)},
+		},
+	}
+	col := testGetCollection(t, provider)
+
+	res, _, err := col.GetWithChecksum("checksumDoc", nil)
+	if err == nil {
+		t.Fatalf("Expected GetWithChecksum to fail with an invalid checksum")
+	}
+
+	if res != nil {
+		t.Fatalf("Expected result to be nil but was %v", res)
+	}
+}
+
+func TestGetMeta(t *testing.T) {
+	provider := &mockKvProvider{
+		store: &mockDocStore{
+			docs: map[string]*mockDocument{
+				"metaDoc": {
+					cas:    0x1600a8a4c6d80000,
+					value:  []byte(`{"name":"beer"}`),
+					xattrs: []byte(`{"txn":{"id":1}}`),
+					flags:  33554432,
+					expiry: 1924992000,
+				},
+			},
+		},
+	}
+	col := testGetCollection(t, provider)
+
+	res, err := col.GetMeta("metaDoc", nil)
+	if err != nil {
+		t.Fatalf("Expected GetMeta to succeed but was %v", err)
+	}
+
+	expectedPaths := []string{"$document.exptime", "$document.flags", "$document.datatype", "$document.deleted"}
+	ops := provider.lookupIns[0].Ops
+	if len(ops) != len(expectedPaths) {
+		t.Fatalf("Expected %d ops to be dispatched but was %d", len(expectedPaths), len(ops))
+	}
+
+	for i, path := range expectedPaths {
+		op := ops[i]
+		if op.Op != gocbcore.SubDocOpGet || op.Path != path || op.Flags&gocbcore.SubdocFlag(SubdocFlagXattr) == 0 {
+			t.Fatalf("Expected xattr get of %s but was %v", path, op)
+		}
+	}
+
+	if provider.lookupIns[0].Flags&gocbcore.SubdocDocFlagAccessDeleted == 0 {
+		t.Fatalf("Expected the lookup to access deleted documents so that tombstones are reported")
+	}
+
+	if res.Cas() != 0x1600a8a4c6d80000 {
+		t.Fatalf("Expected cas to be 0x1600a8a4c6d80000 but was %#x", res.Cas())
+	}
+
+	expiry, ok := res.Expiry()
+	if !ok || !expiry.Equal(time.Unix(1924992000, 0)) {
+		t.Fatalf("Expected expiry to be %v but was %v", time.Unix(1924992000, 0), expiry)
+	}
+
+	if res.Flags() != 33554432 {
+		t.Fatalf("Expected flags to be 33554432 but was %d", res.Flags())
+	}
+
+	if !reflect.DeepEqual(res.Datatype(), []string{"json", "xattr"}) {
+		t.Fatalf("Expected datatype to be [json xattr] but was %v", res.Datatype())
+	}
+
+	if !res.IsJSON() {
+		t.Fatalf("Expected document to be JSON")
+	}
+
+	if res.Deleted() {
+		t.Fatalf("Expected document not to be deleted")
+	}
+}
+
+func TestGetMetaNoExpiry(t *testing.T) {
+	provider := &mockKvProvider{
+		store: &mockDocStore{
+			docs: map[string]*mockDocument{
+				"metaDoc": {cas: 1, value: []byte("not json")},
+			},
+		},
+	}
+	col := testGetCollection(t, provider)
+
+	res, err := col.GetMeta("metaDoc", nil)
+	if err != nil {
+		t.Fatalf("Expected GetMeta to succeed but was %v", err)
+	}
+
+	if _, ok := res.Expiry(); ok {
+		t.Fatalf("Expected document to have no expiry")
+	}
+
+	if res.IsJSON() {
+		t.Fatalf("Expected document not to be JSON")
+	}
+}
+
+func TestInsertMock(t *testing.T) {
+	provider := testStoreProvider(nil)
+	col := testGetCollection(t, provider)
+
+	res, err := col.Insert("beer", map[string]string{"name": "ale"}, &InsertOptions{
+		Expiry:          60,
+		DurabilityLevel: DurabilityLevelMajority,
+	})
+	if err != nil {
+		t.Fatalf("Expected Insert to succeed but was %v", err)
+	}
+
+	if res.Cas() != 1 {
+		t.Fatalf("Expected cas to be 1 but was %d", res.Cas())
+	}
+
+	if res.MutationToken() == nil || res.MutationToken().SequenceNumber() != 3 {
+		t.Fatalf("Expected mutation token with sequence number 3 but was %v", res.MutationToken())
+	}
+
+	doc := provider.store.docs["beer"]
+	if string(doc.value) != `{"name":"ale"}` {
+		t.Fatalf("Expected value to be serialized as JSON but was %s", doc.value)
+	}
+
+	if doc.flags != gocbcore.EncodeCommonFlags(gocbcore.JsonType, gocbcore.NoCompression) {
+		t.Fatalf("Expected JSON flags but was %d", doc.flags)
+	}
+
+	if doc.expiry != 60 {
+		t.Fatalf("Expected expiry to be 60 but was %d", doc.expiry)
+	}
+
+	if provider.adds[0].DurabilityLevel != gocbcore.DurabilityLevel(DurabilityLevelMajority) {
+		t.Fatalf("Expected durability level to be majority but was %d", provider.adds[0].DurabilityLevel)
+	}
+}
+
+func TestInsertMockExists(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"beer": {cas: 5, value: []byte(`{"name":"lager"}`)}})
+	col := testGetCollection(t, provider)
+
+	res, err := col.Insert("beer", map[string]string{"name": "ale"}, nil)
+	if !IsKeyExistsError(err) {
+		t.Fatalf("Expected error to be key exists but was %v", err)
+	}
+
+	if IsCasMismatchError(err) {
+		t.Fatalf("Expected insert error not to be a cas mismatch")
+	}
+
+	if res != nil {
+		t.Fatalf("Expected result to be nil but was %v", res)
+	}
+}
+
+func TestUpsertMock(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"beer": {cas: 5, value: []byte(`{"name":"ale"}`)}})
+	col := testGetCollection(t, provider)
+
+	res, err := col.Upsert("beer", map[string]string{"name": "stout"}, &UpsertOptions{Expiry: 30})
+	if err != nil {
+		t.Fatalf("Expected Upsert of an existing document to succeed but was %v", err)
+	}
+
+	if res.Cas() != 6 {
+		t.Fatalf("Expected cas to be 6 but was %d", res.Cas())
+	}
+
+	doc := provider.store.docs["beer"]
+	if string(doc.value) != `{"name":"stout"}` || doc.expiry != 30 {
+		t.Fatalf("Expected stout to be stored with expiry 30 but was %s with %d", doc.value, doc.expiry)
+	}
+
+	_, err = col.Upsert("lager", map[string]string{"name": "lager"}, nil)
+	if err != nil {
+		t.Fatalf("Expected Upsert of a new document to succeed but was %v", err)
+	}
+
+	if string(provider.store.docs["lager"].value) != `{"name":"lager"}` {
+		t.Fatalf("Expected lager to be stored but was %s", provider.store.docs["lager"].value)
+	}
+}
+
+func TestUpsertMockPersistTo(t *testing.T) {
+	provider := testStoreProvider(nil)
+	provider.persistAfter = 2
+	col := testGetCollection(t, provider)
+	col.sb.UseMutationTokens = true
+	col.sb.DuraTimeout = 10 * time.Second
+	col.sb.DuraPollTimeout = 5 * time.Millisecond
+
+	_, err := col.Upsert("beer", map[string]string{"name": "ale"}, &UpsertOptions{PersistTo: 1})
+	if err != nil {
+		t.Fatalf("Expected Upsert to succeed once persisted but was %v", err)
+	}
+
+	provider.lock.Lock()
+	observed := len(provider.observeVbs)
+	provider.lock.Unlock()
+	if observed != provider.persistAfter+1 {
+		t.Fatalf("Expected upsert to be observed %d times but was %d", provider.persistAfter+1, observed)
+	}
+
+	_, err = col.Upsert("beer", map[string]string{"name": "ale"}, &UpsertOptions{
+		PersistTo:       1,
+		DurabilityLevel: DurabilityLevelMajority,
+	})
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected mixing durability types to be invalid but was %v", err)
+	}
+}
+
+func TestReplaceMock(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"beer": {cas: 5, value: []byte(`{"name":"ale"}`)}})
+	col := testGetCollection(t, provider)
+
+	res, err := col.Replace("beer", map[string]string{"name": "porter"}, &ReplaceOptions{
+		Cas:             5,
+		DurabilityLevel: DurabilityLevelPersistToMajority,
+	})
+	if err != nil {
+		t.Fatalf("Expected Replace to succeed but was %v", err)
+	}
+
+	if res.Cas() != 6 {
+		t.Fatalf("Expected cas to be 6 but was %d", res.Cas())
+	}
+
+	if string(provider.store.docs["beer"].value) != `{"name":"porter"}` {
+		t.Fatalf("Expected porter to be stored but was %s", provider.store.docs["beer"].value)
+	}
+
+	if provider.replaces[0].DurabilityLevel != gocbcore.DurabilityLevel(DurabilityLevelPersistToMajority) {
+		t.Fatalf("Expected durability level to be persist to majority but was %d", provider.replaces[0].DurabilityLevel)
+	}
+}
+
+func TestReplaceMockCasMismatch(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"beer": {cas: 5, value: []byte(`{"name":"ale"}`)}})
+	col := testGetCollection(t, provider)
+
+	_, err := col.Replace("beer", map[string]string{"name": "porter"}, &ReplaceOptions{Cas: 4})
+	if !IsCasMismatchError(err) {
+		t.Fatalf("Expected error to be cas mismatch but was %v", err)
+	}
+}
+
+func TestReplaceMockNotFound(t *testing.T) {
+	provider := testStoreProvider(nil)
+	col := testGetCollection(t, provider)
+
+	res, err := col.Replace("beer", map[string]string{"name": "porter"}, nil)
+	if !IsKeyNotFoundError(err) {
+		t.Fatalf("Expected error to be key not found but was %v", err)
+	}
+
+	if res != nil {
+		t.Fatalf("Expected result to be nil but was %v", res)
+	}
+}
+
+const testProjectionDocument = `{"name":"barry","age":32,"address":{"city":"london","postcode":"n1"},` +
+	`"tags":["a","b"],"f0":0,"f1":1,"f2":2,"f3":3,"f4":4,"f5":5,"f6":6,"f7":7,"f8":8,"f9":9,"f10":10,"f11":11,` +
+	`"f12":12,"f13":13,"f14":14,"f15":15}`
+
+func testProjectionProvider() *mockKvProvider {
+	return testStoreProvider(map[string]*mockDocument{
+		"projectDoc": {cas: 7, value: []byte(testProjectionDocument)},
+	})
+}
+
+func TestGetWithProjections(t *testing.T) {
+	provider := testProjectionProvider()
+	col := testGetCollection(t, provider)
+
+	res, err := col.GetWithProjections("projectDoc", []string{"name", "address.city", "address.postcode", "missing"}, nil)
+	if err != nil {
+		t.Fatalf("Expected GetWithProjections to succeed but was %v", err)
+	}
+
+	expectedPaths := []string{"name", "address.city", "address.postcode", "missing"}
+	ops := provider.lookupIns[0].Ops
+	if len(ops) != len(expectedPaths) {
+		t.Fatalf("Expected %d ops to be dispatched but was %d", len(expectedPaths), len(ops))
+	}
+	for i, path := range expectedPaths {
+		if ops[i].Op != gocbcore.SubDocOpGet || ops[i].Path != path {
+			t.Fatalf("Expected get of %s but was %v", path, ops[i])
+		}
+	}
+
+	var doc map[string]interface{}
+	err = res.Content(&doc)
+	if err != nil {
+		t.Fatalf("Failed to get content: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"name": "barry",
+		"address": map[string]interface{}{
+			"city":     "london",
+			"postcode": "n1",
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Fatalf("Expected content to be %v but was %v", expected, doc)
+	}
+
+	if res.Cas() != 7 {
+		t.Fatalf("Expected cas to be 7 but was %d", res.Cas())
+	}
+}
+
+func TestGetWithProjectionsFullDocFallback(t *testing.T) {
+	provider := testProjectionProvider()
+	col := testGetCollection(t, provider)
+
+	fields := []string{"name", "address.city", "tags[1]", "missing"}
+	for i := 0; i < 16; i++ {
+		fields = append(fields, "f"+strconv.Itoa(i))
+	}
+
+	res, err := col.GetWithProjections("projectDoc", fields, nil)
+	if err != nil {
+		t.Fatalf("Expected GetWithProjections to succeed but was %v", err)
+	}
+
+	if ops := provider.lookupIns[0].Ops; len(ops) != 1 || ops[0].Path != "" {
+		t.Fatalf("Expected a single full document get to be dispatched but was %v", ops)
+	}
+
+	var doc map[string]interface{}
+	err = res.Content(&doc)
+	if err != nil {
+		t.Fatalf("Failed to get content: %v", err)
+	}
+
+	if len(doc) != 19 {
+		t.Fatalf("Expected content to have 19 fields but was %v", doc)
+	}
+
+	if doc["name"] != "barry" || doc["f15"] != float64(15) {
+		t.Fatalf("Expected content to include top level fields but was %v", doc)
+	}
+
+	if !reflect.DeepEqual(doc["address"], map[string]interface{}{"city": "london"}) {
+		t.Fatalf("Expected content to include nested address.city but was %v", doc["address"])
+	}
+
+	if !reflect.DeepEqual(doc["tags"], []interface{}{"b"}) {
+		t.Fatalf("Expected content to include tags[1] but was %v", doc["tags"])
+	}
+
+	if _, ok := doc["missing"]; ok {
+		t.Fatalf("Expected missing field to be left out of the content but was %v", doc)
+	}
+}
+
+func TestGetWithProjectionsNoFields(t *testing.T) {
+	provider := testProjectionProvider()
+	col := testGetCollection(t, provider)
+
+	res, err := col.GetWithProjections("projectDoc", nil, nil)
+	if err != nil {
+		t.Fatalf("Expected GetWithProjections to succeed but was %v", err)
+	}
+
+	if len(provider.lookupIns) != 0 {
+		t.Fatalf("Expected a full document get rather than a lookup in but was %v", provider.lookupIns)
+	}
+
+	var doc map[string]interface{}
+	err = res.Content(&doc)
+	if err != nil {
+		t.Fatalf("Failed to get content: %v", err)
+	}
+
+	if doc["name"] != "barry" || len(doc) != 20 {
+		t.Fatalf("Expected content to be the full document but was %v", doc)
+	}
+}
+
+func TestGetProjectMissingPathMock(t *testing.T) {
+	provider := testProjectionProvider()
+	col := testGetCollection(t, provider)
+
+	_, err := col.Get("projectDoc", &GetOptions{Project: []string{"name", "missing"}})
+	projErr, ok := err.(ProjectionErrors)
+	if !ok {
+		t.Fatalf("Expected Get to fail with projection errors but was %v", err)
+	}
+
+	if len(projErr.Errors()) != 1 || !IsPathNotFoundError(projErr.Errors()[0]) {
+		t.Fatalf("Expected a single path not found error but was %v", projErr.Errors())
+	}
+}
+
+func TestTranscoderRoundTrip(t *testing.T) {
+	type tCase struct {
+		name       string
+		transcoder Transcoder
+		value      interface{}
+		valueType  gocbcore.DataType
+		out        func() interface{}
+		expected   interface{}
+	}
+
+	testCases := []tCase{
+		{
+			name:       "json",
+			transcoder: NewJSONTranscoder(&DefaultJSONSerializer{}),
+			value:      map[string]string{"name": "ale"},
+			valueType:  gocbcore.JsonType,
+			out:        func() interface{} { return &map[string]string{} },
+			expected:   &map[string]string{"name": "ale"},
+		},
+		{
+			name:       "raw binary",
+			transcoder: NewRawBinaryTranscoder(),
+			value:      []byte{0x00, 0xff, 0x10},
+			valueType:  gocbcore.BinaryType,
+			out:        func() interface{} { return &[]byte{} },
+			expected:   &[]byte{0x00, 0xff, 0x10},
+		},
+		{
+			name:       "raw string",
+			transcoder: NewRawStringTranscoder(),
+			value:      "not json",
+			valueType:  gocbcore.StringType,
+			out:        func() interface{} { var s string; return &s },
+			expected:   func() *string { s := "not json"; return &s }(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := testStoreProvider(nil)
+			col := testGetCollection(t, provider)
+
+			_, err := col.Insert("doc", tc.value, &InsertOptions{Transcoder: tc.transcoder})
+			if err != nil {
+				t.Fatalf("Expected Insert to succeed but was %v", err)
+			}
+			_, err = col.Upsert("doc", tc.value, &UpsertOptions{Transcoder: tc.transcoder})
+			if err != nil {
+				t.Fatalf("Expected Upsert to succeed but was %v", err)
+			}
+			_, err = col.Replace("doc", tc.value, &ReplaceOptions{Transcoder: tc.transcoder})
+			if err != nil {
+				t.Fatalf("Expected Replace to succeed but was %v", err)
+			}
+
+			flags := provider.store.docs["doc"].flags
+			valueType, compression := gocbcore.DecodeCommonFlags(flags)
+			if valueType != tc.valueType || compression != gocbcore.NoCompression {
+				t.Fatalf("Expected value to be stored with type %d but flags were %#x", tc.valueType, flags)
+			}
+
+			res, err := col.Get("doc", &GetOptions{Transcoder: tc.transcoder})
+			if err != nil {
+				t.Fatalf("Expected Get to succeed but was %v", err)
+			}
+
+			out := tc.out()
+			err = res.Content(out)
+			if err != nil {
+				t.Fatalf("Expected Content to succeed but was %v", err)
+			}
+
+			if !reflect.DeepEqual(out, tc.expected) {
+				t.Fatalf("Expected content to be %v but was %v", tc.expected, out)
+			}
+		})
+	}
+}
+
+func TestTranscoderHonorsStoredFlags(t *testing.T) {
+	provider := testStoreProvider(nil)
+	col := testGetCollection(t, provider)
+
+	_, err := col.Upsert("doc", []byte("blob"), &UpsertOptions{Transcoder: NewRawBinaryTranscoder()})
+	if err != nil {
+		t.Fatalf("Expected Upsert to succeed but was %v", err)
+	}
+
+	res, err := col.Get("doc", nil)
+	if err != nil {
+		t.Fatalf("Expected Get to succeed but was %v", err)
+	}
+
+	var content interface{}
+	err = res.Content(&content)
+	if err == nil {
+		t.Fatalf("Expected decoding binary flags with the JSON transcoder to fail but got %v", content)
+	}
+
+	res, err = col.Get("doc", &GetOptions{Transcoder: NewLegacyTranscoder(&DefaultJSONSerializer{})})
+	if err != nil {
+		t.Fatalf("Expected Get to succeed but was %v", err)
+	}
+
+	var blob []byte
+	err = res.Content(&blob)
+	if err != nil {
+		t.Fatalf("Expected the legacy transcoder to decode binary flags but was %v", err)
+	}
+
+	if string(blob) != "blob" {
+		t.Fatalf("Expected content to be blob but was %s", blob)
+	}
+}
+
+func TestUnlockMock(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 5, value: []byte(`{"name":"barry"}`)}})
+	col := testGetCollection(t, provider)
+
+	locked, err := col.GetAndLock("key", 10*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Expected GetAndLock to succeed but was %v", err)
+	}
+
+	res, err := col.Unlock("key", locked.Cas(), nil)
+	if err != nil {
+		t.Fatalf("Expected Unlock to succeed but was %v", err)
+	}
+
+	if res.Cas() != locked.Cas() {
+		t.Fatalf("Expected cas to be %d but was %d", locked.Cas(), res.Cas())
+	}
+
+	if provider.store.docs["key"].locked {
+		t.Fatalf("Expected document to be unlocked")
+	}
+}
+
+func TestUnlockMockWrongCas(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 5, value: []byte(`{"name":"barry"}`)}})
+	col := testGetCollection(t, provider)
+
+	locked, err := col.GetAndLock("key", 10*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Expected GetAndLock to succeed but was %v", err)
+	}
+
+	_, err = col.Unlock("key", locked.Cas()+1, nil)
+	if !IsCasMismatchError(err) {
+		t.Fatalf("Expected error to be cas mismatch but was %v", err)
+	}
+
+	if IsKeyLockedError(err) {
+		t.Fatalf("Expected error not to be reported as locked")
+	}
+
+	if !provider.store.docs["key"].locked {
+		t.Fatalf("Expected document to still be locked")
+	}
+}
+
+func TestUnlockMockNotLocked(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 5, value: []byte(`{"name":"barry"}`)}})
+	col := testGetCollection(t, provider)
+
+	_, err := col.Unlock("key", 5, nil)
+	if !IsCasMismatchError(err) {
+		t.Fatalf("Expected error to be cas mismatch but was %v", err)
+	}
+}
+
+func TestUnlockMockKeyNotFound(t *testing.T) {
+	provider := testStoreProvider(nil)
+	col := testGetCollection(t, provider)
+
+	_, err := col.Unlock("key", 5, nil)
+	if !IsKeyNotFoundError(err) {
+		t.Fatalf("Expected error to be key not found but was %v", err)
+	}
+}
+
+func TestUnlockRetryStrategy(t *testing.T) {
+	strategy := &unlockRetryStrategy{wrapped: NewBestEffortRetryStrategy(nil)}
+
+	for _, reason := range []RetryReason{KVLockedRetryReason, KVTemporaryFailureRetryReason} {
+		action := strategy.RetryAfter(&mockRetryRequest{}, reason)
+		if action.Duration() != 0 {
+			t.Fatalf("Expected unlock not to be retried for %s but was %v", reason.Description(), action.Duration())
+		}
+	}
+
+	action := strategy.RetryAfter(&mockRetryRequest{}, KVNotMyVBucketRetryReason)
+	if action.Duration() == 0 {
+		t.Fatalf("Expected unlock to be retried for other reasons")
+	}
+}
@@ -2,6 +2,7 @@ package gocb
 
 import (
 	"context"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 
@@ -42,8 +43,38 @@ func (c *Collection) observeOnceSeqNo(ctx context.Context, tracectx opentracing.
 	})
 }
 
+// observeTimeout returns the time allowed for observe based durability requirements to be met, which is timeout if
+// set and otherwise the configured durability timeout.
+func (c *Collection) observeTimeout(timeout time.Duration) time.Duration {
+	if timeout == 0 {
+		return c.sb.DuraTimeout
+	}
+
+	return timeout
+}
+
+// mutationObserveTimeoutMultiplier is the multiple of an operation's timeout allowed for its durability requirements
+// to be met when no durability timeout is given. Observing has to wait for every replica so is given longer.
+const mutationObserveTimeoutMultiplier = 10
+
+// mutationObserveTimeout returns the time allowed for observe based durability requirements to be met after an
+// operation which has its own durability timeout, which is durabilityTimeout if set and otherwise a multiple of the
+// operation timeout, or of the configured KV timeout if that isn't set either.
+func (c *Collection) mutationObserveTimeout(durabilityTimeout, timeout time.Duration) time.Duration {
+	if durabilityTimeout != 0 {
+		return durabilityTimeout
+	}
+
+	if timeout == 0 {
+		timeout = c.sb.KvTimeout
+	}
+
+	return timeout * mutationObserveTimeoutMultiplier
+}
+
 func (c *Collection) observeOne(ctx context.Context, tracectx opentracing.SpanContext, key []byte, mt MutationToken,
-	cas Cas, forDelete bool, replicaIdx int, replicaCh, persistCh chan bool, scopeName, collectionName string) {
+	cas Cas, forDelete bool, replicaIdx int, replicaCh, persistCh chan bool, scopeName, collectionName string,
+	timeout time.Duration) {
 
 	sentReplicated := false
 	sentPersisted := false
@@ -62,7 +93,7 @@ func (c *Collection) observeOne(ctx context.Context, tracectx opentracing.SpanCo
 	// Doing this will set the context deadline to whichever is shorter, what is already set or the timeout
 	// value
 	var cancel context.CancelFunc
-	ctx, cancel = context.WithTimeout(ctx, c.sb.DuraTimeout)
+	ctx, cancel = context.WithTimeout(ctx, c.observeTimeout(timeout))
 	defer cancel()
 
 	commCh := make(chan uint)
@@ -121,6 +152,9 @@ type durabilitySettings struct {
 	collectionName string
 	scopeName      string
 	tracectx       opentracing.SpanContext
+	// timeout bounds how long to wait for the durability requirements to be met, if zero then the cluster
+	// level durability timeout is used.
+	timeout time.Duration
 }
 
 func (c *Collection) durability(settings durabilitySettings) error {
@@ -146,7 +180,7 @@ func (c *Collection) durability(settings durabilitySettings) error {
 
 	for replicaIdx := 0; replicaIdx < numServers; replicaIdx++ {
 		go c.observeOne(settings.ctx, settings.tracectx, keyBytes, settings.mt, settings.cas, settings.forDelete,
-			replicaIdx, replicaCh, persistCh, settings.scopeName, settings.collectionName)
+			replicaIdx, replicaCh, persistCh, settings.scopeName, settings.collectionName, settings.timeout)
 	}
 
 	results := int(0)
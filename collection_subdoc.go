@@ -1,7 +1,11 @@
 package gocb
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
 	"time"
 
 	gocbcore "github.com/couchbase/gocbcore/v8"
@@ -18,6 +22,37 @@ type LookupInOptions struct {
 	Timeout       time.Duration
 	Serializer    JSONSerializer
 	RetryStrategy RetryStrategy
+	// Internal: This should never be used and is not supported.
+	AccessDeleted bool
+}
+
+var subdocArrayIndexRegexp = regexp.MustCompile(`^\[-?[0-9]+\]$`)
+
+// SubdocPath builds a subdocument path from the given segments. Segments containing characters
+// which have a special meaning within a path (e.g. `.` or `[`) are escaped with backticks, with any
+// embedded backticks doubled. Segments which are array indexes (e.g. `[0]` or `[-1]`) are appended as-is.
+func SubdocPath(segments ...string) string {
+	var path strings.Builder
+	for _, segment := range segments {
+		if subdocArrayIndexRegexp.MatchString(segment) {
+			path.WriteString(segment)
+			continue
+		}
+
+		if path.Len() > 0 {
+			path.WriteByte('.')
+		}
+
+		if strings.ContainsAny(segment, ".[]`") {
+			path.WriteByte('`')
+			path.WriteString(strings.Replace(segment, "`", "``", -1))
+			path.WriteByte('`')
+		} else {
+			path.WriteString(segment)
+		}
+	}
+
+	return path.String()
 }
 
 // GetSpecOptions are the options available to LookupIn subdoc Get operations.
@@ -113,7 +148,57 @@ func CountSpec(path string, opts *CountSpecOptions) LookupInSpec {
 	return LookupInSpec{op: op}
 }
 
+// GetDocAndXattrsSpec returns the specs required to fetch the full document along with the given extended
+// attributes in a single LookupIn. The document is available at index 0 of the LookupInResult and each extended
+// attribute at the index following it, in the order that the paths were provided.
+func GetDocAndXattrsSpec(xattrPaths []string) []LookupInSpec {
+	specs := []LookupInSpec{GetSpec("", nil)}
+	for _, path := range xattrPaths {
+		specs = append(specs, GetSpec(path, &GetSpecOptions{IsXattr: true}))
+	}
+
+	return specs
+}
+
+// orderLookupInOps stably reorders ops so that any extended attribute ops precede document ops, as the server
+// requires. It also returns the index of each reordered op within the original ops.
+func orderLookupInOps(ops []LookupInSpec) ([]gocbcore.SubDocOp, []int) {
+	subdocs := make([]gocbcore.SubDocOp, 0, len(ops))
+	indexes := make([]int, 0, len(ops))
+	for i, op := range ops {
+		if op.op.Flags&gocbcore.SubdocFlag(SubdocFlagXattr) != 0 {
+			subdocs = append(subdocs, op.op)
+			indexes = append(indexes, i)
+		}
+	}
+	for i, op := range ops {
+		if op.op.Flags&gocbcore.SubdocFlag(SubdocFlagXattr) == 0 {
+			subdocs = append(subdocs, op.op)
+			indexes = append(indexes, i)
+		}
+	}
+
+	return subdocs, indexes
+}
+
+// lookupInGetPaths maps the path of each get op to its index within ops, the first op wins for a repeated path.
+func lookupInGetPaths(ops []LookupInSpec) map[string]int {
+	paths := make(map[string]int)
+	for i, op := range ops {
+		if op.op.Op != gocbcore.SubDocOpGet {
+			continue
+		}
+
+		if _, ok := paths[op.op.Path]; !ok {
+			paths[op.op.Path] = i
+		}
+	}
+
+	return paths
+}
+
 // LookupIn performs a set of subdocument lookup operations on the document identified by id.
+// Extended attribute ops are sent ahead of document ops, results are always returned in the order of ops.
 func (c *Collection) LookupIn(id string, ops []LookupInSpec, opts *LookupInOptions) (docOut *LookupInResult, errOut error) {
 	startTime := time.Now()
 	if opts == nil {
@@ -144,10 +229,7 @@ func (c *Collection) lookupIn(ctx context.Context, tracectx requestSpanContext,
 		return nil, err
 	}
 
-	var subdocs []gocbcore.SubDocOp
-	for _, op := range ops {
-		subdocs = append(subdocs, op.op)
-	}
+	subdocs, opIndexes := orderLookupInOps(ops)
 
 	if len(ops) > 16 {
 		return nil, invalidArgumentsError{message: "too many lookupIn ops specified, maximum 16"}
@@ -163,9 +245,15 @@ func (c *Collection) lookupIn(ctx context.Context, tracectx requestSpanContext,
 		retryWrapper = newRetryStrategyWrapper(opts.RetryStrategy)
 	}
 
+	var flags SubdocDocFlag
+	if opts.AccessDeleted {
+		flags |= SubdocDocFlagAccessDeleted
+	}
+
 	ctrl := c.newOpManager(ctx, startTime, "LookupIn")
 	err = ctrl.wait(agent.LookupInEx(gocbcore.LookupInOptions{
 		Key:            []byte(id),
+		Flags:          gocbcore.SubdocDocFlag(flags),
 		Ops:            subdocs,
 		CollectionName: c.name(),
 		ScopeName:      c.scopeName(),
@@ -183,12 +271,13 @@ func (c *Collection) lookupIn(ctx context.Context, tracectx requestSpanContext,
 			resSet.serializer = serializer
 			resSet.cas = Cas(res.Cas)
 			resSet.contents = make([]lookupInPartial, len(subdocs))
+			resSet.pathMap = lookupInGetPaths(ops)
 
 			for i, opRes := range res.Ops {
-				// resSet.contents[i].path = opts.spec.ops[i].Path
-				resSet.contents[i].err = maybeEnhanceKVErr(opRes.Err, id, false)
+				idx := opIndexes[i]
+				resSet.contents[idx].err = maybeEnhanceKVErr(opRes.Err, id, false)
 				if opRes.Value != nil {
-					resSet.contents[i].data = append([]byte(nil), opRes.Value...)
+					resSet.contents[idx].data = append([]byte(nil), opRes.Value...)
 				}
 			}
 
@@ -233,10 +322,12 @@ type MutateInSpec struct {
 }
 
 // MutateInOptions are the set of options available to MutateIn.
+// If the document has been locked with GetAndLock then Cas must be set to the CAS returned by GetAndLock, a
+// successful mutation will then release the lock. If the document is locked and Cas does not match then the
+// operation fails with an error for which IsKeyLockedError returns true.
 type MutateInOptions struct {
 	Timeout         time.Duration
 	Context         context.Context
-	Expiry          uint32
 	Cas             Cas
 	PersistTo       uint
 	ReplicateTo     uint
@@ -244,6 +335,19 @@ type MutateInOptions struct {
 	StoreSemantic   StoreSemantics
 	Serializer      JSONSerializer
 	RetryStrategy   RetryStrategy
+	// Expiry is the expiry to set on the document, in seconds.
+	Expiry uint32
+	// ExpirySet indicates that Expiry should be applied to the document even when it is 0, clearing its expiry.
+	ExpirySet bool
+	// PreserveExpiry leaves the expiry of an existing document as it is when neither Expiry nor ExpirySet are set,
+	// rather than the mutation clearing it. The current expiry is looked up and sent along with the mutation, which
+	// is made against the CAS of the lookup. If the document changes in between then this is tried again, up to a
+	// limited number of times.
+	PreserveExpiry bool
+	// DurabilityTimeout bounds how long to wait for PersistTo and ReplicateTo to be met once the mutation
+	// has succeeded, independently of Timeout. If not set then ten times Timeout, or the KV timeout of the cluster,
+	// is used.
+	DurabilityTimeout time.Duration
 	// Internal: This should never be used and is not supported.
 	AccessDeleted bool
 }
@@ -254,8 +358,15 @@ func (c *Collection) encodeMultiArray(in interface{}, serializer JSONSerializer)
 		return nil, err
 	}
 
+	return multiArrayElements(out)
+}
+
+// multiArrayElements strips the enclosing brackets from an encoded JSON array, leaving its elements.
+func multiArrayElements(out []byte) ([]byte, error) {
+	out = bytes.TrimSpace(out)
+
 	// Assert first character is a '['
-	if len(out) < 2 || out[0] != '[' {
+	if len(out) < 2 || out[0] != '[' || out[len(out)-1] != ']' {
 		return nil, invalidArgumentsError{message: "not a JSON array"}
 	}
 
@@ -263,6 +374,24 @@ func (c *Collection) encodeMultiArray(in interface{}, serializer JSONSerializer)
 	return out, nil
 }
 
+// encodeMutateInValue encodes the value of a mutation op. Values which are a json.RawMessage are already encoded
+// and so are sent as-is, rather than being passed through the serializer.
+func (c *Collection) encodeMutateInValue(op subDocOp, serializer JSONSerializer) ([]byte, error) {
+	if raw, ok := op.Value.(json.RawMessage); ok {
+		if op.MultiValue {
+			return multiArrayElements(raw)
+		}
+
+		return raw, nil
+	}
+
+	if op.MultiValue {
+		return c.encodeMultiArray(op.Value, serializer)
+	}
+
+	return serializer.Serialize(op.Value)
+}
+
 // InsertSpecOptions are the options available to subdocument Insert operations.
 type InsertSpecOptions struct {
 	CreatePath bool
@@ -649,7 +778,12 @@ func (c *Collection) MutateIn(id string, ops []MutateInSpec, opts *MutateInOptio
 		return nil, err
 	}
 
-	res, err := c.mutate(ctx, span.Context(), id, ops, startTime, *opts)
+	var res *MutateInResult
+	if opts.preservesExpiry() {
+		res, err = c.mutatePreservingExpiry(ctx, span.Context(), id, ops, startTime, *opts)
+	} else {
+		res, err = c.mutate(ctx, span.Context(), id, ops, startTime, *opts)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -657,6 +791,7 @@ func (c *Collection) MutateIn(id string, ops []MutateInSpec, opts *MutateInOptio
 	if opts.PersistTo == 0 && opts.ReplicateTo == 0 {
 		return res, nil
 	}
+
 	return res, c.durability(durabilitySettings{
 		ctx:            opts.Context,
 		key:            id,
@@ -667,9 +802,169 @@ func (c *Collection) MutateIn(id string, ops []MutateInSpec, opts *MutateInOptio
 		forDelete:      false,
 		scopeName:      c.scopeName(),
 		collectionName: c.name(),
+		timeout:        c.mutationObserveTimeout(opts.DurabilityTimeout, opts.Timeout),
 	})
 }
 
+// CompareAndMutateIn performs checks against the document identified by id and, only if expected returns true for
+// their result, performs ops using the CAS from the checks. If the document changes in between then the checks are
+// performed again, up to a limited number of times after which the CAS mismatch is returned. If expected returns
+// false then a ComparisonFailedError is returned. Any Cas set in opts is ignored.
+func (c *Collection) CompareAndMutateIn(id string, checks []LookupInSpec, expected func(*LookupInResult) bool,
+	ops []MutateInSpec, opts *MutateInOptions) error {
+	return c.lookupAndMutateIn("CompareAndMutateIn", id, checks, func(res *LookupInResult) ([]MutateInSpec, error) {
+		if !expected(res) {
+			return nil, comparisonFailedError{key: id}
+		}
+
+		return ops, nil
+	}, opts)
+}
+
+// AppendToCappedArray appends value to the array at path within the document identified by id, removing elements
+// from the front of the array so that it holds no more than maxLen elements. The length of the array is read and
+// the document mutated using its CAS, if the document changes in between then this is done again, up to a limited
+// number of times. The array is created if path doesn't exist. At most 15 elements are removed by one call. Any Cas
+// set in opts is ignored.
+func (c *Collection) AppendToCappedArray(id, path string, value interface{}, maxLen int, opts *MutateInOptions) error {
+	if maxLen <= 0 {
+		return invalidArgumentsError{message: "maxLen must be greater than 0"}
+	}
+
+	checks := []LookupInSpec{CountSpec(path, nil)}
+	return c.lookupAndMutateIn("AppendToCappedArray", id, checks, func(res *LookupInResult) ([]MutateInSpec, error) {
+		var count int
+		err := res.ContentAt(0, &count)
+		if err != nil && !IsPathNotFoundError(err) {
+			return nil, err
+		}
+
+		var ops []MutateInSpec
+		for overflow := count + 1 - maxLen; overflow > 0 && len(ops) < 15; overflow-- {
+			ops = append(ops, RemoveSpec(path+"[0]", nil))
+		}
+
+		return append(ops, ArrayAppendSpec(path, value, &ArrayAppendSpecOptions{CreatePath: true})), nil
+	}, opts)
+}
+
+// lookupAndMutateIn performs checks against the document identified by id and then the ops that buildOps returns
+// for their result, using the CAS from the checks. If the document changes in between then this is done again, with
+// a backoff, until maxCasMismatchRetries is reached.
+func (c *Collection) lookupAndMutateIn(opName, id string, checks []LookupInSpec,
+	buildOps func(*LookupInResult) ([]MutateInSpec, error), opts *MutateInOptions) error {
+	if opts == nil {
+		opts = &MutateInOptions{}
+	}
+
+	ctx, cancel := c.context(opts.Context, opts.Timeout)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	// If the expiry is to be left as it is then fetch it alongside the checks, rather than the mutation having to.
+	lookups := checks
+	preserveExpiry := opts.preservesExpiry() && len(checks) < 16
+	if preserveExpiry {
+		lookups = append(checks[:len(checks):len(checks)], GetSpec(expiryXattrPath, &GetSpecOptions{IsXattr: true}))
+	}
+
+	for retryAttempts := uint32(0); ; retryAttempts++ {
+		lookupRes, err := c.LookupIn(id, lookups, &LookupInOptions{
+			Context:       ctx,
+			Serializer:    opts.Serializer,
+			RetryStrategy: opts.RetryStrategy,
+		})
+		if err != nil {
+			return err
+		}
+
+		mutateOpts := *opts
+		mutateOpts.Context = ctx
+		mutateOpts.Cas = lookupRes.Cas()
+		if preserveExpiry {
+			if expiry, ok := lookupRes.Expiry(); ok {
+				mutateOpts.Expiry = uint32(expiry.Unix())
+			}
+			mutateOpts.ExpirySet = true
+			lookupRes.contents = lookupRes.contents[:len(checks)]
+		}
+
+		ops, err := buildOps(lookupRes)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.MutateIn(id, ops, &mutateOpts)
+		if !IsCasMismatchError(err) || !waitCasMismatchRetry(ctx, retryAttempts) {
+			return err
+		}
+
+		logDebugFieldsf(c.kvOpLogFields(opName), "Document %s changed during %s, checking again", id, opName)
+	}
+}
+
+// preservesExpiry returns whether the mutation is to leave the expiry of an existing document as it is.
+func (opts *MutateInOptions) preservesExpiry() bool {
+	return opts.PreserveExpiry && opts.Expiry == 0 && !opts.ExpirySet && opts.StoreSemantic != StoreSemanticsInsert &&
+		!opts.AccessDeleted
+}
+
+// maxCasMismatchRetries is the number of times that a lookup and the mutation made against its CAS are tried again
+// when the document changes in between.
+const maxCasMismatchRetries = 5
+
+// waitCasMismatchRetry backs off before the lookup and mutation are tried again, returning false if ctx is done or
+// no attempts remain.
+func waitCasMismatchRetry(ctx context.Context, retryAttempts uint32) bool {
+	if retryAttempts >= maxCasMismatchRetries {
+		return false
+	}
+
+	select {
+	case <-time.After(gocbcore.ControlledBackoff(retryAttempts)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// mutatePreservingExpiry performs a mutation which leaves the document expiry as it is. The server clears the expiry
+// of a document on any mutation which doesn't specify one, so the current expiry is looked up and sent along with the
+// mutation. The mutation is made against the CAS of the lookup so that a change of expiry in between isn't lost.
+func (c *Collection) mutatePreservingExpiry(ctx context.Context, tracectx requestSpanContext, id string,
+	ops []MutateInSpec, startTime time.Time, opts MutateInOptions) (*MutateInResult, error) {
+	for retryAttempts := uint32(0); ; retryAttempts++ {
+		lookupRes, err := c.lookupIn(ctx, tracectx, id, []LookupInSpec{
+			GetSpec(expiryXattrPath, &GetSpecOptions{IsXattr: true}),
+		}, startTime, LookupInOptions{RetryStrategy: opts.RetryStrategy})
+		if err != nil {
+			if IsKeyNotFoundError(err) && opts.StoreSemantic == StoreSemanticsUpsert {
+				// There's no expiry to preserve, the document is to be created.
+				return c.mutate(ctx, tracectx, id, ops, startTime, opts)
+			}
+
+			return nil, err
+		}
+
+		mutateOpts := opts
+		if expiry, ok := lookupRes.Expiry(); ok {
+			mutateOpts.Expiry = uint32(expiry.Unix())
+		}
+		if opts.Cas == 0 {
+			mutateOpts.Cas = lookupRes.Cas()
+		}
+
+		res, err := c.mutate(ctx, tracectx, id, ops, startTime, mutateOpts)
+		if opts.Cas == 0 && IsCasMismatchError(err) && waitCasMismatchRetry(ctx, retryAttempts) {
+			logDebugFieldsf(c.kvOpLogFields("MutateIn"), "Document %s changed whilst preserving its expiry, trying again", id)
+			continue
+		}
+
+		return res, err
+	}
+}
+
 func (c *Collection) mutate(ctx context.Context, tracectx requestSpanContext, id string, ops []MutateInSpec,
 	startTime time.Time, opts MutateInOptions) (mutOut *MutateInResult, errOut error) {
 	agent, err := c.getKvProvider()
@@ -728,14 +1023,8 @@ func (c *Collection) mutate(ctx context.Context, tracectx requestSpanContext, id
 			continue
 		}
 
-		var marshaled []byte
-		var err error
 		etrace := c.startKvOpTrace("encode", tracectx)
-		if op.op.MultiValue {
-			marshaled, err = c.encodeMultiArray(op.op.Value, serializer)
-		} else {
-			marshaled, err = serializer.Serialize(op.op.Value)
-		}
+		marshaled, err := c.encodeMutateInValue(op.op, serializer)
 		etrace.Finish()
 		if err != nil {
 			return nil, err
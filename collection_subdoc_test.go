@@ -1,8 +1,14 @@
 package gocb
 
 import (
+	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+	"github.com/pkg/errors"
 )
 
 func TestInsertLookupIn(t *testing.T) {
@@ -421,3 +427,852 @@ func TestMutateInLookupInMacro(t *testing.T) {
 		t.Fatalf("Expected caspath to start with 0x but was %s", caspath)
 	}
 }
+
+func TestSubdocPath(t *testing.T) {
+	testCases := []struct {
+		segments []string
+		expected string
+	}{
+		{[]string{"foo"}, "foo"},
+		{[]string{"foo", "bar", "baz"}, "foo.bar.baz"},
+		{[]string{"foo.bar"}, "`foo.bar`"},
+		{[]string{"foo", "bar.baz", "qux"}, "foo.`bar.baz`.qux"},
+		{[]string{"foo[bar]"}, "`foo[bar]`"},
+		{[]string{"foo", "[0]"}, "foo[0]"},
+		{[]string{"foo", "[-1]", "bar"}, "foo[-1].bar"},
+		{[]string{"foo", "[0]", "[1]"}, "foo[0][1]"},
+		{[]string{"foo`bar"}, "`foo``bar`"},
+		{[]string{"foo", "`bar.baz`"}, "foo.```bar.baz```"},
+		{[]string{}, ""},
+	}
+
+	for _, tCase := range testCases {
+		path := SubdocPath(tCase.segments...)
+		if path != tCase.expected {
+			t.Fatalf("Expected path for %v to be %s but was %s", tCase.segments, tCase.expected, path)
+		}
+	}
+}
+
+func TestSubdocTooDeepErrors(t *testing.T) {
+	// Each status should only be matched by the predicate at the same position.
+	statuses := []gocbcore.StatusCode{
+		gocbcore.StatusSubDocPathTooBig,
+		gocbcore.StatusSubDocValueTooDeep,
+		gocbcore.StatusSubDocDocTooDeep,
+	}
+	predicates := []func(error) bool{IsSubdocPathTooDeepError, IsSubdocValueTooDeepError, IsSubdocDocTooDeepError}
+
+	for i, status := range statuses {
+		assertErr := func(err error) {
+			for j, predicate := range predicates {
+				if predicate(err) != (i == j) {
+					t.Fatalf("Expected predicate %d to be %t for status %x but was %t", j, i == j, status, !(i == j))
+				}
+			}
+
+			if IsPathNotFoundError(err) {
+				t.Fatalf("Expected status %x not to be path not found", status)
+			}
+		}
+
+		lookupCol := testGetCollection(t, &mockKvProvider{
+			value: []gocbcore.SubDocResult{
+				{Value: []byte(`"value"`)},
+				{Err: &gocbcore.KvError{Code: status}},
+			},
+		})
+
+		res, err := lookupCol.LookupIn("key", []LookupInSpec{
+			GetSpec("path", nil),
+			GetSpec("deep.path", nil),
+		}, nil)
+		if err != nil {
+			t.Fatalf("Expected LookupIn to succeed but was %v", err)
+		}
+
+		var val string
+		err = res.ContentAt(0, &val)
+		if err != nil {
+			t.Fatalf("Expected ContentAt 0 to succeed but was %v", err)
+		}
+
+		assertErr(res.ContentAt(1, &val))
+
+		mutateCol := testGetCollection(t, &mockKvProvider{
+			err: gocbcore.SubDocMutateError{
+				Err:     &gocbcore.KvError{Code: status},
+				OpIndex: 1,
+			},
+		})
+
+		_, err = mutateCol.MutateIn("key", []MutateInSpec{
+			UpsertSpec("path", "value", nil),
+			UpsertSpec("deep.path", "value", nil),
+		}, nil)
+		if err == nil {
+			t.Fatalf("Expected MutateIn to fail")
+		}
+
+		mutateErr, ok := err.(SubdocMutateError)
+		if !ok {
+			t.Fatalf("Expected error to be SubdocMutateError but was %v", err)
+		}
+
+		if mutateErr.OpIndex() != 1 {
+			t.Fatalf("Expected failing op index to be 1 but was %d", mutateErr.OpIndex())
+		}
+
+		assertErr(err)
+	}
+}
+
+func TestMutateInWithLockCas(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 5, value: []byte(`{"name":"barry"}`)}})
+	col := testGetCollection(t, provider)
+
+	locked, err := col.GetAndLock("key", 10*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Expected GetAndLock to succeed but was %v", err)
+	}
+
+	res, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("name", "sally", nil),
+	}, &MutateInOptions{
+		Cas: locked.Cas(),
+	})
+	if err != nil {
+		t.Fatalf("Expected MutateIn with the lock CAS to succeed but was %v", err)
+	}
+
+	if provider.store.docs["key"].locked {
+		t.Fatalf("Expected MutateIn to release the lock")
+	}
+
+	if res.Cas() == locked.Cas() {
+		t.Fatalf("Expected MutateIn to return a new CAS")
+	}
+}
+
+func TestMutateInWithWrongCasWhenLocked(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 5, value: []byte(`{"name":"barry"}`)}})
+	col := testGetCollection(t, provider)
+
+	locked, err := col.GetAndLock("key", 10*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Expected GetAndLock to succeed but was %v", err)
+	}
+
+	_, err = col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("name", "sally", nil),
+	}, &MutateInOptions{
+		Cas: locked.Cas() + 1,
+	})
+	if !IsKeyLockedError(err) {
+		t.Fatalf("Expected error to be document locked but was %v", err)
+	}
+
+	if IsCasMismatchError(err) {
+		t.Fatalf("Expected document locked error not to be a CAS mismatch")
+	}
+
+	if !provider.store.docs["key"].locked {
+		t.Fatalf("Expected document to remain locked")
+	}
+}
+
+func TestMutateInWithWrongCasWhenUnlocked(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 5, value: []byte(`{"name":"barry"}`)}})
+	col := testGetCollection(t, provider)
+
+	_, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("name", "sally", nil),
+	}, &MutateInOptions{
+		Cas: 4,
+	})
+	if !IsCasMismatchError(err) {
+		t.Fatalf("Expected error to be CAS mismatch but was %v", err)
+	}
+
+	if IsKeyLockedError(err) {
+		t.Fatalf("Expected CAS mismatch error not to be document locked")
+	}
+}
+
+func TestMutateInDurabilityTimeout(t *testing.T) {
+	type tCase struct {
+		name              string
+		timeout           time.Duration
+		durabilityTimeout time.Duration
+		minElapsed        time.Duration
+		maxElapsed        time.Duration
+	}
+
+	testCases := []tCase{
+		{
+			name:              "explicit",
+			timeout:           20 * time.Millisecond,
+			durabilityTimeout: 200 * time.Millisecond,
+			minElapsed:        200 * time.Millisecond,
+			maxElapsed:        2 * time.Second,
+		},
+		{
+			name:       "operation timeout multiple",
+			timeout:    25 * time.Millisecond,
+			minElapsed: 250 * time.Millisecond,
+			maxElapsed: 2 * time.Second,
+		},
+		{
+			name:       "kv timeout multiple",
+			minElapsed: 300 * time.Millisecond,
+			maxElapsed: 2 * time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			col := testGetCollection(t, &mockKvProvider{
+				cas:   gocbcore.Cas(1),
+				value: []gocbcore.SubDocResult{{}},
+				mt:    gocbcore.MutationToken{VbId: 1, VbUuid: 2, SeqNo: 3},
+			})
+			col.sb.UseMutationTokens = true
+			col.sb.KvTimeout = 30 * time.Millisecond
+			// The cluster durability timeout only applies to operations without their own durability timeout.
+			col.sb.DuraTimeout = 10 * time.Second
+			col.sb.DuraPollTimeout = 5 * time.Millisecond
+
+			start := time.Now()
+			res, err := col.MutateIn("key", []MutateInSpec{
+				UpsertSpec("path", "value", nil),
+			}, &MutateInOptions{
+				Timeout:           tc.timeout,
+				DurabilityTimeout: tc.durabilityTimeout,
+				PersistTo:         1,
+			})
+			elapsed := time.Since(start)
+			if err == nil {
+				t.Fatalf("Expected MutateIn to fail to meet durability requirements")
+			}
+
+			if !IsDurabilityError(err) {
+				t.Fatalf("Expected error to be durability error but was %v", err)
+			}
+
+			if res == nil || res.Cas() != Cas(1) {
+				t.Fatalf("Expected mutation result to be returned alongside the durability error")
+			}
+
+			if elapsed < tc.minElapsed || elapsed > tc.maxElapsed {
+				t.Fatalf("Expected durability to wait between %s and %s but was %s", tc.minElapsed, tc.maxElapsed,
+					elapsed)
+			}
+		})
+	}
+}
+
+func TestLookupInXattrOrdering(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{
+		"key": {
+			cas:    1,
+			value:  []byte(`{"a":"a","b":["x","y"],"d":"d"}`),
+			xattrs: []byte(`{"meta":{"c":true}}`),
+			expiry: 1577836800,
+		},
+	})
+	col := testGetCollection(t, provider)
+
+	res, err := col.LookupIn("key", []LookupInSpec{
+		GetSpec("a", nil),
+		GetSpec("$document.exptime", &GetSpecOptions{IsXattr: true}),
+		CountSpec("b", nil),
+		ExistsSpec("meta.c", &ExistsSpecOptions{IsXattr: true}),
+		GetSpec("d", nil),
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected LookupIn to succeed but was %v", err)
+	}
+
+	expectedDispatch := []string{"$document.exptime", "meta.c", "a", "b", "d"}
+	ops := provider.lookupIns[0].Ops
+	if len(ops) != len(expectedDispatch) {
+		t.Fatalf("Expected %d ops to be dispatched but was %d", len(expectedDispatch), len(ops))
+	}
+	for i, path := range expectedDispatch {
+		if ops[i].Path != path {
+			t.Fatalf("Expected op %d to be dispatched for %s but was %s", i, path, ops[i].Path)
+		}
+	}
+
+	for i, expected := range map[int]string{0: "a", 4: "d"} {
+		var val string
+		err = res.ContentAt(i, &val)
+		if err != nil {
+			t.Fatalf("Expected ContentAt %d to succeed but was %v", i, err)
+		}
+
+		if val != expected {
+			t.Fatalf("Expected result %d to be %s but was %s", i, expected, val)
+		}
+	}
+
+	for i, expected := range map[int]int{1: 1577836800, 2: 2} {
+		var val int
+		err = res.ContentAt(i, &val)
+		if err != nil {
+			t.Fatalf("Expected ContentAt %d to succeed but was %v", i, err)
+		}
+
+		if val != expected {
+			t.Fatalf("Expected result %d to be %d but was %d", i, expected, val)
+		}
+	}
+
+	if !res.Exists(3) {
+		t.Fatalf("Expected result 3 to exist")
+	}
+}
+
+func TestLookupInGetDocAndXattrsSpec(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{
+		"key": {
+			cas:    1,
+			value:  []byte(`"doc"`),
+			xattrs: []byte(`{"txn":"txn","meta":{"owner":"meta.owner"}}`),
+		},
+	})
+	col := testGetCollection(t, provider)
+
+	res, err := col.LookupIn("key", GetDocAndXattrsSpec([]string{"txn", "meta.owner"}), nil)
+	if err != nil {
+		t.Fatalf("Expected LookupIn to succeed but was %v", err)
+	}
+
+	if ops := provider.lookupIns[0].Ops; len(ops) != 3 || ops[2].Op != gocbcore.SubDocOpGetDoc {
+		t.Fatalf("Expected full document op to be dispatched after the xattr ops but was %v", ops)
+	}
+
+	for i, expected := range []string{"doc", "txn", "meta.owner"} {
+		var val string
+		err = res.ContentAt(i, &val)
+		if err != nil {
+			t.Fatalf("Expected ContentAt %d to succeed but was %v", i, err)
+		}
+
+		if val != expected {
+			t.Fatalf("Expected result %d to be %s but was %s", i, expected, val)
+		}
+	}
+}
+
+// testStatusProvider returns a provider backed by a store holding the order document with the given status.
+func testStatusProvider(status string) *mockKvProvider {
+	return testStoreProvider(map[string]*mockDocument{
+		"order": {cas: 10, value: []byte(`{"status":"` + status + `"}`)},
+	})
+}
+
+// testDocStatus returns the status of a document held by the store of provider.
+func testDocStatus(t *testing.T, provider *mockKvProvider, key string) string {
+	var doc struct {
+		Status string `json:"status"`
+	}
+	err := json.Unmarshal(provider.store.docs[key].value, &doc)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal document: %v", err)
+	}
+
+	return doc.Status
+}
+
+func testStatusIsPending(res *LookupInResult) bool {
+	var status string
+	if err := res.ContentAt(0, &status); err != nil {
+		return false
+	}
+
+	return status == "pending"
+}
+
+func TestCompareAndMutateIn(t *testing.T) {
+	provider := testStatusProvider("pending")
+	col := testGetCollection(t, provider)
+
+	err := col.CompareAndMutateIn("order", []LookupInSpec{GetSpec("status", nil)}, testStatusIsPending,
+		[]MutateInSpec{ReplaceSpec("status", "shipped", nil)}, nil)
+	if err != nil {
+		t.Fatalf("Expected CompareAndMutateIn to succeed but was %v", err)
+	}
+
+	if len(provider.mutateIns) != 1 || testDocStatus(t, provider, "order") != "shipped" {
+		t.Fatalf("Expected document to be mutated once but was mutated %d times", len(provider.mutateIns))
+	}
+}
+
+func TestCompareAndMutateInPredicateFalse(t *testing.T) {
+	provider := testStatusProvider("cancelled")
+	col := testGetCollection(t, provider)
+
+	err := col.CompareAndMutateIn("order", []LookupInSpec{GetSpec("status", nil)}, testStatusIsPending,
+		[]MutateInSpec{ReplaceSpec("status", "shipped", nil)}, nil)
+	if !IsComparisonFailedError(err) {
+		t.Fatalf("Expected error to be comparison failed but was %v", err)
+	}
+
+	if len(provider.mutateIns) != 0 || testDocStatus(t, provider, "order") != "cancelled" {
+		t.Fatalf("Expected document to not be mutated")
+	}
+}
+
+func TestCompareAndMutateInCasChanged(t *testing.T) {
+	provider := testStatusProvider("pending")
+	changed := false
+	provider.store.beforeMutate = func() {
+		// Simulate another writer touching the document between the first check and mutation.
+		if !changed {
+			changed = true
+			provider.store.docs["order"].cas++
+		}
+	}
+	col := testGetCollection(t, provider)
+
+	err := col.CompareAndMutateIn("order", []LookupInSpec{GetSpec("status", nil)}, testStatusIsPending,
+		[]MutateInSpec{ReplaceSpec("status", "shipped", nil)}, &MutateInOptions{
+			Cas: 1,
+		})
+	if err != nil {
+		t.Fatalf("Expected CompareAndMutateIn to succeed after retrying but was %v", err)
+	}
+
+	if len(provider.lookupIns) != 2 {
+		t.Fatalf("Expected checks to be performed again after the CAS changed but were performed %d times",
+			len(provider.lookupIns))
+	}
+
+	if provider.store.docs["order"].cas != 12 || testDocStatus(t, provider, "order") != "shipped" {
+		t.Fatalf("Expected document to be mutated once but cas was %d", provider.store.docs["order"].cas)
+	}
+}
+
+func TestCompareAndMutateInRetriesLimited(t *testing.T) {
+	provider := testStatusProvider("pending")
+	provider.store.beforeMutate = func() {
+		// Simulate another writer touching the document between every check and mutation.
+		provider.store.docs["order"].cas++
+	}
+	col := testGetCollection(t, provider)
+
+	err := col.CompareAndMutateIn("order", []LookupInSpec{GetSpec("status", nil)}, testStatusIsPending,
+		[]MutateInSpec{ReplaceSpec("status", "shipped", nil)}, nil)
+	if !IsCasMismatchError(err) {
+		t.Fatalf("Expected error to be CAS mismatch but was %v", err)
+	}
+
+	if len(provider.lookupIns) != maxCasMismatchRetries+1 {
+		t.Fatalf("Expected checks to be performed %d times but were performed %d times", maxCasMismatchRetries+1,
+			len(provider.lookupIns))
+	}
+
+	if testDocStatus(t, provider, "order") != "pending" {
+		t.Fatalf("Expected document to not be mutated")
+	}
+}
+
+func TestMutateInRawMessageValue(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 1, value: []byte(`{"list":[]}`)}})
+	col := testGetCollection(t, provider)
+
+	raw := json.RawMessage(`{ "name" : "beer", "abv": 5.0 }`)
+	_, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("doc", raw, nil),
+		ArrayAppendSpec("list", json.RawMessage(` [1, "two"] `), &ArrayAppendSpecOptions{HasMultiple: true}),
+		UpsertSpec("count", 3, nil),
+	}, &MutateInOptions{
+		Serializer: &DefaultJSONSerializer{},
+	})
+	if err != nil {
+		t.Fatalf("Expected MutateIn to succeed but was %v", err)
+	}
+
+	expected := []string{`{ "name" : "beer", "abv": 5.0 }`, `1, "two"`, `3`}
+	ops := provider.mutateIns[0].Ops
+	if len(ops) != len(expected) {
+		t.Fatalf("Expected %d ops to be dispatched but was %d", len(expected), len(ops))
+	}
+
+	for i, value := range expected {
+		if string(ops[i].Value) != value {
+			t.Fatalf("Expected op %d value to be %s but was %s", i, value, ops[i].Value)
+		}
+	}
+
+	expectedDoc := `{"count":3,"doc":{"abv":5.0,"name":"beer"},"list":[1,"two"]}`
+	if string(provider.store.docs["key"].value) != expectedDoc {
+		t.Fatalf("Expected document to be %s but was %s", expectedDoc, provider.store.docs["key"].value)
+	}
+}
+
+func TestMutateInRawMessageSkipsSerializer(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 1, value: []byte(`{"list":[]}`)}})
+	col := testGetCollection(t, provider)
+
+	_, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("doc", json.RawMessage(`"value"`), nil),
+	}, &MutateInOptions{
+		Serializer: &MockSerializer{err: errors.New("serializer should not be used")},
+	})
+	if err != nil {
+		t.Fatalf("Expected MutateIn to succeed but was %v", err)
+	}
+
+	if value := provider.mutateIns[0].Ops[0].Value; string(value) != `"value"` {
+		t.Fatalf("Expected value to be sent verbatim but was %s", value)
+	}
+
+	_, err = col.MutateIn("key", []MutateInSpec{
+		ArrayAppendSpec("list", json.RawMessage(`{"a":1}`), &ArrayAppendSpecOptions{HasMultiple: true}),
+	}, nil)
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
+func TestLookupInExpiry(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 1, value: []byte(`{"a":1}`), expiry: 1577836800}})
+	col := testGetCollection(t, provider)
+
+	res, err := col.LookupIn("key", []LookupInSpec{
+		GetSpec("a", nil),
+		GetSpec("$document.exptime", &GetSpecOptions{IsXattr: true}),
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected LookupIn to succeed but was %v", err)
+	}
+
+	expiry, ok := res.Expiry()
+	if !ok {
+		t.Fatalf("Expected document to have an expiry")
+	}
+
+	expected := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !expiry.Equal(expected) {
+		t.Fatalf("Expected expiry to be %v but was %v", expected, expiry)
+	}
+}
+
+func TestLookupInExpiryNoExpiry(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 1, value: []byte(`{"a":1}`)}})
+	col := testGetCollection(t, provider)
+
+	res, err := col.LookupIn("key", []LookupInSpec{
+		GetSpec("$document.exptime", &GetSpecOptions{IsXattr: true}),
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected LookupIn to succeed but was %v", err)
+	}
+
+	expiry, ok := res.Expiry()
+	if ok {
+		t.Fatalf("Expected document with exptime 0 to have no expiry but was %v", expiry)
+	}
+
+	if !expiry.IsZero() {
+		t.Fatalf("Expected expiry to be the zero time but was %v", expiry)
+	}
+}
+
+func TestLookupInExpiryNotRequested(t *testing.T) {
+	provider := testStoreProvider(map[string]*mockDocument{"key": {cas: 1, value: []byte(`{"a":1}`), expiry: 1577836800}})
+	col := testGetCollection(t, provider)
+
+	res, err := col.LookupIn("key", []LookupInSpec{
+		GetSpec("a", nil),
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected LookupIn to succeed but was %v", err)
+	}
+
+	if _, ok := res.Expiry(); ok {
+		t.Fatalf("Expected no expiry when $document.exptime was not looked up")
+	}
+}
+
+// testExpiryProvider returns a provider backed by a store holding documents key and order with an expiry.
+func testExpiryProvider() *mockKvProvider {
+	return testStoreProvider(map[string]*mockDocument{
+		"key":   {cas: 10, value: []byte(`{"status":"pending"}`), expiry: 1893456000},
+		"order": {cas: 10, value: []byte(`{"status":"pending"}`), expiry: 1893456000},
+	})
+}
+
+func TestMutateInSetExpiry(t *testing.T) {
+	provider := testExpiryProvider()
+	col := testGetCollection(t, provider)
+
+	_, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("status", "shipped", nil),
+	}, &MutateInOptions{
+		Expiry: 60,
+	})
+	if err != nil {
+		t.Fatalf("Expected MutateIn to succeed but was %v", err)
+	}
+
+	if len(provider.lookupIns) != 0 {
+		t.Fatalf("Expected no lookup when setting the expiry but was %d", len(provider.lookupIns))
+	}
+
+	if expiry := provider.store.docs["key"].expiry; expiry != 60 {
+		t.Fatalf("Expected expiry to be 60 but was %d", expiry)
+	}
+}
+
+func TestMutateInClearExpiry(t *testing.T) {
+	provider := testExpiryProvider()
+	col := testGetCollection(t, provider)
+
+	_, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("status", "shipped", nil),
+	}, &MutateInOptions{
+		ExpirySet: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected MutateIn to succeed but was %v", err)
+	}
+
+	if len(provider.lookupIns) != 0 {
+		t.Fatalf("Expected no lookup when clearing the expiry but was %d", len(provider.lookupIns))
+	}
+
+	if expiry := provider.store.docs["key"].expiry; expiry != 0 {
+		t.Fatalf("Expected expiry to be cleared but was %d", expiry)
+	}
+}
+
+func TestMutateInNoExpirySingleRequest(t *testing.T) {
+	provider := testExpiryProvider()
+	col := testGetCollection(t, provider)
+
+	_, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("status", "shipped", nil),
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected MutateIn to succeed but was %v", err)
+	}
+
+	if len(provider.lookupIns) != 0 {
+		t.Fatalf("Expected no lookup without PreserveExpiry but was %d", len(provider.lookupIns))
+	}
+
+	if len(provider.mutateIns) != 1 || provider.mutateIns[0].Cas != 0 || provider.mutateIns[0].Expiry != 0 {
+		t.Fatalf("Expected a single mutation without CAS or expiry but was %v", provider.mutateIns)
+	}
+}
+
+func TestMutateInPreserveExpiry(t *testing.T) {
+	provider := testExpiryProvider()
+	col := testGetCollection(t, provider)
+
+	_, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("status", "shipped", nil),
+	}, &MutateInOptions{
+		PreserveExpiry: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected MutateIn to succeed but was %v", err)
+	}
+
+	if expiry := provider.store.docs["key"].expiry; expiry != 1893456000 {
+		t.Fatalf("Expected expiry to be left at 1893456000 but was %d", expiry)
+	}
+
+	if len(provider.mutateIns) != 1 || provider.mutateIns[0].Cas != 10 {
+		t.Fatalf("Expected mutation to be made against the CAS of the expiry lookup but was %v", provider.mutateIns)
+	}
+}
+
+func TestMutateInPreserveExpiryCasChanged(t *testing.T) {
+	provider := testExpiryProvider()
+	changed := false
+	provider.store.beforeMutate = func() {
+		// Simulate another writer changing the expiry between the lookup and mutation.
+		if !changed {
+			changed = true
+			provider.store.docs["key"].cas++
+			provider.store.docs["key"].expiry = 1924992000
+		}
+	}
+	col := testGetCollection(t, provider)
+
+	_, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("status", "shipped", nil),
+	}, &MutateInOptions{
+		PreserveExpiry: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected MutateIn to succeed after retrying but was %v", err)
+	}
+
+	if len(provider.lookupIns) != 2 {
+		t.Fatalf("Expected expiry to be looked up again after the CAS changed but was looked up %d times",
+			len(provider.lookupIns))
+	}
+
+	if expiry := provider.store.docs["key"].expiry; expiry != 1924992000 {
+		t.Fatalf("Expected the changed expiry of 1924992000 to be left but was %d", expiry)
+	}
+}
+
+func TestMutateInPreserveExpiryRetriesLimited(t *testing.T) {
+	provider := testExpiryProvider()
+	provider.store.beforeMutate = func() {
+		// Simulate another writer changing the document between every lookup and mutation.
+		provider.store.docs["key"].cas++
+	}
+	col := testGetCollection(t, provider)
+
+	_, err := col.MutateIn("key", []MutateInSpec{
+		UpsertSpec("status", "shipped", nil),
+	}, &MutateInOptions{
+		PreserveExpiry: true,
+	})
+	if !IsCasMismatchError(err) {
+		t.Fatalf("Expected error to be CAS mismatch but was %v", err)
+	}
+
+	if len(provider.lookupIns) != maxCasMismatchRetries+1 {
+		t.Fatalf("Expected expiry to be looked up %d times but was %d", maxCasMismatchRetries+1, len(provider.lookupIns))
+	}
+}
+
+func TestCompareAndMutateInLeavesExpiryUnchanged(t *testing.T) {
+	provider := testExpiryProvider()
+	col := testGetCollection(t, provider)
+
+	err := col.CompareAndMutateIn("order", []LookupInSpec{GetSpec("status", nil)}, testStatusIsPending,
+		[]MutateInSpec{ReplaceSpec("status", "shipped", nil)}, &MutateInOptions{
+			PreserveExpiry: true,
+		})
+	if err != nil {
+		t.Fatalf("Expected CompareAndMutateIn to succeed but was %v", err)
+	}
+
+	if len(provider.lookupIns) != 1 {
+		t.Fatalf("Expected expiry to be looked up alongside the checks but was %d lookups", len(provider.lookupIns))
+	}
+
+	if expiry := provider.store.docs["order"].expiry; expiry != 1893456000 {
+		t.Fatalf("Expected expiry to be left at 1893456000 but was %d", expiry)
+	}
+}
+
+// testLogProvider returns a provider backed by a store holding document key with the given value.
+func testLogProvider(value string) *mockKvProvider {
+	return testStoreProvider(map[string]*mockDocument{"key": {cas: 10, value: []byte(value)}})
+}
+
+// testDocLog returns the log array of a document held by the store of provider.
+func testDocLog(t *testing.T, provider *mockKvProvider) []string {
+	var doc struct {
+		Log []string `json:"log"`
+	}
+	err := json.Unmarshal(provider.store.docs["key"].value, &doc)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal document: %v", err)
+	}
+
+	return doc.Log
+}
+
+func TestAppendToCappedArrayUnderCap(t *testing.T) {
+	provider := testLogProvider(`{"log":["a"]}`)
+	col := testGetCollection(t, provider)
+
+	err := col.AppendToCappedArray("key", "log", "b", 3, nil)
+	if err != nil {
+		t.Fatalf("Expected AppendToCappedArray to succeed but was %v", err)
+	}
+
+	if ops := provider.mutateIns[0].Ops; len(ops) != 1 || ops[0].Op != gocbcore.SubDocOpArrayPushLast {
+		t.Fatalf("Expected only an append to be performed but was %v", ops)
+	}
+
+	if log := testDocLog(t, provider); !reflect.DeepEqual(log, []string{"a", "b"}) {
+		t.Fatalf("Expected log to be [a b] but was %v", log)
+	}
+}
+
+func TestAppendToCappedArrayCreatesArray(t *testing.T) {
+	provider := testLogProvider(`{}`)
+	col := testGetCollection(t, provider)
+
+	err := col.AppendToCappedArray("key", "log", "a", 3, nil)
+	if err != nil {
+		t.Fatalf("Expected AppendToCappedArray to succeed but was %v", err)
+	}
+
+	if ops := provider.mutateIns[0].Ops; len(ops) != 1 || ops[0].Flags&gocbcore.SubdocFlagMkDirP == 0 {
+		t.Fatalf("Expected an append creating the path to be performed but was %v", ops)
+	}
+
+	if log := testDocLog(t, provider); !reflect.DeepEqual(log, []string{"a"}) {
+		t.Fatalf("Expected log to be [a] but was %v", log)
+	}
+}
+
+func TestAppendToCappedArrayAtCap(t *testing.T) {
+	provider := testLogProvider(`{"log":["a","b","c"]}`)
+	col := testGetCollection(t, provider)
+
+	err := col.AppendToCappedArray("key", "log", "d", 3, nil)
+	if err != nil {
+		t.Fatalf("Expected AppendToCappedArray to succeed but was %v", err)
+	}
+
+	ops := provider.mutateIns[0].Ops
+	if len(ops) != 2 || ops[0].Op != gocbcore.SubDocOpDelete || ops[0].Path != "log[0]" {
+		t.Fatalf("Expected the front element to be removed alongside the append but was %v", ops)
+	}
+
+	if log := testDocLog(t, provider); !reflect.DeepEqual(log, []string{"b", "c", "d"}) {
+		t.Fatalf("Expected log to be [b c d] but was %v", log)
+	}
+}
+
+func TestAppendToCappedArrayCasChanged(t *testing.T) {
+	provider := testLogProvider(`{"log":["a"]}`)
+	changed := false
+	provider.store.beforeMutate = func() {
+		// Simulate another writer appending to the log between the count and mutation.
+		if !changed {
+			changed = true
+			doc := provider.store.docs["key"]
+			doc.cas++
+			doc.value = []byte(`{"log":["a","b"]}`)
+		}
+	}
+	col := testGetCollection(t, provider)
+
+	err := col.AppendToCappedArray("key", "log", "c", 2, nil)
+	if err != nil {
+		t.Fatalf("Expected AppendToCappedArray to succeed after retrying but was %v", err)
+	}
+
+	if len(provider.lookupIns) != 2 {
+		t.Fatalf("Expected the array to be counted again after the CAS changed but was counted %d times",
+			len(provider.lookupIns))
+	}
+
+	if log := testDocLog(t, provider); !reflect.DeepEqual(log, []string{"b", "c"}) {
+		t.Fatalf("Expected log to be [b c] but was %v", log)
+	}
+}
+
+func TestAppendToCappedArrayInvalidMaxLen(t *testing.T) {
+	col := testGetCollection(t, &mockKvProvider{})
+
+	err := col.AppendToCappedArray("key", "log", "a", 0, nil)
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
@@ -1,7 +1,10 @@
 package gocb
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"sort"
 	"strings"
 	"time"
 
@@ -28,6 +31,11 @@ type AuthenticationError interface {
 	AuthenticationError() bool
 }
 
+// AuthorizationError represents an error caused by the user not having the role needed to perform an operation.
+type AuthorizationError interface {
+	AuthorizationError() bool
+}
+
 // TemporaryFailureError represents an error that is temporary.
 type TemporaryFailureError interface {
 	TemporaryFailureError() bool
@@ -229,6 +237,94 @@ func (err timeoutError) Operation() string {
 	return err.operation
 }
 
+// CancelledError occurs when an operation is cancelled by the caller before it completes, e.g. by cancelling the
+// context passed in its options.
+type CancelledError interface {
+	Cancelled() bool
+}
+
+type operationCancelledError struct {
+	operationID   string
+	retryReasons  []gocbcore.RetryReason
+	retryAttempts uint32
+	operation     string
+}
+
+func (err operationCancelledError) Error() string {
+	base := "operation cancelled"
+	if err.operationID != "" {
+		base = fmt.Sprintf("%s, lastOperationID: %s", base, err.operationID)
+	}
+	if err.retryAttempts > 0 {
+		base = fmt.Sprintf("%s, retried: %d", base, err.retryAttempts)
+	}
+	if len(err.retryReasons) > 0 {
+		var reasons []string
+		for _, reason := range err.retryReasons {
+			reasons = append(reasons, reason.Description())
+		}
+		base = fmt.Sprintf("%s, retryReasons: [%s]", base, strings.Join(reasons, ","))
+	}
+	if err.operation != "" {
+		base = fmt.Sprintf("%s, operation: %s", base, err.operation)
+	}
+
+	return base
+}
+
+func (err operationCancelledError) Cancelled() bool {
+	return true
+}
+
+func (err operationCancelledError) OperationID() string {
+	return err.operationID
+}
+
+func (err operationCancelledError) RetryAttempts() uint32 {
+	return err.retryAttempts
+}
+
+func (err operationCancelledError) RetryReasons() []RetryReason {
+	var reasons []RetryReason
+	for _, reason := range err.retryReasons {
+		reasons = append(reasons, RetryReason(reason))
+	}
+	return reasons
+}
+
+func (err operationCancelledError) Operation() string {
+	return err.operation
+}
+
+// WaitUntilReadyTimeoutError occurs when services are still not ready once a WaitUntilReady times out.
+type WaitUntilReadyTimeoutError interface {
+	TimeoutError
+	UnreadyServices() []ServiceType
+}
+
+type waitUntilReadyTimeoutError struct {
+	unreadyServices []ServiceType
+}
+
+func (err waitUntilReadyTimeoutError) Error() string {
+	services := make([]string, len(err.unreadyServices))
+	for i, service := range err.unreadyServices {
+		services[i] = diagServiceString(service)
+	}
+
+	return fmt.Sprintf("timed out waiting for services to become ready, unready services: [%s]",
+		strings.Join(services, ","))
+}
+
+func (err waitUntilReadyTimeoutError) Timeout() bool {
+	return true
+}
+
+// UnreadyServices returns the services which were still not ready when the wait timed out.
+func (err waitUntilReadyTimeoutError) UnreadyServices() []ServiceType {
+	return err.unreadyServices
+}
+
 type serviceNotAvailableError struct {
 	message string
 }
@@ -237,6 +333,58 @@ func (e serviceNotAvailableError) Error() string {
 	return e.message
 }
 
+// NonJSONResponseError occurs when a service responds with a body which isn't JSON, such as an error page served by
+// a misconfigured proxy.
+type NonJSONResponseError interface {
+	error
+	NonJSONResponse() bool
+	HTTPStatus() int
+	ContentType() string
+	Snippet() string
+}
+
+type nonJSONResponseError struct {
+	httpStatus  int
+	contentType string
+	snippet     string
+	endpoint    string
+}
+
+func (err nonJSONResponseError) Error() string {
+	return fmt.Sprintf("received a non-JSON response from %s with status code %d and content type %s: %s",
+		err.endpoint, err.httpStatus, err.contentType, err.snippet)
+}
+
+func (err nonJSONResponseError) NonJSONResponse() bool {
+	return true
+}
+
+// HTTPStatus returns the HTTP status code of the response.
+func (err nonJSONResponseError) HTTPStatus() int {
+	return err.httpStatus
+}
+
+// ContentType returns the content type of the response, as detected from its body.
+func (err nonJSONResponseError) ContentType() string {
+	return err.contentType
+}
+
+// Snippet returns the start of the response body.
+func (err nonJSONResponseError) Snippet() string {
+	return err.snippet
+}
+
+// IsNonJSONResponseError verifies whether or not the cause for an error is a service responding with a body which
+// isn't JSON.
+func IsNonJSONResponseError(err error) bool {
+	switch errType := errors.Cause(err).(type) {
+	case NonJSONResponseError:
+		return errType.NonJSONResponse()
+	default:
+		return false
+	}
+}
+
 // InvalidIndexError occurs when an invalid index is specified on a LookupInResult.
 type InvalidIndexError interface {
 	InvalidIndex() bool
@@ -281,6 +429,17 @@ func IsAuthenticationError(err error) bool {
 	return false
 }
 
+// IsAuthorizationError verifies whether or not the cause for an error is the user not having the role needed for
+// the operation, the message of the error describes the permissions which are required.
+func IsAuthorizationError(err error) bool {
+	cause := errors.Cause(err)
+	if authErr, ok := cause.(AuthorizationError); ok && authErr.AuthorizationError() {
+		return true
+	}
+
+	return false
+}
+
 // IsServiceNotAvailableError indicates whether the passed error occurred due to
 // the requested service not being available.
 func IsServiceNotAvailableError(err error) bool {
@@ -302,6 +461,28 @@ func IsTimeoutError(err error) bool {
 	}
 }
 
+// IsRetriesExhaustedError verifies whether or not the cause for an error is an operation giving up after reaching
+// its maximum number of retries.
+func IsRetriesExhaustedError(err error) bool {
+	switch errType := errors.Cause(err).(type) {
+	case RetriesExhaustedError:
+		return errType.RetriesExhausted()
+	default:
+		return false
+	}
+}
+
+// IsCancelledError verifies whether or not the cause for an error is the operation being cancelled by the caller,
+// as opposed to timing out.
+func IsCancelledError(err error) bool {
+	switch errType := errors.Cause(err).(type) {
+	case CancelledError:
+		return errType.Cancelled()
+	default:
+		return false
+	}
+}
+
 // IsRetryableError indicates that the operation should be retried.
 func IsRetryableError(err error) bool {
 	switch errType := errors.Cause(err).(type) {
@@ -593,6 +774,66 @@ func IsSubdocPathExistsError(err error) bool {
 	return false
 }
 
+// IsSubdocPathTooDeepError verifies whether or not the cause for an error is due to a subdoc operation path being
+// too large or containing too many components.
+func IsSubdocPathTooDeepError(err error) bool {
+	cause := errors.Cause(err)
+	if kvErr, ok := cause.(KeyValueError); ok && kvErr.KeyValueError() {
+		return kvErr.StatusCode() == int(gocbcore.StatusSubDocPathTooBig)
+	}
+
+	return false
+}
+
+// IsSubdocValueTooDeepError verifies whether or not the cause for an error is due to a subdoc operation value
+// being too deeply nested to be inserted.
+func IsSubdocValueTooDeepError(err error) bool {
+	cause := errors.Cause(err)
+	if kvErr, ok := cause.(KeyValueError); ok && kvErr.KeyValueError() {
+		return kvErr.StatusCode() == int(gocbcore.StatusSubDocValueTooDeep)
+	}
+
+	return false
+}
+
+// IsSubdocDocTooDeepError verifies whether or not the cause for an error is due to the target document being
+// too deeply nested to be processed by a subdoc operation.
+func IsSubdocDocTooDeepError(err error) bool {
+	cause := errors.Cause(err)
+	if kvErr, ok := cause.(KeyValueError); ok && kvErr.KeyValueError() {
+		return kvErr.StatusCode() == int(gocbcore.StatusSubDocDocTooDeep)
+	}
+
+	return false
+}
+
+// SubdocMutateError occurs when one of the ops of a MutateIn fails, which causes the whole MutateIn to fail. The
+// cause of the error is the error for the failing op, so is checked with the usual predicates, e.g.
+// IsSubdocPathNotFoundError.
+type SubdocMutateError interface {
+	error
+	OpIndex() int
+}
+
+type subdocMutateError struct {
+	err     error
+	opIndex int
+}
+
+func (e subdocMutateError) Error() string {
+	return fmt.Sprintf("subdoc mutation failed at op index %d: %s", e.opIndex, e.err)
+}
+
+// Cause returns the error for the failing op.
+func (e subdocMutateError) Cause() error {
+	return e.err
+}
+
+// OpIndex returns the index of the failing op within the ops passed to MutateIn.
+func (e subdocMutateError) OpIndex() int {
+	return e.opIndex
+}
+
 // IsInvalidIndexError verifies whether or not the cause for an error is due to an invalid index being specified on
 // a LookupInResult
 func IsInvalidIndexError(err error) bool {
@@ -711,6 +952,17 @@ func IsDesignDocumentNotFoundError(err error) bool {
 	}
 }
 
+// IsDesignDocumentConflictError occurs when a design document is upserted with IfMatchRev but the revision of the
+// design document on the server no longer matches.
+func IsDesignDocumentConflictError(err error) bool {
+	switch errType := errors.Cause(err).(type) {
+	case ViewIndexesError:
+		return errType.DesignDocumentConflictError()
+	default:
+		return false
+	}
+}
+
 // IsDesignDocumentExistsError occurs when a specific design document already exists.
 func IsDesignDocumentExistsError(err error) bool {
 	switch errType := errors.Cause(err).(type) {
@@ -771,6 +1023,16 @@ func IsQueryIndexNotFoundError(err error) bool {
 	}
 }
 
+// IsKeyspaceNotFoundError verifies that the keyspace for a query index operation could not be found.
+func IsKeyspaceNotFoundError(err error) bool {
+	switch errType := errors.Cause(err).(type) {
+	case QueryIndexesError:
+		return errType.KeyspaceNotFoundError()
+	default:
+		return false
+	}
+}
+
 // IsAnalyticsIndexAlreadyExistsError verifies that an analytics index already exists.
 func IsAnalyticsIndexAlreadyExistsError(err error) bool {
 	switch errType := errors.Cause(err).(type) {
@@ -881,6 +1143,17 @@ func IsGroupNotFoundError(err error) bool {
 	}
 }
 
+// IsPasswordPolicyError verifies whether or not the cause for an error is a user password which does not meet the
+// password policy of the cluster.
+func IsPasswordPolicyError(err error) bool {
+	switch errType := errors.Cause(err).(type) {
+	case UserManagerError:
+		return errType.PasswordPolicyError()
+	default:
+		return false
+	}
+}
+
 // IsSearchIndexNotFoundError verifies that an index could not be found.
 func IsSearchIndexNotFoundError(err error) bool {
 	switch errType := errors.Cause(err).(type) {
@@ -975,6 +1248,10 @@ func (e projectionErrors) ProjectionErrors() bool {
 	return true
 }
 
+func (e projectionErrors) Errors() []KeyValueError {
+	return e.errors
+}
+
 func (e projectionErrors) Error() string {
 	var errs []string
 	for _, err := range e.errors {
@@ -983,19 +1260,59 @@ func (e projectionErrors) Error() string {
 	return strings.Join(errs, ", ")
 }
 
+// ViewErrorPhase indicates which phase of view execution an error originated in.
+type ViewErrorPhase string
+
+const (
+	// ViewErrorPhaseUnknown indicates that the phase could not be determined from the error.
+	ViewErrorPhaseUnknown = ViewErrorPhase("")
+
+	// ViewErrorPhaseMap indicates that the error originated in the map function.
+	ViewErrorPhaseMap = ViewErrorPhase("map")
+
+	// ViewErrorPhaseReduce indicates that the error originated in the reduce function.
+	ViewErrorPhaseReduce = ViewErrorPhase("reduce")
+)
+
+// viewErrorPhase determines the phase of an error from the text returned by the server, which names the function
+// which failed. Reduce is checked first as reduce errors can also mention the map results being reduced.
+func viewErrorPhase(texts ...string) ViewErrorPhase {
+	for _, text := range texts {
+		text = strings.ToLower(text)
+		if strings.Contains(text, "reduce") || strings.Contains(text, "reduction") {
+			return ViewErrorPhaseReduce
+		}
+	}
+
+	for _, text := range texts {
+		text = strings.ToLower(text)
+		if strings.Contains(text, "map_") || strings.Contains(text, "map function") || strings.Contains(text, "emit") {
+			return ViewErrorPhaseMap
+		}
+	}
+
+	return ViewErrorPhaseUnknown
+}
+
 // ViewQueryError is the error type for an error that occurs during view query execution.
 type ViewQueryError interface {
 	error
 	Reason() string
 	Message() string
+	Phase() ViewErrorPhase
+	Node() string
 }
 
 type viewError struct {
 	ErrorMessage string `json:"message"`
 	ErrorReason  string `json:"reason"`
+	ErrorNode    string `json:"from"`
 }
 
 func (e viewError) Error() string {
+	if e.ErrorNode != "" && e.ErrorMessage == "" {
+		return e.ErrorNode + " - " + e.ErrorReason
+	}
 	return e.ErrorMessage + " - " + e.ErrorReason
 }
 
@@ -1009,6 +1326,17 @@ func (e viewError) Message() string {
 	return e.ErrorMessage
 }
 
+// Node is the node that the error occurred on, if the error occurred on an individual node whilst gathering the
+// results.
+func (e viewError) Node() string {
+	return e.ErrorNode
+}
+
+// Phase is the phase of view execution that the error originated in, e.g. map or reduce.
+func (e viewError) Phase() ViewErrorPhase {
+	return viewErrorPhase(e.ErrorMessage, e.ErrorReason)
+}
+
 // ViewQueryErrors is a collection of one or more ViewQueryError that occurs for errors created by Couchbase Server
 // during View query execution.
 type ViewQueryErrors interface {
@@ -1064,6 +1392,7 @@ type analyticsQueryError struct {
 	httpStatus   int
 	endpoint     string
 	contextID    string
+	status       string
 }
 
 func (e analyticsQueryError) Error() string {
@@ -1081,6 +1410,20 @@ func (e analyticsQueryError) Message() string {
 }
 
 func (e analyticsQueryError) retryable() bool {
+	// A statement which failed to compile will fail the same way however many times it is sent.
+	if isAnalyticsCompilationCode(e.Code()) {
+		return false
+	}
+
+	// The response status takes precedence over the error code, a fatal request will never succeed whereas one
+	// which timed out may do.
+	switch e.status {
+	case "fatal":
+		return false
+	case "timeout":
+		return true
+	}
+
 	if e.Code() == 21002 || e.Code() == 23000 || e.Code() == 23003 || e.Code() == 23007 {
 		return true
 	}
@@ -1103,6 +1446,31 @@ func (e analyticsQueryError) ContextID() string {
 	return e.contextID
 }
 
+// analyticsFatalError occurs when the analytics service responds with a fatal status, retrying the request will not
+// cause it to succeed.
+type analyticsFatalError struct {
+	analyticsQueryError
+}
+
+func (e analyticsFatalError) retryable() bool {
+	return false
+}
+
+// Fatal indicates that the analytics service responded with a fatal status.
+func (e analyticsFatalError) Fatal() bool {
+	return true
+}
+
+// IsAnalyticsFatalError verifies whether the analytics service responded with a fatal status.
+func IsAnalyticsFatalError(err error) bool {
+	switch errType := errors.Cause(err).(type) {
+	case analyticsFatalError:
+		return errType.Fatal()
+	default:
+		return false
+	}
+}
+
 // QueryError occurs for errors created by Couchbase Server during N1ql query execution.
 type QueryError interface {
 	error
@@ -1154,6 +1522,54 @@ func (e queryError) retryable() bool {
 	return false
 }
 
+// analyticsPreparedStatementNotFoundCode is returned by the analytics service when asked to execute a prepared
+// statement handle that it does not know, e.g. because the node which prepared it has restarted.
+const analyticsPreparedStatementNotFoundCode = 24120
+
+// isAnalyticsPlanError verifies whether or not the cause for an error is the analytics service no longer knowing a
+// prepared statement.
+func isAnalyticsPlanError(err error) bool {
+	aErr, ok := errors.Cause(err).(AnalyticsQueryError)
+	if !ok {
+		return false
+	}
+
+	return aErr.Code() == analyticsPreparedStatementNotFoundCode
+}
+
+// isAnalyticsCompilationCode verifies whether an analytics error code is one of the 24000 range of codes returned
+// when the statement cannot be compiled. A prepared statement which the service no longer knows is reported in the
+// same range but is not a problem with the statement itself.
+func isAnalyticsCompilationCode(code uint32) bool {
+	return code >= 24000 && code < 25000 && code != analyticsPreparedStatementNotFoundCode
+}
+
+// IsAnalyticsCompilationError verifies whether or not the cause for an error is the analytics service failing to
+// compile the statement, e.g. due to a syntax error or it referring to a dataset which does not exist. Retrying the
+// statement will not cause it to succeed.
+func IsAnalyticsCompilationError(err error) bool {
+	switch errType := errors.Cause(err).(type) {
+	case analyticsQueryError:
+		return isAnalyticsCompilationCode(errType.Code())
+	case analyticsFatalError:
+		return isAnalyticsCompilationCode(errType.Code())
+	default:
+		return false
+	}
+}
+
+// isQueryPlanError verifies whether or not the cause for an error is the server no longer having a valid plan for
+// a prepared statement.
+func isQueryPlanError(err error) bool {
+	qErr, ok := errors.Cause(err).(QueryError)
+	if !ok {
+		return false
+	}
+
+	code := qErr.Code()
+	return code == 4040 || code == 4050 || code == 4070
+}
+
 // HTTPStatus returns the HTTP status code for the operation.
 func (e queryError) HTTPStatus() int {
 	return e.httpStatus
@@ -1280,6 +1696,177 @@ func (e noResultsError) NoResultsError() bool {
 	return true
 }
 
+// ComparisonFailedError occurs when a document does not match the expected state during a CompareAndMutateIn.
+type ComparisonFailedError interface {
+	error
+	ComparisonFailed() bool
+}
+
+type comparisonFailedError struct {
+	key string
+}
+
+func (e comparisonFailedError) Error() string {
+	return fmt.Sprintf("document %s did not match the expected state", e.key)
+}
+
+// ComparisonFailed indicates whether or not this error is a ComparisonFailedError.
+func (e comparisonFailedError) ComparisonFailed() bool {
+	return true
+}
+
+// IsComparisonFailedError verifies whether or not the cause for an error is that a document did not match the
+// expected state during a CompareAndMutateIn.
+func IsComparisonFailedError(err error) bool {
+	switch errType := errors.Cause(err).(type) {
+	case ComparisonFailedError:
+		return errType.ComparisonFailed()
+	default:
+		return false
+	}
+}
+
+// InvalidRolesError occurs when validating a user against the roles supported by the cluster fails.
+type InvalidRolesError interface {
+	error
+	InvalidRoles() []Role
+}
+
+type invalidRolesError struct {
+	roles []Role
+}
+
+func (e invalidRolesError) Error() string {
+	roleStrs := make([]string, len(e.roles))
+	for i, role := range e.roles {
+		if role.Bucket == "" {
+			roleStrs[i] = role.Name
+		} else {
+			roleStrs[i] = fmt.Sprintf("%s[%s]", role.Name, role.Bucket)
+		}
+	}
+
+	return "invalid roles: " + strings.Join(roleStrs, ", ")
+}
+
+// InvalidRoles returns the roles which are unknown or were not applied to a bucket correctly.
+func (e invalidRolesError) InvalidRoles() []Role {
+	return e.roles
+}
+
+// InvalidArgumentsError indicates that invalid arguments were provided to an operation.
+func (e invalidRolesError) InvalidArgumentsError() bool {
+	return true
+}
+
+// RetriesExhaustedError occurs when an operation fails after being retried as many times as it was allowed to,
+// before its timeout was reached.
+type RetriesExhaustedError interface {
+	error
+	RetriesExhausted() bool
+	RetryAttempts() uint32
+	LastError() error
+}
+
+type retriesExhaustedError struct {
+	retryAttempts uint32
+	lastErr       error
+	operation     string
+}
+
+func (e retriesExhaustedError) Error() string {
+	return fmt.Sprintf("%s operation failed after %d retries: %s", e.operation, e.retryAttempts, e.lastErr)
+}
+
+// RetriesExhausted indicates that the operation stopped retrying because it reached its maximum number of retries.
+func (e retriesExhaustedError) RetriesExhausted() bool {
+	return true
+}
+
+// RetryAttempts returns the number of times that the operation was retried.
+func (e retriesExhaustedError) RetryAttempts() uint32 {
+	return e.retryAttempts
+}
+
+// LastError returns the error from the final attempt of the operation.
+func (e retriesExhaustedError) LastError() error {
+	return e.lastErr
+}
+
+// ExistsMultiError occurs when the existence of one or more keys in an ExistsMulti operation could not be
+// determined. Keys which are present in Errors are not present in the results.
+type ExistsMultiError interface {
+	error
+	Errors() map[string]error
+}
+
+type existsMultiError struct {
+	errors map[string]error
+}
+
+func (e existsMultiError) Error() string {
+	return fmt.Sprintf("failed to check existence of %d keys", len(e.errors))
+}
+
+// Errors returns the error which occurred for each key that failed.
+func (e existsMultiError) Errors() map[string]error {
+	return e.errors
+}
+
+// CreateQueryIndexesError occurs when one or more indexes in a CreateIndexes operation could not be created.
+// Indexes which are not present in Errors were created successfully.
+type CreateQueryIndexesError interface {
+	error
+	Errors() map[string]error
+}
+
+type createQueryIndexesError struct {
+	errors map[string]error
+}
+
+func (e createQueryIndexesError) Error() string {
+	names := make([]string, 0, len(e.errors))
+	for name := range e.errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return fmt.Sprintf("failed to create %d indexes: %s", len(e.errors), strings.Join(names, ", "))
+}
+
+// Errors returns the error which occurred for each index that failed, keyed by index name. A primary index
+// created without a name is keyed as #primary.
+func (e createQueryIndexesError) Errors() map[string]error {
+	return e.errors
+}
+
+// BuildDeferredQueryIndexesError occurs when the deferred indexes on one or more keyspaces in a BuildDeferredIndexes
+// operation could not be built. Indexes on keyspaces which are not present in Errors were built successfully.
+type BuildDeferredQueryIndexesError interface {
+	error
+	Errors() map[string]error
+}
+
+type buildDeferredQueryIndexesError struct {
+	errors map[string]error
+}
+
+func (e buildDeferredQueryIndexesError) Error() string {
+	keyspaces := make([]string, 0, len(e.errors))
+	for keyspace := range e.errors {
+		keyspaces = append(keyspaces, keyspace)
+	}
+	sort.Strings(keyspaces)
+
+	return fmt.Sprintf("failed to build deferred indexes on %d keyspaces: %s", len(e.errors),
+		strings.Join(keyspaces, ", "))
+}
+
+// Errors returns the error which occurred for each keyspace that failed, keyed by keyspace.
+func (e buildDeferredQueryIndexesError) Errors() map[string]error {
+	return e.errors
+}
+
 // ViewIndexesError occurs for errors created By Couchbase Server when performing index management.
 type ViewIndexesError interface {
 	error
@@ -1287,6 +1874,7 @@ type ViewIndexesError interface {
 	DesignDocumentNotFoundError() bool
 	DesignDocumentExistsError() bool
 	DesignDocumentPublishDropFailError() bool
+	DesignDocumentConflictError() bool
 }
 
 type viewIndexError struct {
@@ -1295,6 +1883,7 @@ type viewIndexError struct {
 	indexMissing    bool
 	indexExists     bool
 	publishDropFail bool
+	revConflict     bool
 }
 
 func (e viewIndexError) Error() string {
@@ -1306,6 +1895,16 @@ func (e viewIndexError) HTTPStatus() int {
 	return e.statusCode
 }
 
+// AuthenticationError indicates that the credentials used for the operation were rejected.
+func (e viewIndexError) AuthenticationError() bool {
+	return isMgmtAuthenticationStatus(e.statusCode)
+}
+
+// AuthorizationError indicates that the user lacks the role required for the operation.
+func (e viewIndexError) AuthorizationError() bool {
+	return isMgmtAuthorizationStatus(e.statusCode)
+}
+
 // DesignDocumentNotFoundError indicates that a design document could not be found.
 func (e viewIndexError) DesignDocumentNotFoundError() bool {
 	return e.indexMissing
@@ -1321,6 +1920,11 @@ func (e viewIndexError) DesignDocumentPublishDropFailError() bool {
 	return e.publishDropFail
 }
 
+// DesignDocumentConflictError indicates that a design document was changed by someone else since it was retrieved.
+func (e viewIndexError) DesignDocumentConflictError() bool {
+	return e.revConflict
+}
+
 func (e viewIndexError) FeatureNotFoundError() bool {
 	return e.statusCode == 404 && e.message == "Not Found."
 }
@@ -1336,17 +1940,32 @@ type BucketManagerError interface {
 type bucketManagerError struct {
 	statusCode int
 	message    string
+	idempotent bool
 }
 
 func (e bucketManagerError) Error() string {
 	return e.message
 }
 
+func (e bucketManagerError) retryable() bool {
+	return e.idempotent && isRetryableMgmtStatus(e.statusCode)
+}
+
 // HTTPStatus returns the HTTP status code for the operation.
 func (e bucketManagerError) HTTPStatus() int {
 	return e.statusCode
 }
 
+// AuthenticationError indicates that the credentials used for the operation were rejected.
+func (e bucketManagerError) AuthenticationError() bool {
+	return isMgmtAuthenticationStatus(e.statusCode)
+}
+
+// AuthorizationError indicates that the user lacks the role required for the operation.
+func (e bucketManagerError) AuthorizationError() bool {
+	return isMgmtAuthorizationStatus(e.statusCode)
+}
+
 // BucketNotFoundError indicates that a bucket could not be found.
 func (e bucketManagerError) BucketNotFoundError() bool {
 	return e.statusCode == 404 && strings.Contains(e.message, "Requested resource not found")
@@ -1368,12 +1987,15 @@ type QueryIndexesError interface {
 	QueryIndexNotFoundError() bool
 	QueryIndexExistsError() bool
 	BucketNotFoundError() bool
+	KeyspaceNotFoundError() bool
 }
 
 type queryIndexError struct {
-	statusCode   int
-	message      string
-	indexMissing bool
+	statusCode      int
+	message         string
+	indexMissing    bool
+	indexExists     bool
+	keyspaceMissing bool
 }
 
 func (e queryIndexError) Error() string {
@@ -1385,6 +2007,16 @@ func (e queryIndexError) HTTPStatus() int {
 	return e.statusCode
 }
 
+// AuthenticationError indicates that the credentials used for the operation were rejected.
+func (e queryIndexError) AuthenticationError() bool {
+	return isMgmtAuthenticationStatus(e.statusCode)
+}
+
+// AuthorizationError indicates that the user lacks the role required for the operation.
+func (e queryIndexError) AuthorizationError() bool {
+	return isMgmtAuthorizationStatus(e.statusCode)
+}
+
 // Code returns the analytics error for the error.
 func (e queryIndexError) Code() int {
 	return e.statusCode
@@ -1397,12 +2029,17 @@ func (e queryIndexError) QueryIndexNotFoundError() bool {
 
 // QueryIndexExistsError indicates that an index already exists.
 func (e queryIndexError) QueryIndexExistsError() bool {
-	return e.statusCode == 409 && strings.Contains(strings.ToLower(e.message), "already exists")
+	return e.indexExists || (e.statusCode == 409 && strings.Contains(strings.ToLower(e.message), "already exists"))
 }
 
 // BucketNotFoundError indicates that a bucket with a given name could not be found.
 func (e queryIndexError) BucketNotFoundError() bool {
-	return e.statusCode == 500 && strings.Contains(strings.ToLower(e.message), "no bucket named")
+	return e.keyspaceMissing || (e.statusCode == 500 && strings.Contains(strings.ToLower(e.message), "no bucket named"))
+}
+
+// KeyspaceNotFoundError indicates that the keyspace the index is on could not be found.
+func (e queryIndexError) KeyspaceNotFoundError() bool {
+	return e.keyspaceMissing
 }
 
 func (e queryIndexError) FeatureNotFoundError() bool {
@@ -1415,21 +2052,37 @@ type UserManagerError interface {
 	HTTPStatus() int
 	UserNotFoundError() bool
 	GroupNotFoundError() bool
+	PasswordPolicyError() bool
 }
 
 type userManagerError struct {
 	statusCode int
 	message    string
+	idempotent bool
 }
 
 func (e userManagerError) Error() string {
 	return e.message
 }
 
+func (e userManagerError) retryable() bool {
+	return e.idempotent && isRetryableMgmtStatus(e.statusCode)
+}
+
 func (e userManagerError) HTTPStatus() int {
 	return e.statusCode
 }
 
+// AuthenticationError indicates that the credentials used for the operation were rejected.
+func (e userManagerError) AuthenticationError() bool {
+	return isMgmtAuthenticationStatus(e.statusCode)
+}
+
+// AuthorizationError indicates that the user lacks the role required for the operation.
+func (e userManagerError) AuthorizationError() bool {
+	return isMgmtAuthorizationStatus(e.statusCode)
+}
+
 // UserNotFoundError indicates that a specified user could not be found.
 func (e userManagerError) UserNotFoundError() bool {
 	if strings.Contains(strings.ToLower(e.message), "unknown user.") {
@@ -1448,6 +2101,27 @@ func (e userManagerError) GroupNotFoundError() bool {
 	return false
 }
 
+// PasswordPolicyError indicates that the password given for a user does not meet the password policy of the cluster,
+// e.g. it is too short or is missing a required character class.
+func (e userManagerError) PasswordPolicyError() bool {
+	if e.statusCode != 400 {
+		return false
+	}
+
+	// RBAC validation failures are reported as a map of the invalid field to the reason, e.g.
+	// {"errors":{"password":"The password must be at least 6 characters long."}}
+	var validationErr struct {
+		Errors map[string]string `json:"errors"`
+	}
+	err := json.Unmarshal([]byte(e.message), &validationErr)
+	if err != nil {
+		return false
+	}
+
+	_, ok := validationErr.Errors["password"]
+	return ok
+}
+
 func (e userManagerError) FeatureNotFoundError() bool {
 	return e.statusCode == 404 && e.message == "Not Found."
 }
@@ -1480,6 +2154,16 @@ func (e analyticsIndexesError) HTTPStatus() int {
 	return e.statusCode
 }
 
+// AuthenticationError indicates that the credentials used for the operation were rejected.
+func (e analyticsIndexesError) AuthenticationError() bool {
+	return isMgmtAuthenticationStatus(e.statusCode)
+}
+
+// AuthorizationError indicates that the user lacks the role required for the operation.
+func (e analyticsIndexesError) AuthorizationError() bool {
+	return isMgmtAuthorizationStatus(e.statusCode)
+}
+
 // AnalyticsIndexNotFoundError indicates that a specified analytics index could not be found.
 func (e analyticsIndexesError) AnalyticsIndexNotFoundError() bool {
 	if strings.Contains(strings.ToLower(e.message), "cannot find index") {
@@ -1570,6 +2254,16 @@ func (e searchIndexError) HTTPStatus() int {
 	return e.statusCode
 }
 
+// AuthenticationError indicates that the credentials used for the operation were rejected.
+func (e searchIndexError) AuthenticationError() bool {
+	return isMgmtAuthenticationStatus(e.statusCode)
+}
+
+// AuthorizationError indicates that the user lacks the role required for the operation.
+func (e searchIndexError) AuthorizationError() bool {
+	return isMgmtAuthorizationStatus(e.statusCode)
+}
+
 // Code returns the analytics error for the error.
 func (e searchIndexError) Code() int {
 	return e.statusCode
@@ -1603,6 +2297,9 @@ func maybeEnhanceKVErr(err error, key string, isInsertOp bool) error {
 			name:        errType.Name,
 			isInsertOp:  isInsertOp,
 		}
+	case gocbcore.SubDocMutateError:
+		// Mutations report the status of the first failing op, keep hold of which op it was.
+		return subdocMutateError{err: maybeEnhanceKVErr(errType.Err, key, isInsertOp), opIndex: errType.OpIndex}
 	default:
 	}
 
@@ -1613,6 +2310,150 @@ func maybeEnhanceKVErr(err error, key string, isInsertOp bool) error {
 	return err
 }
 
+// mgmtHTTPError is returned by decodeMgmtError when a management response has an unexpected status code, managers
+// convert it into their own typed error.
+type mgmtHTTPError struct {
+	statusCode int
+	message    string
+}
+
+func (e mgmtHTTPError) Error() string {
+	return e.message
+}
+
+func (e mgmtHTTPError) retryable() bool {
+	return isRetryableMgmtStatus(e.statusCode)
+}
+
+func (e mgmtHTTPError) AuthenticationError() bool {
+	return isMgmtAuthenticationStatus(e.statusCode)
+}
+
+func (e mgmtHTTPError) AuthorizationError() bool {
+	return isMgmtAuthorizationStatus(e.statusCode)
+}
+
+// isRetryableMgmtStatus returns whether a management response status indicates a transient server side failure
+// which should be retried, any 5xx. A 4xx indicates a problem with the request itself so is never retried.
+func isRetryableMgmtStatus(statusCode int) bool {
+	return statusCode >= 500 && statusCode < 600
+}
+
+// isMgmtAuthenticationStatus returns whether a management response status indicates that the credentials were
+// rejected, a 401.
+func isMgmtAuthenticationStatus(statusCode int) bool {
+	return statusCode == 401
+}
+
+// isMgmtAuthorizationStatus returns whether a management response status indicates that the credentials were valid
+// but lack the required role, a 403.
+func isMgmtAuthorizationStatus(statusCode int) bool {
+	return statusCode == 403
+}
+
+// decodeMgmtError checks the status code of a management response against expectedStatuses, or any 2xx status if
+// none are given. If the status is unexpected then the body is read and closed and returned as a mgmtHTTPError.
+func decodeMgmtError(resp *gocbcore.HttpResponse, expectedStatuses ...int) error {
+	if len(expectedStatuses) == 0 {
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+	}
+	for _, status := range expectedStatuses {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	closeErr := resp.Body.Close()
+	if closeErr != nil {
+		logDebugf("Failed to close socket (%s)", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	return mgmtHTTPError{
+		statusCode: resp.StatusCode,
+		message:    string(data),
+	}
+}
+
+// makeBucketManagerError converts a mgmtHTTPError for req into a bucketManagerError, which is only retryable when req
+// is idempotent.
+func makeBucketManagerError(req *gocbcore.HttpRequest, err error) error {
+	if mErr, ok := err.(mgmtHTTPError); ok {
+		return bucketManagerError{statusCode: mErr.statusCode, message: mErr.message, idempotent: req.IsIdempotent}
+	}
+
+	return err
+}
+
+// makeUserManagerError converts a mgmtHTTPError for req into a userManagerError, which is only retryable when req is
+// idempotent.
+func makeUserManagerError(req *gocbcore.HttpRequest, err error) error {
+	if mErr, ok := err.(mgmtHTTPError); ok {
+		return userManagerError{statusCode: mErr.statusCode, message: mErr.message, idempotent: req.IsIdempotent}
+	}
+
+	return err
+}
+
+func makeViewIndexError(err error, notFoundIsMissing bool) error {
+	if mErr, ok := err.(mgmtHTTPError); ok {
+		return viewIndexError{
+			statusCode:   mErr.statusCode,
+			message:      mErr.message,
+			indexMissing: notFoundIsMissing && mErr.statusCode == 404,
+		}
+	}
+
+	return err
+}
+
+// QuerySettingsError occurs for errors created By Couchbase Server when managing query service settings.
+type QuerySettingsError interface {
+	error
+	HTTPStatus() int
+}
+
+type querySettingsError struct {
+	statusCode int
+	message    string
+}
+
+func (e querySettingsError) Error() string {
+	return e.message
+}
+
+func (e querySettingsError) retryable() bool {
+	return isRetryableMgmtStatus(e.statusCode)
+}
+
+// HTTPStatus returns the HTTP status code for the operation.
+func (e querySettingsError) HTTPStatus() int {
+	return e.statusCode
+}
+
+// AuthenticationError indicates that the credentials used for the operation were rejected.
+func (e querySettingsError) AuthenticationError() bool {
+	return isMgmtAuthenticationStatus(e.statusCode)
+}
+
+// AuthorizationError indicates that the user lacks the role required for the operation.
+func (e querySettingsError) AuthorizationError() bool {
+	return isMgmtAuthorizationStatus(e.statusCode)
+}
+
+func makeQuerySettingsError(err error) error {
+	if mErr, ok := err.(mgmtHTTPError); ok {
+		return querySettingsError{statusCode: mErr.statusCode, message: mErr.message}
+	}
+
+	return err
+}
+
 // CollectionManagerError occurs for errors created By Couchbase Server when performing collection management.
 type CollectionManagerError interface {
 	error
@@ -1636,6 +2477,16 @@ func (e collectionMgrError) HTTPStatus() int {
 	return e.statusCode
 }
 
+// AuthenticationError indicates that the credentials used for the operation were rejected.
+func (e collectionMgrError) AuthenticationError() bool {
+	return isMgmtAuthenticationStatus(e.statusCode)
+}
+
+// AuthorizationError indicates that the user lacks the role required for the operation.
+func (e collectionMgrError) AuthorizationError() bool {
+	return isMgmtAuthorizationStatus(e.statusCode)
+}
+
 // CollectionNotFoundError indicates that a given collection could not be found.
 func (e collectionMgrError) CollectionNotFoundError() bool {
 	if e.statusCode == 404 {
@@ -1,7 +1,9 @@
 package gocb
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/couchbase/gocbcore/v8"
 )
@@ -75,3 +77,187 @@ func TestKVIsRetryable(t *testing.T) {
 		t.Fatalf("StatusTooBig error should not have been retryable")
 	}
 }
+
+func TestDecodeMgmtError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		expected []int
+		isErr    bool
+	}{
+		{name: "200 any 2xx", status: 200},
+		{name: "201 any 2xx", status: 201},
+		{name: "204 any 2xx", status: 204},
+		{name: "404 any 2xx", status: 404, isErr: true},
+		{name: "500 any 2xx", status: 500, isErr: true},
+		{name: "200 expected 200", status: 200, expected: []int{200}},
+		{name: "201 expected 200", status: 201, expected: []int{200}, isErr: true},
+		{name: "201 expected 200 or 201", status: 201, expected: []int{200, 201}},
+	}
+
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			body := &testReadCloser{bytes.NewBufferString("some error body"), nil}
+			err := decodeMgmtError(&gocbcore.HttpResponse{
+				StatusCode: tCase.status,
+				Body:       body,
+			}, tCase.expected...)
+			if !tCase.isErr {
+				if err != nil {
+					t.Fatalf("Expected error to be nil but was %v", err)
+				}
+				return
+			}
+
+			mErr, ok := err.(mgmtHTTPError)
+			if !ok {
+				t.Fatalf("Expected error to be mgmtHTTPError but was %v", err)
+			}
+
+			if mErr.statusCode != tCase.status {
+				t.Fatalf("Expected status code to be %d but was %d", tCase.status, mErr.statusCode)
+			}
+
+			if mErr.message != "some error body" {
+				t.Fatalf("Expected message to be body but was %s", mErr.message)
+			}
+		})
+	}
+}
+
+func TestMgmtErrorsNoContent(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 204,
+			Body:       &testReadCloser{bytes.NewBufferString(""), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{doFn: doHTTP}
+	bucketMgr := &BucketManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+	userMgr := &UserManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+
+	err := bucketMgr.DropBucket("test", nil)
+	if err != nil {
+		t.Fatalf("Expected DropBucket to not error but was %v", err)
+	}
+
+	err = userMgr.DropUser("test", nil)
+	if err != nil {
+		t.Fatalf("Expected DropUser to not error but was %v", err)
+	}
+}
+
+func TestMgmtErrorsFailureBody(t *testing.T) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 404,
+			Body:       &testReadCloser{bytes.NewBufferString("Requested resource not found."), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{doFn: doHTTP}
+	bucketMgr := &BucketManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+	userMgr := &UserManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+	viewMgr := &ViewIndexManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+
+	_, err := bucketMgr.GetBucket("test", nil)
+	bErr, ok := err.(BucketManagerError)
+	if !ok {
+		t.Fatalf("Expected error to be BucketManagerError but was %v", err)
+	}
+	if bErr.HTTPStatus() != 404 || !bErr.BucketNotFoundError() {
+		t.Fatalf("Expected error to be bucket not found but was %v", err)
+	}
+
+	_, err = userMgr.GetUser("test", nil)
+	uErr, ok := err.(UserManagerError)
+	if !ok {
+		t.Fatalf("Expected error to be UserManagerError but was %v", err)
+	}
+	if uErr.HTTPStatus() != 404 || uErr.Error() != "Requested resource not found." {
+		t.Fatalf("Expected error to contain body but was %v", err)
+	}
+
+	_, err = viewMgr.GetDesignDocument("test", ProductionDesignDocumentNamespace, nil)
+	vErr, ok := err.(ViewIndexesError)
+	if !ok {
+		t.Fatalf("Expected error to be ViewIndexesError but was %v", err)
+	}
+	if vErr.HTTPStatus() != 404 || !vErr.DesignDocumentNotFoundError() {
+		t.Fatalf("Expected error to be design document not found but was %v", err)
+	}
+}
+
+func TestMgmtErrorsAuthStatus(t *testing.T) {
+	testCases := []struct {
+		name           string
+		status         int
+		body           string
+		authentication bool
+		authorization  bool
+	}{
+		{name: "401", status: 401, body: "Unauthorized", authentication: true},
+		{
+			name:          "403",
+			status:        403,
+			body:          `{"message":"Forbidden. User needs the following permissions","permissions":["cluster.buckets!create"]}`,
+			authorization: true,
+		},
+		{name: "404", status: 404, body: "Requested resource not found."},
+	}
+
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8091",
+					StatusCode: tCase.status,
+					Body:       &testReadCloser{bytes.NewBufferString(tCase.body), nil},
+				}, nil
+			}
+
+			provider := &mockHTTPProvider{doFn: doHTTP}
+			bucketMgr := &BucketManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+			userMgr := &UserManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+			viewMgr := &ViewIndexManager{httpClient: provider, globalTimeout: 10 * time.Second, tracer: &noopTracer{}}
+
+			errs := make(map[string]error)
+			_, errs["bucket"] = bucketMgr.GetBucket("test", nil)
+			_, errs["user"] = userMgr.GetUser("test", nil)
+			_, errs["view"] = viewMgr.GetDesignDocument("test", ProductionDesignDocumentNamespace, nil)
+
+			for mgr, err := range errs {
+				if err == nil {
+					t.Fatalf("Expected %s manager to error", mgr)
+				}
+
+				if IsAuthenticationError(err) != tCase.authentication {
+					t.Fatalf("Expected %s manager authentication error to be %t for %v", mgr, tCase.authentication, err)
+				}
+
+				if IsAuthorizationError(err) != tCase.authorization {
+					t.Fatalf("Expected %s manager authorization error to be %t for %v", mgr, tCase.authorization, err)
+				}
+
+				if tCase.authorization && err.Error() != tCase.body {
+					t.Fatalf("Expected %s manager error to carry the required permissions but was %s", mgr, err)
+				}
+			}
+
+			bErr, ok := errs["bucket"].(BucketManagerError)
+			if !ok {
+				t.Fatalf("Expected error to still be BucketManagerError but was %v", errs["bucket"])
+			}
+			if bErr.HTTPStatus() != tCase.status {
+				t.Fatalf("Expected status to be %d but was %d", tCase.status, bErr.HTTPStatus())
+			}
+		})
+	}
+
+	if IsAuthorizationError(queryError{ErrorCode: 13014, ErrorMessage: "User does not have credentials", httpStatus: 401}) {
+		t.Fatalf("Expected non management errors to not be classified by status")
+	}
+}
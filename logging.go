@@ -1,8 +1,11 @@
 package gocb
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
 	gocbcore "github.com/couchbase/gocbcore/v8"
@@ -54,6 +57,30 @@ type Logger interface {
 	Log(level LogLevel, offset int, format string, v ...interface{}) error
 }
 
+// LogFields are structured fields which give a log message correlation context, such as the operation id.
+type LogFields map[string]interface{}
+
+// The fields which the library attaches to log messages.
+const (
+	// LogFieldOperationID is the unique id of the request, e.g. the id of a management request.
+	LogFieldOperationID = "operation_id"
+	// LogFieldOperation is the name of the operation being performed, e.g. MutateIn.
+	LogFieldOperation = "operation"
+	// LogFieldService is the service the operation was sent to, e.g. kv or mgmt.
+	LogFieldService = "service"
+	// LogFieldBucket is the name of the bucket the operation was performed against.
+	LogFieldBucket = "bucket"
+)
+
+// FieldLogger is a Logger which can also receive the structured fields attached to a log message. If the logger
+// passed to SetLogger does not implement FieldLogger then any fields are appended to the message instead.
+type FieldLogger interface {
+	Logger
+	// LogWithFields outputs logging information along with its structured fields, the remaining arguments are as
+	// for Log.
+	LogWithFields(level LogLevel, offset int, fields LogFields, format string, v ...interface{}) error
+}
+
 var (
 	globalLogger            Logger
 	globalLogRedactionLevel LogRedactLevel
@@ -122,6 +149,38 @@ func logExf(level LogLevel, offset int, format string, v ...interface{}) {
 	}
 }
 
+func logExFieldsf(level LogLevel, offset int, fields LogFields, format string, v ...interface{}) {
+	if globalLogger == nil {
+		return
+	}
+
+	var err error
+	if fieldLogger, ok := globalLogger.(FieldLogger); ok {
+		err = fieldLogger.LogWithFields(level, offset+1, fields, format, v...)
+	} else {
+		err = globalLogger.Log(level, offset+1, format+" %s", append(v, formatLogFields(fields))...)
+	}
+	if err != nil {
+		log.Printf("Logger error occurred (%s)\n", err)
+	}
+}
+
+// formatLogFields formats fields for loggers which cannot receive them, e.g. (bucket=default, service=kv).
+func formatLogFields(fields LogFields) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", key, fields[key])
+	}
+
+	return "(" + strings.Join(pairs, ", ") + ")"
+}
+
 func logInfof(format string, v ...interface{}) {
 	logExf(LogInfo, 1, format, v...)
 }
@@ -130,6 +189,10 @@ func logDebugf(format string, v ...interface{}) {
 	logExf(LogDebug, 1, format, v...)
 }
 
+func logDebugFieldsf(fields LogFields, format string, v ...interface{}) {
+	logExFieldsf(LogDebug, 1, fields, format, v...)
+}
+
 func logSchedf(format string, v ...interface{}) {
 	logExf(LogSched, 1, format, v...)
 }
@@ -142,6 +205,54 @@ func logErrorf(format string, v ...interface{}) {
 	logExf(LogError, 1, format, v...)
 }
 
+// logRequestBody logs the body of an outgoing request at debug level, see redactRequestBody.
+func logRequestBody(fields LogFields, body []byte) {
+	if globalLogger == nil {
+		return
+	}
+
+	logExFieldsf(LogDebug, 1, fields, "Request body:\n%s", redactRequestBody(body))
+}
+
+// redactRequestBody pretty prints a JSON request body with any credentials removed. The remainder of the body is user
+// data so is redacted according to the log redaction level, as set by SetLogRedactionLevel. Bodies which are not JSON
+// cannot be checked for credentials so are not included at all.
+func redactRequestBody(body []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var content interface{}
+	err := decoder.Decode(&content)
+	if err != nil {
+		return "<non-JSON body redacted>"
+	}
+	if fields, ok := content.(map[string]interface{}); ok {
+		if _, ok := fields["creds"]; ok {
+			fields["creds"] = "<redacted>"
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(content)
+	if err != nil {
+		return "<unencodable body redacted>"
+	}
+
+	return redactUserData(strings.TrimSuffix(buf.String(), "\n"))
+}
+
+// redactUserData tags v as user data when logs are being redacted, in the same way as gocbcore.
+func redactUserData(v interface{}) string {
+	if globalLogRedactionLevel == RedactNone {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return fmt.Sprintf("<ud>%v<ud>", v)
+}
+
 func reindentLog(indent, message string) string {
 	reindentedMessage := strings.Replace(message, "\n", "\n"+indent, -1)
 	return fmt.Sprintf("%s%s", indent, reindentedMessage)
@@ -0,0 +1,293 @@
+package gocb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+)
+
+type testCapturedLog struct {
+	level   LogLevel
+	message string
+	fields  LogFields
+}
+
+// testCaptureLogger records each message logged to it along with any structured fields.
+type testCaptureLogger struct {
+	logs []testCapturedLog
+}
+
+func (l *testCaptureLogger) Log(level LogLevel, offset int, format string, v ...interface{}) error {
+	l.logs = append(l.logs, testCapturedLog{level: level, message: fmt.Sprintf(format, v...)})
+	return nil
+}
+
+func (l *testCaptureLogger) LogWithFields(level LogLevel, offset int, fields LogFields, format string,
+	v ...interface{}) error {
+	l.logs = append(l.logs, testCapturedLog{level: level, message: fmt.Sprintf(format, v...), fields: fields})
+	return nil
+}
+
+// testPlainCaptureLogger records each message logged to it, it cannot receive structured fields.
+type testPlainCaptureLogger struct {
+	logs []string
+}
+
+func (l *testPlainCaptureLogger) Log(level LogLevel, offset int, format string, v ...interface{}) error {
+	l.logs = append(l.logs, fmt.Sprintf(format, v...))
+	return nil
+}
+
+// testSetGlobalLogger sets the library logger, returning a function to restore the previous logger.
+func testSetGlobalLogger(logger Logger) func() {
+	oldLogger := globalLogger
+	globalLogger = logger
+
+	return func() {
+		globalLogger = oldLogger
+	}
+}
+
+func testBucketMgrFailingCloseHTTP(uniqueIDs *[]string) func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+	return func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		*uniqueIDs = append(*uniqueIDs, req.UniqueId)
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(""), errors.New("connection reset")},
+		}, nil
+	}
+}
+
+func TestBucketMgrLogFields(t *testing.T) {
+	logger := &testCaptureLogger{}
+	defer testSetGlobalLogger(logger)()
+
+	var uniqueIDs []string
+	mgr := &BucketManager{
+		httpClient:    &mockHTTPProvider{doFn: testBucketMgrFailingCloseHTTP(&uniqueIDs)},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	err := mgr.FlushBucket("travel-sample", nil)
+	if err != nil {
+		t.Fatalf("Expected FlushBucket to succeed but was %v", err)
+	}
+
+	if len(logger.logs) != 1 {
+		t.Fatalf("Expected 1 log message but was %d", len(logger.logs))
+	}
+
+	logged := logger.logs[0]
+	if logged.level != LogDebug || !strings.Contains(logged.message, "connection reset") {
+		t.Fatalf("Expected failed close to be logged at debug but was %v", logged)
+	}
+
+	if logged.fields[LogFieldOperationID] != uniqueIDs[0] {
+		t.Fatalf("Expected operation id to be %s but was %v", uniqueIDs[0], logged.fields[LogFieldOperationID])
+	}
+
+	if logged.fields[LogFieldService] != "mgmt" {
+		t.Fatalf("Expected service to be mgmt but was %v", logged.fields[LogFieldService])
+	}
+
+	if logged.fields[LogFieldBucket] != "travel-sample" {
+		t.Fatalf("Expected bucket to be travel-sample but was %v", logged.fields[LogFieldBucket])
+	}
+}
+
+func TestLogFieldsAppendedForPlainLogger(t *testing.T) {
+	logger := &testPlainCaptureLogger{}
+	defer testSetGlobalLogger(logger)()
+
+	var uniqueIDs []string
+	mgr := &BucketManager{
+		httpClient:    &mockHTTPProvider{doFn: testBucketMgrFailingCloseHTTP(&uniqueIDs)},
+		globalTimeout: 10 * time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	err := mgr.DropBucket("travel-sample", nil)
+	if err != nil {
+		t.Fatalf("Expected DropBucket to succeed but was %v", err)
+	}
+
+	if len(logger.logs) != 1 {
+		t.Fatalf("Expected 1 log message but was %d", len(logger.logs))
+	}
+
+	expected := "Failed to close socket (connection reset) (bucket=travel-sample, operation_id=" + uniqueIDs[0] +
+		", service=mgmt)"
+	if logger.logs[0] != expected {
+		t.Fatalf("Expected log message to be %s but was %s", expected, logger.logs[0])
+	}
+}
+
+func TestSubdocLogFields(t *testing.T) {
+	logger := &testCaptureLogger{}
+	defer testSetGlobalLogger(logger)()
+
+	provider := testExpiryProvider()
+	changed := false
+	provider.store.beforeMutate = func() {
+		// Simulate another writer changing the document between the lookup and the first mutation.
+		if !changed {
+			changed = true
+			provider.store.docs["key"].cas++
+		}
+	}
+	col := testGetCollection(t, provider)
+	col.sb.BucketName = "travel-sample"
+
+	_, err := col.MutateIn("key", []MutateInSpec{UpsertSpec("status", "shipped", nil)}, &MutateInOptions{
+		PreserveExpiry: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected MutateIn to succeed but was %v", err)
+	}
+
+	if len(logger.logs) != 1 {
+		t.Fatalf("Expected 1 log message but was %d", len(logger.logs))
+	}
+
+	fields := logger.logs[0].fields
+	if fields[LogFieldOperation] != "MutateIn" || fields[LogFieldService] != "kv" ||
+		fields[LogFieldBucket] != "travel-sample" {
+		t.Fatalf("Expected MutateIn kv fields for travel-sample but was %v", fields)
+	}
+}
+
+func testRequestBodyLogs(logger *testCaptureLogger) []testCapturedLog {
+	var logs []testCapturedLog
+	for _, logged := range logger.logs {
+		if strings.HasPrefix(logged.message, "Request body:") {
+			logs = append(logs, logged)
+		}
+	}
+
+	return logs
+}
+
+func testQueryForRequestBodyLogging(t *testing.T, logBodies bool) {
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString(`{"results":[],"status":"success"}`), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 60*time.Second, 0, 0)
+	cluster.sb.LogRequestBodies = logBodies
+
+	res, err := cluster.Query("SELECT * FROM users WHERE name = $name AND secret = $password", &QueryOptions{
+		ClientContextID: "debug-ctx",
+		NamedParameters: map[string]interface{}{"name": "barry", "password": "hunter2"},
+		Raw: map[string]interface{}{
+			"creds": []map[string]string{{"user": "admin", "pass": "letmein"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected query to succeed but was %v", err)
+	}
+
+	err = res.Close()
+	if err != nil {
+		t.Fatalf("results close had error: %v", err)
+	}
+}
+
+func TestQueryRequestBodyLogging(t *testing.T) {
+	logger := &testCaptureLogger{}
+	defer testSetGlobalLogger(logger)()
+
+	testQueryForRequestBodyLogging(t, true)
+
+	logs := testRequestBodyLogs(logger)
+	if len(logs) != 1 {
+		t.Fatalf("Expected the request body to be logged once but was %v", logger.logs)
+	}
+
+	logged := logs[0]
+	if logged.level != LogDebug {
+		t.Fatalf("Expected request body to be logged at debug but was %d", logged.level)
+	}
+
+	if logged.fields[LogFieldOperationID] != "debug-ctx" || logged.fields[LogFieldService] != "n1ql" {
+		t.Fatalf("Expected n1ql fields for debug-ctx but was %v", logged.fields)
+	}
+
+	if !strings.Contains(logged.message, `"statement": "SELECT * FROM users WHERE name = $name AND secret = $password"`) {
+		t.Fatalf("Expected statement to be logged pretty printed but was %s", logged.message)
+	}
+
+	if !strings.Contains(logged.message, `"$name": "barry"`) {
+		t.Fatalf("Expected named parameters to be logged but was %s", logged.message)
+	}
+
+	for _, secret := range []string{"letmein", "admin"} {
+		if strings.Contains(logged.message, secret) {
+			t.Fatalf("Expected %s to be redacted but was %s", secret, logged.message)
+		}
+	}
+
+	if !strings.Contains(logged.message, `"creds": "<redacted>"`) {
+		t.Fatalf("Expected credentials to be replaced but was %s", logged.message)
+	}
+}
+
+func TestQueryRequestBodyLoggingRedactionLevel(t *testing.T) {
+	logger := &testCaptureLogger{}
+	defer testSetGlobalLogger(logger)()
+
+	SetLogRedactionLevel(RedactPartial)
+	defer SetLogRedactionLevel(RedactNone)
+
+	testQueryForRequestBodyLogging(t, true)
+
+	logs := testRequestBodyLogs(logger)
+	if len(logs) != 1 {
+		t.Fatalf("Expected the request body to be logged once but was %v", logger.logs)
+	}
+
+	logged := logs[0]
+	if !strings.HasPrefix(logged.message, "Request body:\n<ud>{") || !strings.HasSuffix(logged.message, "}<ud>") {
+		t.Fatalf("Expected the request body to be tagged as user data but was %s", logged.message)
+	}
+
+	if strings.Contains(logged.message, "letmein") {
+		t.Fatalf("Expected credentials to be replaced but was %s", logged.message)
+	}
+}
+
+func TestQueryRequestBodyLoggingDisabledByDefault(t *testing.T) {
+	logger := &testCaptureLogger{}
+	defer testSetGlobalLogger(logger)()
+
+	testQueryForRequestBodyLogging(t, false)
+
+	if logs := testRequestBodyLogs(logger); len(logs) != 0 {
+		t.Fatalf("Expected no request body to be logged but was %v", logs)
+	}
+}
+
+func TestRedactRequestBody(t *testing.T) {
+	redacted := redactRequestBody([]byte(`{"statement":"SELECT 1 < 2","creds":[{"user":"admin","pass":"abc"}],"n":12345678901234567890}`))
+	expected := "{\n  \"creds\": \"<redacted>\",\n" +
+		"  \"n\": 12345678901234567890,\n  \"statement\": \"SELECT 1 < 2\"\n}"
+	if redacted != expected {
+		t.Fatalf("Expected redacted body to be %s but was %s", expected, redacted)
+	}
+
+	redacted = redactRequestBody([]byte("user=admin&password=letmein"))
+	if strings.Contains(redacted, "letmein") {
+		t.Fatalf("Expected non-JSON body not to be logged but was %s", redacted)
+	}
+}
@@ -1,8 +1,14 @@
 package gocb
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/couchbase/gocbcore/v8"
@@ -27,6 +33,27 @@ type mockKvProvider struct {
 	datatype              uint8
 	err                   error
 	opCancellationSuccess bool
+
+	// store, if set, models the documents of the collection. Requests are answered from, and applied to, the store
+	// rather than with the fixed responses above.
+	store *mockDocStore
+	// persistAfter, if set, is the number of times a vbucket is observed as not yet persisted before the sequence
+	// number of mt is reported as persisted.
+	persistAfter int
+
+	// lock guards the requests recorded below, in the order they were dispatched, and the count of those in flight.
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+	adds        []gocbcore.AddOptions
+	sets        []gocbcore.SetOptions
+	replaces    []gocbcore.ReplaceOptions
+	touches     []gocbcore.TouchOptions
+	deletes     []gocbcore.DeleteOptions
+	counters    []gocbcore.CounterOptions
+	lookupIns   []gocbcore.LookupInOptions
+	mutateIns   []gocbcore.MutateInOptions
+	observeVbs  []gocbcore.ObserveVbOptions
 }
 
 type mockHTTPProvider struct {
@@ -80,6 +107,25 @@ func (mpo *mockPendingOp) LocalEndpoint() string {
 }
 
 func (mko *mockKvProvider) AddEx(opts gocbcore.AddOptions, cb gocbcore.StoreExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.adds = append(mko.adds, opts)
+	mko.lock.Unlock()
+
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			cas, err := mko.store.store(opts.Key, opts.Value, opts.Flags, opts.Expiry, 0, mockStoreAdd)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.StoreResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.StoreResult{
@@ -95,6 +141,25 @@ func (mko *mockKvProvider) AddEx(opts gocbcore.AddOptions, cb gocbcore.StoreExCa
 }
 
 func (mko *mockKvProvider) SetEx(opts gocbcore.SetOptions, cb gocbcore.StoreExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.sets = append(mko.sets, opts)
+	mko.lock.Unlock()
+
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			cas, err := mko.store.store(opts.Key, opts.Value, opts.Flags, opts.Expiry, 0, mockStoreSet)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.StoreResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.StoreResult{
@@ -111,6 +176,25 @@ func (mko *mockKvProvider) SetEx(opts gocbcore.SetOptions, cb gocbcore.StoreExCa
 }
 
 func (mko *mockKvProvider) ReplaceEx(opts gocbcore.ReplaceOptions, cb gocbcore.StoreExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.replaces = append(mko.replaces, opts)
+	mko.lock.Unlock()
+
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			cas, err := mko.store.store(opts.Key, opts.Value, opts.Flags, opts.Expiry, opts.Cas, mockStoreReplace)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.StoreResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.StoreResult{
@@ -126,13 +210,29 @@ func (mko *mockKvProvider) ReplaceEx(opts gocbcore.ReplaceOptions, cb gocbcore.S
 }
 
 func (mko *mockKvProvider) GetEx(opts gocbcore.GetOptions, cb gocbcore.GetExCallback) (gocbcore.PendingOp, error) {
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			doc, err := mko.store.get(opts.Key)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.GetResult{
+				Cas:   doc.cas,
+				Flags: doc.flags,
+				Value: doc.value,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.GetResult{
 				Cas:      mko.cas,
 				Flags:    mko.flags,
 				Datatype: mko.datatype,
-				Value:    mko.value.([]byte),
+				Value:    mko.valueBytes(),
 			}, nil)
 		} else {
 			cb(nil, mko.err)
@@ -143,13 +243,29 @@ func (mko *mockKvProvider) GetEx(opts gocbcore.GetOptions, cb gocbcore.GetExCall
 }
 
 func (mko *mockKvProvider) GetAndTouchEx(opts gocbcore.GetAndTouchOptions, cb gocbcore.GetAndTouchExCallback) (gocbcore.PendingOp, error) {
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			doc, err := mko.store.getAndTouch(opts.Key, opts.Expiry)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.GetAndTouchResult{
+				Cas:   doc.cas,
+				Flags: doc.flags,
+				Value: doc.value,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.GetAndTouchResult{
 				Cas:      mko.cas,
 				Flags:    mko.flags,
 				Datatype: mko.datatype,
-				Value:    mko.value.([]byte),
+				Value:    mko.valueBytes(),
 			}, nil)
 		} else {
 			cb(nil, mko.err)
@@ -160,13 +276,29 @@ func (mko *mockKvProvider) GetAndTouchEx(opts gocbcore.GetAndTouchOptions, cb go
 }
 
 func (mko *mockKvProvider) GetAndLockEx(opts gocbcore.GetAndLockOptions, cb gocbcore.GetAndLockExCallback) (gocbcore.PendingOp, error) {
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			doc, err := mko.store.getAndLock(opts.Key)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.GetAndLockResult{
+				Cas:   doc.cas,
+				Flags: doc.flags,
+				Value: doc.value,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.GetAndLockResult{
 				Cas:      mko.cas,
 				Flags:    mko.flags,
 				Datatype: mko.datatype,
-				Value:    mko.value.([]byte),
+				Value:    mko.valueBytes(),
 			}, nil)
 		} else {
 			cb(nil, mko.err)
@@ -177,6 +309,21 @@ func (mko *mockKvProvider) GetAndLockEx(opts gocbcore.GetAndLockOptions, cb gocb
 }
 
 func (mko *mockKvProvider) UnlockEx(opts gocbcore.UnlockOptions, cb gocbcore.UnlockExCallback) (gocbcore.PendingOp, error) {
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			cas, err := mko.store.unlock(opts.Key, opts.Cas)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.UnlockResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.UnlockResult{
@@ -192,6 +339,25 @@ func (mko *mockKvProvider) UnlockEx(opts gocbcore.UnlockOptions, cb gocbcore.Unl
 }
 
 func (mko *mockKvProvider) TouchEx(opts gocbcore.TouchOptions, cb gocbcore.TouchExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.touches = append(mko.touches, opts)
+	mko.lock.Unlock()
+
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			cas, err := mko.store.touch(opts.Key, opts.Expiry)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.TouchResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.TouchResult{
@@ -207,6 +373,25 @@ func (mko *mockKvProvider) TouchEx(opts gocbcore.TouchOptions, cb gocbcore.Touch
 }
 
 func (mko *mockKvProvider) DeleteEx(opts gocbcore.DeleteOptions, cb gocbcore.DeleteExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.deletes = append(mko.deletes, opts)
+	mko.lock.Unlock()
+
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			cas, err := mko.store.remove(opts.Key, opts.Cas)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.DeleteResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.DeleteResult{
@@ -222,6 +407,26 @@ func (mko *mockKvProvider) DeleteEx(opts gocbcore.DeleteOptions, cb gocbcore.Del
 }
 
 func (mko *mockKvProvider) IncrementEx(opts gocbcore.CounterOptions, cb gocbcore.CounterExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.counters = append(mko.counters, opts)
+	mko.lock.Unlock()
+
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			value, cas, err := mko.store.counter(opts.Key, opts.Delta, false, opts.Initial, opts.Expiry)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.CounterResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+				Value:         value,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.CounterResult{
@@ -238,6 +443,26 @@ func (mko *mockKvProvider) IncrementEx(opts gocbcore.CounterOptions, cb gocbcore
 }
 
 func (mko *mockKvProvider) DecrementEx(opts gocbcore.CounterOptions, cb gocbcore.CounterExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.counters = append(mko.counters, opts)
+	mko.lock.Unlock()
+
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			value, cas, err := mko.store.counter(opts.Key, opts.Delta, true, opts.Initial, opts.Expiry)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.CounterResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+				Value:         value,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.CounterResult{
@@ -254,6 +479,21 @@ func (mko *mockKvProvider) DecrementEx(opts gocbcore.CounterOptions, cb gocbcore
 }
 
 func (mko *mockKvProvider) AppendEx(opts gocbcore.AdjoinOptions, cb gocbcore.AdjoinExCallback) (gocbcore.PendingOp, error) {
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			cas, err := mko.store.adjoin(opts.Key, opts.Value, opts.Cas, false)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.AdjoinResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.AdjoinResult{
@@ -269,6 +509,21 @@ func (mko *mockKvProvider) AppendEx(opts gocbcore.AdjoinOptions, cb gocbcore.Adj
 }
 
 func (mko *mockKvProvider) PrependEx(opts gocbcore.AdjoinOptions, cb gocbcore.AdjoinExCallback) (gocbcore.PendingOp, error) {
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			cas, err := mko.store.adjoin(opts.Key, opts.Value, opts.Cas, true)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(&gocbcore.AdjoinResult{
+				Cas:           cas,
+				MutationToken: mko.mt,
+			}, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.AdjoinResult{
@@ -284,6 +539,22 @@ func (mko *mockKvProvider) PrependEx(opts gocbcore.AdjoinOptions, cb gocbcore.Ad
 }
 
 func (mko *mockKvProvider) LookupInEx(opts gocbcore.LookupInOptions, cb gocbcore.LookupInExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.lookupIns = append(mko.lookupIns, opts)
+	mko.lock.Unlock()
+
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			res, err := mko.store.lookupIn(opts)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(res, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.LookupInResult{
@@ -300,6 +571,23 @@ func (mko *mockKvProvider) LookupInEx(opts gocbcore.LookupInOptions, cb gocbcore
 }
 
 func (mko *mockKvProvider) MutateInEx(opts gocbcore.MutateInOptions, cb gocbcore.MutateInExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.mutateIns = append(mko.mutateIns, opts)
+	mko.lock.Unlock()
+
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			res, err := mko.store.mutateIn(opts)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			res.MutationToken = mko.mt
+			cb(res, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.MutateInResult{
@@ -316,6 +604,18 @@ func (mko *mockKvProvider) MutateInEx(opts gocbcore.MutateInOptions, cb gocbcore
 }
 
 func (mko *mockKvProvider) ObserveEx(opts gocbcore.ObserveOptions, cb gocbcore.ObserveExCallback) (gocbcore.PendingOp, error) {
+	if mko.store != nil {
+		return mko.dispatch(func() {
+			res, err := mko.store.observe(opts.Key)
+			if err != nil {
+				cb(nil, err)
+				return
+			}
+
+			cb(res, nil)
+		}), nil
+	}
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
 			cb(&gocbcore.ObserveResult{
@@ -331,9 +631,19 @@ func (mko *mockKvProvider) ObserveEx(opts gocbcore.ObserveOptions, cb gocbcore.O
 }
 
 func (mko *mockKvProvider) ObserveVbEx(opts gocbcore.ObserveVbOptions, cb gocbcore.ObserveVbExCallback) (gocbcore.PendingOp, error) {
+	mko.lock.Lock()
+	mko.observeVbs = append(mko.observeVbs, opts)
+	persisted := mko.persistAfter > 0 && len(mko.observeVbs) > mko.persistAfter
+	mko.lock.Unlock()
+
 	time.AfterFunc(mko.opWait, func() {
 		if mko.err == nil {
-			cb(&gocbcore.ObserveVbResult{}, nil)
+			res := &gocbcore.ObserveVbResult{}
+			if persisted {
+				res.CurrentSeqNo = mko.mt.SeqNo
+				res.PersistSeqNo = mko.mt.SeqNo
+			}
+			cb(res, nil)
 		} else {
 			cb(nil, mko.err)
 		}
@@ -349,7 +659,7 @@ func (mko *mockKvProvider) GetAnyReplicaEx(opts gocbcore.GetAnyReplicaOptions, c
 				Cas:      mko.cas,
 				Flags:    mko.flags,
 				Datatype: mko.datatype,
-				Value:    mko.value.([]byte),
+				Value:    mko.valueBytes(),
 			}, nil)
 		} else {
 			cb(nil, mko.err)
@@ -366,7 +676,7 @@ func (mko *mockKvProvider) GetOneReplicaEx(opts gocbcore.GetOneReplicaOptions, c
 				Cas:      mko.cas,
 				Flags:    mko.flags,
 				Datatype: mko.datatype,
-				Value:    mko.value.([]byte),
+				Value:    mko.valueBytes(),
 			}, nil)
 		} else {
 			cb(nil, mko.err)
@@ -392,13 +702,49 @@ func (mko *mockKvProvider) NumReplicas() int {
 	return 0
 }
 
+// valueBytes returns value as bytes, a nil value is allowed for tests which expect the operation to time out before
+// it is answered, the answer still arrives once opWait has elapsed.
+func (mko *mockKvProvider) valueBytes() []byte {
+	value, _ := mko.value.([]byte)
+	return value
+}
+
+// dispatch answers a request by calling fn once opWait has elapsed, counting the request as in flight until then.
+func (mko *mockKvProvider) dispatch(fn func()) gocbcore.PendingOp {
+	mko.lock.Lock()
+	mko.inFlight++
+	if mko.inFlight > mko.maxInFlight {
+		mko.maxInFlight = mko.inFlight
+	}
+	mko.lock.Unlock()
+
+	time.AfterFunc(mko.opWait, func() {
+		mko.lock.Lock()
+		mko.inFlight--
+		mko.lock.Unlock()
+
+		fn()
+	})
+
+	return &mockPendingOp{cancelSuccess: mko.opCancellationSuccess}
+}
+
 func (p *mockHTTPProvider) DoHttpRequest(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
 	return p.doFn(req)
 }
 
 func (p *mockHTTPProvider) MaybeRetryRequest(req gocbcore.RetryRequest, reason gocbcore.RetryReason,
 	strategy gocbcore.RetryStrategy, retryFunc func()) bool {
-	time.AfterFunc(1*time.Millisecond, retryFunc)
+	duration := 1 * time.Millisecond
+	if wrapper, ok := strategy.(*retryStrategyWrapper); ok && wrapper != nil {
+		action := wrapper.RetryAfter(req, reason)
+		if action == nil || action.Duration() == 0 {
+			return false
+		}
+		duration = action.Duration()
+	}
+
+	time.AfterFunc(duration, retryFunc)
 	return true
 }
 
@@ -460,3 +806,697 @@ func (mc *mockClient) getHTTPProvider() (httpProvider, error) {
 func (mc *mockClient) getDiagnosticsProvider() (diagnosticsProvider, error) {
 	return mc.mockDiagnosticsProvider, nil
 }
+
+// mockDocument is a document held by a mockDocStore. The value is the document body and xattrs, if set, is a JSON
+// object of its extended attributes. The expiry is held as it was sent rather than converted to a Unix time.
+type mockDocument struct {
+	value   []byte
+	xattrs  []byte
+	cas     gocbcore.Cas
+	flags   uint32
+	expiry  uint32
+	locked  bool
+	deleted bool
+}
+
+// mockDocStore is an in-memory model of the documents in a collection, applying requests to them as the server
+// would. Removed documents are left as tombstones.
+type mockDocStore struct {
+	lock sync.Mutex
+	docs map[string]*mockDocument
+	// errs fails every request for a key with the given error.
+	errs map[string]error
+	// beforeMutate is called, with the store locked, ahead of each mutation allowing a concurrent change to be
+	// simulated.
+	beforeMutate func()
+}
+
+// testStoreProvider returns a provider backed by a store holding the given documents.
+func testStoreProvider(docs map[string]*mockDocument) *mockKvProvider {
+	return &mockKvProvider{
+		mt:    gocbcore.MutationToken{VbId: 1, VbUuid: 2, SeqNo: 3},
+		store: &mockDocStore{docs: docs},
+	}
+}
+
+type mockStoreSemantic int
+
+const (
+	mockStoreAdd mockStoreSemantic = iota
+	mockStoreSet
+	mockStoreReplace
+)
+
+func mockKvError(code gocbcore.StatusCode) error {
+	return &gocbcore.KvError{Code: code}
+}
+
+// mutated records that the document has been mutated, releasing any lock, and returns its new cas.
+func (d *mockDocument) mutated() gocbcore.Cas {
+	d.cas++
+	d.locked = false
+	return d.cas
+}
+
+// vattrs returns the $document virtual extended attribute of the document.
+func (d *mockDocument) vattrs() map[string]interface{} {
+	datatype := []interface{}{"raw"}
+	if json.Valid(d.value) {
+		datatype = []interface{}{"json"}
+	}
+	if len(d.xattrs) > 0 {
+		datatype = append(datatype, "xattr")
+	}
+
+	return map[string]interface{}{
+		"CAS":          fmt.Sprintf("0x%016x", uint64(d.cas)),
+		"exptime":      d.expiry,
+		"flags":        d.flags,
+		"datatype":     datatype,
+		"deleted":      d.deleted,
+		"value_bytes":  len(d.value),
+		"value_crc32c": fmt.Sprintf("0x%08x", crc32.Checksum(d.value, crc32.MakeTable(crc32.Castagnoli))),
+	}
+}
+
+// lookupOp returns the result of a single LookupIn op against the document.
+func (d *mockDocument) lookupOp(op gocbcore.SubDocOp) ([]byte, gocbcore.StatusCode) {
+	if op.Op == gocbcore.SubDocOpGetDoc {
+		return d.value, gocbcore.StatusSuccess
+	}
+
+	path, ok := parseMockPath(op.Path)
+	if !ok {
+		return nil, gocbcore.StatusSubDocPathInvalid
+	}
+
+	var root interface{}
+	if op.Flags&gocbcore.SubdocFlagXattrPath != 0 {
+		xattrs, _ := mockDecode(d.xattrs, "{}")
+		xattrs.(map[string]interface{})["$document"] = d.vattrs()
+		root = xattrs
+	} else if root, ok = mockDecode(d.value, ""); !ok {
+		return nil, gocbcore.StatusSubDocNotJson
+	}
+
+	value, ok := mockGetPath(root, path)
+	if !ok {
+		return nil, gocbcore.StatusSubDocPathNotFound
+	}
+
+	switch op.Op {
+	case gocbcore.SubDocOpGet:
+		data, _ := json.Marshal(value)
+		return data, gocbcore.StatusSuccess
+	case gocbcore.SubDocOpExists:
+		return nil, gocbcore.StatusSuccess
+	case gocbcore.SubDocOpGetCount:
+		switch value := value.(type) {
+		case []interface{}:
+			return []byte(strconv.Itoa(len(value))), gocbcore.StatusSuccess
+		case map[string]interface{}:
+			return []byte(strconv.Itoa(len(value))), gocbcore.StatusSuccess
+		}
+		return nil, gocbcore.StatusSubDocPathMismatch
+	}
+
+	return nil, gocbcore.StatusNotSupported
+}
+
+// lookup returns the document for key, nil if there is no such document. Tombstones are only returned if
+// accessDeleted is set.
+func (s *mockDocStore) lookup(key []byte, accessDeleted bool) (*mockDocument, error) {
+	if err, ok := s.errs[string(key)]; ok {
+		return nil, err
+	}
+
+	doc, ok := s.docs[string(key)]
+	if !ok || (doc.deleted && !accessDeleted) {
+		return nil, nil
+	}
+
+	return doc, nil
+}
+
+// live returns the document for key, failing with key not found if there is no such document.
+func (s *mockDocStore) live(key []byte, accessDeleted bool) (*mockDocument, error) {
+	doc, err := s.lookup(key, accessDeleted)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, mockKvError(gocbcore.StatusKeyNotFound)
+	}
+
+	return doc, nil
+}
+
+// mutable returns the document for key ahead of it being mutated with cas. If there is no such document nil is
+// returned when allowMissing is set, otherwise the request fails with key not found.
+func (s *mockDocStore) mutable(key []byte, cas gocbcore.Cas, allowMissing bool) (*mockDocument, error) {
+	if s.beforeMutate != nil {
+		s.beforeMutate()
+	}
+
+	doc, err := s.lookup(key, false)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		if !allowMissing || cas != 0 {
+			return nil, mockKvError(gocbcore.StatusKeyNotFound)
+		}
+		return nil, nil
+	}
+
+	// A locked document can only be mutated with the cas returned when it was locked.
+	if doc.locked && cas != doc.cas {
+		return nil, mockKvError(gocbcore.StatusLocked)
+	}
+	if cas != 0 && cas != doc.cas {
+		return nil, mockKvError(gocbcore.StatusKeyExists)
+	}
+
+	return doc, nil
+}
+
+// put adds doc as the document for key, replacing any tombstone.
+func (s *mockDocStore) put(key []byte, doc *mockDocument) {
+	if s.docs == nil {
+		s.docs = make(map[string]*mockDocument)
+	}
+	if tombstone, ok := s.docs[string(key)]; ok {
+		doc.cas = tombstone.cas
+	}
+
+	s.docs[string(key)] = doc
+}
+
+func (s *mockDocStore) store(key, value []byte, flags, expiry uint32, cas gocbcore.Cas,
+	semantic mockStoreSemantic) (gocbcore.Cas, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.mutable(key, cas, semantic != mockStoreReplace)
+	if err != nil {
+		return 0, err
+	}
+	if doc == nil {
+		doc = &mockDocument{}
+		s.put(key, doc)
+	} else if semantic == mockStoreAdd {
+		return 0, mockKvError(gocbcore.StatusKeyExists)
+	}
+
+	doc.value = value
+	doc.xattrs = nil
+	doc.flags = flags
+	doc.expiry = expiry
+
+	return doc.mutated(), nil
+}
+
+func (s *mockDocStore) get(key []byte) (mockDocument, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.live(key, false)
+	if err != nil {
+		return mockDocument{}, err
+	}
+
+	return *doc, nil
+}
+
+func (s *mockDocStore) getAndTouch(key []byte, expiry uint32) (mockDocument, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.mutable(key, 0, false)
+	if err != nil {
+		return mockDocument{}, err
+	}
+
+	doc.expiry = expiry
+	doc.mutated()
+
+	return *doc, nil
+}
+
+func (s *mockDocStore) getAndLock(key []byte) (mockDocument, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.live(key, false)
+	if err != nil {
+		return mockDocument{}, err
+	}
+	if doc.locked {
+		return mockDocument{}, mockKvError(gocbcore.StatusLocked)
+	}
+
+	doc.cas++
+	doc.locked = true
+
+	return *doc, nil
+}
+
+func (s *mockDocStore) unlock(key []byte, cas gocbcore.Cas) (gocbcore.Cas, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.live(key, false)
+	if err != nil {
+		return 0, err
+	}
+
+	// The server reports unlocking a document which is not locked as a temporary failure, and a stale lock cas as
+	// locked.
+	if !doc.locked {
+		return 0, mockKvError(gocbcore.StatusTmpFail)
+	}
+	if cas != doc.cas {
+		return 0, mockKvError(gocbcore.StatusLocked)
+	}
+
+	doc.locked = false
+
+	return doc.cas, nil
+}
+
+func (s *mockDocStore) touch(key []byte, expiry uint32) (gocbcore.Cas, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.mutable(key, 0, false)
+	if err != nil {
+		return 0, err
+	}
+
+	doc.expiry = expiry
+
+	return doc.mutated(), nil
+}
+
+func (s *mockDocStore) remove(key []byte, cas gocbcore.Cas) (gocbcore.Cas, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.mutable(key, cas, false)
+	if err != nil {
+		return 0, err
+	}
+
+	*doc = mockDocument{
+		cas:     doc.cas,
+		deleted: true,
+	}
+
+	return doc.mutated(), nil
+}
+
+// counter applies delta to the counter document for key, creating it with initial if there is no such document
+// and initial isn't the all ones value. The server clamps decrements at zero rather than wrapping.
+func (s *mockDocStore) counter(key []byte, delta uint64, decrement bool, initial uint64,
+	expiry uint32) (uint64, gocbcore.Cas, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.mutable(key, 0, initial != uint64(0xFFFFFFFFFFFFFFFF))
+	if err != nil {
+		return 0, 0, err
+	}
+	if doc == nil {
+		doc = &mockDocument{
+			value:  []byte(strconv.FormatUint(initial, 10)),
+			expiry: expiry,
+		}
+		s.put(key, doc)
+
+		return initial, doc.mutated(), nil
+	}
+
+	current, err := strconv.ParseUint(string(doc.value), 10, 64)
+	if err != nil {
+		return 0, 0, mockKvError(gocbcore.StatusBadDelta)
+	}
+
+	switch {
+	case !decrement:
+		current += delta
+	case delta > current:
+		current = 0
+	default:
+		current -= delta
+	}
+	doc.value = []byte(strconv.FormatUint(current, 10))
+
+	return current, doc.mutated(), nil
+}
+
+func (s *mockDocStore) adjoin(key, value []byte, cas gocbcore.Cas, prepend bool) (gocbcore.Cas, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.mutable(key, cas, true)
+	if err != nil {
+		return 0, err
+	}
+	if doc == nil {
+		return 0, mockKvError(gocbcore.StatusNotStored)
+	}
+
+	if prepend {
+		doc.value = append(append([]byte{}, value...), doc.value...)
+	} else {
+		doc.value = append(append([]byte{}, doc.value...), value...)
+	}
+
+	return doc.mutated(), nil
+}
+
+func (s *mockDocStore) observe(key []byte) (*gocbcore.ObserveResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.lookup(key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case doc == nil:
+		return &gocbcore.ObserveResult{KeyState: gocbcore.KeyStateNotFound}, nil
+	case doc.deleted:
+		return &gocbcore.ObserveResult{KeyState: gocbcore.KeyStateDeleted, Cas: doc.cas}, nil
+	}
+
+	return &gocbcore.ObserveResult{KeyState: gocbcore.KeyStatePersisted, Cas: doc.cas}, nil
+}
+
+func (s *mockDocStore) lookupIn(opts gocbcore.LookupInOptions) (*gocbcore.LookupInResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.live(opts.Key, opts.Flags&gocbcore.SubdocDocFlagAccessDeleted != 0)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]gocbcore.SubDocResult, len(opts.Ops))
+	for i, op := range opts.Ops {
+		value, status := doc.lookupOp(op)
+		if status != gocbcore.StatusSuccess {
+			results[i].Err = mockKvError(status)
+			continue
+		}
+
+		results[i].Value = value
+	}
+
+	return &gocbcore.LookupInResult{
+		Cas: doc.cas,
+		Ops: results,
+	}, nil
+}
+
+// mutateIn applies the ops of a MutateIn to the document, as with the server either all of the ops are applied or
+// none are. The expiry of the document is always replaced, clearing it if none was sent.
+func (s *mockDocStore) mutateIn(opts gocbcore.MutateInOptions) (*gocbcore.MutateInResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, err := s.mutable(opts.Key, opts.Cas, opts.Flags&gocbcore.SubdocDocFlagMkDoc != 0)
+	if err != nil {
+		return nil, err
+	}
+
+	value, xattrs := []byte("{}"), []byte(nil)
+	if doc != nil {
+		value, xattrs = doc.value, doc.xattrs
+	}
+
+	root, ok := mockDecode(value, "")
+	if !ok {
+		return nil, gocbcore.SubDocMutateError{Err: mockKvError(gocbcore.StatusSubDocNotJson)}
+	}
+	xroot, _ := mockDecode(xattrs, "{}")
+
+	for i, op := range opts.Ops {
+		var status gocbcore.StatusCode
+		switch {
+		case op.Flags&gocbcore.SubdocFlagXattrPath == 0:
+			root, status = mockMutatePath(root, op)
+		case strings.HasPrefix(op.Path, "$document"):
+			status = gocbcore.StatusSubDocXattrCannotModifyVAttr
+		default:
+			xroot, status = mockMutatePath(xroot, op)
+		}
+
+		if status != gocbcore.StatusSuccess {
+			return nil, gocbcore.SubDocMutateError{Err: mockKvError(status), OpIndex: i}
+		}
+	}
+
+	if doc == nil {
+		doc = &mockDocument{}
+		s.put(opts.Key, doc)
+	}
+
+	doc.value, _ = json.Marshal(root)
+	doc.xattrs = nil
+	if len(xroot.(map[string]interface{})) > 0 {
+		doc.xattrs, _ = json.Marshal(xroot)
+	}
+	doc.expiry = opts.Expiry
+
+	return &gocbcore.MutateInResult{
+		Cas: doc.mutated(),
+		Ops: make([]gocbcore.SubDocResult, len(opts.Ops)),
+	}, nil
+}
+
+// mockPathComponent is a single field name or array index of a subdocument path.
+type mockPathComponent struct {
+	field   string
+	index   int
+	isIndex bool
+}
+
+// parseMockPath splits a subdocument path such as a.b[0].c into its components.
+func parseMockPath(path string) ([]mockPathComponent, bool) {
+	var components []mockPathComponent
+	for _, part := range strings.Split(path, ".") {
+		field, indexes := part, ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			field, indexes = part[:i], part[i:]
+		}
+
+		if field != "" {
+			components = append(components, mockPathComponent{field: field})
+		} else if indexes == "" {
+			return nil, false
+		}
+
+		for indexes != "" {
+			end := strings.IndexByte(indexes, ']')
+			if indexes[0] != '[' || end < 0 {
+				return nil, false
+			}
+
+			index, err := strconv.Atoi(indexes[1:end])
+			if err != nil {
+				return nil, false
+			}
+
+			components = append(components, mockPathComponent{index: index, isIndex: true})
+			indexes = indexes[end+1:]
+		}
+	}
+
+	return components, true
+}
+
+// mockDecode decodes a JSON value, decoding def in its place if data is empty.
+func mockDecode(data []byte, def string) (interface{}, bool) {
+	if len(data) == 0 {
+		data = []byte(def)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	return value, true
+}
+
+func mockGetPath(node interface{}, path []mockPathComponent) (interface{}, bool) {
+	for _, component := range path {
+		if component.isIndex {
+			arr, ok := node.([]interface{})
+			if !ok {
+				return nil, false
+			}
+
+			index := component.index
+			if index < 0 {
+				index += len(arr)
+			}
+			if index < 0 || index >= len(arr) {
+				return nil, false
+			}
+
+			node = arr[index]
+			continue
+		}
+
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		node, ok = obj[component.field]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return node, true
+}
+
+// mockMutatePath applies a single MutateIn op to root, returning the updated root.
+func mockMutatePath(root interface{}, op gocbcore.SubDocOp) (interface{}, gocbcore.StatusCode) {
+	path, ok := parseMockPath(op.Path)
+	if !ok {
+		return root, gocbcore.StatusSubDocPathInvalid
+	}
+	mkdirp := op.Flags&gocbcore.SubdocFlagMkDirP != 0
+
+	var value interface{}
+	switch op.Op {
+	case gocbcore.SubDocOpDictAdd, gocbcore.SubDocOpDictSet, gocbcore.SubDocOpReplace:
+		value, ok = mockDecode(op.Value, "")
+	case gocbcore.SubDocOpArrayPushLast, gocbcore.SubDocOpArrayPushFirst:
+		value, ok = mockDecode([]byte("["+string(op.Value)+"]"), "")
+	case gocbcore.SubDocOpDelete:
+	default:
+		return root, gocbcore.StatusNotSupported
+	}
+	if !ok {
+		return root, gocbcore.StatusSubDocCantInsert
+	}
+
+	return mockSetPath(root, path, mkdirp, func(current interface{}, exists bool) (interface{}, bool,
+		gocbcore.StatusCode) {
+		switch op.Op {
+		case gocbcore.SubDocOpDictAdd:
+			if exists {
+				return nil, false, gocbcore.StatusSubDocPathExists
+			}
+		case gocbcore.SubDocOpReplace, gocbcore.SubDocOpDelete:
+			if !exists {
+				return nil, false, gocbcore.StatusSubDocPathNotFound
+			}
+		case gocbcore.SubDocOpArrayPushLast, gocbcore.SubDocOpArrayPushFirst:
+			if !exists {
+				if !mkdirp {
+					return nil, false, gocbcore.StatusSubDocPathNotFound
+				}
+				current = []interface{}{}
+			}
+
+			arr, ok := current.([]interface{})
+			if !ok {
+				return nil, false, gocbcore.StatusSubDocPathMismatch
+			}
+
+			if op.Op == gocbcore.SubDocOpArrayPushLast {
+				return append(arr, value.([]interface{})...), false, gocbcore.StatusSuccess
+			}
+			return append(value.([]interface{}), arr...), false, gocbcore.StatusSuccess
+		}
+
+		return value, op.Op == gocbcore.SubDocOpDelete, gocbcore.StatusSuccess
+	})
+}
+
+// mockSetPath replaces the value at path within node with the result of fn, which is given the current value and
+// whether it exists. The value is removed if fn returns true for remove. Missing objects along the path are created
+// if mkdirp is set.
+func mockSetPath(node interface{}, path []mockPathComponent, mkdirp bool,
+	fn func(current interface{}, exists bool) (interface{}, bool, gocbcore.StatusCode)) (interface{},
+	gocbcore.StatusCode) {
+	if len(path) == 0 {
+		return node, gocbcore.StatusSubDocPathInvalid
+	}
+	component := path[0]
+
+	if component.isIndex {
+		arr, ok := node.([]interface{})
+		if !ok {
+			return node, gocbcore.StatusSubDocPathMismatch
+		}
+
+		index := component.index
+		if index < 0 {
+			index += len(arr)
+		}
+		if index < 0 || index >= len(arr) {
+			return node, gocbcore.StatusSubDocPathNotFound
+		}
+
+		if len(path) > 1 {
+			child, status := mockSetPath(arr[index], path[1:], mkdirp, fn)
+			arr[index] = child
+			return arr, status
+		}
+
+		value, remove, status := fn(arr[index], true)
+		if status != gocbcore.StatusSuccess {
+			return node, status
+		}
+		if remove {
+			return append(arr[:index:index], arr[index+1:]...), status
+		}
+
+		arr[index] = value
+		return arr, status
+	}
+
+	obj, ok := node.(map[string]interface{})
+	if !ok {
+		return node, gocbcore.StatusSubDocPathMismatch
+	}
+	current, exists := obj[component.field]
+
+	if len(path) > 1 {
+		if !exists {
+			if !mkdirp || path[1].isIndex {
+				return node, gocbcore.StatusSubDocPathNotFound
+			}
+			current = map[string]interface{}{}
+		}
+
+		child, status := mockSetPath(current, path[1:], mkdirp, fn)
+		if status == gocbcore.StatusSuccess {
+			obj[component.field] = child
+		}
+		return obj, status
+	}
+
+	value, remove, status := fn(current, exists)
+	if status != gocbcore.StatusSuccess {
+		return node, status
+	}
+	if remove {
+		delete(obj, component.field)
+	} else {
+		obj[component.field] = value
+	}
+
+	return obj, status
+}
@@ -2,6 +2,7 @@ package gocb
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -23,8 +24,15 @@ const (
 type QueryOptions struct {
 	ScanConsistency QueryScanConsistency
 	ConsistentWith  *MutationState
-	AdHoc           bool
-	Profile         QueryProfileType
+	// AdHoc has no effect, statements are executed as is unless Prepared is set.
+	//
+	// Deprecated: Use Prepared to opt in to prepared statements.
+	AdHoc bool
+	// Prepared, if set, prepares the statement the first time that it is run and caches the plan against the
+	// statement, later runs execute the plan by name. The statement is prepared again if the server reports that it
+	// no longer has the plan.
+	Prepared bool
+	Profile  QueryProfileType
 	// ScanCap specifies the maximum buffered channel size between the indexer
 	// client and the query service for index scans. This parameter controls
 	// when to use scan backfill. Use a negative number to disable.
@@ -53,10 +61,15 @@ type QueryOptions struct {
 	Metrics bool
 	// Raw allows specifying custom query options.
 	Raw map[string]interface{}
+	// QueryContext is the bucket and scope that unqualified collection names in the statement are resolved against,
+	// e.g. default:`travel-sample`.`inventory`.
+	QueryContext string
 
 	// JSONSerializer is used to deserialize each row in the result. This should be a JSON deserializer as results are JSON.
 	// NOTE: if not set then query will always default to DefaultJSONSerializer.
-	Serializer    JSONSerializer
+	Serializer JSONSerializer
+	// RetryStrategy is consulted each time the query service responds with a temporary failure, the duration of the
+	// returned RetryAction is waited before the request is retried. If not set then the cluster level strategy is used.
 	RetryStrategy RetryStrategy
 }
 
@@ -67,8 +80,9 @@ func (opts *QueryOptions) toMap(statement string) (map[string]interface{}, error
 		execOpts["timeout"] = opts.Timeout.String()
 	}
 
-	if opts.ScanConsistency != 0 && opts.ConsistentWith != nil {
-		return nil, invalidArgumentsError{message: "ScanConsistency and ConsistentWith must be used exclusively"}
+	err := validateScanConsistency(opts.ScanConsistency != 0, opts.ConsistentWith)
+	if err != nil {
+		return nil, err
 	}
 
 	if opts.ScanConsistency != 0 {
@@ -103,10 +117,16 @@ func (opts *QueryOptions) toMap(statement string) (map[string]interface{}, error
 	}
 
 	if opts.NamedParameters != nil {
+		// Parameters can be named with or without the leading $, so two keys may refer to the same parameter.
 		for key, value := range opts.NamedParameters {
 			if !strings.HasPrefix(key, "$") {
 				key = "$" + key
 			}
+			if _, ok := execOpts[key]; ok {
+				return nil, invalidArgumentsError{
+					message: fmt.Sprintf("named parameter %s was specified more than once, with and without $", key),
+				}
+			}
 			execOpts[key] = value
 		}
 	}
@@ -127,6 +147,10 @@ func (opts *QueryOptions) toMap(statement string) (map[string]interface{}, error
 		execOpts["scan_wait"] = opts.ScanWait.String()
 	}
 
+	if opts.QueryContext != "" {
+		execOpts["query_context"] = opts.QueryContext
+	}
+
 	if opts.Raw != nil {
 		for k, v := range opts.Raw {
 			execOpts[k] = v
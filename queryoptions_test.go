@@ -147,6 +147,94 @@ func TestQueryOptionsToMap(t *testing.T) {
 	}
 }
 
+func TestQueryOptionsQueryContext(t *testing.T) {
+	queryContext := "default:`travel-sample`.`inventory`"
+	opts := &QueryOptions{
+		QueryContext: queryContext,
+	}
+
+	statement := "select * from airline"
+	optMap, err := opts.toMap(statement)
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	testAssertOption(t, statement, "statement", optMap)
+	testAssertOption(t, queryContext, "query_context", optMap)
+
+	optMap, err = (&QueryOptions{}).toMap(statement)
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	testAssertOption(t, nil, "query_context", optMap)
+}
+
+func TestQueryOptionsScanConsistencyConflict(t *testing.T) {
+	opts := &QueryOptions{
+		ScanConsistency: QueryScanConsistencyRequestPlus,
+		ConsistentWith:  NewMutationState(),
+	}
+
+	_, err := opts.toMap("select * from default")
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
+func TestQueryOptionsNamedParams(t *testing.T) {
+	opts := &QueryOptions{
+		NamedParameters: map[string]interface{}{
+			"num":     1,
+			"imafish": "namedbarry",
+			"$cilit":  "bang",
+		},
+	}
+
+	statement := "select * from default"
+	optMap, err := opts.toMap(statement)
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	testAssertOption(t, statement, "statement", optMap)
+	testAssertOption(t, 1, "$num", optMap)
+	testAssertOption(t, "namedbarry", "$imafish", optMap)
+	testAssertOption(t, "bang", "$cilit", optMap)
+	testAssertOption(t, nil, "num", optMap)
+	testAssertOption(t, nil, "$$cilit", optMap)
+}
+
+func TestQueryOptionsNamedParamsDuplicate(t *testing.T) {
+	opts := &QueryOptions{
+		NamedParameters: map[string]interface{}{
+			"num":  1,
+			"$num": 2,
+		},
+	}
+
+	_, err := opts.toMap("select * from default")
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
+func TestQueryOptionsAllTheParams(t *testing.T) {
+	opts := &QueryOptions{
+		NamedParameters: map[string]interface{}{
+			"num":     1,
+			"imafish": "namedbarry",
+			"$cilit":  "bang",
+		},
+		PositionalParameters: []interface{}{1, "imafish"},
+	}
+
+	_, err := opts.toMap("select * from default")
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
 func testAssertOption(t *testing.T, expected interface{}, key string, optMap map[string]interface{}) {
 	if expected == nil {
 		if val, ok := optMap[key]; ok {
@@ -1,10 +1,14 @@
 package gocb
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	gocbcore "github.com/couchbase/gocbcore/v8"
@@ -40,7 +44,8 @@ func (d *GetResult) Expiry() *uint32 {
 	return d.expiry
 }
 
-func (d *GetResult) fromFullProjection(ops []LookupInSpec, result *LookupInResult, fields []string) error {
+func (d *GetResult) fromFullProjection(ops []LookupInSpec, result *LookupInResult, fields []string,
+	skipMissing bool) error {
 	if fields == nil || len(fields) == 0 {
 		// This is a special case where user specified a full doc fetch with expiration.
 		d.contents = result.contents[0].data
@@ -56,7 +61,7 @@ func (d *GetResult) fromFullProjection(ops []LookupInSpec, result *LookupInResul
 		return resultContent.err
 	}
 
-	var content map[string]interface{}
+	var content interface{}
 	err := json.Unmarshal(resultContent.data, &content)
 	if err != nil {
 		return err
@@ -64,8 +69,12 @@ func (d *GetResult) fromFullProjection(ops []LookupInSpec, result *LookupInResul
 
 	newContent := make(map[string]interface{})
 	for _, field := range fields {
+		value, ok := projectionValue(content, field)
+		if !ok && skipMissing {
+			continue
+		}
 		parts := d.pathParts(field)
-		d.set(parts, newContent, content[field])
+		d.set(parts, newContent, value)
 	}
 
 	bytes, err := json.Marshal(newContent)
@@ -77,13 +86,17 @@ func (d *GetResult) fromFullProjection(ops []LookupInSpec, result *LookupInResul
 	return nil
 }
 
-func (d *GetResult) fromSubDoc(ops []LookupInSpec, result *LookupInResult) error {
+func (d *GetResult) fromSubDoc(ops []LookupInSpec, result *LookupInResult, skipMissing bool) error {
 	content := make(map[string]interface{})
 
 	var errs projectionErrors
 	for i, op := range ops {
 		err := result.contents[i].err
 		if err != nil {
+			if skipMissing && IsPathNotFoundError(err) {
+				continue
+			}
+
 			kvErr, ok := err.(kvError)
 			if !ok {
 				// this shouldn't happen, if it does then let's just bail.
@@ -110,6 +123,55 @@ func (d *GetResult) fromSubDoc(ops []LookupInSpec, result *LookupInResult) error
 	return nil
 }
 
+// projectionValue finds the value at the subdocument path within a decoded document, returning false if
+// there is nothing at that path.
+func projectionValue(content interface{}, path string) (interface{}, bool) {
+	for path != "" {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, false
+			}
+			arr, ok := content.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			idx, err := strconv.Atoi(path[1:end])
+			if err != nil {
+				return nil, false
+			}
+			if idx < 0 {
+				// negative indexes count back from the end of the array, as they do in subdoc.
+				idx += len(arr)
+			}
+			if idx < 0 || idx >= len(arr) {
+				return nil, false
+			}
+			content = arr[idx]
+			path = path[end+1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			obj, ok := content.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			content, ok = obj[path[:end]]
+			if !ok {
+				return nil, false
+			}
+			path = path[end:]
+		}
+	}
+
+	return content, true
+}
+
 type subdocPath struct {
 	path    string
 	elem    int
@@ -207,8 +269,13 @@ func (d *GetResult) set(paths []subdocPath, content interface{}, value interface
 		if !ok {
 			// this isn't possible but the linter won't play nice without it
 		}
-		cMap[path.path] = make(map[string]interface{})
-		return d.set(paths[1:], cMap[path.path], value)
+		// other projected paths may already have created this object, in which case add to it.
+		child, ok := cMap[path.path].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			cMap[path.path] = child
+		}
+		return d.set(paths[1:], child, value)
 	}
 
 	return content
@@ -258,6 +325,27 @@ func (lir *LookupInResult) ContentAt(idx int, valuePtr interface{}) error {
 	return lir.contents[idx].as(valuePtr, lir.serializer)
 }
 
+// expiryXattrPath is the virtual extended attribute holding the document expiry as Unix seconds, 0 for no expiry.
+const expiryXattrPath = "$document.exptime"
+
+// Expiry returns the time at which the document expires. The expiry is only available if the LookupIn included
+// a get of the $document.exptime extended attribute, false is returned if it did not or if the document has no
+// expiry.
+func (lir *LookupInResult) Expiry() (time.Time, bool) {
+	idx, ok := lir.pathMap[expiryXattrPath]
+	if !ok || idx >= len(lir.contents) || lir.contents[idx].err != nil {
+		return time.Time{}, false
+	}
+
+	var exptime int64
+	err := json.Unmarshal(lir.contents[idx].data, &exptime)
+	if err != nil || exptime == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(exptime, 0), true
+}
+
 // Exists verifies that the item at idx exists.
 func (lir *LookupInResult) Exists(idx int) bool {
 	if idx >= len(lir.contents) {
@@ -277,6 +365,50 @@ func (d *ExistsResult) Exists() bool {
 	return d.keyState != gocbcore.KeyStateNotFound && d.keyState != gocbcore.KeyStateDeleted
 }
 
+// MetaResult is the return type of GetMeta operations.
+type MetaResult struct {
+	Result
+	expiry   int64
+	flags    uint32
+	datatype []string
+	deleted  bool
+}
+
+// Expiry returns the time at which the document expires, false is returned if the document has no expiry.
+func (mr *MetaResult) Expiry() (time.Time, bool) {
+	if mr.expiry == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(mr.expiry, 0), true
+}
+
+// Flags returns the flags stored alongside the document, as written by the transcoder which encoded it.
+func (mr *MetaResult) Flags() uint32 {
+	return mr.flags
+}
+
+// Datatype returns the datatypes of the document as reported by the server, e.g. json, snappy or xattr.
+func (mr *MetaResult) Datatype() []string {
+	return mr.datatype
+}
+
+// IsJSON returns whether the server considers the document body to be JSON.
+func (mr *MetaResult) IsJSON() bool {
+	for _, datatype := range mr.datatype {
+		if datatype == "json" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Deleted returns whether the document is a tombstone.
+func (mr *MetaResult) Deleted() bool {
+	return mr.deleted
+}
+
 // MutationResult is the return type of any store related operations. It contains Cas and mutation tokens.
 type MutationResult struct {
 	Result
@@ -339,19 +471,69 @@ func (mr CounterResult) Content() uint64 {
 	return mr.content
 }
 
+// decodeRow decodes a single row of a streamed result into valuePtr. The target is checked first so that a
+// mistake such as passing a value rather than a pointer to it is reported clearly, rather than as an error from
+// deep within the serializer.
+func decodeRow(serializer JSONSerializer, row []byte, valuePtr interface{}) error {
+	val := reflect.ValueOf(valuePtr)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return invalidArgumentsError{
+			message: fmt.Sprintf("rows must be decoded into a non-nil pointer but was given %T", valuePtr),
+		}
+	}
+
+	return serializer.Deserialize(row, valuePtr)
+}
+
 type streamingResultCb func(decoder *json.Decoder, t json.Token) (rowsHit bool, err error)
 
 type streamingResult struct {
 	stream      io.ReadCloser
+	capture     *rawCaptureReader
 	closed      bool
 	decoder     *json.Decoder
 	allRowsRead bool
 	hasRows     bool
+	rowsRead    bool
+	raw         bool
 	attributeCb streamingResultCb
 }
 
+// rawCaptureReader records the bytes read from a stream until stopped, so that the part of a response already
+// consumed by the decoder can be replayed if the raw response is requested.
+type rawCaptureReader struct {
+	stream  io.Reader
+	buf     bytes.Buffer
+	stopped bool
+}
+
+func (r *rawCaptureReader) Read(p []byte) (int, error) {
+	n, err := r.stream.Read(p)
+	if !r.stopped && n > 0 {
+		r.buf.Write(p[:n])
+	}
+
+	return n, err
+}
+
+func (r *rawCaptureReader) stop() {
+	r.stopped = true
+	r.buf = bytes.Buffer{}
+}
+
+// rawResultReader is the reader returned for a raw result, closing it closes the result.
+type rawResultReader struct {
+	io.Reader
+	closeFn func() error
+}
+
+func (r *rawResultReader) Close() error {
+	return r.closeFn()
+}
+
 func newStreamingResults(stream io.ReadCloser, attributeCb streamingResultCb) (*streamingResult, error) {
-	dec := json.NewDecoder(stream)
+	capture := &rawCaptureReader{stream: stream}
+	dec := json.NewDecoder(capture)
 
 	// read the opening { to prevent the decoder from trying to read the entire response into memory
 	t, err := dec.Token()
@@ -381,12 +563,22 @@ func newStreamingResults(stream io.ReadCloser, attributeCb streamingResultCb) (*
 	return &streamingResult{
 		decoder:     dec,
 		stream:      stream,
+		capture:     capture,
 		attributeCb: attributeCb,
 	}, nil
 }
 
 func (r *streamingResult) NextBytes() ([]byte, error) {
-	if !r.hasRows {
+	if r.raw {
+		return nil, clientError{message: "rows cannot be read once the raw response has been requested"}
+	}
+
+	if !r.rowsRead {
+		r.rowsRead = true
+		r.capture.stop()
+	}
+
+	if !r.hasRows || r.allRowsRead {
 		return nil, nil
 	}
 
@@ -419,11 +611,38 @@ func (r *streamingResult) NextBytes() ([]byte, error) {
 }
 
 func (r *streamingResult) Close() error {
+	return r.closeWithContext(context.Background())
+}
+
+// closeWithContext closes the stream, reading any remaining attributes first. If ctx is done before the attributes
+// have been read then the stream is closed underneath the read and the context error is returned, so a stalled
+// server cannot block closing indefinitely. The read is always finished before returning so that the attributes
+// aren't written to whilst they are being accessed.
+func (r *streamingResult) closeWithContext(ctx context.Context) error {
 	var finalReadErr error
 	// if we haven't read all of the rows then we can't read the remaining attributes
 	if r.allRowsRead {
 		// don't just return error here, we need to close the stream first
-		finalReadErr = r.readAttributes()
+		if ctx.Done() == nil {
+			finalReadErr = r.readAttributes()
+		} else if ctx.Err() != nil {
+			r.closeStreamAfterDeadline()
+			return ctx.Err()
+		} else {
+			readCh := make(chan error, 1)
+			go func() {
+				readCh <- r.readAttributes()
+			}()
+
+			select {
+			case finalReadErr = <-readCh:
+			case <-ctx.Done():
+				// Closing the stream unblocks the read, which must be waited for as it writes to the attributes.
+				r.closeStreamAfterDeadline()
+				<-readCh
+				return ctx.Err()
+			}
+		}
 	}
 	r.closed = true
 	// We always need to close but we'll let any error from decoding take precedent
@@ -437,6 +656,42 @@ func (r *streamingResult) Close() error {
 	return err
 }
 
+// closeStreamAfterDeadline marks the result as closed and closes the stream without reading the remaining attributes.
+func (r *streamingResult) closeStreamAfterDeadline() {
+	r.closed = true
+	if r.stream == nil {
+		return
+	}
+
+	err := r.stream.Close()
+	if err != nil {
+		logDebugf("Failed to close socket (%s)", err.Error())
+	}
+}
+
+// Raw returns a reader over the entire response body, including the part already consumed whilst reading the
+// attributes preceding the rows. It cannot be used once rows have been read.
+func (r *streamingResult) Raw() (io.Reader, error) {
+	if r.rowsRead {
+		return nil, clientError{message: "the raw response cannot be requested once rows have been read"}
+	}
+	if r.raw {
+		return nil, clientError{message: "the raw response has already been requested"}
+	}
+	if r.closed && r.hasRows {
+		return nil, clientError{message: "the raw response cannot be requested once the result has been closed"}
+	}
+	r.raw = true
+
+	consumed := bytes.NewReader(r.capture.buf.Bytes())
+	r.capture.stopped = true
+	if r.closed {
+		return consumed, nil
+	}
+
+	return io.MultiReader(consumed, r.stream), nil
+}
+
 func (r *streamingResult) Closed() bool {
 	return r.closed
 }
@@ -1,6 +1,8 @@
 package gocb
 
 import (
+	"bytes"
+	"sync"
 	"testing"
 	"time"
 
@@ -156,3 +158,208 @@ func TestFailFastRetryStrategy_RetryAfterAllowsNonIdempotent(t *testing.T) {
 		t.Fatalf("Expected duration to be %d but was %d", 0, action.Duration())
 	}
 }
+
+func TestManagerUsesClusterRetryStrategy(t *testing.T) {
+	clusterStrategy := NewFailFastRetryStrategy()
+	opStrategy := NewBestEffortRetryStrategy(nil)
+
+	var reqStrategy RetryStrategy
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		wrapper, ok := req.RetryStrategy.(*retryStrategyWrapper)
+		if !ok {
+			t.Fatalf("Expected request retry strategy to be a retryStrategyWrapper but was %T", req.RetryStrategy)
+		}
+		reqStrategy = wrapper.wrapped
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBufferString("[]"), nil},
+		}, nil
+	}
+
+	provider := &mockHTTPProvider{
+		doFn: doHTTP,
+	}
+
+	cluster := testGetClusterForHTTP(provider, 0, 0, 0)
+	cluster.sb.RetryStrategyWrapper = newRetryStrategyWrapper(clusterStrategy)
+	cluster.sb.ManagementTimeout = 10 * time.Second
+
+	mgr, err := cluster.Users()
+	if err != nil {
+		t.Fatalf("Failed to get user manager: %v", err)
+	}
+
+	_, err = mgr.GetAllUsers(nil)
+	if err != nil {
+		t.Fatalf("Expected GetAllUsers to succeed but was %v", err)
+	}
+
+	if reqStrategy != clusterStrategy {
+		t.Fatalf("Expected request to use the cluster retry strategy but was %v", reqStrategy)
+	}
+
+	_, err = mgr.GetAllUsers(&GetAllUsersOptions{
+		RetryStrategy: opStrategy,
+	})
+	if err != nil {
+		t.Fatalf("Expected GetAllUsers to succeed but was %v", err)
+	}
+
+	if reqStrategy != opStrategy {
+		t.Fatalf("Expected request to use the operation retry strategy but was %v", reqStrategy)
+	}
+}
+
+// testRecordingRetryStrategy returns each of its delays in turn and records when it was consulted.
+type testRecordingRetryStrategy struct {
+	lock    sync.Mutex
+	delays  []time.Duration
+	calls   []time.Time
+	reasons []RetryReason
+}
+
+func (rs *testRecordingRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	idx := len(rs.calls)
+	rs.calls = append(rs.calls, time.Now())
+	rs.reasons = append(rs.reasons, reason)
+	if idx >= len(rs.delays) {
+		return &NoRetryRetryAction{}
+	}
+
+	return &WithDurationRetryAction{WithDuration: rs.delays[idx]}
+}
+
+func TestHTTPRetryUsesRetryStrategyBackoff(t *testing.T) {
+	type tCase struct {
+		name         string
+		errorDataset string
+		okDataset    string
+		run          func(cluster *Cluster, strategy RetryStrategy) error
+	}
+
+	testCases := []tCase{
+		{
+			name:         "analytics",
+			errorDataset: "beer_sample_analytics_temp_error",
+			okDataset:    "beer_sample_analytics_dataset",
+			run: func(cluster *Cluster, strategy RetryStrategy) error {
+				res, err := cluster.AnalyticsQuery("SELECT 1", &AnalyticsOptions{RetryStrategy: strategy})
+				if err != nil {
+					return err
+				}
+				return res.Close()
+			},
+		},
+		{
+			name:         "query",
+			errorDataset: "beer_sample_query_temp_error",
+			okDataset:    "beer_sample_query_dataset",
+			run: func(cluster *Cluster, strategy RetryStrategy) error {
+				res, err := cluster.Query("SELECT 1", &QueryOptions{RetryStrategy: strategy})
+				if err != nil {
+					return err
+				}
+				return res.Close()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errBytes, err := loadRawTestDataset(tc.errorDataset)
+			if err != nil {
+				t.Fatalf("Could not read test dataset: %v", err)
+			}
+
+			okBytes, err := loadRawTestDataset(tc.okDataset)
+			if err != nil {
+				t.Fatalf("Could not read test dataset: %v", err)
+			}
+
+			strategy := &testRecordingRetryStrategy{
+				delays: []time.Duration{20 * time.Millisecond, 40 * time.Millisecond},
+			}
+
+			var dispatches []time.Time
+			doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+				dispatches = append(dispatches, time.Now())
+
+				body := errBytes
+				if len(dispatches) == 3 {
+					body = okBytes
+				}
+
+				return &gocbcore.HttpResponse{
+					Endpoint:   "http://localhost:8095",
+					StatusCode: 200,
+					Body:       &testReadCloser{bytes.NewBuffer(body), nil},
+				}, nil
+			}
+
+			cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 10*time.Second, 10*time.Second, 0)
+
+			err = tc.run(cluster, strategy)
+			if err != nil {
+				t.Fatalf("Expected request to succeed after retrying but was %v", err)
+			}
+
+			if len(dispatches) != 3 {
+				t.Fatalf("Expected 3 dispatches but was %d", len(dispatches))
+			}
+
+			if len(strategy.calls) != 2 {
+				t.Fatalf("Expected retry strategy to be consulted 2 times but was %d", len(strategy.calls))
+			}
+
+			for i, call := range strategy.calls {
+				if call.Before(dispatches[i]) || call.After(dispatches[i+1]) {
+					t.Fatalf("Expected retry strategy to be consulted between dispatches %d and %d", i, i+1)
+				}
+
+				if strategy.reasons[i] != ServiceResponseCodeIndicatedRetryReason {
+					t.Fatalf("Expected retry reason to be %v but was %v", ServiceResponseCodeIndicatedRetryReason,
+						strategy.reasons[i])
+				}
+
+				if waited := dispatches[i+1].Sub(call); waited < strategy.delays[i] {
+					t.Fatalf("Expected retry %d to wait at least %s but was %s", i, strategy.delays[i], waited)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPRetryStrategyDeclines(t *testing.T) {
+	errBytes, err := loadRawTestDataset("beer_sample_analytics_temp_error")
+	if err != nil {
+		t.Fatalf("Could not read test dataset: %v", err)
+	}
+
+	var dispatches int
+	doHTTP := func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		dispatches++
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8095",
+			StatusCode: 200,
+			Body:       &testReadCloser{bytes.NewBuffer(errBytes), nil},
+		}, nil
+	}
+
+	cluster := testGetClusterForHTTP(&mockHTTPProvider{doFn: doHTTP}, 0, 10*time.Second, 0)
+	strategy := &testRecordingRetryStrategy{}
+
+	_, err = cluster.AnalyticsQuery("SELECT 1", &AnalyticsOptions{RetryStrategy: strategy})
+	if err == nil {
+		t.Fatalf("Expected AnalyticsQuery to fail when the retry strategy declines to retry")
+	}
+
+	if dispatches != 1 || len(strategy.calls) != 1 {
+		t.Fatalf("Expected 1 dispatch and 1 strategy call but was %d and %d", dispatches, len(strategy.calls))
+	}
+}
@@ -1,5 +1,7 @@
 package gocb
 
+import "time"
+
 // Scope represents a single scope within a bucket.
 type Scope struct {
 	sb stateBlock
@@ -32,3 +34,32 @@ func (s *Scope) Collection(collectionName string) *Collection {
 func (s *Scope) stateBlock() stateBlock {
 	return s.sb
 }
+
+// Query executes the N1QL query statement against the scope. Collection names in the statement which are not
+// qualified by a bucket and scope are resolved against this scope, any QueryContext set in opts is ignored.
+// Volatile: This API is subject to change at any time.
+func (s *Scope) Query(statement string, opts *QueryOptions) (*QueryResult, error) {
+	return scopeQuery(&s.sb, statement, opts)
+}
+
+// scopeQuery executes a N1QL query with the query context set to the scope in sb.
+func scopeQuery(sb *stateBlock, statement string, opts *QueryOptions) (*QueryResult, error) {
+	startTime := time.Now()
+	if sb.executeQuery == nil {
+		return nil, configurationError{message: "scope was not opened from a cluster, it cannot be queried"}
+	}
+
+	// Copy the options so that the query context is not set on the options that were passed in.
+	var queryOpts QueryOptions
+	if opts != nil {
+		queryOpts = *opts
+	}
+	queryOpts.QueryContext = queryIndexQueryContext(sb.BucketName, sb.ScopeName)
+
+	span := sb.Tracer.StartSpan("Query", nil).
+		SetTag("couchbase.service", "n1ql").
+		SetTag("couchbase.bucket", sb.BucketName)
+	defer span.Finish()
+
+	return sb.executeQuery(span.Context(), statement, startTime, &queryOpts)
+}
@@ -107,8 +107,9 @@ func (opts *SearchOptions) toOptionsData() (*searchQueryOptionsData, error) {
 		data.Ctl.Timeout = uint(opts.Timeout / time.Millisecond)
 	}
 
-	if opts.ScanConsistency != 0 && opts.ConsistentWith != nil {
-		return nil, invalidArgumentsError{message: "ScanConsistency and ConsistentWith must be used exclusively"}
+	err := validateScanConsistency(opts.ScanConsistency != 0, opts.ConsistentWith)
+	if err != nil {
+		return nil, err
 	}
 
 	if opts.ScanConsistency != 0 {
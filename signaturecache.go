@@ -0,0 +1,118 @@
+package gocb
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+)
+
+// signatureCache is a least recently used cache of raw query and analytics result signatures, keyed by statement
+// and query context. Parameter values are sent separately to the statement so do not form part of the key.
+type signatureCache struct {
+	lock     sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type signatureCacheEntry struct {
+	key       string
+	raw       json.RawMessage
+	signature interface{}
+}
+
+func newSignatureCache(capacity int) *signatureCache {
+	return &signatureCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (sc *signatureCache) get(key string) (json.RawMessage, interface{}, bool) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	elem, ok := sc.entries[key]
+	if !ok {
+		return nil, nil, false
+	}
+	sc.order.MoveToFront(elem)
+
+	entry := elem.Value.(*signatureCacheEntry)
+	return entry.raw, entry.signature, true
+}
+
+func (sc *signatureCache) put(key string, raw json.RawMessage, signature interface{}) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	if elem, ok := sc.entries[key]; ok {
+		entry := elem.Value.(*signatureCacheEntry)
+		entry.raw = raw
+		entry.signature = signature
+		sc.order.MoveToFront(elem)
+		return
+	}
+
+	sc.entries[key] = sc.order.PushFront(&signatureCacheEntry{key: key, raw: raw, signature: signature})
+
+	for sc.order.Len() > sc.capacity {
+		oldest := sc.order.Back()
+		sc.order.Remove(oldest)
+		delete(sc.entries, oldest.Value.(*signatureCacheEntry).key)
+	}
+}
+
+// decode reads the signature attribute from decoder, returning it raw as well as decoding it into signature. If the
+// signature for key is already cached then the attribute is skipped without being parsed and a copy of the cached
+// signature is used, so that results never share it. A nil cache always parses the attribute.
+func (sc *signatureCache) decode(decoder *json.Decoder, key string, signature *interface{}) (json.RawMessage, error) {
+	// Reading the attribute raw only scans it, it still has to be consumed to reach the attributes which follow.
+	var raw json.RawMessage
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if sc != nil && key != "" {
+		if cachedRaw, cached, ok := sc.get(key); ok {
+			*signature = copySignature(cached)
+			return cachedRaw, nil
+		}
+	}
+
+	var parsed interface{}
+	err = json.Unmarshal(raw, &parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	if sc != nil && key != "" {
+		sc.put(key, raw, parsed)
+		parsed = copySignature(parsed)
+	}
+	*signature = parsed
+
+	return raw, nil
+}
+
+// copySignature deep copies a signature decoded into an interface{}, only objects and arrays are mutable.
+func copySignature(signature interface{}) interface{} {
+	switch v := signature.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			out[key] = copySignature(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = copySignature(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
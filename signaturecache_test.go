@@ -0,0 +1,244 @@
+package gocb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v8"
+)
+
+func TestSignatureCacheEviction(t *testing.T) {
+	cache := newSignatureCache(2)
+
+	cache.put("a", json.RawMessage(`"sigA"`), "sigA")
+	cache.put("b", json.RawMessage(`"sigB"`), "sigB")
+
+	// Using a makes b the least recently used.
+	if sig, _, ok := cache.get("a"); !ok || string(sig) != `"sigA"` {
+		t.Fatalf("Expected a to be cached as sigA but was %s", sig)
+	}
+
+	cache.put("c", json.RawMessage(`"sigC"`), "sigC")
+
+	if _, _, ok := cache.get("b"); ok {
+		t.Fatalf("Expected b to have been evicted")
+	}
+
+	for key, expected := range map[string]string{"a": `"sigA"`, "c": `"sigC"`} {
+		if sig, _, ok := cache.get(key); !ok || string(sig) != expected {
+			t.Fatalf("Expected %s to be cached as %s but was %s", key, expected, sig)
+		}
+	}
+}
+
+func TestSignatureCacheDecodeSkipsCachedSignature(t *testing.T) {
+	cache := newSignatureCache(2)
+	// The cached signature doesn't match its raw form, so it can only be returned if neither is parsed.
+	cache.put("key", json.RawMessage(`{"field1":"string"}`), map[string]interface{}{"cached": []interface{}{"string"}})
+
+	var signature interface{}
+	raw, err := cache.decode(json.NewDecoder(bytes.NewBufferString(`{"field2":"string"}`)), "key", &signature)
+	if err != nil {
+		t.Fatalf("Expected decode to succeed but was %v", err)
+	}
+
+	if string(raw) != `{"field1":"string"}` {
+		t.Fatalf("Expected raw signature to be the cached one but was %s", raw)
+	}
+
+	expected := map[string]interface{}{"cached": []interface{}{"string"}}
+	if !reflect.DeepEqual(signature, expected) {
+		t.Fatalf("Expected signature to be %v but was %v", expected, signature)
+	}
+
+	// The result must have its own copy, changing it can't affect the cache.
+	signature.(map[string]interface{})["cached"].([]interface{})[0] = "changed"
+	_, cached, _ := cache.get("key")
+	if !reflect.DeepEqual(cached, expected) {
+		t.Fatalf("Expected cached signature to be unchanged as %v but was %v", expected, cached)
+	}
+}
+
+func testSignatureCacheResponses(service gocbcore.ServiceType,
+	requests *[]map[string]interface{}) func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+	return func(req *gocbcore.HttpRequest) (*gocbcore.HttpResponse, error) {
+		if req.Service != service {
+			return nil, fmt.Errorf("unexpected service type %d", req.Service)
+		}
+
+		var body map[string]interface{}
+		err := json.Unmarshal(req.Body, &body)
+		if err != nil {
+			return nil, err
+		}
+		*requests = append(*requests, body)
+
+		// Each response has a distinct signature so that a cached signature can be told apart from a parsed one.
+		signature := fmt.Sprintf(`{"field%d":"string"}`, len(*requests))
+
+		return &gocbcore.HttpResponse{
+			Endpoint:   "http://localhost:8093",
+			StatusCode: 200,
+			Body: &testReadCloser{bytes.NewBufferString(`{"requestID":"a1b2c3","signature":` + signature +
+				`,"results":[],"status":"success"}`), nil},
+		}, nil
+	}
+}
+
+func TestQuerySignatureCache(t *testing.T) {
+	var requests []map[string]interface{}
+	provider := &mockHTTPProvider{
+		doFn: testSignatureCacheResponses(gocbcore.N1qlService, &requests),
+	}
+
+	cluster := testGetClusterForHTTP(provider, time.Minute, 0, 0)
+	cluster.signatureCache = newSignatureCache(10)
+
+	querySignature := func(statement string, params ...interface{}) interface{} {
+		res, err := cluster.Query(statement, &QueryOptions{PositionalParameters: params})
+		if err != nil {
+			t.Fatalf("Expected query to succeed but was %v", err)
+		}
+
+		err = res.Close()
+		if err != nil {
+			t.Fatalf("Expected close to succeed but was %v", err)
+		}
+
+		metadata, err := res.Metadata()
+		if err != nil {
+			t.Fatalf("Expected metadata to be available but was %v", err)
+		}
+
+		return metadata.Signature()
+	}
+
+	first := querySignature("SELECT name FROM default WHERE id = $1", 1)
+	// Results must not share the cached signature, so changing one can't affect another.
+	first.(map[string]interface{})["changed"] = true
+	first = querySignature("SELECT name FROM default WHERE id = $1", 1)
+	second := querySignature("SELECT name FROM default WHERE id = $1", 2)
+	other := querySignature("SELECT id FROM default")
+
+	if len(requests) != 4 {
+		t.Fatalf("Expected 4 requests to be sent but was %d", len(requests))
+	}
+
+	expectedFirst := map[string]interface{}{"field1": "string"}
+	if !reflect.DeepEqual(first, expectedFirst) {
+		t.Fatalf("Expected first signature to be %v but was %v", expectedFirst, first)
+	}
+
+	if !reflect.DeepEqual(second, expectedFirst) {
+		t.Fatalf("Expected second signature to be served from the cache as %v but was %v", expectedFirst, second)
+	}
+
+	expectedOther := map[string]interface{}{"field4": "string"}
+	if !reflect.DeepEqual(other, expectedOther) {
+		t.Fatalf("Expected other statement signature to be %v but was %v", expectedOther, other)
+	}
+}
+
+func TestQuerySignatureCacheQueryContext(t *testing.T) {
+	var requests []map[string]interface{}
+	provider := &mockHTTPProvider{
+		doFn: testSignatureCacheResponses(gocbcore.N1qlService, &requests),
+	}
+
+	cluster := testGetClusterForHTTP(provider, time.Minute, 0, 0)
+	cluster.signatureCache = newSignatureCache(10)
+
+	for i, queryContext := range []string{"default:`travel-sample`.inventory", "default:`travel-sample`.tenant"} {
+		res, err := cluster.Query("SELECT name FROM airline", &QueryOptions{QueryContext: queryContext})
+		if err != nil {
+			t.Fatalf("Expected query to succeed but was %v", err)
+		}
+
+		err = res.Close()
+		if err != nil {
+			t.Fatalf("Expected close to succeed but was %v", err)
+		}
+
+		metadata, err := res.Metadata()
+		if err != nil {
+			t.Fatalf("Expected metadata to be available but was %v", err)
+		}
+
+		expected := map[string]interface{}{fmt.Sprintf("field%d", i+1): "string"}
+		if !reflect.DeepEqual(metadata.Signature(), expected) {
+			t.Fatalf("Expected signature in %s to be %v but was %v", queryContext, expected, metadata.Signature())
+		}
+	}
+}
+
+func TestAnalyticsSignatureCache(t *testing.T) {
+	var requests []map[string]interface{}
+	provider := &mockHTTPProvider{
+		doFn: testSignatureCacheResponses(gocbcore.CbasService, &requests),
+	}
+
+	cluster := testGetClusterForHTTP(provider, 0, time.Minute, 0)
+	cluster.signatureCache = newSignatureCache(10)
+
+	var results []interface{}
+	for i := 0; i < 2; i++ {
+		res, err := cluster.AnalyticsQuery("SELECT name FROM dataset", nil)
+		if err != nil {
+			t.Fatalf("Expected analytics query to succeed but was %v", err)
+		}
+
+		err = res.Close()
+		if err != nil {
+			t.Fatalf("Expected close to succeed but was %v", err)
+		}
+
+		metadata, err := res.Metadata()
+		if err != nil {
+			t.Fatalf("Expected metadata to be available but was %v", err)
+		}
+
+		results = append(results, metadata.Signature())
+	}
+
+	expected := map[string]interface{}{"field1": "string"}
+	for i, signature := range results {
+		if !reflect.DeepEqual(signature, expected) {
+			t.Fatalf("Expected signature %d to be %v but was %v", i, expected, signature)
+		}
+	}
+}
+
+func TestSignatureCacheDisabled(t *testing.T) {
+	var requests []map[string]interface{}
+	provider := &mockHTTPProvider{
+		doFn: testSignatureCacheResponses(gocbcore.N1qlService, &requests),
+	}
+
+	cluster := testGetClusterForHTTP(provider, time.Minute, 0, 0)
+
+	for i := 1; i <= 2; i++ {
+		res, err := cluster.Query("SELECT name FROM default", nil)
+		if err != nil {
+			t.Fatalf("Expected query to succeed but was %v", err)
+		}
+
+		err = res.Close()
+		if err != nil {
+			t.Fatalf("Expected close to succeed but was %v", err)
+		}
+
+		metadata, err := res.Metadata()
+		if err != nil {
+			t.Fatalf("Expected metadata to be available but was %v", err)
+		}
+
+		expected := map[string]interface{}{fmt.Sprintf("field%d", i): "string"}
+		if !reflect.DeepEqual(metadata.Signature(), expected) {
+			t.Fatalf("Expected signature to be parsed as %v but was %v", expected, metadata.Signature())
+		}
+	}
+}
@@ -15,6 +15,8 @@ func (sb *clientStateBlock) Hash() string {
 
 type stateBlock struct {
 	cachedClient client
+	// executeQuery runs a N1QL query through the cluster which the bucket was opened from.
+	executeQuery func(requestSpanContext, string, time.Time, *QueryOptions) (*QueryResult, error)
 
 	clientStateBlock
 
@@ -48,7 +50,11 @@ type stateBlock struct {
 
 	Tracer requestTracer
 
+	ActiveRequests *activeRequestRegistry
+
 	CircuitBreakerConfig CircuitBreakerConfig
+
+	LogRequestBodies bool
 }
 
 func (sb *stateBlock) getCachedClient() client {
@@ -138,6 +138,16 @@ func (mt *MutationState) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// validateScanConsistency checks that a scalar scan consistency and a ConsistentWith mutation state have not both
+// been set on a request, as they are mutually exclusive for every service.
+func validateScanConsistency(scanConsistencySet bool, consistentWith *MutationState) error {
+	if scanConsistencySet && consistentWith != nil {
+		return invalidArgumentsError{message: "ScanConsistency and ConsistentWith must be used exclusively"}
+	}
+
+	return nil
+}
+
 // toSearchMutationState is specific to search, search doesn't accept tokens in the same format as other services.
 func (mt *MutationState) toSearchMutationState() searchMutationState {
 	data := make(searchMutationState)
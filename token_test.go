@@ -101,3 +101,34 @@ func TestMutationState_toSeachMutationState(t *testing.T) {
 		t.Fatalf("Failed to generate correct JSON output %s", bytes)
 	}
 }
+
+func TestValidateScanConsistency(t *testing.T) {
+	if err := validateScanConsistency(false, nil); err != nil {
+		t.Fatalf("Expected no error with neither option set but was %v", err)
+	}
+
+	if err := validateScanConsistency(true, nil); err != nil {
+		t.Fatalf("Expected no error with only scan consistency set but was %v", err)
+	}
+
+	if err := validateScanConsistency(false, NewMutationState()); err != nil {
+		t.Fatalf("Expected no error with only ConsistentWith set but was %v", err)
+	}
+
+	err := validateScanConsistency(true, NewMutationState())
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
+
+func TestSearchOptionsScanConsistencyConflict(t *testing.T) {
+	opts := &SearchOptions{
+		ScanConsistency: SearchScanConsistencyNotBounded,
+		ConsistentWith:  NewMutationState(),
+	}
+
+	_, err := opts.toOptionsData()
+	if !IsInvalidArgumentsError(err) {
+		t.Fatalf("Expected error to be invalid arguments but was %v", err)
+	}
+}
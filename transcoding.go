@@ -1,6 +1,7 @@
 package gocb
 
 import (
+	"bytes"
 	"encoding/json"
 
 	gocbcore "github.com/couchbase/gocbcore/v8"
@@ -423,6 +424,9 @@ func (t *LegacyTranscoder) Encode(value interface{}) ([]byte, uint32, error) {
 
 // DefaultJSONSerializer implements the JSONSerializer interface using json.Marshal/Unmarshal.
 type DefaultJSONSerializer struct {
+	// UseNumber causes numbers to be decoded into an interface{} as a json.Number instead of as a float64,
+	// preserving the precision of large integers.
+	UseNumber bool
 }
 
 // Serialize applies the json.Marshal behaviour to serialize a Go type
@@ -436,8 +440,14 @@ func (s *DefaultJSONSerializer) Serialize(value interface{}) ([]byte, error) {
 }
 
 // Deserialize applies the json.Unmarshal behaviour to deserialize into a Go type
-func (s *DefaultJSONSerializer) Deserialize(bytes []byte, out interface{}) error {
-	err := json.Unmarshal(bytes, &out)
+func (s *DefaultJSONSerializer) Deserialize(data []byte, out interface{}) error {
+	if s.UseNumber {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		return decoder.Decode(&out)
+	}
+
+	err := json.Unmarshal(data, &out)
 	if err != nil {
 		return err
 	}
@@ -885,3 +885,53 @@ func (s *MockSerializer) Deserialize(bytes []byte, out interface{}) error {
 	}
 	return errors.New("MockSerializer expects an out value of []byte")
 }
+
+func TestDefaultJSONSerializerUseNumber(t *testing.T) {
+	data := []byte(`{"id":9007199254740993,"amount":12.5}`)
+
+	var lossy map[string]interface{}
+	err := (&DefaultJSONSerializer{}).Deserialize(data, &lossy)
+	if err != nil {
+		t.Fatalf("Failed to deserialize: %v", err)
+	}
+
+	if _, ok := lossy["id"].(float64); !ok {
+		t.Fatalf("Expected id to be decoded as float64 by default but was %T", lossy["id"])
+	}
+
+	var precise map[string]interface{}
+	err = (&DefaultJSONSerializer{UseNumber: true}).Deserialize(data, &precise)
+	if err != nil {
+		t.Fatalf("Failed to deserialize: %v", err)
+	}
+
+	id, ok := precise["id"].(json.Number)
+	if !ok {
+		t.Fatalf("Expected id to be decoded as json.Number but was %T", precise["id"])
+	}
+
+	idVal, err := id.Int64()
+	if err != nil {
+		t.Fatalf("Failed to convert id to int64: %v", err)
+	}
+
+	if idVal != 9007199254740993 {
+		t.Fatalf("Expected id to be 9007199254740993 but was %d", idVal)
+	}
+
+	if amount, ok := precise["amount"].(json.Number); !ok || amount.String() != "12.5" {
+		t.Fatalf("Expected amount to be json.Number 12.5 but was %v", precise["amount"])
+	}
+
+	var typed struct {
+		ID int64 `json:"id"`
+	}
+	err = (&DefaultJSONSerializer{UseNumber: true}).Deserialize(data, &typed)
+	if err != nil {
+		t.Fatalf("Failed to deserialize: %v", err)
+	}
+
+	if typed.ID != 9007199254740993 {
+		t.Fatalf("Expected typed id to be 9007199254740993 but was %d", typed.ID)
+	}
+}
@@ -1,9 +1,11 @@
 package gocb
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"sync"
 	"testing"
 	"time"
 
@@ -108,8 +110,50 @@ func (trc *testReadCloser) Close() error {
 	return trc.closeErr
 }
 
+// testTrackingReadCloser records whether it has been closed.
+type testTrackingReadCloser struct {
+	io.Reader
+	closed bool
+}
+
+func (trc *testTrackingReadCloser) Close() error {
+	trc.closed = true
+	return nil
+}
+
+// testBlockingReadCloser returns its data and then blocks on any further read until it is closed.
+type testBlockingReadCloser struct {
+	data     io.Reader
+	closedCh chan struct{}
+	once     sync.Once
+}
+
+func newTestBlockingReadCloser(data []byte) *testBlockingReadCloser {
+	return &testBlockingReadCloser{
+		data:     bytes.NewReader(data),
+		closedCh: make(chan struct{}),
+	}
+}
+
+func (trc *testBlockingReadCloser) Read(p []byte) (int, error) {
+	n, err := trc.data.Read(p)
+	if err != io.EOF {
+		return n, err
+	}
+
+	<-trc.closedCh
+	return 0, io.ErrUnexpectedEOF
+}
+
+func (trc *testBlockingReadCloser) Close() error {
+	trc.once.Do(func() {
+		close(trc.closedCh)
+	})
+	return nil
+}
+
 // Not a test, just gets a collection instance.
-func testGetCollection(t *testing.T, provider *mockKvProvider) *Collection {
+func testGetCollection(t *testing.T, provider kvProvider) *Collection {
 	clients := make(map[string]client)
 	cli := &mockClient{
 		bucketName:        "mock",
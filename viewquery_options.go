@@ -61,7 +61,18 @@ type ViewOptions struct {
 	EndKeyDocID     string
 	Namespace       DesignDocumentNamespace
 	Raw             map[string]string
-	// Timeout and context are used to control cancellation of the data stream.
+	// StartKeyIsNull and EndKeyIsNull start or end the range at the JSON null key, as a nil StartKey or EndKey
+	// is treated as unset. They cannot be used alongside a non-nil StartKey or EndKey respectively.
+	StartKeyIsNull bool
+	EndKeyIsNull   bool
+	// KeysPostThreshold is the encoded size, in bytes, of Keys above which the keys are sent in the request body
+	// of a POST rather than as a URL parameter. If not set then defaults to 1024.
+	KeysPostThreshold int
+	// StrictReduce rejects a Limit or Skip on a reduced view which is not grouped. Without grouping the reduce
+	// produces a single row so paginating it is almost certainly a mistake, by default this is only logged.
+	StrictReduce bool
+	// Timeout and context are used to control cancellation of the data stream. Any timeout or deadline will also be
+	// propagated to the server as connection_timeout.
 	Context context.Context
 	Timeout time.Duration
 	OnError ViewErrorMode
@@ -74,6 +85,17 @@ type ViewOptions struct {
 	RetryStrategy RetryStrategy
 }
 
+const defaultViewKeysPostThreshold = 1024
+
+// GroupByKeys sets the options to reduce the view and group the results by each of the given keys. When Keys is
+// used with Reduce and Group the reduction is performed per key, with one row returned for each key.
+func (opts *ViewOptions) GroupByKeys(keys ...interface{}) *ViewOptions {
+	opts.Reduce = true
+	opts.Group = true
+	opts.Keys = keys
+	return opts
+}
+
 func (opts *ViewOptions) toURLValues() (*url.Values, error) {
 	options := &url.Values{}
 
@@ -107,6 +129,17 @@ func (opts *ViewOptions) toURLValues() (*url.Values, error) {
 		}
 	}
 
+	if !opts.Reduce && (opts.Group || opts.GroupLevel != 0) {
+		return nil, invalidArgumentsError{message: "group and group level cannot be used without reduce"}
+	}
+
+	if opts.Reduce && !opts.Group && opts.GroupLevel == 0 && (opts.Limit > 0 || opts.Skip > 0) {
+		if opts.StrictReduce {
+			return nil, invalidArgumentsError{message: "limit and skip cannot be used with an ungrouped reduce"}
+		}
+		logWarnf("Limit or skip used with an ungrouped reduce, this applies to the single reduced row")
+	}
+
 	options.Set("reduce", "false") // is this line necessary?
 	if opts.Reduce {
 		options.Set("reduce", "true")
@@ -122,6 +155,21 @@ func (opts *ViewOptions) toURLValues() (*url.Values, error) {
 		}
 	}
 
+	if opts.StartKeyIsNull && opts.StartKey != nil {
+		return nil, invalidArgumentsError{message: "startkey cannot be used with startkey is null"}
+	}
+
+	if opts.EndKeyIsNull && opts.EndKey != nil {
+		return nil, invalidArgumentsError{message: "endkey cannot be used with endkey is null"}
+	}
+
+	hasStartKey := opts.StartKey != nil || opts.StartKeyIsNull
+	hasEndKey := opts.EndKey != nil || opts.EndKeyIsNull
+
+	if opts.Key != nil && (hasStartKey || hasEndKey) {
+		return nil, invalidArgumentsError{message: "key cannot be used with startkey or endkey"}
+	}
+
 	if opts.Key != nil {
 		jsonKey, err := opts.marshalJson(opts.Key)
 		if err != nil {
@@ -138,7 +186,7 @@ func (opts *ViewOptions) toURLValues() (*url.Values, error) {
 		options.Set("keys", string(jsonKeys))
 	}
 
-	if opts.StartKey != nil {
+	if hasStartKey {
 		jsonStartKey, err := opts.marshalJson(opts.StartKey)
 		if err != nil {
 			return nil, err
@@ -148,7 +196,7 @@ func (opts *ViewOptions) toURLValues() (*url.Values, error) {
 		options.Del("startkey")
 	}
 
-	if opts.EndKey != nil {
+	if hasEndKey {
 		jsonEndKey, err := opts.marshalJson(opts.EndKey)
 		if err != nil {
 			return nil, err
@@ -158,7 +206,7 @@ func (opts *ViewOptions) toURLValues() (*url.Values, error) {
 		options.Del("endkey")
 	}
 
-	if opts.StartKey != nil || opts.EndKey != nil {
+	if hasStartKey || hasEndKey {
 		if opts.InclusiveEnd {
 			options.Set("inclusive_end", "true")
 		} else {
@@ -201,6 +249,30 @@ func (opts *ViewOptions) toURLValues() (*url.Values, error) {
 	return options, nil
 }
 
+// keysBody removes the keys parameter from options and returns it as a JSON request body if its encoded size
+// exceeds the post threshold, otherwise it returns nil and options is left untouched.
+func (opts *ViewOptions) keysBody(options *url.Values) ([]byte, error) {
+	threshold := opts.KeysPostThreshold
+	if threshold <= 0 {
+		threshold = defaultViewKeysPostThreshold
+	}
+
+	keys := options.Get("keys")
+	if len(keys) <= threshold {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(map[string]json.RawMessage{
+		"keys": json.RawMessage(keys),
+	})
+	if err != nil {
+		return nil, err
+	}
+	options.Del("keys")
+
+	return body, nil
+}
+
 func (opts *ViewOptions) marshalJson(value interface{}) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	enc := json.NewEncoder(buf)
@@ -30,6 +30,22 @@ func TestViewQueryOptionsToURLValues(t *testing.T) {
 			}
 		}
 
+		if !opts.Reduce && (opts.Group || opts.GroupLevel != 0) {
+			if err == nil {
+				t.Fatalf("Expected an error for group used without reduce")
+			} else {
+				continue
+			}
+		}
+
+		if opts.Key != nil && (opts.StartKey != nil || opts.EndKey != nil) {
+			if err == nil {
+				t.Fatalf("Expected an error for key used with startkey or endkey")
+			} else {
+				continue
+			}
+		}
+
 		if err != nil {
 			t.Fatalf("Expected no error but was %v", err)
 		}
@@ -98,6 +114,220 @@ func TestViewQueryOptionsToURLValues(t *testing.T) {
 	}
 }
 
+func TestViewQueryOptionsKeyOnly(t *testing.T) {
+	opts := &ViewOptions{
+		Key:          "key1",
+		InclusiveEnd: true,
+	}
+
+	optValues, err := opts.toURLValues()
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	testAssertViewOption(t, "\"key1\"\n", "key", optValues)
+	testAssertViewOption(t, "", "startkey", optValues)
+	testAssertViewOption(t, "", "endkey", optValues)
+	testAssertViewOption(t, "", "inclusive_end", optValues)
+}
+
+func TestViewQueryOptionsRangeOnly(t *testing.T) {
+	opts := &ViewOptions{
+		StartKey:     "keystart",
+		EndKey:       "keyend",
+		InclusiveEnd: true,
+	}
+
+	optValues, err := opts.toURLValues()
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	testAssertViewOption(t, "", "key", optValues)
+	testAssertViewOption(t, "\"keystart\"\n", "startkey", optValues)
+	testAssertViewOption(t, "\"keyend\"\n", "endkey", optValues)
+	testAssertViewOption(t, "true", "inclusive_end", optValues)
+}
+
+func TestViewQueryOptionsKeyAndRange(t *testing.T) {
+	testCases := []*ViewOptions{
+		{Key: "key1", StartKey: "keystart"},
+		{Key: "key1", EndKey: "keyend"},
+		{Key: "key1", StartKey: "keystart", EndKey: "keyend"},
+	}
+
+	for _, opts := range testCases {
+		_, err := opts.toURLValues()
+		if err == nil {
+			t.Fatalf("Expected an error for key used with startkey or endkey")
+		}
+
+		if !IsInvalidArgumentsError(err) {
+			t.Fatalf("Expected error to be invalid arguments but was %v", err)
+		}
+	}
+}
+
+func TestViewQueryOptionsNullRange(t *testing.T) {
+	type tCase struct {
+		name     string
+		opts     *ViewOptions
+		startKey string
+		endKey   string
+	}
+
+	testCases := []tCase{
+		{
+			name:     "unset",
+			opts:     &ViewOptions{},
+			startKey: "",
+			endKey:   "",
+		},
+		{
+			name:     "explicit null",
+			opts:     &ViewOptions{StartKeyIsNull: true, EndKeyIsNull: true},
+			startKey: "null\n",
+			endKey:   "null\n",
+		},
+		{
+			name:     "null start with value end",
+			opts:     &ViewOptions{StartKeyIsNull: true, EndKey: "keyend"},
+			startKey: "null\n",
+			endKey:   "\"keyend\"\n",
+		},
+		{
+			name:     "value",
+			opts:     &ViewOptions{StartKey: "keystart", EndKey: "keyend"},
+			startKey: "\"keystart\"\n",
+			endKey:   "\"keyend\"\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			optValues, err := tc.opts.toURLValues()
+			if err != nil {
+				t.Fatalf("Expected no error but was %v", err)
+			}
+
+			testAssertViewOption(t, tc.startKey, "startkey", optValues)
+			testAssertViewOption(t, tc.endKey, "endkey", optValues)
+
+			if tc.startKey == "" && tc.endKey == "" {
+				testAssertViewOption(t, "", "inclusive_end", optValues)
+			} else {
+				testAssertViewOption(t, "false", "inclusive_end", optValues)
+			}
+		})
+	}
+}
+
+func TestViewQueryOptionsNullRangeInvalid(t *testing.T) {
+	testCases := []*ViewOptions{
+		{StartKeyIsNull: true, StartKey: "keystart"},
+		{EndKeyIsNull: true, EndKey: "keyend"},
+		{Key: "key1", StartKeyIsNull: true},
+		{Key: "key1", EndKeyIsNull: true},
+	}
+
+	for _, opts := range testCases {
+		_, err := opts.toURLValues()
+		if !IsInvalidArgumentsError(err) {
+			t.Fatalf("Expected error to be invalid arguments but was %v", err)
+		}
+	}
+}
+
+func TestViewQueryOptionsGroupByKeys(t *testing.T) {
+	opts := (&ViewOptions{}).GroupByKeys("key1", "key2")
+
+	optValues, err := opts.toURLValues()
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	testAssertViewOption(t, "true", "reduce", optValues)
+	testAssertViewOption(t, "true", "group", optValues)
+	testAssertViewOption(t, "[\"key1\",\"key2\"]\n", "keys", optValues)
+}
+
+func TestViewQueryOptionsGroupWithoutReduce(t *testing.T) {
+	testCases := []*ViewOptions{
+		{Group: true},
+		{GroupLevel: 2},
+		{Group: true, Keys: []interface{}{"key1"}},
+	}
+
+	for _, opts := range testCases {
+		_, err := opts.toURLValues()
+		if err == nil {
+			t.Fatalf("Expected an error for group used without reduce")
+		}
+
+		if !IsInvalidArgumentsError(err) {
+			t.Fatalf("Expected error to be invalid arguments but was %v", err)
+		}
+	}
+}
+
+func TestViewQueryOptionsUngroupedReduceStrict(t *testing.T) {
+	testCases := []*ViewOptions{
+		{Reduce: true, Limit: 10, StrictReduce: true},
+		{Reduce: true, Skip: 5, StrictReduce: true},
+		{Reduce: true, Limit: 10, Skip: 5, Keys: []interface{}{"key1"}, StrictReduce: true},
+	}
+
+	for _, opts := range testCases {
+		_, err := opts.toURLValues()
+		if !IsInvalidArgumentsError(err) {
+			t.Fatalf("Expected error to be invalid arguments for %+v but was %v", opts, err)
+		}
+	}
+
+	valid := []*ViewOptions{
+		{Reduce: true, StrictReduce: true},
+		{Reduce: true, Group: true, Limit: 10, Skip: 5, StrictReduce: true},
+		{Reduce: true, GroupLevel: 1, Limit: 10, StrictReduce: true},
+		{Limit: 10, Skip: 5, StrictReduce: true},
+	}
+
+	for _, opts := range valid {
+		_, err := opts.toURLValues()
+		if err != nil {
+			t.Fatalf("Expected no error for %+v but was %v", opts, err)
+		}
+	}
+}
+
+func TestViewQueryOptionsUngroupedReducePermissive(t *testing.T) {
+	logger := &testCaptureLogger{}
+	defer testSetGlobalLogger(logger)()
+
+	opts := &ViewOptions{Reduce: true, Limit: 10, Skip: 5}
+	optValues, err := opts.toURLValues()
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	testAssertViewOption(t, "true", "reduce", optValues)
+	testAssertViewOption(t, "10", "limit", optValues)
+	testAssertViewOption(t, "5", "skip", optValues)
+
+	if len(logger.logs) != 1 || logger.logs[0].level != LogWarn {
+		t.Fatalf("Expected a warning to be logged but logs were %v", logger.logs)
+	}
+
+	logger.logs = nil
+	_, err = (&ViewOptions{Reduce: true, Group: true, Limit: 10}).toURLValues()
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+
+	if len(logger.logs) != 0 {
+		t.Fatalf("Expected nothing to be logged for a grouped reduce but logs were %v", logger.logs)
+	}
+}
+
 func testAssertViewOption(t *testing.T, expected string, key string, optValues *url.Values) {
 	val := optValues.Get(key)
 	if val != expected {